@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AlarmConfig 模拟量通道的报警设置，未设置的限值不产生对应报警
+type AlarmConfig struct {
+	HiHi       *float64 `json:"hiHi,omitempty"`       // 高高限
+	Hi         *float64 `json:"hi,omitempty"`         // 高限
+	Lo         *float64 `json:"lo,omitempty"`         // 低限
+	LoLo       *float64 `json:"loLo,omitempty"`       // 低低限
+	Deadband   float64  `json:"deadband,omitempty"`   // 死区（工程单位），回到限值内该距离后才恢复
+	OnDelayMs  int      `json:"onDelayMs,omitempty"`  // 越限持续该时长后才产生报警
+	OffDelayMs int      `json:"offDelayMs,omitempty"` // 恢复持续该时长后才解除报警
+	Priority   int      `json:"priority,omitempty"`   // 优先级：1紧急 2高 3中（默认） 4低
+}
+
+// 报警优先级
+const (
+	AlarmPriorityUrgent  = 1
+	AlarmPriorityHigh    = 2
+	AlarmPriorityMedium  = 3
+	AlarmPriorityLow     = 4
+	defaultAlarmPriority = AlarmPriorityMedium
+)
+
+// AlarmLevel 报警限值类型
+type AlarmLevel string
+
+// 报警限值类型
+const (
+	AlarmHiHi AlarmLevel = "HiHi"
+	AlarmHi   AlarmLevel = "Hi"
+	AlarmLo   AlarmLevel = "Lo"
+	AlarmLoLo AlarmLevel = "LoLo"
+)
+
+var alarmLevelLabels = map[AlarmLevel]string{
+	AlarmHiHi: "高高限",
+	AlarmHi:   "高限",
+	AlarmLo:   "低限",
+	AlarmLoLo: "低低限",
+}
+
+// AlarmState 报警状态
+type AlarmState string
+
+// 报警状态
+const (
+	AlarmNormal         AlarmState = "normal"          // 未越限且已确认
+	AlarmActiveUnacked  AlarmState = "active-unacked"  // 越限，未确认
+	AlarmActiveAcked    AlarmState = "active-acked"    // 越限，已确认
+	AlarmClearedUnacked AlarmState = "cleared-unacked" // 已恢复，未确认
+)
+
+// priority 返回优先级，未设置时为默认值
+func (c *AlarmConfig) priority() int {
+	if c.Priority == 0 {
+		return defaultAlarmPriority
+	}
+	return c.Priority
+}
+
+// alarmLimit 一个已设置的限值
+type alarmLimit struct {
+	level AlarmLevel
+	limit float64
+}
+
+// limits 返回已设置的限值，按高高、高、低、低低排列
+func (c *AlarmConfig) limits() []alarmLimit {
+	var limits []alarmLimit
+	add := func(level AlarmLevel, limit *float64) {
+		if limit != nil {
+			limits = append(limits, alarmLimit{level, *limit})
+		}
+	}
+	add(AlarmHiHi, c.HiHi)
+	add(AlarmHi, c.Hi)
+	add(AlarmLo, c.Lo)
+	add(AlarmLoLo, c.LoLo)
+	return limits
+}
+
+// validate 检查限值顺序、死区、延时和优先级
+func (c *AlarmConfig) validate() error {
+	limits := c.limits()
+	for i := 1; i < len(limits); i++ {
+		if limits[i].limit > limits[i-1].limit {
+			return fmt.Errorf("alarm limit %s %g is above %s %g", limits[i].level, limits[i].limit, limits[i-1].level, limits[i-1].limit)
+		}
+	}
+	if c.Deadband < 0 {
+		return fmt.Errorf("alarm deadband %g is negative", c.Deadband)
+	}
+	if c.OnDelayMs < 0 || c.OffDelayMs < 0 {
+		return fmt.Errorf("alarm delays must not be negative")
+	}
+	if c.Priority < 0 || c.Priority > AlarmPriorityLow {
+		return fmt.Errorf("alarm priority %d out of range 1-%d", c.Priority, AlarmPriorityLow)
+	}
+	return nil
+}
+
+// alarm 单个通道单个限值的报警
+type alarm struct {
+	id      string
+	channel *AnalogChannel
+	level   AlarmLevel
+	limit   float64
+	config  *AlarmConfig
+
+	active       bool
+	acked        bool
+	value        float64   // 最近一次参与判断的工程值
+	pendingSince time.Time // 越限（未报警时）或恢复（报警时）条件开始满足的时间
+	activatedAt  time.Time
+	clearedAt    time.Time
+	ackedAt      time.Time
+	shelvedUntil time.Time
+}
+
+// high 是否为高限类报警
+func (a *alarm) high() bool {
+	return a.level == AlarmHiHi || a.level == AlarmHi
+}
+
+// state 返回报警状态
+func (a *alarm) state() AlarmState {
+	switch {
+	case a.active && !a.acked:
+		return AlarmActiveUnacked
+	case a.active:
+		return AlarmActiveAcked
+	case !a.acked:
+		return AlarmClearedUnacked
+	default:
+		return AlarmNormal
+	}
+}
+
+// message 返回报警描述，如 温度 高限报警
+func (a *alarm) message() string {
+	return a.channel.DisplayName() + " " + alarmLevelLabels[a.level] + "报警"
+}
+
+// AlarmStatus 报警的当前状态
+type AlarmStatus struct {
+	ID           string     `json:"id"` // 通道名.限值类型，如 Temperature.Hi
+	Channel      string     `json:"channel"`
+	Level        AlarmLevel `json:"level"`
+	Limit        float64    `json:"limit"`
+	Unit         string     `json:"unit,omitempty"`
+	Priority     int        `json:"priority"`
+	State        AlarmState `json:"state"`
+	Message      string     `json:"message"`
+	Value        float64    `json:"value"`                 // 最近一次参与判断的工程值
+	ActivatedAt  time.Time  `json:"activatedAt,omitzero"`  // 最近一次产生时间
+	ClearedAt    time.Time  `json:"clearedAt,omitzero"`    // 最近一次解除时间
+	AckedAt      time.Time  `json:"ackedAt,omitzero"`      // 最近一次确认时间
+	ShelvedUntil time.Time  `json:"shelvedUntil,omitzero"` // 搁置到期时间，搁置期间在报警横幅中淡化显示
+}
+
+// AlarmEngine 按模拟量读数判断越限报警，带死区、延时、确认与搁置
+type AlarmEngine struct {
+	station string
+
+	mu     sync.Mutex
+	alarms []*alarm
+	byID   map[string]*alarm
+}
+
+// 搁置时长上限
+const maxAlarmShelve = 24 * time.Hour
+
+// NewAlarmEngine 按通道的报警设置创建报警引擎
+func NewAlarmEngine(station string, channels []AnalogChannel) *AlarmEngine {
+	engine := &AlarmEngine{station: station, byID: make(map[string]*alarm)}
+	for i := range channels {
+		ch := &channels[i]
+		if ch.Alarm == nil {
+			continue
+		}
+		for _, l := range ch.Alarm.limits() {
+			a := &alarm{
+				id:      ch.Name + "." + string(l.level),
+				channel: ch,
+				level:   l.level,
+				limit:   l.limit,
+				config:  ch.Alarm,
+				acked:   true,
+			}
+			engine.alarms = append(engine.alarms, a)
+			engine.byID[a.id] = a
+		}
+	}
+	return engine
+}
+
+// Evaluate 按读数更新报警状态
+// 质量为bad或stale的读数不参与判断，报警保持原状态
+func (e *AlarmEngine) Evaluate(readings []AnalogReading, now time.Time) {
+	values := make(map[string]AnalogReading, len(readings))
+	for _, reading := range readings {
+		values[reading.Name] = reading
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, a := range e.alarms {
+		reading, ok := values[a.channel.Name]
+		if !ok || (reading.Quality != QualityGood && reading.Quality != QualityUncertain) {
+			a.pendingSince = time.Time{}
+			continue
+		}
+		a.value = reading.Value
+
+		var exceeded, recovered bool
+		if a.high() {
+			exceeded = reading.Value >= a.limit
+			recovered = reading.Value < a.limit-a.config.Deadband
+		} else {
+			exceeded = reading.Value <= a.limit
+			recovered = reading.Value > a.limit+a.config.Deadband
+		}
+
+		// 报警前等待越限持续onDelay，报警后等待恢复持续offDelay
+		condition, delay := exceeded, a.config.OnDelayMs
+		if a.active {
+			condition, delay = recovered, a.config.OffDelayMs
+		}
+		if !condition {
+			a.pendingSince = time.Time{}
+			continue
+		}
+		if a.pendingSince.IsZero() {
+			a.pendingSince = now
+		}
+		if now.Sub(a.pendingSince) < time.Duration(delay)*time.Millisecond {
+			continue
+		}
+
+		a.pendingSince = time.Time{}
+		if a.active {
+			a.active = false
+			a.clearedAt = now
+			log.Printf("[%s] 报警解除: %s，当前值 %s", e.station, a.message(), a.channel.Format(reading.Value))
+		} else {
+			a.active = true
+			a.activatedAt = now
+			if now.Before(a.shelvedUntil) {
+				// 搁置期间再次报警保持已确认，不重新提示
+				log.Printf("[%s] 报警产生（已搁置）: %s，当前值 %s，限值 %s", e.station, a.message(), a.channel.Format(reading.Value), a.channel.Format(a.limit))
+				continue
+			}
+			a.acked = false
+			log.Printf("[%s] 报警产生: %s，当前值 %s，限值 %s", e.station, a.message(), a.channel.Format(reading.Value), a.channel.Format(a.limit))
+		}
+	}
+}
+
+// Alarms 返回非正常状态或搁置中的报警，按优先级、产生时间（新的在前）排序
+func (e *AlarmEngine) Alarms() []AlarmStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]AlarmStatus, 0)
+	for _, a := range e.alarms {
+		shelved := now.Before(a.shelvedUntil)
+		if a.state() == AlarmNormal && !shelved {
+			continue
+		}
+		status := AlarmStatus{
+			ID:          a.id,
+			Channel:     a.channel.Name,
+			Level:       a.level,
+			Limit:       a.limit,
+			Unit:        a.channel.Unit,
+			Priority:    a.config.priority(),
+			State:       a.state(),
+			Message:     a.message(),
+			Value:       a.value,
+			ActivatedAt: a.activatedAt,
+			ClearedAt:   a.clearedAt,
+			AckedAt:     a.ackedAt,
+		}
+		if shelved {
+			status.ShelvedUntil = a.shelvedUntil
+		}
+		statuses = append(statuses, status)
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		if statuses[i].Priority != statuses[j].Priority {
+			return statuses[i].Priority < statuses[j].Priority
+		}
+		return statuses[i].ActivatedAt.After(statuses[j].ActivatedAt)
+	})
+	return statuses
+}
+
+// Acknowledge 确认报警，id为空时确认全部报警，返回本次确认的数量
+func (e *AlarmEngine) Acknowledge(id string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alarms := e.alarms
+	if id != "" {
+		a, ok := e.byID[id]
+		if !ok {
+			return 0, fmt.Errorf("unknown alarm %q", id)
+		}
+		alarms = []*alarm{a}
+	}
+
+	count := 0
+	now := time.Now()
+	for _, a := range alarms {
+		if a.acked {
+			continue
+		}
+		a.acked = true
+		a.ackedAt = now
+		count++
+		log.Printf("[%s] 报警已确认: %s", e.station, a.message())
+	}
+	return count, nil
+}
+
+// Shelve 搁置报警：搁置期间视为已确认并在报警横幅中淡化显示，duration不大于0时取消搁置
+func (e *AlarmEngine) Shelve(id string, duration time.Duration) error {
+	if duration > maxAlarmShelve {
+		return fmt.Errorf("shelve duration %s exceeds maximum %s", duration, maxAlarmShelve)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	a, ok := e.byID[id]
+	if !ok {
+		return fmt.Errorf("unknown alarm %q", id)
+	}
+	if duration <= 0 {
+		a.shelvedUntil = time.Time{}
+		log.Printf("[%s] 取消搁置报警: %s", e.station, a.message())
+		return nil
+	}
+
+	now := time.Now()
+	a.shelvedUntil = now.Add(duration)
+	if !a.acked {
+		a.acked = true
+		a.ackedAt = now
+	}
+	log.Printf("[%s] 搁置报警 %s: %s", e.station, duration, a.message())
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestAlarmEngine 为单个温度通道创建报警引擎
+func newTestAlarmEngine(t *testing.T, config AlarmConfig) *AlarmEngine {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return NewAlarmEngine("test", []AnalogChannel{{Name: "Temperature", Label: "温度", EngMin: -40, EngMax: 80, Unit: "℃", Alarm: &config}})
+}
+
+// temperature 返回温度通道的读数
+func temperature(value float64, quality Quality) []AnalogReading {
+	return []AnalogReading{{Name: "Temperature", Value: value, Quality: quality}}
+}
+
+// alarmState 返回报警的当前状态，不在列表中时为normal
+func alarmState(engine *AlarmEngine, id string) AlarmState {
+	for _, status := range engine.Alarms() {
+		if status.ID == id {
+			return status.State
+		}
+	}
+	return AlarmNormal
+}
+
+// alarmStep 一次读数及之后期望的报警状态
+type alarmStep struct {
+	at      time.Duration
+	value   float64
+	quality Quality
+	want    AlarmState
+}
+
+// runAlarmSteps 依次送入读数并检查状态
+func runAlarmSteps(t *testing.T, engine *AlarmEngine, id string, steps []alarmStep) {
+	t.Helper()
+	start := time.Now()
+	for i, step := range steps {
+		quality := step.quality
+		if quality == "" {
+			quality = QualityGood
+		}
+		engine.Evaluate(temperature(step.value, quality), start.Add(step.at))
+		if got := alarmState(engine, id); got != step.want {
+			t.Fatalf("step %d (%v, %g %s): state %s, want %s", i, step.at, step.value, quality, got, step.want)
+		}
+	}
+}
+
+// ptr 返回v的指针，用于设置可选限值
+func ptr[T any](v T) *T { return &v }
+
+func TestAlarmDeadband(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		steps []alarmStep
+	}{
+		{"high", "Temperature.Hi", []alarmStep{
+			{0, 49.9, "", AlarmNormal},
+			{1, 50, "", AlarmActiveUnacked}, // 达到限值即越限
+			{2, 49, "", AlarmActiveUnacked}, // 仍在死区内
+			{3, 48, "", AlarmActiveUnacked}, // 恰好在死区边界
+			{4, 47.9, "", AlarmClearedUnacked},
+			{5, 49, "", AlarmClearedUnacked}, // 恢复后低于限值不再产生
+			{6, 50.5, "", AlarmActiveUnacked},
+		}},
+		{"low", "Temperature.Lo", []alarmStep{
+			{0, 0.1, "", AlarmNormal},
+			{1, 0, "", AlarmActiveUnacked},
+			{2, 2, "", AlarmActiveUnacked},
+			{3, 2.1, "", AlarmClearedUnacked},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestAlarmEngine(t, AlarmConfig{Hi: ptr(50.0), Lo: ptr(0.0), Deadband: 2})
+			runAlarmSteps(t, engine, tt.id, tt.steps)
+		})
+	}
+}
+
+func TestAlarmDelays(t *testing.T) {
+	engine := newTestAlarmEngine(t, AlarmConfig{Hi: ptr(50.0), OnDelayMs: 1000, OffDelayMs: 500})
+	ms := time.Millisecond
+	runAlarmSteps(t, engine, "Temperature.Hi", []alarmStep{
+		{0, 55, "", AlarmNormal},
+		{999 * ms, 55, "", AlarmNormal},
+		{1100 * ms, 45, "", AlarmNormal}, // 越限中断，重新计时
+		{1200 * ms, 55, "", AlarmNormal},
+		{2100 * ms, 55, "", AlarmNormal},
+		{2200 * ms, 55, "", AlarmActiveUnacked},
+		{2300 * ms, 45, "", AlarmActiveUnacked},
+		{2600 * ms, 55, "", AlarmActiveUnacked}, // 恢复中断，重新计时
+		{2700 * ms, 45, "", AlarmActiveUnacked},
+		{3199 * ms, 45, "", AlarmActiveUnacked},
+		{3200 * ms, 45, "", AlarmClearedUnacked},
+	})
+}
+
+func TestAlarmBadQualityHoldsState(t *testing.T) {
+	engine := newTestAlarmEngine(t, AlarmConfig{Hi: ptr(50.0), Lo: ptr(0.0), OnDelayMs: 1000})
+	ms := time.Millisecond
+
+	// 坏值的工程值为0，不应触发低限报警，也不应延续高限的越限计时
+	runAlarmSteps(t, engine, "Temperature.Lo", []alarmStep{
+		{0, 0, QualityBad, AlarmNormal},
+		{2000 * ms, 0, QualityBad, AlarmNormal},
+	})
+	runAlarmSteps(t, engine, "Temperature.Hi", []alarmStep{
+		{0, 55, "", AlarmNormal},
+		{500 * ms, 55, QualityStale, AlarmNormal},
+		{1000 * ms, 55, "", AlarmNormal},
+		{1999 * ms, 55, "", AlarmNormal},
+		{2000 * ms, 55, QualityUncertain, AlarmActiveUnacked}, // 不确定的读数参与判断
+		{3000 * ms, 20, QualityBad, AlarmActiveUnacked},       // 坏值不解除报警
+		{4000 * ms, 20, QualityStale, AlarmActiveUnacked},
+		{5000 * ms, 20, "", AlarmClearedUnacked},
+	})
+
+	// 没有该通道读数时同样保持原状态
+	engine.Evaluate(nil, time.Now())
+	if got := alarmState(engine, "Temperature.Hi"); got != AlarmClearedUnacked {
+		t.Fatalf("state after missing reading %s", got)
+	}
+}
+
+func TestAlarmAcknowledge(t *testing.T) {
+	engine := newTestAlarmEngine(t, AlarmConfig{HiHi: ptr(80.0), Hi: ptr(50.0), Priority: AlarmPriorityHigh})
+	now := time.Now()
+	engine.Evaluate(temperature(90, QualityGood), now)
+
+	alarms := engine.Alarms()
+	if len(alarms) != 2 || alarms[0].Priority != AlarmPriorityHigh || alarms[0].Message != "温度 高高限报警" || alarms[0].Value != 90 {
+		t.Fatalf("alarms = %+v", alarms)
+	}
+
+	if count, err := engine.Acknowledge("Temperature.HiHi"); err != nil || count != 1 {
+		t.Fatalf("acknowledge HiHi: %d, %v", count, err)
+	}
+	if got := alarmState(engine, "Temperature.HiHi"); got != AlarmActiveAcked {
+		t.Fatalf("HiHi state %s, want %s", got, AlarmActiveAcked)
+	}
+	if count, _ := engine.Acknowledge("Temperature.HiHi"); count != 0 {
+		t.Fatalf("acknowledged HiHi twice")
+	}
+	if _, err := engine.Acknowledge("Temperature.Lo"); err == nil {
+		t.Fatal("acknowledged unknown alarm")
+	}
+
+	// 已确认的报警恢复后回到正常，不再出现在列表中
+	engine.Evaluate(temperature(60, QualityGood), now.Add(time.Second))
+	if got := alarmState(engine, "Temperature.HiHi"); got != AlarmNormal {
+		t.Fatalf("HiHi state after recovery %s", got)
+	}
+
+	// 未确认的报警恢复后等待确认，id为空时确认全部
+	engine.Evaluate(temperature(20, QualityGood), now.Add(2*time.Second))
+	if got := alarmState(engine, "Temperature.Hi"); got != AlarmClearedUnacked {
+		t.Fatalf("Hi state after recovery %s", got)
+	}
+	if count, err := engine.Acknowledge(""); err != nil || count != 1 {
+		t.Fatalf("acknowledge all: %d, %v", count, err)
+	}
+	if alarms := engine.Alarms(); len(alarms) != 0 {
+		t.Fatalf("alarms after acknowledge %+v", alarms)
+	}
+}
+
+func TestAlarmShelve(t *testing.T) {
+	engine := newTestAlarmEngine(t, AlarmConfig{Hi: ptr(50.0)})
+	engine.Evaluate(temperature(55, QualityGood), time.Now())
+
+	if err := engine.Shelve("Temperature.Hi", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	alarms := engine.Alarms()
+	if len(alarms) != 1 || alarms[0].State != AlarmActiveAcked || alarms[0].ShelvedUntil.IsZero() {
+		t.Fatalf("shelved alarm %+v", alarms)
+	}
+
+	// 搁置中的报警恢复后仍显示在列表中
+	engine.Evaluate(temperature(20, QualityGood), time.Now())
+	if alarms := engine.Alarms(); len(alarms) != 1 || alarms[0].State != AlarmNormal {
+		t.Fatalf("shelved cleared alarm %+v", alarms)
+	}
+
+	// 搁置期间再次报警保持已确认
+	engine.Evaluate(temperature(55, QualityGood), time.Now())
+	if got := alarmState(engine, "Temperature.Hi"); got != AlarmActiveAcked {
+		t.Fatalf("shelved alarm re-tripped as %s, want %s", got, AlarmActiveAcked)
+	}
+	engine.Evaluate(temperature(20, QualityGood), time.Now())
+
+	if err := engine.Shelve("Temperature.Hi", 0); err != nil {
+		t.Fatal(err)
+	}
+	if alarms := engine.Alarms(); len(alarms) != 0 {
+		t.Fatalf("alarms after unshelve %+v", alarms)
+	}
+
+	// 搁置到期后再次报警需要重新确认
+	if err := engine.Shelve("Temperature.Hi", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	engine.Evaluate(temperature(55, QualityGood), time.Now().Add(2*time.Hour))
+	if got := alarmState(engine, "Temperature.Hi"); got != AlarmActiveUnacked {
+		t.Fatalf("alarm after shelve expired %s, want %s", got, AlarmActiveUnacked)
+	}
+
+	if err := engine.Shelve("Temperature.Hi", 25*time.Hour); err == nil {
+		t.Error("shelve beyond maximum accepted")
+	}
+	if err := engine.Shelve("Temperature.Lo", time.Hour); err == nil {
+		t.Error("shelved unknown alarm")
+	}
+}
+
+func TestAlarmConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AlarmConfig
+		valid  bool
+	}{
+		{"ordered", AlarmConfig{HiHi: ptr(80.0), Hi: ptr(50.0), Lo: ptr(0.0), LoLo: ptr(-10.0)}, true},
+		{"equal limits", AlarmConfig{Hi: ptr(50.0), Lo: ptr(50.0)}, true},
+		{"hi above hihi", AlarmConfig{HiHi: ptr(50.0), Hi: ptr(60.0)}, false},
+		{"lo above hi", AlarmConfig{Hi: ptr(50.0), Lo: ptr(60.0)}, false},
+		{"negative deadband", AlarmConfig{Hi: ptr(50.0), Deadband: -1}, false},
+		{"negative delay", AlarmConfig{Hi: ptr(50.0), OffDelayMs: -1}, false},
+		{"priority", AlarmConfig{Hi: ptr(50.0), Priority: 5}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// S7模拟量原始值
+const (
+	s7AnalogNominal   = 27648  // 额定范围上限（单极性0~27648，双极性±27648）
+	s7AnalogOverflow  = 32767  // 上溢，电流/电压型输入断线时也会出现
+	s7AnalogUnderflow = -32768 // 下溢
+)
+
+// 原始值范围
+const (
+	AnalogUnipolar = "unipolar" // 0 ~ 27648
+	AnalogBipolar  = "bipolar"  // -27648 ~ 27648
+)
+
+// AnalogChannel 模拟量通道：一个输入寄存器及其量程换算与显示方式
+type AnalogChannel struct {
+	Name     string       `json:"name"`               // 通道标识，同时作为标签名
+	Label    string       `json:"label,omitempty"`    // 显示名称，默认与name相同
+	Alias    string       `json:"alias,omitempty"`    // S7地址，如 IW64；为空时使用register
+	Register int          `json:"register,omitempty"` // 输入寄存器短地址（IWn对应n/2）
+	Range    string       `json:"range,omitempty"`    // 原始值范围：unipolar（默认）/bipolar，rawMin/rawMax均为0时生效
+	RawMin   float64      `json:"rawMin,omitempty"`   // 自定义原始值下限
+	RawMax   float64      `json:"rawMax,omitempty"`   // 自定义原始值上限
+	EngMin   float64      `json:"engMin"`             // 工程值下限
+	EngMax   float64      `json:"engMax"`             // 工程值上限
+	Unit     string       `json:"unit,omitempty"`     // 工程单位
+	Decimals *int         `json:"decimals,omitempty"` // 显示小数位数，默认1
+	Alarm    *AlarmConfig `json:"alarm,omitempty"`    // 越限报警设置
+}
+
+// DefaultAnalogChannels 返回默认模拟量通道：IW64温度、IW66湿度
+func DefaultAnalogChannels() []AnalogChannel {
+	return []AnalogChannel{
+		// 温度（℃） = (值 / 27648) × 120 − 40
+		{Name: "Temperature", Label: "温度", Alias: "IW64", EngMin: -40, EngMax: 80, Unit: "℃"},
+		// 湿度（%） = (值 / 27648) × 100
+		{Name: "Humidity", Label: "湿度", Alias: "IW66", EngMin: 0, EngMax: 100, Unit: "%"},
+	}
+}
+
+// DisplayName 返回显示名称
+func (ch *AnalogChannel) DisplayName() string {
+	if ch.Label == "" {
+		return ch.Name
+	}
+	return ch.Label
+}
+
+// Precision 返回显示小数位数
+func (ch *AnalogChannel) Precision() int {
+	if ch.Decimals == nil {
+		return 1
+	}
+	return *ch.Decimals
+}
+
+// RawRange 返回原始值范围
+func (ch *AnalogChannel) RawRange() (float64, float64) {
+	if ch.RawMin != 0 || ch.RawMax != 0 {
+		return ch.RawMin, ch.RawMax
+	}
+	if ch.Range == AnalogBipolar {
+		return -s7AnalogNominal, s7AnalogNominal
+	}
+	return 0, s7AnalogNominal
+}
+
+// Tag 返回通道对应的标签（INT类型，带量程换算）
+func (ch *AnalogChannel) Tag() Tag {
+	rawMin, rawMax := ch.RawRange()
+	tag := Tag{
+		Name:     ch.Name,
+		Alias:    ch.Alias,
+		DataType: TypeInt16,
+		Scaling:  &TagScaling{RawMin: rawMin, RawMax: rawMax, EngMin: ch.EngMin, EngMax: ch.EngMax},
+		Unit:     ch.Unit,
+		Comment:  ch.Label,
+	}
+	if ch.Alias == "" {
+		tag.Area = AreaInputRegister
+		tag.Offset = ch.Register
+	}
+	return tag
+}
+
+// Format 按通道小数位数和单位格式化工程值
+func (ch *AnalogChannel) Format(value float64) string {
+	return strconv.FormatFloat(value, 'f', ch.Precision(), 64) + ch.Unit
+}
+
+// validateAnalogChannels 检查通道名称与量程，以及标签库中通道标签的数据类型
+// 读数质量按S7 INT原始值判断，自定义标签覆盖通道标签时也必须是int16
+func validateAnalogChannels(channels []AnalogChannel, db *TagDB) error {
+	names := make(map[string]bool)
+	for i, ch := range channels {
+		if ch.Name == "" {
+			return fmt.Errorf("analog channel %d: missing name", i+1)
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("analog channel %q: duplicate name", ch.Name)
+		}
+		names[ch.Name] = true
+
+		if ch.Range != "" && ch.Range != AnalogUnipolar && ch.Range != AnalogBipolar {
+			return fmt.Errorf("analog channel %q: unknown range %q", ch.Name, ch.Range)
+		}
+		if rawMin, rawMax := ch.RawRange(); rawMin >= rawMax {
+			return fmt.Errorf("analog channel %q: raw range %g..%g is empty", ch.Name, rawMin, rawMax)
+		}
+		if ch.EngMin == ch.EngMax {
+			return fmt.Errorf("analog channel %q: engineering range %g..%g is empty", ch.Name, ch.EngMin, ch.EngMax)
+		}
+		if tag, err := db.Lookup(ch.Name); err == nil && tag.DataType != TypeInt16 {
+			return fmt.Errorf("analog channel %q: tag data type %s, want %s", ch.Name, tag.DataType, TypeInt16)
+		}
+		if ch.Decimals != nil && (*ch.Decimals < 0 || *ch.Decimals > 6) {
+			return fmt.Errorf("analog channel %q: decimals %d out of range 0-6", ch.Name, *ch.Decimals)
+		}
+		if ch.Alarm != nil {
+			if err := ch.Alarm.validate(); err != nil {
+				return fmt.Errorf("analog channel %q: %w", ch.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Quality 模拟量读数质量
+type Quality string
+
+// 读数质量
+const (
+	QualityGood      Quality = "good"      // 正常
+	QualityUncertain Quality = "uncertain" // 超出量程，工程值仅供参考
+	QualityBad       Quality = "bad"       // 上溢/断线、下溢或无法读取，工程值无效
+	QualityStale     Quality = "stale"     // 连接断开或长时间未更新，保留最后读数
+)
+
+// qualityLabels 读数质量的显示名称
+var qualityLabels = map[Quality]string{
+	QualityGood:      "正常",
+	QualityUncertain: "不确定",
+	QualityBad:       "坏值",
+	QualityStale:     "过期",
+}
+
+// Label 返回质量的显示名称
+func (q Quality) Label() string {
+	if label, ok := qualityLabels[q]; ok {
+		return label
+	}
+	return string(q)
+}
+
+// AnalogReading 模拟量通道的一次读数
+type AnalogReading struct {
+	Name      string    `json:"name"`
+	Label     string    `json:"label"`
+	Unit      string    `json:"unit,omitempty"`
+	Raw       int16     `json:"raw"`                // 原始值
+	Value     float64   `json:"value"`              // 工程值，质量为bad时为0
+	Text      string    `json:"text"`               // 显示文本
+	Quality   Quality   `json:"quality"`            // 质量
+	Reason    string    `json:"reason,omitempty"`   // 质量非good的原因
+	Timestamp time.Time `json:"timestamp,omitzero"` // 采样时间，尚无读数时为零值
+}
+
+// newAnalogReading 由原始值生成读数并判断质量，上溢/下溢时不换算工程值
+func newAnalogReading(ch *AnalogChannel, scaling *TagScaling, raw int16, now time.Time) AnalogReading {
+	reading := AnalogReading{Name: ch.Name, Label: ch.DisplayName(), Unit: ch.Unit, Raw: raw, Timestamp: now}
+	rawMin, rawMax := ch.RawRange()
+	switch {
+	case raw == s7AnalogOverflow:
+		reading.Quality, reading.Reason, reading.Text = QualityBad, "上溢或断线（原始值32767）", "上溢"
+	case raw == s7AnalogUnderflow:
+		reading.Quality, reading.Reason, reading.Text = QualityBad, "下溢（原始值-32768）", "下溢"
+	default:
+		reading.Value = scaling.ToEngineering(float64(raw))
+		reading.Text = ch.Format(reading.Value)
+		reading.Quality = QualityGood
+		if float64(raw) < rawMin || float64(raw) > rawMax {
+			reading.Quality = QualityUncertain
+			reading.Reason = fmt.Sprintf("原始值%d超出量程%g~%g", raw, rawMin, rawMax)
+		}
+	}
+	return reading
+}
+
+// emptyAnalogReading 返回尚无读数的通道
+func emptyAnalogReading(ch *AnalogChannel, reason string) AnalogReading {
+	return AnalogReading{Name: ch.Name, Label: ch.DisplayName(), Unit: ch.Unit, Text: "--", Quality: QualityBad, Reason: reason}
+}
+
+// stale 将读数标记为过期，保留最后的工程值与采样时间
+// 尚无读数时为bad；最后读数已是bad时保留原因
+func (r AnalogReading) stale(reason string) AnalogReading {
+	switch {
+	case r.Timestamp.IsZero():
+		r.Quality, r.Reason = QualityBad, reason
+	case r.Quality != QualityBad:
+		r.Quality, r.Reason = QualityStale, reason
+	}
+	return r
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// temperatureChannel 默认的温度通道：单极性0~27648对应-40~80℃
+func temperatureChannel() AnalogChannel {
+	return DefaultAnalogChannels()[0]
+}
+
+// u16 返回INT原始值对应的寄存器值
+func u16(raw int16) uint16 {
+	return uint16(raw)
+}
+
+func TestAnalogReadingQuality(t *testing.T) {
+	ch := temperatureChannel()
+	tag := ch.Tag()
+	now := time.Now()
+	tests := []struct {
+		name    string
+		raw     int16
+		quality Quality
+		value   float64
+		text    string
+	}{
+		{"lower limit", 0, QualityGood, -40, "-40.0℃"},
+		{"mid range", 13824, QualityGood, 20, "20.0℃"},
+		{"upper limit", 27648, QualityGood, 80, "80.0℃"},
+		{"above range", 27649, QualityUncertain, 80.004, "80.0℃"},
+		{"below range", -1, QualityUncertain, -40.004, "-40.0℃"},
+		{"overshoot", 32511, QualityUncertain, 101.107, "101.1℃"},
+		{"overflow", 32767, QualityBad, 0, "上溢"},
+		{"underflow", -32768, QualityBad, 0, "下溢"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := newAnalogReading(&ch, tag.Scaling, tt.raw, now)
+			if reading.Quality != tt.quality || reading.Text != tt.text || reading.Raw != tt.raw || !reading.Timestamp.Equal(now) {
+				t.Fatalf("reading %+v, want %s %q", reading, tt.quality, tt.text)
+			}
+			if diff := reading.Value - tt.value; diff > 0.001 || diff < -0.001 {
+				t.Errorf("value %g, want %g", reading.Value, tt.value)
+			}
+			if (reading.Quality == QualityGood) != (reading.Reason == "") {
+				t.Errorf("quality %s with reason %q", reading.Quality, reading.Reason)
+			}
+		})
+	}
+}
+
+func TestAnalogReadingStale(t *testing.T) {
+	channels := []AnalogChannel{temperatureChannel(), {Name: "Level", Alias: "IW66", EngMin: 0, EngMax: 100}}
+	em := NewEnvironmentMonitor(nil, channels, time.Second)
+	now := time.Now()
+
+	// 尚无读数时为bad
+	if readings := em.readingsLocked(now); readings[0].Quality != QualityBad || readings[0].Text != "--" {
+		t.Fatalf("initial reading %+v", readings[0])
+	}
+
+	em.last[0] = newAnalogReading(&channels[0], channels[0].Tag().Scaling, 13824, now)
+	em.last[1] = newAnalogReading(&channels[1], channels[1].Tag().Scaling, 32767, now)
+	if readings := em.readingsLocked(now.Add(time.Second)); readings[0].Quality != QualityGood {
+		t.Fatalf("reading stale at staleAfter: %+v", readings[0])
+	}
+
+	// 超过staleAfter未更新时标记为过期，保留最后的工程值和采样时间；坏值保持为bad
+	readings := em.readingsLocked(now.Add(time.Second + time.Millisecond))
+	if r := readings[0]; r.Quality != QualityStale || r.Value != 20 || !r.Timestamp.Equal(now) || !strings.Contains(r.Reason, "未更新") {
+		t.Fatalf("expired reading %+v", r)
+	}
+	if r := readings[1]; r.Quality != QualityBad || r.Text != "上溢" {
+		t.Fatalf("expired bad reading %+v", r)
+	}
+
+	// 连接断开时全部标记为过期
+	stale := em.StaleReadings("连接断开")
+	if stale[0].Quality != QualityStale || stale[0].Reason != "连接断开" || stale[1].Quality != QualityBad {
+		t.Fatalf("stale readings %+v", stale)
+	}
+}
+
+func TestEnvironmentReadChannels(t *testing.T) {
+	sim, client := startTestSimulator(t, 8)
+	channels := []AnalogChannel{temperatureChannel(), {Name: "Level", Alias: "IW66", EngMin: 0, EngMax: 100}}
+	config := DefaultConfig()
+	config.AnalogChannels = channels
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	em := NewEnvironmentMonitor(NewTagClient(client, db), channels, time.Minute)
+
+	sim.SetInputRegister(32, u16(-32768))
+	sim.SetInputRegister(33, u16(13824))
+	readings, err := em.ReadChannels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readings[0].Quality != QualityBad || readings[0].Raw != -32768 || readings[1].Quality != QualityGood || readings[1].Value != 50 {
+		t.Fatalf("readings %+v", readings)
+	}
+
+	// 读取失败时保留最后读数并标记为过期
+	client.Close()
+	readings, err = em.ReadChannels(context.Background())
+	if err == nil {
+		t.Fatal("read succeeded without connection")
+	}
+	if r := readings[1]; r.Quality != QualityStale || r.Value != 50 || !strings.Contains(r.Reason, "读取失败") {
+		t.Fatalf("reading after failure %+v", r)
+	}
+}
+
+func TestAnalogChannelTagOverride(t *testing.T) {
+	tests := []struct {
+		name  string
+		tag   Tag
+		valid bool
+	}{
+		{"int with scaling", Tag{Name: "Temperature", Alias: "IW70"}, true},
+		{"real", Tag{Name: "Temperature", Alias: "ID64", DataType: TypeFloat32}, false},
+		{"dint from alias", Tag{Name: "Temperature", Alias: "ID64"}, false},
+		{"word", Tag{Name: "Temperature", Alias: "IW64", DataType: TypeUint16}, false},
+		{"bit", Tag{Name: "Temperature", Alias: "I0.0"}, false},
+		{"other tag", Tag{Name: "Flow", Alias: "ID68", DataType: TypeFloat32}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Tags = []Tag{tt.tag}
+			db, err := NewTagDB(config.TagTable())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateAnalogChannels(config.AnalogChannelList(), db)
+			if tt.valid && err != nil {
+				t.Fatalf("valid override rejected: %v", err)
+			}
+			if !tt.valid && (err == nil || !strings.Contains(err.Error(), "want int16")) {
+				t.Fatalf("error %v, want data type rejection", err)
+			}
+		})
+	}
+
+	// 站点创建时同样检查
+	config := DefaultConfig()
+	config.Tags = []Tag{{Name: "Humidity", Alias: "ID66", DataType: TypeFloat32}}
+	if _, err := NewStation(config); err == nil || !strings.Contains(err.Error(), `analog channel "Humidity"`) {
+		t.Fatalf("NewStation error %v", err)
+	}
+}
+
+func TestAnalogChannelScaling(t *testing.T) {
+	tests := []struct {
+		name     string
+		channel  AnalogChannel
+		rawMin   float64
+		rawMax   float64
+		raw      float64
+		want     float64
+		quality  Quality // raw对应的读数质量
+		register int     // 标签的输入寄存器短地址
+	}{
+		{"unipolar default", AnalogChannel{Alias: "IW64", EngMin: 0, EngMax: 100}, 0, 27648, 13824, 50, QualityGood, 32},
+		{"unipolar negative", AnalogChannel{Alias: "IW64", EngMin: 0, EngMax: 100}, 0, 27648, -13824, -50, QualityUncertain, 32},
+		{"bipolar", AnalogChannel{Alias: "IW64", Range: AnalogBipolar, EngMin: -10, EngMax: 10}, -27648, 27648, -13824, -5, QualityGood, 32},
+		{"bipolar zero", AnalogChannel{Alias: "IW64", Range: AnalogBipolar, EngMin: -10, EngMax: 10}, -27648, 27648, 0, 0, QualityGood, 32},
+		// 4~20mA输入按0~27648换算时4mA对应5530
+		{"custom range", AnalogChannel{Register: 40, RawMin: 5530, RawMax: 27648, EngMin: 0, EngMax: 16}, 5530, 27648, 16589, 8, QualityGood, 40},
+		{"custom range below", AnalogChannel{Register: 40, RawMin: 5530, RawMax: 27648, EngMin: 0, EngMax: 16}, 5530, 27648, 0, -4, QualityUncertain, 40},
+		// 自定义范围优先于range
+		{"custom overrides range", AnalogChannel{Register: 40, Range: AnalogBipolar, RawMax: 1000, EngMin: 0, EngMax: 10}, 0, 1000, 500, 5, QualityGood, 40},
+		{"inverted engineering", AnalogChannel{Alias: "IW64", EngMin: 100, EngMax: 0}, 0, 27648, 27648, 0, QualityGood, 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := tt.channel
+			ch.Name = "Ch"
+			if rawMin, rawMax := ch.RawRange(); rawMin != tt.rawMin || rawMax != tt.rawMax {
+				t.Fatalf("raw range %g..%g, want %g..%g", rawMin, rawMax, tt.rawMin, tt.rawMax)
+			}
+
+			db, err := NewTagDB([]Tag{ch.Tag()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tag, _ := db.Lookup("Ch")
+			if tag.Area != AreaInputRegister || tag.Offset != tt.register || tag.DataType != TypeInt16 {
+				t.Fatalf("tag %+v, want int16 input register %d", tag, tt.register)
+			}
+			if got := tag.Scaling.ToEngineering(tt.raw); math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("raw %g = %g, want %g", tt.raw, got, tt.want)
+			}
+			if reading := newAnalogReading(&ch, tag.Scaling, int16(tt.raw), time.Now()); reading.Quality != tt.quality {
+				t.Errorf("raw %g quality %s, want %s", tt.raw, reading.Quality, tt.quality)
+			}
+		})
+	}
+}
+
+func TestAnalogChannelFormat(t *testing.T) {
+	tests := []struct {
+		decimals *int
+		value    float64
+		want     string
+	}{
+		{nil, 23.456, "23.5℃"}, // 默认1位小数
+		{ptr(0), 23.5, "24℃"},
+		{ptr(0), -0.4, "-0℃"},
+		{ptr(3), 23.4567, "23.457℃"},
+		{ptr(2), -40, "-40.00℃"},
+	}
+	for _, tt := range tests {
+		ch := AnalogChannel{Name: "Temperature", Unit: "℃", Decimals: tt.decimals}
+		if got := ch.Format(tt.value); got != tt.want {
+			t.Errorf("Format(%g) with %d decimals = %q, want %q", tt.value, ch.Precision(), got, tt.want)
+		}
+	}
+
+	ch := AnalogChannel{Name: "Temperature"}
+	if ch.DisplayName() != "Temperature" {
+		t.Errorf("display name without label %q", ch.DisplayName())
+	}
+	ch.Label = "温度"
+	if ch.DisplayName() != "温度" || ch.Tag().Comment != "温度" {
+		t.Errorf("display name %q, tag comment %q", ch.DisplayName(), ch.Tag().Comment)
+	}
+}
+
+func TestValidateAnalogChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []AnalogChannel
+		want     string // 为空表示有效
+	}{
+		{"default", DefaultAnalogChannels(), ""},
+		{"none", nil, ""},
+		{"missing name", []AnalogChannel{{Alias: "IW64", EngMax: 100}}, "analog channel 1: missing name"},
+		{"duplicate name", []AnalogChannel{{Name: "A", Alias: "IW64", EngMax: 1}, {Name: "A", Alias: "IW66", EngMax: 1}}, `analog channel "A": duplicate name`},
+		{"unknown range", []AnalogChannel{{Name: "A", Alias: "IW64", Range: "4-20mA", EngMax: 1}}, `unknown range "4-20mA"`},
+		{"inverted raw range", []AnalogChannel{{Name: "A", Alias: "IW64", RawMin: 1000, RawMax: 100, EngMax: 1}}, "raw range 1000..100 is empty"},
+		{"empty raw range", []AnalogChannel{{Name: "A", Alias: "IW64", RawMin: 500, RawMax: 500, EngMax: 1}}, "raw range 500..500 is empty"},
+		{"negative raw max", []AnalogChannel{{Name: "A", Alias: "IW64", RawMax: -100, EngMax: 1}}, "raw range 0..-100 is empty"},
+		{"empty engineering range", []AnalogChannel{{Name: "A", Alias: "IW64", EngMin: 5, EngMax: 5}}, "engineering range 5..5 is empty"},
+		{"decimals", []AnalogChannel{{Name: "A", Alias: "IW64", EngMax: 1, Decimals: ptr(7)}}, "decimals 7 out of range 0-6"},
+		{"negative decimals", []AnalogChannel{{Name: "A", Alias: "IW64", EngMax: 1, Decimals: ptr(-1)}}, "decimals -1 out of range"},
+		{"alarm", []AnalogChannel{{Name: "A", Alias: "IW64", EngMax: 1, Alarm: &AlarmConfig{Hi: ptr(1.0), Lo: ptr(2.0)}}}, `analog channel "A"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tags []Tag
+			for _, ch := range tt.channels {
+				if ch.Name != "" && !slices.ContainsFunc(tags, func(tag Tag) bool { return tag.Name == ch.Name }) {
+					tags = append(tags, ch.Tag())
+				}
+			}
+			db, err := NewTagDB(tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validateAnalogChannels(tt.channels, db)
+			if tt.want == "" && err != nil {
+				t.Fatalf("valid channels rejected: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Fatalf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+// ASCII报文常量
+const (
+	asciiMaxFrameLength = 513 // ':' + 2×(地址 + 253字节PDU + LRC) + CRLF
+)
+
+// lrc 计算Modbus ASCII LRC校验值（字节和的二进制补码）
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
+
+// encodeASCIIFrame 构造ASCII报文：':' + 十六进制(地址 + PDU + LRC) + CRLF
+func encodeASCIIFrame(unitID byte, pdu []byte) []byte {
+	raw := make([]byte, 0, len(pdu)+2)
+	raw = append(raw, unitID)
+	raw = append(raw, pdu...)
+	raw = append(raw, lrc(raw))
+
+	frame := make([]byte, 0, 1+len(raw)*2+2)
+	frame = append(frame, ':')
+	frame = append(frame, bytes.ToUpper([]byte(hex.EncodeToString(raw)))...)
+	return append(frame, '\r', '\n')
+}
+
+// decodeASCIIFrame 解码ASCII报文内容（不含':'和CRLF），校验LRC并拆分出地址和PDU
+func decodeASCIIFrame(body []byte) (byte, []byte, error) {
+	if len(body)%2 != 0 {
+		return 0, nil, &FrameError{Reason: fmt.Sprintf("odd ASCII frame length %d", len(body))}
+	}
+
+	raw := make([]byte, len(body)/2)
+	if _, err := hex.Decode(raw, body); err != nil {
+		return 0, nil, &FrameError{Reason: "invalid hex in ASCII frame"}
+	}
+	if len(raw) < 3 {
+		return 0, nil, &FrameError{Reason: fmt.Sprintf("ASCII frame too short: %d bytes", len(raw))}
+	}
+
+	n := len(raw) - 1
+	if expected := lrc(raw[:n]); expected != raw[n] {
+		return 0, nil, &FrameError{Reason: fmt.Sprintf("LRC mismatch: expected 0x%02X, got 0x%02X", expected, raw[n])}
+	}
+
+	return raw[0], raw[1:n], nil
+}
+
+// readASCIIFrame 读取一个ASCII报文，丢弃起始符':'之前的数据
+// 返回值为':'与CRLF之间的十六进制内容
+func readASCIIFrame(reader *bufio.Reader) ([]byte, error) {
+	// 查找起始符
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == ':' {
+			break
+		}
+	}
+
+	// 读取到LF为止
+	var frame []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == ':' {
+			// 出现新的起始符，丢弃不完整的前一帧
+			frame = frame[:0]
+			continue
+		}
+		frame = append(frame, b)
+		if len(frame) > asciiMaxFrameLength {
+			return nil, &FrameError{Reason: "ASCII frame exceeds maximum length"}
+		}
+		if b == '\n' {
+			break
+		}
+	}
+
+	if len(frame) < 2 || frame[len(frame)-2] != '\r' {
+		return nil, &FrameError{Reason: "ASCII frame missing CR"}
+	}
+	return frame[:len(frame)-2], nil
+}
+
+// asciiTransport Modbus ASCII传输层
+type asciiTransport struct {
+	conn   deadlineConn
+	reader *bufio.Reader
+	config *Config
+	busy   chan struct{} // 串行化事务，等待期间可被ctx取消
+}
+
+// newASCIITransport 在已建立的字节流上创建ASCII传输层
+func newASCIITransport(conn deadlineConn, config *Config) *asciiTransport {
+	return &asciiTransport{
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, asciiMaxFrameLength),
+		config: config,
+		busy:   make(chan struct{}, 1),
+	}
+}
+
+// Send 发送请求PDU并接收响应PDU
+// 与RTU相同，请求发出后ctx只能缩短读取截止时间
+func (t *asciiTransport) Send(ctx context.Context, unitID byte, pdu []byte) ([]byte, error) {
+	// 串行链路同一时刻只能有一个事务在途
+	select {
+	case t.busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-t.busy }()
+
+	// 发送请求
+	t.conn.SetWriteDeadline(requestDeadline(ctx, t.config.WriteTimeout()))
+	if _, err := t.conn.Write(encodeASCIIFrame(unitID, pdu)); err != nil {
+		return nil, &LinkError{Err: err}
+	}
+
+	// 读取响应
+	t.conn.SetReadDeadline(requestDeadline(ctx, t.config.ReadTimeout()))
+	body, err := readASCIIFrame(t.reader)
+	if err != nil {
+		return nil, contextError(ctx, t.resync(err))
+	}
+
+	address, respPDU, err := decodeASCIIFrame(body)
+	if err != nil {
+		return nil, t.resync(err)
+	}
+
+	// 验证从站地址
+	if address != unitID {
+		return nil, t.resync(&FrameError{Reason: fmt.Sprintf("slave address mismatch: expected %d, got %d", unitID, address)})
+	}
+
+	return respPDU, nil
+}
+
+// resync 出错后丢弃缓冲区和线路上的残留数据，无法恢复时返回链路错误
+func (t *asciiTransport) resync(err error) error {
+	if !isResyncable(err) {
+		return &LinkError{Err: err}
+	}
+	t.reader.Discard(t.reader.Buffered())
+	if !drainConn(t.conn) {
+		return &LinkError{Err: err}
+	}
+	return err
+}
+
+// Close 关闭底层连接
+func (t *asciiTransport) Close() error {
+	return t.conn.Close()
+}
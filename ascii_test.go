@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLRC(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want byte
+	}{
+		{"empty", nil, 0x00},
+		{"read holding register", []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}, 0xFB},
+		{"spec example", []byte{0xF7, 0x03, 0x13, 0x89, 0x00, 0x0A}, 0x60},
+		{"sum wraps", []byte{0xFF, 0xFF}, 0x02},
+	}
+	for _, tt := range tests {
+		if got := lrc(tt.data); got != tt.want {
+			t.Errorf("%s: lrc = 0x%02X, want 0x%02X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestASCIIFrameRoundTrip(t *testing.T) {
+	pdu := []byte{0x03, 0x13, 0x89, 0x00, 0x0A}
+	frame := encodeASCIIFrame(0xF7, pdu)
+	if want := ":F7031389000A60\r\n"; string(frame) != want {
+		t.Fatalf("encodeASCIIFrame = %q, want %q", frame, want)
+	}
+
+	address, decoded, err := decodeASCIIFrame(frame[1 : len(frame)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != 0xF7 || !bytes.Equal(decoded, pdu) {
+		t.Fatalf("decodeASCIIFrame = %d, % X", address, decoded)
+	}
+
+	// 小写十六进制同样合法
+	if _, _, err := decodeASCIIFrame([]byte("f7031389000a60")); err != nil {
+		t.Errorf("lowercase frame: %v", err)
+	}
+
+	for _, body := range []string{
+		"F7031389000A61", // LRC错误
+		"F7031389000A6",  // 奇数长度
+		"F7031389000AZ0", // 非十六进制字符
+		"0103",           // 过短
+	} {
+		var frameErr *FrameError
+		if _, _, err := decodeASCIIFrame([]byte(body)); !errors.As(err, &frameErr) {
+			t.Errorf("%q: got %v, want FrameError", body, err)
+		}
+	}
+}
+
+func TestReadASCIIFrameSplitReads(t *testing.T) {
+	frame := encodeASCIIFrame(0x01, []byte{0x03, 0x02, 0x12, 0x34})
+	want := frame[1 : len(frame)-2]
+
+	tests := []struct {
+		name   string
+		chunks [][]byte
+	}{
+		{"byte by byte", splitFrame(frame, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)},
+		{"CR and LF split", splitFrame(frame, len(frame)-1)},
+		{"noise before start", append([][]byte{[]byte("\x00\xFF")}, splitFrame(frame, 4)...)},
+		{"restarted frame", append([][]byte{[]byte(":0103")}, splitFrame(frame, 6, 3)...)},
+	}
+	for _, tt := range tests {
+		reader := bufio.NewReaderSize(&chunkedConn{chunks: tt.chunks}, asciiMaxFrameLength)
+		got, err := readASCIIFrame(reader)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, want)
+		}
+	}
+
+	var frameErr *FrameError
+	reader := bufio.NewReader(&chunkedConn{chunks: [][]byte{[]byte(":0103"), []byte("\n")}})
+	if _, err := readASCIIFrame(reader); !errors.As(err, &frameErr) {
+		t.Errorf("missing CR: got %v, want FrameError", err)
+	}
+}
+
+func TestASCIITransportSendSplitResponse(t *testing.T) {
+	response := encodeASCIIFrame(0x01, []byte{0x03, 0x02, 0x12, 0x34})
+	conn := &chunkedConn{chunks: splitFrame(response, 3, 5, 4)}
+	transport := newASCIITransport(conn, testTransportConfig(1))
+
+	pdu, err := transport.Send(context.Background(), 0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x03, 0x02, 0x12, 0x34}; !bytes.Equal(pdu, want) {
+		t.Fatalf("response PDU = % X, want % X", pdu, want)
+	}
+	if want := encodeASCIIFrame(0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01}); !bytes.Equal(conn.written.Bytes(), want) {
+		t.Fatalf("request frame = %q, want %q", conn.written.Bytes(), want)
+	}
+}
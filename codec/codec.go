@@ -0,0 +1,333 @@
+// Package codec 在Modbus寄存器与S7数据类型之间编解码
+//
+// 支持 INT/UINT/DINT/UDINT/REAL/LREAL、BCD 以及固定长度的 S7 STRING，
+// 多寄存器数值可按 ABCD/CDAB/BADC/DCBA 选择字序和字节序。
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ByteOrder 寄存器字节序，字母表示最高有效字节A到最低有效字节D在寄存器流中的排列
+type ByteOrder int
+
+// 字节序
+const (
+	ABCD ByteOrder = iota // 大端，高位字在前（S7默认）
+	CDAB                  // 字交换，低位字在前
+	BADC                  // 字内字节交换
+	DCBA                  // 小端，字交换且字内字节交换
+)
+
+var byteOrderNames = [...]string{"ABCD", "CDAB", "BADC", "DCBA"}
+
+// String 返回字节序名称
+func (o ByteOrder) String() string {
+	if o < 0 || int(o) >= len(byteOrderNames) {
+		return fmt.Sprintf("ByteOrder(%d)", int(o))
+	}
+	return byteOrderNames[o]
+}
+
+// ParseByteOrder 解析字节序名称，空串表示ABCD
+func ParseByteOrder(name string) (ByteOrder, error) {
+	if name == "" {
+		return ABCD, nil
+	}
+	for i, n := range byteOrderNames {
+		if strings.EqualFold(name, n) {
+			return ByteOrder(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown byte order %q", name)
+}
+
+// wordSwap 是否为低位字在前
+func (o ByteOrder) wordSwap() bool {
+	return o == CDAB || o == DCBA
+}
+
+// byteSwap 是否交换字内字节
+func (o ByteOrder) byteSwap() bool {
+	return o == BADC || o == DCBA
+}
+
+// Type 数据类型
+type Type string
+
+// 数据类型
+const (
+	Int16   Type = "int16"   // INT
+	Uint16  Type = "uint16"  // WORD/UINT
+	Int32   Type = "int32"   // DINT
+	Uint32  Type = "uint32"  // DWORD/UDINT
+	Float32 Type = "float32" // REAL
+	Float64 Type = "float64" // LREAL
+	BCD16   Type = "bcd16"   // 4位BCD码，0-9999
+	BCD32   Type = "bcd32"   // 8位BCD码，0-99999999
+	String  Type = "string"  // S7 STRING[n]：最大长度 + 实际长度 + 字符
+)
+
+// 固定长度类型占用的寄存器数
+var typeRegisters = map[Type]int{
+	Int16:   1,
+	Uint16:  1,
+	BCD16:   1,
+	Int32:   2,
+	Uint32:  2,
+	Float32: 2,
+	BCD32:   2,
+	Float64: 4,
+}
+
+// MaxStringLength S7 STRING的最大字符数
+const MaxStringLength = 254
+
+// Registers 返回数据类型占用的寄存器数，length为STRING的最大字符数，其他类型忽略
+func (t Type) Registers(length int) (int, error) {
+	if t == String {
+		if length < 1 || length > MaxStringLength {
+			return 0, fmt.Errorf("string length %d out of range 1-%d", length, MaxStringLength)
+		}
+		return (length + 2 + 1) / 2, nil
+	}
+	n, ok := typeRegisters[t]
+	if !ok {
+		return 0, fmt.Errorf("unknown data type %q", t)
+	}
+	return n, nil
+}
+
+// Codec 一种数据类型在寄存器区间上的编解码器
+type Codec struct {
+	Type   Type
+	Order  ByteOrder
+	Length int // STRING最大字符数
+
+	registers int
+}
+
+// New 创建编解码器
+func New(t Type, order ByteOrder, length int) (*Codec, error) {
+	if order < ABCD || order > DCBA {
+		return nil, fmt.Errorf("invalid byte order %d", int(order))
+	}
+	n, err := t.Registers(length)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{Type: t, Order: order, Length: length, registers: n}, nil
+}
+
+// Registers 返回占用的寄存器数
+func (c *Codec) Registers() int {
+	return c.registers
+}
+
+// Decode 解码为对应的Go类型：int16/uint16/int32/uint32/float32/float64，BCD为uint32，STRING为string
+func (c *Codec) Decode(registers []uint16) (any, error) {
+	data, err := c.bytes(registers)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case Int16:
+		return int16(binary.BigEndian.Uint16(data)), nil
+	case Uint16:
+		return binary.BigEndian.Uint16(data), nil
+	case Int32:
+		return int32(binary.BigEndian.Uint32(data)), nil
+	case Uint32:
+		return binary.BigEndian.Uint32(data), nil
+	case Float32:
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case Float64:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case BCD16, BCD32:
+		return decodeBCD(data)
+	case String:
+		return decodeS7String(data)
+	default:
+		return nil, fmt.Errorf("unknown data type %q", c.Type)
+	}
+}
+
+// DecodeFloat 解码数值类型并转换为float64
+func (c *Codec) DecodeFloat(registers []uint16) (float64, error) {
+	value, err := c.Decode(registers)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case int16:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("data type %s is not numeric", c.Type)
+	}
+}
+
+// DecodeString 解码S7 STRING
+func (c *Codec) DecodeString(registers []uint16) (string, error) {
+	if c.Type != String {
+		return "", fmt.Errorf("data type %s is not a string", c.Type)
+	}
+	value, err := c.Decode(registers)
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// EncodeFloat 将数值编码为寄存器，整数类型四舍五入并检查范围
+func (c *Codec) EncodeFloat(value float64) ([]uint16, error) {
+	if math.IsNaN(value) && c.Type != Float32 && c.Type != Float64 {
+		return nil, fmt.Errorf("value NaN out of %s range", c.Type)
+	}
+
+	data := make([]byte, c.registers*2)
+	switch c.Type {
+	case Float32:
+		binary.BigEndian.PutUint32(data, math.Float32bits(float32(value)))
+	case Float64:
+		binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	case Int16, Uint16, Int32, Uint32:
+		limits := map[Type][2]float64{
+			Int16:  {math.MinInt16, math.MaxInt16},
+			Uint16: {0, math.MaxUint16},
+			Int32:  {math.MinInt32, math.MaxInt32},
+			Uint32: {0, math.MaxUint32},
+		}[c.Type]
+		rounded := math.Round(value)
+		if rounded < limits[0] || rounded > limits[1] {
+			return nil, fmt.Errorf("value %g out of %s range", value, c.Type)
+		}
+		if c.registers == 1 {
+			binary.BigEndian.PutUint16(data, uint16(int64(rounded)))
+		} else {
+			binary.BigEndian.PutUint32(data, uint32(int64(rounded)))
+		}
+	case BCD16, BCD32:
+		rounded := math.Round(value)
+		if rounded < 0 || rounded >= math.Pow10(len(data)*2) {
+			return nil, fmt.Errorf("value %g out of %s range", value, c.Type)
+		}
+		encodeBCD(data, uint64(rounded))
+	default:
+		return nil, fmt.Errorf("data type %s is not numeric", c.Type)
+	}
+	return c.registersOf(data), nil
+}
+
+// EncodeString 将字符串编码为S7 STRING，字符按Latin-1单字节存储
+func (c *Codec) EncodeString(value string) ([]uint16, error) {
+	if c.Type != String {
+		return nil, fmt.Errorf("data type %s is not a string", c.Type)
+	}
+
+	chars := make([]byte, 0, len(value))
+	for _, r := range value {
+		if r > 0xFF {
+			return nil, fmt.Errorf("character %q cannot be stored in S7 STRING", r)
+		}
+		chars = append(chars, byte(r))
+	}
+	if len(chars) > c.Length {
+		return nil, fmt.Errorf("string length %d exceeds maximum %d", len(chars), c.Length)
+	}
+
+	data := make([]byte, c.registers*2)
+	data[0] = byte(c.Length)
+	data[1] = byte(len(chars))
+	copy(data[2:], chars)
+	return c.registersOf(data), nil
+}
+
+// bytes 按字节序将寄存器转换为大端字节流
+// STRING为字节流，只按字内字节交换处理
+func (c *Codec) bytes(registers []uint16) ([]byte, error) {
+	if len(registers) != c.registers {
+		return nil, fmt.Errorf("%s needs %d registers, got %d", c.Type, c.registers, len(registers))
+	}
+
+	data := make([]byte, len(registers)*2)
+	for i, register := range registers {
+		if c.Order.wordSwap() && c.Type != String {
+			i = len(registers) - 1 - i
+		}
+		if c.Order.byteSwap() {
+			register = register>>8 | register<<8
+		}
+		binary.BigEndian.PutUint16(data[i*2:], register)
+	}
+	return data, nil
+}
+
+// registersOf bytes的逆变换
+func (c *Codec) registersOf(data []byte) []uint16 {
+	registers := make([]uint16, len(data)/2)
+	for i := range registers {
+		register := binary.BigEndian.Uint16(data[i*2:])
+		if c.Order.byteSwap() {
+			register = register>>8 | register<<8
+		}
+		j := i
+		if c.Order.wordSwap() && c.Type != String {
+			j = len(registers) - 1 - i
+		}
+		registers[j] = register
+	}
+	return registers
+}
+
+// decodeBCD 解码大端BCD字节流，每个半字节必须为0-9
+func decodeBCD(data []byte) (uint32, error) {
+	var value uint32
+	for _, b := range data {
+		high, low := b>>4, b&0x0F
+		if high > 9 || low > 9 {
+			return 0, fmt.Errorf("invalid BCD digit in 0x%02X", b)
+		}
+		value = value*100 + uint32(high)*10 + uint32(low)
+	}
+	return value, nil
+}
+
+// encodeBCD 将value按大端BCD写入data
+func encodeBCD(data []byte, value uint64) {
+	for i := len(data) - 1; i >= 0; i-- {
+		low := value % 10
+		value /= 10
+		high := value % 10
+		value /= 10
+		data[i] = byte(high<<4 | low)
+	}
+}
+
+// decodeS7String 解码S7 STRING：最大长度 + 实际长度 + 字符（Latin-1）
+func decodeS7String(data []byte) (string, error) {
+	maxLength, length := int(data[0]), int(data[1])
+	if length > maxLength || 2+length > len(data) {
+		return "", fmt.Errorf("invalid S7 STRING header: max %d, length %d", maxLength, length)
+	}
+
+	runes := make([]rune, length)
+	for i, b := range data[2 : 2+length] {
+		runes[i] = rune(b)
+	}
+	return string(runes), nil
+}
@@ -0,0 +1,297 @@
+package codec
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var allOrders = []ByteOrder{ABCD, CDAB, BADC, DCBA}
+
+func mustNew(t *testing.T, typ Type, order ByteOrder, length int) *Codec {
+	t.Helper()
+	c, err := New(typ, order, length)
+	if err != nil {
+		t.Fatalf("New(%s, %s, %d): %v", typ, order, length, err)
+	}
+	return c
+}
+
+func TestByteOrderLayout(t *testing.T) {
+	tests := []struct {
+		typ   Type
+		value float64
+		want  map[ByteOrder][]uint16
+	}{
+		{Uint16, 0x1122, map[ByteOrder][]uint16{
+			ABCD: {0x1122},
+			CDAB: {0x1122},
+			BADC: {0x2211},
+			DCBA: {0x2211},
+		}},
+		{Uint32, 0x11223344, map[ByteOrder][]uint16{
+			ABCD: {0x1122, 0x3344},
+			CDAB: {0x3344, 0x1122},
+			BADC: {0x2211, 0x4433},
+			DCBA: {0x4433, 0x2211},
+		}},
+		{Float64, math.Float64frombits(0x0102030405060708), map[ByteOrder][]uint16{
+			ABCD: {0x0102, 0x0304, 0x0506, 0x0708},
+			CDAB: {0x0708, 0x0506, 0x0304, 0x0102},
+			BADC: {0x0201, 0x0403, 0x0605, 0x0807},
+			DCBA: {0x0807, 0x0605, 0x0403, 0x0201},
+		}},
+		{BCD32, 12345678, map[ByteOrder][]uint16{
+			ABCD: {0x1234, 0x5678},
+			CDAB: {0x5678, 0x1234},
+			BADC: {0x3412, 0x7856},
+			DCBA: {0x7856, 0x3412},
+		}},
+	}
+	for _, tt := range tests {
+		for _, order := range allOrders {
+			got, err := mustNew(t, tt.typ, order, 0).EncodeFloat(tt.value)
+			if err != nil {
+				t.Errorf("%s/%s: %v", tt.typ, order, err)
+				continue
+			}
+			if want := tt.want[order]; !slices.Equal(got, want) {
+				t.Errorf("%s/%s: registers %04X, want %04X", tt.typ, order, got, want)
+			}
+		}
+	}
+}
+
+func TestNumericRoundTrip(t *testing.T) {
+	tests := []struct {
+		typ    Type
+		values []float64
+	}{
+		{Int16, []float64{0, -1, math.MinInt16, math.MaxInt16}},
+		{Uint16, []float64{0, 1, math.MaxUint16}},
+		{Int32, []float64{0, -1, math.MinInt32, math.MaxInt32}},
+		{Uint32, []float64{0, 27648, math.MaxUint32}},
+		{Float32, []float64{0, -1.5, 3.1415927, math.MaxFloat32, math.Inf(-1)}},
+		{Float64, []float64{0, -1.5e300, math.Pi, math.SmallestNonzeroFloat64, math.Inf(1)}},
+		{BCD16, []float64{0, 1234, 9999}},
+		{BCD32, []float64{0, 12345678, 99999999}},
+	}
+	for _, tt := range tests {
+		for _, order := range allOrders {
+			c := mustNew(t, tt.typ, order, 0)
+			for _, value := range tt.values {
+				registers, err := c.EncodeFloat(value)
+				if err != nil {
+					t.Errorf("%s/%s encode %g: %v", tt.typ, order, value, err)
+					continue
+				}
+				if len(registers) != c.Registers() {
+					t.Errorf("%s/%s encode %g: %d registers, want %d", tt.typ, order, value, len(registers), c.Registers())
+				}
+				got, err := c.DecodeFloat(registers)
+				if err != nil {
+					t.Errorf("%s/%s decode %g: %v", tt.typ, order, value, err)
+					continue
+				}
+				want := value
+				if tt.typ == Float32 {
+					want = float64(float32(value))
+				}
+				if got != want {
+					t.Errorf("%s/%s: round trip %g, got %g", tt.typ, order, value, got)
+				}
+			}
+		}
+	}
+}
+
+func TestFloatNaNRoundTrip(t *testing.T) {
+	for _, typ := range []Type{Float32, Float64} {
+		for _, order := range allOrders {
+			c := mustNew(t, typ, order, 0)
+			registers, err := c.EncodeFloat(math.NaN())
+			if err != nil {
+				t.Fatalf("%s/%s: %v", typ, order, err)
+			}
+			if got, err := c.DecodeFloat(registers); err != nil || !math.IsNaN(got) {
+				t.Errorf("%s/%s: got %g, %v, want NaN", typ, order, got, err)
+			}
+		}
+	}
+}
+
+func TestDecodeGoTypes(t *testing.T) {
+	tests := []struct {
+		typ       Type
+		registers []uint16
+		want      any
+	}{
+		{Int16, []uint16{0xFFFE}, int16(-2)},
+		{Uint16, []uint16{0xFFFE}, uint16(0xFFFE)},
+		{Int32, []uint16{0xFFFF, 0xFFFD}, int32(-3)},
+		{Uint32, []uint16{0x0001, 0x0000}, uint32(65536)},
+		{Float32, []uint16{0x3FC0, 0x0000}, float32(1.5)},
+		{Float64, []uint16{0x3FF8, 0, 0, 0}, float64(1.5)},
+		{BCD16, []uint16{0x0420}, uint32(420)},
+		{BCD32, []uint16{0x0001, 0x2345}, uint32(12345)},
+	}
+	for _, tt := range tests {
+		got, err := mustNew(t, tt.typ, ABCD, 0).Decode(tt.registers)
+		if err != nil {
+			t.Errorf("%s: %v", tt.typ, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %#v, want %#v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		length int
+		value  string
+	}{
+		{10, ""},
+		{10, "Hello"},
+		{5, "Hello"}, // 恰好为最大长度
+		{4, "Grüß"},  // Latin-1字符按单字节存储
+		{MaxStringLength, strings.Repeat("x", MaxStringLength)},
+	}
+	for _, tt := range tests {
+		for _, order := range allOrders {
+			c := mustNew(t, String, order, tt.length)
+			registers, err := c.EncodeString(tt.value)
+			if err != nil {
+				t.Errorf("%s STRING[%d] %q: %v", order, tt.length, tt.value, err)
+				continue
+			}
+			if want := (tt.length + 3) / 2; len(registers) != want {
+				t.Errorf("%s STRING[%d]: %d registers, want %d", order, tt.length, len(registers), want)
+			}
+			got, err := c.DecodeString(registers)
+			if err != nil {
+				t.Errorf("%s STRING[%d] %q: %v", order, tt.length, tt.value, err)
+				continue
+			}
+			if got != tt.value {
+				t.Errorf("%s STRING[%d]: round trip %q, got %q", order, tt.length, tt.value, got)
+			}
+		}
+	}
+}
+
+func TestStringLayout(t *testing.T) {
+	// STRING为字节流，字序不影响布局，只有字内字节交换生效
+	want := map[ByteOrder][]uint16{
+		ABCD: {0x0402, 0x4142, 0x0000},
+		CDAB: {0x0402, 0x4142, 0x0000},
+		BADC: {0x0204, 0x4241, 0x0000},
+		DCBA: {0x0204, 0x4241, 0x0000},
+	}
+	for _, order := range allOrders {
+		got, err := mustNew(t, String, order, 4).EncodeString("AB")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want[order]) {
+			t.Errorf("%s: registers %04X, want %04X", order, got, want[order])
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		typ       Type
+		length    int
+		registers []uint16
+		errText   string
+	}{
+		{"short int16", Int16, 0, nil, "needs 1 registers"},
+		{"short float32", Float32, 0, []uint16{0x3FC0}, "needs 2 registers"},
+		{"short float64", Float64, 0, []uint16{0, 0, 0}, "needs 4 registers"},
+		{"long uint32", Uint32, 0, []uint16{0, 0, 0}, "needs 2 registers"},
+		{"short string", String, 10, []uint16{0x0A00, 0, 0}, "needs 6 registers"},
+		{"BCD low nibble", BCD16, 0, []uint16{0x12A4}, "invalid BCD digit in 0xA4"},
+		{"BCD high nibble", BCD16, 0, []uint16{0xF234}, "invalid BCD digit in 0xF2"},
+		{"BCD32 low word", BCD32, 0, []uint16{0x1234, 0x567B}, "invalid BCD digit in 0x7B"},
+		{"string length over header maximum", String, 4, []uint16{0x0405, 0x4142, 0x4344}, "invalid S7 STRING header"},
+		{"string length over buffer", String, 4, []uint16{0x0A06, 0x4142, 0x4344}, "invalid S7 STRING header"},
+	}
+	for _, tt := range tests {
+		_, err := mustNew(t, tt.typ, ABCD, tt.length).Decode(tt.registers)
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("%s: got %v, want error containing %q", tt.name, err, tt.errText)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	numeric := []struct {
+		typ   Type
+		value float64
+	}{
+		{Int16, 32768},
+		{Int16, math.NaN()},
+		{Uint16, -1},
+		{Int32, math.MaxInt32 + 1},
+		{Uint32, math.MaxUint32 + 1},
+		{BCD16, 10000},
+		{BCD16, -1},
+		{BCD32, 100000000},
+		{String, 1},
+	}
+	for _, tt := range numeric {
+		if _, err := mustNew(t, tt.typ, ABCD, 4).EncodeFloat(tt.value); err == nil {
+			t.Errorf("%s encode %g: expected error", tt.typ, tt.value)
+		}
+	}
+
+	c := mustNew(t, String, ABCD, 4)
+	if _, err := c.EncodeString("Hello"); err == nil || !strings.Contains(err.Error(), "exceeds maximum 4") {
+		t.Errorf("string over maximum: got %v", err)
+	}
+	if _, err := c.EncodeString("中文"); err == nil {
+		t.Error("non Latin-1 string: expected error")
+	}
+	if _, err := mustNew(t, Int16, ABCD, 0).EncodeString("1"); err == nil {
+		t.Error("EncodeString on int16: expected error")
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		typ    Type
+		order  ByteOrder
+		length int
+	}{
+		{String, ABCD, 0},
+		{String, ABCD, MaxStringLength + 1},
+		{"int64", ABCD, 0},
+		{Int16, DCBA + 1, 0},
+		{Int16, -1, 0},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.typ, tt.order, tt.length); err == nil {
+			t.Errorf("New(%s, %d, %d): expected error", tt.typ, int(tt.order), tt.length)
+		}
+	}
+}
+
+func TestParseByteOrder(t *testing.T) {
+	for _, order := range allOrders {
+		for _, name := range []string{order.String(), strings.ToLower(order.String())} {
+			if got, err := ParseByteOrder(name); err != nil || got != order {
+				t.Errorf("ParseByteOrder(%q) = %s, %v", name, got, err)
+			}
+		}
+	}
+	if got, err := ParseByteOrder(""); err != nil || got != ABCD {
+		t.Errorf("ParseByteOrder(\"\") = %s, %v", got, err)
+	}
+	if _, err := ParseByteOrder("ABDC"); err == nil {
+		t.Error("ParseByteOrder(\"ABDC\"): expected error")
+	}
+}
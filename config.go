@@ -2,43 +2,375 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 )
 
+// configMu 保护运行中会被修改的配置字段（连接参数、当前花样、自定义序列）和保存配置时的序列化
+// 站点配置是整体配置的副本，与整体配置共用同一把锁
+var configMu sync.RWMutex
+
 // Config 项目配置结构体
 type Config struct {
-	IP             string `json:"ip"`
-	Port           int    `json:"port"`
-	UnitID         int    `json:"unitId"`
-	SpeedDelays    []int  `json:"speedDelays"`
-	PollIntervalMs int    `json:"pollIntervalMs"`
-	WindowSize     []int  `json:"windowSize"`
-	WindowPosition []int  `json:"windowPosition"`
+	IP                  string            `json:"ip"`
+	Port                int               `json:"port"`
+	UnitID              int               `json:"unitId"`
+	Transport           string            `json:"transport,omitempty"` // 传输方式：tcp（默认）/rtu/rtuovertcp/ascii
+	Serial              *SerialConfig     `json:"serial,omitempty"`    // 串口参数（rtu传输时使用）
+	SpeedDelays         []int             `json:"speedDelays"`
+	SpeedRampMs         int               `json:"speedRampMs,omitempty"` // 速度变化的过渡时间，0表示立即切换
+	SpeedInput          *SpeedInputConfig `json:"speedInput,omitempty"`  // 由模拟量通道（如IW68电位器）连续调速
+	Pattern             string            `json:"pattern,omitempty"`     // 跑马灯花样，为空时为单点流水（chase）
+	Sequences           []SequenceConfig  `json:"sequences,omitempty"`   // 自定义跑马灯序列，全部站点共用
+	Outputs             *IOConfig         `json:"outputs,omitempty"`     // 跑马灯输出点，为空时为Q0.0-Q1.5共14点
+	Inputs              *IOConfig         `json:"inputs,omitempty"`      // 界面显示的数字输入点，为空时为I0.0-I1.5共14点
+	PollIntervalMs      int               `json:"pollIntervalMs"`
+	ConnectTimeoutMs    int               `json:"connectTimeoutMs"`    // 建立连接超时
+	ReadTimeoutMs       int               `json:"readTimeoutMs"`       // 单次请求读取响应超时
+	WriteTimeoutMs      int               `json:"writeTimeoutMs"`      // 单次请求发送超时
+	PipelineWindow      int               `json:"pipelineWindow"`      // Modbus TCP最多同时在途的事务数
+	AutoConnect         bool              `json:"autoConnect"`         // 启动时自动连接PLC
+	ReconnectInitialMs  int               `json:"reconnectInitialMs"`  // 首次重连等待时间
+	ReconnectMaxMs      int               `json:"reconnectMaxMs"`      // 重连等待时间上限
+	ReconnectMultiplier float64           `json:"reconnectMultiplier"` // 每次失败后等待时间倍数
+	ReconnectJitter     float64           `json:"reconnectJitter"`     // 等待时间随机抖动比例 (0-1)
+	WindowSize          []int             `json:"windowSize"`
+	WindowPosition      []int             `json:"windowPosition"`
+	Simulator           *SimulatorConfig  `json:"simulator,omitempty"`      // 仿真器配置
+	Devices             []DeviceConfig    `json:"devices,omitempty"`        // 多站点配置，为空时顶层连接参数即唯一站点
+	Tags                []Tag             `json:"tags,omitempty"`           // 自定义标签，与默认标签同名时覆盖默认标签
+	AnalogChannels      []AnalogChannel   `json:"analogChannels,omitempty"` // 模拟量通道，为空时使用默认的温度/湿度通道
+	AnalogStaleMs       int               `json:"analogStaleMs,omitempty"`  // 模拟量读数超过该时长未更新即标记为过期
+	History             *HistoryConfig    `json:"history,omitempty"`        // 历史数据记录设置
+
+	simulated bool          // 仿真模式下不保存配置，避免覆盖真实PLC地址
+	name      string        // 站点名称
+	root      *Config       // 站点配置所属的整体配置
+	device    *DeviceConfig // 站点配置对应的设备条目
+	path      string        // 配置文件路径，为空时为程序目录下的config/config.json
+}
+
+// DeviceConfig 单个站点（PLC）的连接参数，未设置的字段沿用顶层配置
+type DeviceConfig struct {
+	Name      string        `json:"name"`
+	IP        string        `json:"ip"`
+	Port      int           `json:"port,omitempty"`
+	UnitID    int           `json:"unitId,omitempty"`
+	Transport string        `json:"transport,omitempty"`
+	Serial    *SerialConfig `json:"serial,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"` // 该站点的跑马灯花样
+	Outputs   *IOConfig     `json:"outputs,omitempty"` // 该站点的跑马灯输出点
+	Inputs    *IOConfig     `json:"inputs,omitempty"`  // 该站点的数字输入点
+}
+
+// 未配置站点列表时唯一站点的名称
+const defaultStationName = "PLC"
+
+// StationConfigs 返回各站点的配置
+// 每个站点配置是顶层配置的副本，连接参数由对应的设备条目覆盖；未配置devices时返回顶层配置本身
+func (c *Config) StationConfigs() []*Config {
+	if len(c.Devices) == 0 {
+		if c.name == "" {
+			c.name = defaultStationName
+		}
+		return []*Config{c}
+	}
+
+	configs := make([]*Config, 0, len(c.Devices))
+	for i := range c.Devices {
+		device := &c.Devices[i]
+		station := *c
+		station.Devices = nil
+		station.name = device.Name
+		station.root = c
+		station.device = device
+		if station.name == "" {
+			station.name = fmt.Sprintf("站点%d", i+1)
+		}
+		if device.IP != "" {
+			station.IP = device.IP
+		}
+		if device.Port != 0 {
+			station.Port = device.Port
+		}
+		if device.UnitID != 0 {
+			station.UnitID = device.UnitID
+		}
+		if device.Transport != "" {
+			station.Transport = device.Transport
+		}
+		if device.Serial != nil {
+			station.Serial = device.Serial
+		}
+		if device.Pattern != "" {
+			station.Pattern = device.Pattern
+		}
+		if device.Outputs != nil {
+			station.Outputs = device.Outputs
+		}
+		if device.Inputs != nil {
+			station.Inputs = device.Inputs
+		}
+		configs = append(configs, &station)
+	}
+	return configs
+}
+
+// StationName 返回站点名称
+func (c *Config) StationName() string {
+	if c.name == "" {
+		return defaultStationName
+	}
+	return c.name
+}
+
+// TagTable 返回站点使用的标签表：默认标签、模拟量通道标签依次合并自定义标签
+func (c *Config) TagTable() []Tag {
+	var channelTags []Tag
+	for _, ch := range c.AnalogChannelList() {
+		channelTags = append(channelTags, ch.Tag())
+	}
+	// 输出点或输入点地址有误时站点无法创建，见NewStation
+	lamps, _ := c.LampAddresses()
+	inputs, _ := c.InputAddresses()
+	return mergeTags(mergeTags(DefaultTags(lamps, inputs), channelTags), c.Tags)
+}
+
+// LampAddresses 返回跑马灯输出点地址，按点亮顺序排列
+func (c *Config) LampAddresses() ([]string, error) {
+	addresses, err := c.Outputs.BitAddresses("Q", lampCount)
+	if err != nil {
+		return nil, fmt.Errorf("outputs: %w", err)
+	}
+	return addresses, nil
+}
+
+// InputAddresses 返回界面显示的数字输入点地址
+func (c *Config) InputAddresses() ([]string, error) {
+	addresses, err := c.Inputs.BitAddresses("I", inputCount)
+	if err != nil {
+		return nil, fmt.Errorf("inputs: %w", err)
+	}
+	return addresses, nil
+}
+
+// LampTagNames 返回跑马灯输出点标签名，按点亮顺序排列，数量与输出点地址相同
+func (c *Config) LampTagNames() []string {
+	addresses, _ := c.LampAddresses()
+	return numberedTagNames("Lamp", len(addresses))
+}
+
+// InputTagNames 返回数字输入点标签名，数量与输入点地址相同
+func (c *Config) InputTagNames() []string {
+	addresses, _ := c.InputAddresses()
+	return numberedTagNames("Input", len(addresses))
+}
+
+// AnalogChannelList 返回模拟量通道，未配置时返回默认通道
+func (c *Config) AnalogChannelList() []AnalogChannel {
+	if len(c.AnalogChannels) == 0 {
+		return DefaultAnalogChannels()
+	}
+	return c.AnalogChannels
+}
+
+// SequenceList 返回自定义序列，站点配置返回整体配置中的序列
+// 序列列表按写时复制更新，返回的切片不会再被修改，调用方不得修改其内容
+func (c *Config) SequenceList() []SequenceConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return c.rootConfig().Sequences
+}
+
+// rootConfig 返回站点配置所属的整体配置，顶层配置返回自身
+func (c *Config) rootConfig() *Config {
+	if c.root != nil {
+		return c.root
+	}
+	return c
+}
+
+// Connection 返回当前的连接参数
+func (c *Config) Connection() (ip string, port, unitID int) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return c.IP, c.Port, c.UnitID
+}
+
+// SetConnection 设置连接参数，返回参数是否有变化
+// 新参数在下次建立连接时生效，保存配置时写入
+func (c *Config) SetConnection(ip string, port, unitID int) bool {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if c.IP == ip && c.Port == port && c.UnitID == unitID {
+		return false
+	}
+	c.IP, c.Port, c.UnitID = ip, port, unitID
+	return true
+}
+
+// snapshot 返回配置的副本，建立连接时使用，连接期间修改参数不影响已建立的连接
+func (c *Config) snapshot() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	config := *c
+	return &config
+}
+
+// SetPattern 设置当前花样，保存配置时写入
+func (c *Config) SetPattern(name string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	c.Pattern = name
+}
+
+// SetSequence 添加自定义序列，已有同名序列时替换
+func (c *Config) SetSequence(seq SequenceConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	root := c.rootConfig()
+	sequences := slices.Clone(root.Sequences)
+	if i := slices.IndexFunc(sequences, func(s SequenceConfig) bool { return s.Name == seq.Name }); i >= 0 {
+		sequences[i] = seq
+	} else {
+		sequences = append(sequences, seq)
+	}
+	root.Sequences = sequences
+}
+
+// DeleteSequence 删除自定义序列，引用该序列的花样设置恢复为默认花样，序列不存在时返回false
+func (c *Config) DeleteSequence(name string) bool {
+	configMu.Lock()
+	defer configMu.Unlock()
+	root := c.rootConfig()
+	i := slices.IndexFunc(root.Sequences, func(s SequenceConfig) bool { return s.Name == name })
+	if i < 0 {
+		return false
+	}
+	root.Sequences = slices.Delete(slices.Clone(root.Sequences), i, i+1)
+	if c.Pattern == name {
+		c.Pattern = ""
+	}
+	if root.Pattern == name {
+		root.Pattern = ""
+	}
+	for j := range root.Devices {
+		if root.Devices[j].Pattern == name {
+			root.Devices[j].Pattern = ""
+		}
+	}
+	return true
+}
+
+// AnalogStaleAfter 返回模拟量读数的过期时长，未配置时使用默认值
+func (c *Config) AnalogStaleAfter() time.Duration {
+	return durationOrDefault(c.AnalogStaleMs, 5000)
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		IP:             "192.168.0.10",
-		Port:           502,
-		UnitID:         1,
-		SpeedDelays:    []int{1000, 500, 200},
-		PollIntervalMs: 200,
-		WindowSize:     []int{800, 600},
-		WindowPosition: []int{100, 100},
+		IP:                  "192.168.0.10",
+		Port:                502,
+		UnitID:              1,
+		SpeedDelays:         []int{1000, 500, 200},
+		PollIntervalMs:      200,
+		ConnectTimeoutMs:    5000,
+		ReadTimeoutMs:       1000,
+		WriteTimeoutMs:      1000,
+		PipelineWindow:      4,
+		ReconnectInitialMs:  500,
+		ReconnectMaxMs:      30000,
+		ReconnectMultiplier: 2,
+		ReconnectJitter:     0.2,
+		WindowSize:          []int{800, 600},
+		WindowPosition:      []int{100, 100},
 	}
 }
 
+// PollInterval 返回状态刷新与输入轮询周期，未配置时使用默认值
+func (c *Config) PollInterval() time.Duration {
+	return durationOrDefault(c.PollIntervalMs, 200)
+}
+
+// ConnectTimeout 返回建立连接超时，未配置时使用默认值
+func (c *Config) ConnectTimeout() time.Duration {
+	return durationOrDefault(c.ConnectTimeoutMs, 5000)
+}
+
+// ReadTimeout 返回单次请求读取响应超时，未配置时使用默认值
+func (c *Config) ReadTimeout() time.Duration {
+	return durationOrDefault(c.ReadTimeoutMs, 1000)
+}
+
+// WriteTimeout 返回单次请求发送超时，未配置时使用默认值
+func (c *Config) WriteTimeout() time.Duration {
+	return durationOrDefault(c.WriteTimeoutMs, 1000)
+}
+
+// 在途事务数上限（MBAP事务ID可区分的范围远大于此，主要受PLC处理能力限制）
+const maxPipelineWindow = 32
+
+// PipelineDepth 返回Modbus TCP最多同时在途的事务数
+// 未配置时为1，即严格的一问一答
+func (c *Config) PipelineDepth() int {
+	switch {
+	case c.PipelineWindow <= 1:
+		return 1
+	case c.PipelineWindow > maxPipelineWindow:
+		return maxPipelineWindow
+	default:
+		return c.PipelineWindow
+	}
+}
+
+// ReconnectInitialDelay 返回首次重连等待时间
+func (c *Config) ReconnectInitialDelay() time.Duration {
+	return durationOrDefault(c.ReconnectInitialMs, 500)
+}
+
+// ReconnectMaxDelay 返回重连等待时间上限
+func (c *Config) ReconnectMaxDelay() time.Duration {
+	return durationOrDefault(c.ReconnectMaxMs, 30000)
+}
+
+// BackoffMultiplier 返回重连等待时间倍数，不大于1时使用默认值
+func (c *Config) BackoffMultiplier() float64 {
+	if c.ReconnectMultiplier <= 1 {
+		return 2
+	}
+	return c.ReconnectMultiplier
+}
+
+// durationOrDefault 将毫秒配置转换为时长，非正值使用默认值
+func durationOrDefault(ms int, defaultMs int) time.Duration {
+	if ms <= 0 {
+		ms = defaultMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
 // LoadConfig 从文件加载配置
 func LoadConfig() (*Config, error) {
+	return loadConfig(defaultConfigPath())
+}
+
+// defaultConfigPath 返回默认配置文件路径：程序目录下的config/config.json
+func defaultConfigPath() string {
 	ex, err := os.Executable()
 	if err != nil {
 		panic(err)
 	}
 	exPath := filepath.Dir(ex)
-	configPath := filepath.Join(exPath, "config", "config.json")
-	
+	return filepath.Join(exPath, "config", "config.json")
+}
+
+// loadConfig 从指定文件加载配置，文件不存在时创建默认配置，之后保存到同一文件
+func loadConfig(configPath string) (*Config, error) {
 	// 检查配置文件是否存在
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// 如果不存在，创建默认配置
@@ -52,7 +384,7 @@ func LoadConfig() (*Config, error) {
 	}
 	
 	// 解析配置
-	config := &Config{}
+	config := &Config{path: configPath}
 	err = json.Unmarshal(data, config)
 	if err != nil {
 		return nil, err
@@ -71,6 +403,7 @@ func createDefaultConfig(configPath string) (*Config, error) {
 	
 	// 获取默认配置
 	config := DefaultConfig()
+	config.path = configPath
 	
 	// 保存默认配置到文件
 	err := saveConfig(config, configPath)
@@ -99,13 +432,32 @@ func saveConfig(config *Config, configPath string) error {
 	return os.WriteFile(configPath, data, 0644)
 }
 
-// SaveConfig 保存配置到默认位置
+// SaveConfig 保存配置到加载时的文件，默认为程序目录下的config/config.json
+// 站点配置将连接参数写回对应的设备条目后保存整体配置
 func (c *Config) SaveConfig() error {
-	ex, err := os.Executable()
-	if err != nil {
-		panic(err)
+	if c.simulated {
+		return nil
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	return c.save()
+}
+
+// save 保存配置，调用方需持有configMu
+func (c *Config) save() error {
+	if c.root != nil {
+		c.device.IP = c.IP
+		c.device.Port = c.Port
+		c.device.UnitID = c.UnitID
+		c.device.Pattern = "" // 与顶层花样相同时不单独保存
+		if c.Pattern != c.root.Pattern {
+			c.device.Pattern = c.Pattern
+		}
+		return c.root.save()
+	}
+	configPath := c.path
+	if configPath == "" {
+		configPath = defaultConfigPath()
 	}
-	exPath := filepath.Dir(ex)
-	configPath := filepath.Join(exPath, "config", "config.json")
 	return saveConfig(c, configPath)
 }
\ No newline at end of file
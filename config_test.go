@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStationConfigs(t *testing.T) {
+	root := DefaultConfig()
+	root.Pattern = "fill"
+	root.Devices = []DeviceConfig{
+		{Name: "Line1"},
+		{IP: "192.168.0.20", Port: 1502, UnitID: 3, Transport: "rtuovertcp", Pattern: "binary", Outputs: &IOConfig{Count: 8}},
+	}
+	stations := root.StationConfigs()
+	if len(stations) != 2 {
+		t.Fatalf("%d stations, want 2", len(stations))
+	}
+
+	// 未设置的字段沿用顶层配置
+	first := stations[0]
+	if first.StationName() != "Line1" || first.IP != root.IP || first.Port != 502 || first.UnitID != 1 || first.Pattern != "fill" || first.Outputs != nil {
+		t.Errorf("first station %s: %s:%d unit %d pattern %q outputs %v", first.StationName(), first.IP, first.Port, first.UnitID, first.Pattern, first.Outputs)
+	}
+
+	second := stations[1]
+	if second.StationName() != "站点2" || second.IP != "192.168.0.20" || second.Port != 1502 || second.UnitID != 3 ||
+		second.Transport != "rtuovertcp" || second.Pattern != "binary" || second.Outputs.Count != 8 {
+		t.Errorf("second station %s: %s:%d unit %d transport %s pattern %q", second.StationName(), second.IP, second.Port, second.UnitID, second.Transport, second.Pattern)
+	}
+	if names := second.LampTagNames(); len(names) != 8 {
+		t.Errorf("second station lamps %v", names)
+	}
+	for _, station := range stations {
+		if station.Devices != nil || station.rootConfig() != root {
+			t.Errorf("station %s not a copy of the root config", station.StationName())
+		}
+	}
+	if root.IP != "192.168.0.10" || root.Pattern != "fill" {
+		t.Errorf("root changed: %s pattern %q", root.IP, root.Pattern)
+	}
+
+	// 未配置devices时返回顶层配置本身
+	single := DefaultConfig()
+	if stations := single.StationConfigs(); len(stations) != 1 || stations[0] != single || single.StationName() != "PLC" {
+		t.Fatalf("single device stations %v, name %s", stations, single.StationName())
+	}
+}
+
+func TestStationConfigSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	const file = `{
+  "ip": "192.168.0.10",
+  "port": 502,
+  "unitId": 1,
+  "pattern": "fill",
+  "speedDelays": [1000, 500],
+  "sequences": [{"name": "wave", "lines": ["frame all", "frame none"]}],
+  "devices": [
+    {"name": "Line1", "ip": "192.168.0.11"},
+    {"name": "Line2", "ip": "192.168.0.12", "port": 1502, "unitId": 2, "outputs": {"count": 8}}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二个站点修改连接参数和花样后保存，写回第二个设备条目
+	stations := config.StationConfigs()
+	stations[1].SetConnection("192.168.0.22", 1503, 7)
+	stations[1].SetPattern("wave")
+	if err := stations[1].SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一个站点的花样与顶层相同，不单独保存
+	stations[0].SetPattern("fill")
+	if err := stations[0].SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.IP != "192.168.0.10" || reloaded.Port != 502 || reloaded.UnitID != 1 || reloaded.Pattern != "fill" {
+		t.Errorf("root after save: %s:%d unit %d pattern %q", reloaded.IP, reloaded.Port, reloaded.UnitID, reloaded.Pattern)
+	}
+	if len(reloaded.Devices) != 2 || len(reloaded.Sequences) != 1 || len(reloaded.SpeedDelays) != 2 {
+		t.Fatalf("reloaded config: %d devices, %d sequences, speeds %v", len(reloaded.Devices), len(reloaded.Sequences), reloaded.SpeedDelays)
+	}
+	if device := reloaded.Devices[0]; device.Name != "Line1" || device.IP != "192.168.0.11" || device.Pattern != "" {
+		t.Errorf("first device %+v", device)
+	}
+	if device := reloaded.Devices[1]; device.Name != "Line2" || device.IP != "192.168.0.22" || device.Port != 1503 ||
+		device.UnitID != 7 || device.Pattern != "wave" || device.Outputs == nil || device.Outputs.Count != 8 {
+		t.Errorf("second device %+v", device)
+	}
+
+	// 重新加载后各站点的参数与保存前一致
+	for i, station := range reloaded.StationConfigs() {
+		before := stations[i]
+		if station.StationName() != before.StationName() || station.IP != before.IP || station.Port != before.Port ||
+			station.UnitID != before.UnitID || station.Pattern != before.Pattern {
+			t.Errorf("station %d after reload %s %s:%d unit %d pattern %q, want %s %s:%d unit %d pattern %q", i,
+				station.StationName(), station.IP, station.Port, station.UnitID, station.Pattern,
+				before.StationName(), before.IP, before.Port, before.UnitID, before.Pattern)
+		}
+	}
+
+	// 单站点配置直接保存顶层连接参数
+	single, err := loadConfig(filepath.Join(t.TempDir(), "config", "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	single.StationConfigs()[0].SetConnection("10.0.0.1", 503, 4)
+	if err := single.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err = loadConfig(single.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.IP != "10.0.0.1" || reloaded.Port != 503 || reloaded.UnitID != 4 || len(reloaded.Devices) != 0 {
+		t.Errorf("single device config after save: %s:%d unit %d, %d devices", reloaded.IP, reloaded.Port, reloaded.UnitID, len(reloaded.Devices))
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ConnectionState 连接状态
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota // 未连接
+	StateConnecting                          // 连接中
+	StateConnected                           // 已连接
+	StateBackoff                             // 等待重连
+)
+
+// String 返回状态名称
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "Disconnected"
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateBackoff:
+		return "Backoff"
+	default:
+		return "Unknown"
+	}
+}
+
+// Label 返回界面显示的状态文字
+func (s ConnectionState) Label() string {
+	switch s {
+	case StateConnecting:
+		return "连接中"
+	case StateConnected:
+		return "已连接"
+	case StateBackoff:
+		return "等待重连"
+	default:
+		return "未连接"
+	}
+}
+
+// ConnectionEvent 连接状态变化事件
+type ConnectionEvent struct {
+	State          ConnectionState
+	Previous       ConnectionState
+	Err            error // 最近一次连接错误
+	ReconnectCount int   // 断线后成功重连的次数
+	Time           time.Time
+}
+
+// ConnectionSupervisor 连接监督器，负责断线检测与指数退避重连
+type ConnectionSupervisor struct {
+	client *ModbusClient
+	config *Config
+
+	mu             sync.Mutex
+	state          ConnectionState
+	lastErr        error
+	reconnectCount int
+	failures       int  // 连续连接失败次数，决定下一次退避等待时间
+	enabled        bool // 是否需要保持连接
+	hadConnection  bool // 是否曾经连接成功，用于统计重连次数
+	subscribers    []chan ConnectionEvent
+
+	dialMu     sync.Mutex         // 串行化连接尝试
+	dialCancel context.CancelFunc // 取消进行中的连接尝试，受mu保护
+	wake       chan struct{}
+	stopChan   chan struct{} // Stop时关闭
+}
+
+// NewConnectionSupervisor 创建新的连接监督器
+func NewConnectionSupervisor(client *ModbusClient, config *Config) *ConnectionSupervisor {
+	return &ConnectionSupervisor{
+		client:   client,
+		config:   config,
+		state:    StateDisconnected,
+		wake:     make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 启动监督循环
+func (s *ConnectionSupervisor) Start() {
+	go s.run()
+}
+
+// Stop 停止监督循环并关闭所有订阅通道，只能调用一次
+// 关闭而非发送stopChan，监督循环已退出时也不会阻塞
+func (s *ConnectionSupervisor) Stop() {
+	close(s.stopChan)
+
+	s.mu.Lock()
+	for _, ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = nil
+	s.mu.Unlock()
+}
+
+// Subscribe 订阅连接状态变化事件
+// 订阅后立即收到一次当前状态，消费过慢时事件会被丢弃
+func (s *ConnectionSupervisor) Subscribe() <-chan ConnectionEvent {
+	ch := make(chan ConnectionEvent, 16)
+
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, ch)
+	ch <- s.eventLocked(s.state)
+	s.mu.Unlock()
+
+	return ch
+}
+
+// Connect 立即尝试连接，失败后由监督循环按退避策略继续重试
+func (s *ConnectionSupervisor) Connect() error {
+	s.mu.Lock()
+	s.enabled = true
+	s.mu.Unlock()
+
+	err := s.dial(false)
+	s.notify()
+	return err
+}
+
+// Reconnect 断开现有连接并按当前连接参数重新连接，用于修改IP、端口或Unit ID后生效
+// 失败后同样由监督循环继续重试
+func (s *ConnectionSupervisor) Reconnect() error {
+	s.mu.Lock()
+	s.enabled = true
+	s.mu.Unlock()
+
+	err := s.dial(true)
+	s.notify()
+	return err
+}
+
+// Disconnect 主动断开连接并停止重连
+// 取消进行中的连接尝试而不等待其完成；连接尝试成功后见enabled为false会自行关闭连接
+func (s *ConnectionSupervisor) Disconnect() error {
+	s.mu.Lock()
+	s.enabled = false
+	cancel := s.dialCancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	err := s.client.Close()
+
+	s.notify()
+	return err
+}
+
+// State 获取当前连接状态
+func (s *ConnectionSupervisor) State() ConnectionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// LastError 获取最近一次连接错误
+func (s *ConnectionSupervisor) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// ReconnectCount 获取断线后成功重连的次数
+func (s *ConnectionSupervisor) ReconnectCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reconnectCount
+}
+
+// run 监督主循环
+func (s *ConnectionSupervisor) run() {
+	for {
+		s.mu.Lock()
+		enabled := s.enabled
+		if !enabled {
+			s.failures = 0
+		}
+		s.mu.Unlock()
+
+		// 未要求连接时保持空闲
+		if !enabled {
+			s.setState(StateDisconnected)
+			select {
+			case <-s.wake:
+				continue
+			case <-s.stopChan:
+				return
+			}
+		}
+
+		// 已连接时等待断线通知
+		if s.client.IsConnected() {
+			s.setState(StateConnected)
+			select {
+			case err := <-s.client.Disconnected():
+				log.Printf("PLC连接断开: %v", err)
+				s.setError(err)
+			case <-s.wake:
+			case <-s.stopChan:
+				return
+			}
+			continue
+		}
+
+		// 尝试连接，失败后退避等待
+		if err := s.dial(false); err != nil {
+			s.mu.Lock()
+			delay := s.backoff(s.failures)
+			s.failures++
+			s.mu.Unlock()
+			log.Printf("PLC连接失败: %v，%v后重试", err, delay)
+			s.setState(StateBackoff)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-s.wake:
+				timer.Stop()
+			case <-s.stopChan:
+				timer.Stop()
+				return
+			}
+		}
+	}
+}
+
+// dial 执行一次连接尝试，已主动断开时不再连接
+// force为false时已连接则直接返回，为true时关闭现有连接后重新连接
+func (s *ConnectionSupervisor) dial(force bool) error {
+	s.dialMu.Lock()
+	defer s.dialMu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.mu.Lock()
+	connected := s.client.IsConnected()
+	if !s.enabled || (connected && !force) {
+		s.mu.Unlock()
+		return nil
+	}
+	s.dialCancel = cancel
+	s.mu.Unlock()
+
+	s.setState(StateConnecting)
+	err := s.client.ReconnectCtx(ctx)
+
+	s.mu.Lock()
+	s.dialCancel = nil
+	if !s.enabled {
+		// 连接期间已主动断开：放弃结果，成功建立的连接立即关闭
+		s.mu.Unlock()
+		s.client.Close()
+		return nil
+	}
+	if err != nil {
+		s.mu.Unlock()
+		s.setError(err)
+		return err
+	}
+	if s.hadConnection && !connected { // 主动切换连接不计入断线重连
+		s.reconnectCount++
+	}
+	s.hadConnection = true
+	s.failures = 0
+	s.lastErr = nil
+	s.mu.Unlock()
+
+	s.setState(StateConnected)
+	return nil
+}
+
+// backoff 计算第attempt次失败后的等待时间（指数退避加随机抖动）
+func (s *ConnectionSupervisor) backoff(attempt int) time.Duration {
+	delay := float64(s.config.ReconnectInitialDelay())
+	maxDelay := float64(s.config.ReconnectMaxDelay())
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= s.config.BackoffMultiplier()
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	// 抖动范围为 ±jitter
+	if jitter := s.config.ReconnectJitter; jitter > 0 {
+		delay *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// notify 唤醒监督循环
+func (s *ConnectionSupervisor) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// setError 记录最近一次连接错误
+func (s *ConnectionSupervisor) setError(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// setState 更新连接状态，状态变化时发布事件
+func (s *ConnectionSupervisor) setState(state ConnectionState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == state {
+		return
+	}
+
+	event := s.eventLocked(s.state)
+	event.State = state
+	s.state = state
+
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// eventLocked 构造当前状态事件，调用方需持有mu
+func (s *ConnectionSupervisor) eventLocked(previous ConnectionState) ConnectionEvent {
+	return ConnectionEvent{
+		State:          s.state,
+		Previous:       previous,
+		Err:            s.lastErr,
+		ReconnectCount: s.reconnectCount,
+		Time:           time.Now(),
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// fastReconnect 缩短重连等待时间并关闭抖动，便于测量
+func fastReconnect(config *Config) *Config {
+	config.ReconnectInitialMs = 20
+	config.ReconnectMaxMs = 2000
+	config.ReconnectMultiplier = 4
+	config.ReconnectJitter = 0
+	return config
+}
+
+// startTestSupervisor 为config创建并启动连接监督器，返回订阅的事件通道
+func startTestSupervisor(t *testing.T, config *Config) (*ConnectionSupervisor, *ModbusClient, <-chan ConnectionEvent) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	client := NewModbusClient(config)
+	supervisor := NewConnectionSupervisor(client, config)
+	events := supervisor.Subscribe()
+	supervisor.Start()
+	t.Cleanup(func() { client.Close() })
+	return supervisor, client, events
+}
+
+// waitState 读取事件直到进入指定状态
+func waitState(t *testing.T, events <-chan ConnectionEvent, state ConnectionState) ConnectionEvent {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("event channel closed while waiting for %v", state)
+			}
+			if event.State == state {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v", state)
+		}
+	}
+}
+
+// nextRetryDelay 测量一次退避的实际等待时间：从进入Backoff到下一次Connecting
+func nextRetryDelay(t *testing.T, events <-chan ConnectionEvent) time.Duration {
+	t.Helper()
+	backoff := waitState(t, events, StateBackoff)
+	connecting := waitState(t, events, StateConnecting)
+	return connecting.Time.Sub(backoff.Time)
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	config := DefaultConfig()
+	config.ReconnectInitialMs = 100
+	config.ReconnectMaxMs = 1000
+	config.ReconnectMultiplier = 2
+	config.ReconnectJitter = 0
+	supervisor := NewConnectionSupervisor(nil, config)
+
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for attempt, ms := range want {
+		if got := supervisor.backoff(attempt); got != ms*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, ms*time.Millisecond)
+		}
+	}
+
+	// 抖动不超过±jitter
+	config.ReconnectJitter = 0.2
+	for range 100 {
+		if got := supervisor.backoff(1); got < 160*time.Millisecond || got > 240*time.Millisecond {
+			t.Fatalf("backoff(1) with 20%% jitter = %v, want 160ms-240ms", got)
+		}
+	}
+}
+
+func TestSupervisorEvents(t *testing.T) {
+	server := startFakeRegisterServer(t)
+	supervisor, client, events := startTestSupervisor(t, fastReconnect(server.clientConfig()))
+	t.Cleanup(supervisor.Stop)
+
+	if event := waitState(t, events, StateDisconnected); event.ReconnectCount != 0 {
+		t.Fatalf("initial reconnect count %d", event.ReconnectCount)
+	}
+	if err := supervisor.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, events, StateConnecting)
+	if event := waitState(t, events, StateConnected); event.ReconnectCount != 0 {
+		t.Fatalf("first connect counted as reconnect: %d", event.ReconnectCount)
+	}
+
+	// 链路中断在下一次请求时被发现，监督器自动重连
+	// 先完成一次请求，确保服务器已登记该连接
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	server.dropConns()
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("request on dropped connection succeeded")
+	}
+	if connecting := waitState(t, events, StateConnecting); connecting.Previous != StateConnected {
+		t.Fatalf("break event: previous %v, want Connected", connecting.Previous)
+	}
+	reconnected := waitState(t, events, StateConnected)
+	if reconnected.ReconnectCount != 1 || reconnected.Err != nil {
+		t.Fatalf("reconnect event: count %d, err %v", reconnected.ReconnectCount, reconnected.Err)
+	}
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("request after reconnect: %v", err)
+	}
+
+	if err := supervisor.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, events, StateDisconnected)
+}
+
+func TestSupervisorBackoffGrowsAndResets(t *testing.T) {
+	server := startFakeRegisterServer(t)
+	addr := server.listener.Addr().String()
+	supervisor, client, events := startTestSupervisor(t, fastReconnect(server.clientConfig()))
+	t.Cleanup(supervisor.Stop)
+
+	if err := supervisor.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, events, StateConnected)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务器下线后等待时间按倍数增长：20ms、80ms、320ms
+	// Connect留下的唤醒信号可能提前结束第一次等待，只测量之后的两次
+	server.stop()
+	client.ReadHoldingRegisters(0, 1)
+	nextRetryDelay(t, events)
+	for _, want := range []time.Duration{80, 320} {
+		want *= time.Millisecond
+		if got := nextRetryDelay(t, events); got < want*9/10 || got > want*3 {
+			t.Fatalf("retry delay %v, want about %v", got, want)
+		}
+	}
+
+	// 服务器恢复后连接成功，连续失败次数清零
+	server = startFakeRegisterServerAt(t, addr)
+	if err := supervisor.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, events, StateConnected)
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未清零时第二次等待应为1280ms以上
+	server.stop()
+	client.ReadHoldingRegisters(0, 1)
+	nextRetryDelay(t, events)
+	if got := nextRetryDelay(t, events); got > 200*time.Millisecond {
+		t.Fatalf("second retry after reconnect waited %v, want 80ms", got)
+	}
+}
+
+func TestSupervisorStopDuringBackoff(t *testing.T) {
+	server := startFakeRegisterServer(t)
+	config := fastReconnect(server.clientConfig())
+	config.ReconnectInitialMs = 10000 // 保证Stop时正处于退避等待
+	server.stop()
+	supervisor, _, events := startTestSupervisor(t, config)
+
+	if err := supervisor.Connect(); err == nil {
+		t.Fatal("connect to stopped server succeeded")
+	}
+	waitState(t, events, StateBackoff)
+
+	stopped := make(chan struct{})
+	go func() {
+		supervisor.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked during backoff")
+	}
+
+	// Stop关闭所有订阅通道
+	for range events {
+	}
+}
+
+func TestSupervisorDisconnectDuringDial(t *testing.T) {
+	config := fastReconnect(DefaultConfig())
+	config.IP, config.Port = "192.0.2.1", 502 // TEST-NET-1，不可路由，连接尝试挂起到超时
+	config.ConnectTimeoutMs = 5000
+	supervisor, _, events := startTestSupervisor(t, config)
+	t.Cleanup(supervisor.Stop)
+
+	connected := make(chan error, 1)
+	go func() { connected <- supervisor.Connect() }()
+	waitState(t, events, StateConnecting)
+
+	start := time.Now()
+	if err := supervisor.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-connected:
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Connect returned %v after %v", err, elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("in-flight dial not cancelled by Disconnect")
+	}
+	if supervisor.client.IsConnected() {
+		t.Fatal("client connected after Disconnect")
+	}
+}
+
+func TestSupervisorReconnectWithChangedPort(t *testing.T) {
+	first := startFakeRegisterServer(t)
+	second := startFakeRegisterServer(t)
+	config := fastReconnect(first.clientConfig())
+	supervisor, client, events := startTestSupervisor(t, config)
+	t.Cleanup(supervisor.Stop)
+
+	if err := supervisor.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, events, StateConnected)
+
+	// 请求与修改连接参数并发进行，配合-race检查连接参数的数据竞争
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 20 {
+			client.ReadHoldingRegisters(0, 1)
+		}
+	}()
+	ip, _, _ := config.Connection()
+	port := second.listener.Addr().(*net.TCPAddr).Port
+	if !config.SetConnection(ip, port, 7) {
+		t.Fatal("changed connection reported unchanged")
+	}
+	if config.SetConnection(ip, port, 7) {
+		t.Fatal("unchanged connection reported changed")
+	}
+	<-done
+
+	// 新参数在重新连接前不影响现有连接
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	first.mu.Lock()
+	unitID := first.unitID
+	first.mu.Unlock()
+	if unitID != 1 {
+		t.Fatalf("unit ID before reconnect %d, want 1", unitID)
+	}
+
+	if err := supervisor.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("request after reconnect: %v", err)
+	}
+	first.mu.Lock()
+	firstRequests := first.requests
+	first.mu.Unlock()
+	second.mu.Lock()
+	secondRequests, unitID := second.requests, second.unitID
+	second.mu.Unlock()
+	if secondRequests != 1 || unitID != 7 {
+		t.Fatalf("new server: %d requests, unit ID %d, want 1 request to unit 7", secondRequests, unitID)
+	}
+	if firstRequests != 21 {
+		t.Fatalf("old server got %d requests, want 21", firstRequests)
+	}
+	if supervisor.State() != StateConnected || supervisor.ReconnectCount() != 0 {
+		t.Fatalf("after reconnect: state %v, reconnect count %d", supervisor.State(), supervisor.ReconnectCount())
+	}
+}
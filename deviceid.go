@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// 封装接口传输 (功能码 0x2B) 常量
+const (
+	meiReadDeviceID = 0x0E // MEI类型：读设备标识
+
+	maxDeviceIDRequests = 64 // 流式读取的最大请求次数，防止设备返回错误的续读标志导致死循环
+)
+
+// DeviceIDCode 读设备标识的访问类型
+type DeviceIDCode byte
+
+// 访问类型
+const (
+	DeviceIDBasic    DeviceIDCode = 0x01 // 基本对象 (0x00-0x02)，流式读取
+	DeviceIDRegular  DeviceIDCode = 0x02 // 常规对象 (0x03-0x7F)，流式读取
+	DeviceIDExtended DeviceIDCode = 0x03 // 扩展对象 (0x80-0xFF)，流式读取
+	DeviceIDSpecific DeviceIDCode = 0x04 // 单个对象
+)
+
+// 标准对象ID
+const (
+	DeviceObjectVendorName          = 0x00 // 厂商名称
+	DeviceObjectProductCode         = 0x01 // 产品代码（订货号）
+	DeviceObjectMajorMinorRevision  = 0x02 // 版本号
+	DeviceObjectVendorURL           = 0x03 // 厂商网址
+	DeviceObjectProductName         = 0x04 // 产品名称
+	DeviceObjectModelName           = 0x05 // 型号
+	DeviceObjectUserApplicationName = 0x06 // 用户应用名称
+)
+
+// 标准对象名称
+var deviceObjectNames = map[byte]string{
+	DeviceObjectVendorName:          "VendorName",
+	DeviceObjectProductCode:         "ProductCode",
+	DeviceObjectMajorMinorRevision:  "MajorMinorRevision",
+	DeviceObjectVendorURL:           "VendorUrl",
+	DeviceObjectProductName:         "ProductName",
+	DeviceObjectModelName:           "ModelName",
+	DeviceObjectUserApplicationName: "UserApplicationName",
+}
+
+// DeviceObjectName 返回对象名称，扩展对象返回十六进制ID
+func DeviceObjectName(id byte) string {
+	if name, ok := deviceObjectNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("Object0x%02X", id)
+}
+
+// DeviceIdentification 设备标识
+type DeviceIdentification struct {
+	ConformityLevel byte            // 一致性等级，bit7表示支持单个对象访问
+	Objects         map[byte]string // 对象ID -> 值
+}
+
+// VendorName 返回厂商名称
+func (d *DeviceIdentification) VendorName() string {
+	return d.Objects[DeviceObjectVendorName]
+}
+
+// ProductCode 返回产品代码
+func (d *DeviceIdentification) ProductCode() string {
+	return d.Objects[DeviceObjectProductCode]
+}
+
+// Revision 返回版本号
+func (d *DeviceIdentification) Revision() string {
+	return d.Objects[DeviceObjectMajorMinorRevision]
+}
+
+// ObjectIDs 返回按ID排序的对象列表
+func (d *DeviceIdentification) ObjectIDs() []byte {
+	ids := make([]byte, 0, len(d.Objects))
+	for id := range d.Objects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// deviceIDResponse 单个读设备标识响应
+type deviceIDResponse struct {
+	conformityLevel byte
+	moreFollows     bool
+	nextObjectID    byte
+	objects         map[byte]string
+}
+
+// ReadDeviceIdentification 读设备标识 (功能码 0x2B / MEI 0x0E)
+// 按访问类型流式读取该类别及以下的全部对象，设备分多次返回时自动续读
+func (m *ModbusClient) ReadDeviceIdentification(code DeviceIDCode) (*DeviceIdentification, error) {
+	return m.ReadDeviceIdentificationCtx(context.Background(), code)
+}
+
+// ReadDeviceIdentificationCtx 读设备标识，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadDeviceIdentificationCtx(ctx context.Context, code DeviceIDCode) (*DeviceIdentification, error) {
+	if code < DeviceIDBasic || code > DeviceIDExtended {
+		return nil, fmt.Errorf("invalid stream access code: %d", code)
+	}
+
+	ident := &DeviceIdentification{Objects: make(map[byte]string)}
+	objectID := byte(0x00)
+	for i := 0; i < maxDeviceIDRequests; i++ {
+		resp, err := m.readDeviceID(ctx, code, objectID)
+		if err != nil {
+			return nil, err
+		}
+
+		ident.ConformityLevel = resp.conformityLevel
+		for id, value := range resp.objects {
+			ident.Objects[id] = value
+		}
+		if !resp.moreFollows {
+			return ident, nil
+		}
+
+		// 续读对象ID必须前进，否则视为设备响应错误
+		if resp.nextObjectID <= objectID {
+			return nil, &FrameError{Reason: fmt.Sprintf("device identification next object ID 0x%02X does not advance", resp.nextObjectID)}
+		}
+		objectID = resp.nextObjectID
+	}
+	return nil, fmt.Errorf("device identification did not complete after %d requests", maxDeviceIDRequests)
+}
+
+// ReadDeviceObject 读取单个设备标识对象
+func (m *ModbusClient) ReadDeviceObject(objectID byte) (string, error) {
+	return m.ReadDeviceObjectCtx(context.Background(), objectID)
+}
+
+// ReadDeviceObjectCtx 读取单个设备标识对象，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadDeviceObjectCtx(ctx context.Context, objectID byte) (string, error) {
+	resp, err := m.readDeviceID(ctx, DeviceIDSpecific, objectID)
+	if err != nil {
+		return "", err
+	}
+	value, ok := resp.objects[objectID]
+	if !ok {
+		return "", &FrameError{Reason: fmt.Sprintf("device identification object 0x%02X missing in response", objectID)}
+	}
+	return value, nil
+}
+
+// readDeviceID 发送一次读设备标识请求
+func (m *ModbusClient) readDeviceID(ctx context.Context, code DeviceIDCode, objectID byte) (*deviceIDResponse, error) {
+	pdu := []byte{
+		0x2B,            // 功能码
+		meiReadDeviceID, // MEI类型
+		byte(code),      // 访问类型
+		objectID,        // 起始对象ID
+	}
+
+	resp, err := m.sendAndReceive(ctx, pdu)
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceIDResponse(resp, code)
+}
+
+// parseDeviceIDResponse 解析读设备标识响应
+// 格式：功能码 + MEI类型 + 访问类型 + 一致性等级 + 续读标志 + 下一对象ID + 对象数 + (ID + 长度 + 值)*
+func parseDeviceIDResponse(resp []byte, code DeviceIDCode) (*deviceIDResponse, error) {
+	if len(resp) < 7 {
+		return nil, &FrameError{Reason: fmt.Sprintf("device identification response too short: %d bytes", len(resp))}
+	}
+	if resp[1] != meiReadDeviceID {
+		return nil, &FrameError{Reason: fmt.Sprintf("MEI type mismatch: expected 0x%02X, got 0x%02X", meiReadDeviceID, resp[1])}
+	}
+	if DeviceIDCode(resp[2]) != code {
+		return nil, &FrameError{Reason: fmt.Sprintf("read device ID code mismatch: expected %d, got %d", code, resp[2])}
+	}
+
+	result := &deviceIDResponse{
+		conformityLevel: resp[3],
+		moreFollows:     resp[4] == 0xFF,
+		nextObjectID:    resp[5],
+		objects:         make(map[byte]string),
+	}
+
+	count := int(resp[6])
+	data := resp[7:]
+	for i := 0; i < count; i++ {
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return nil, &FrameError{Reason: fmt.Sprintf("device identification object %d truncated", i)}
+		}
+		id, length := data[0], int(data[1])
+		result.objects[id] = string(data[2 : 2+length])
+		data = data[2+length:]
+	}
+
+	return result, nil
+}
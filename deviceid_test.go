@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// simServerMessages 读取仿真器的本站报文计数
+func simServerMessages(sim *Simulator) uint16 {
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+	return sim.diagCounters[DiagServerMessageCount-DiagBusMessageCount]
+}
+
+func TestReadDeviceIdentificationRoundTrip(t *testing.T) {
+	sim, client := startTestSimulator(t, 14)
+	objects := sim.config.DeviceObjects
+
+	// 仿真器每个响应最多返回simMaxDeviceObjects个对象，其余通过续读标志分次读取
+	tests := []struct {
+		code     DeviceIDCode
+		objects  int
+		requests uint16
+	}{
+		{DeviceIDBasic, 3, 1},
+		{DeviceIDRegular, 7, 3},
+		{DeviceIDExtended, 8, 3},
+	}
+	for _, tt := range tests {
+		before := simServerMessages(sim)
+		ident, err := client.ReadDeviceIdentification(tt.code)
+		if err != nil {
+			t.Fatalf("code %d: %v", tt.code, err)
+		}
+		if requests := simServerMessages(sim) - before; requests != tt.requests {
+			t.Errorf("code %d: %d requests, want %d", tt.code, requests, tt.requests)
+		}
+		if ident.ConformityLevel != 0x83 {
+			t.Errorf("code %d: conformity level 0x%02X, want 0x83", tt.code, ident.ConformityLevel)
+		}
+		if len(ident.Objects) != tt.objects {
+			t.Errorf("code %d: %d objects, want %d", tt.code, len(ident.Objects), tt.objects)
+		}
+		for id, value := range ident.Objects {
+			if value != objects[id] {
+				t.Errorf("code %d: object 0x%02X = %q, want %q", tt.code, id, value, objects[id])
+			}
+		}
+	}
+
+	ident, err := client.ReadDeviceIdentification(DeviceIDBasic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ident.VendorName() != objects[DeviceObjectVendorName] || ident.ProductCode() != objects[DeviceObjectProductCode] || ident.Revision() != objects[DeviceObjectMajorMinorRevision] {
+		t.Errorf("basic objects = %q %q %q", ident.VendorName(), ident.ProductCode(), ident.Revision())
+	}
+
+	value, err := client.ReadDeviceObject(0x80)
+	if err != nil || value != "MB_SERVER" {
+		t.Fatalf("ReadDeviceObject(0x80) = %q, %v", value, err)
+	}
+	_, err = client.ReadDeviceObject(0x7F)
+	var exception *ModbusException
+	if !errors.As(err, &exception) || exception.ExceptionCode != 0x02 {
+		t.Fatalf("ReadDeviceObject(0x7F): got %v, want illegal data address", err)
+	}
+}
+
+func TestParseDeviceIDResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    []byte
+		wantErr bool
+		more    bool
+		next    byte
+	}{
+		{"complete", []byte{0x2B, 0x0E, 0x01, 0x81, 0x00, 0x00, 0x01, 0x00, 0x02, 'A', 'B'}, false, false, 0},
+		{"more follows", []byte{0x2B, 0x0E, 0x01, 0x81, 0xFF, 0x02, 0x01, 0x00, 0x01, 'A'}, false, true, 0x02},
+		{"too short", []byte{0x2B, 0x0E, 0x01}, true, false, 0},
+		{"MEI mismatch", []byte{0x2B, 0x0D, 0x01, 0x81, 0x00, 0x00, 0x00}, true, false, 0},
+		{"code mismatch", []byte{0x2B, 0x0E, 0x02, 0x81, 0x00, 0x00, 0x00}, true, false, 0},
+		{"object truncated", []byte{0x2B, 0x0E, 0x01, 0x81, 0x00, 0x00, 0x01, 0x00, 0x05, 'A'}, true, false, 0},
+	}
+	for _, tt := range tests {
+		resp, err := parseDeviceIDResponse(tt.resp, DeviceIDBasic)
+		if tt.wantErr {
+			var frameErr *FrameError
+			if !errors.As(err, &frameErr) {
+				t.Errorf("%s: got %v, want FrameError", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if resp.conformityLevel != 0x81 || resp.moreFollows != tt.more || resp.nextObjectID != tt.next {
+			t.Errorf("%s: got %+v", tt.name, resp)
+		}
+	}
+}
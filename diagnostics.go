@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// 诊断 (功能码 0x08) 子功能码
+const (
+	DiagReturnQueryData          uint16 = 0x0000 // 回送查询数据
+	DiagReturnDiagnosticRegister uint16 = 0x0002 // 读诊断寄存器
+	DiagClearCounters            uint16 = 0x000A // 清除计数器和诊断寄存器
+	DiagBusMessageCount          uint16 = 0x000B // 总线报文计数
+	DiagBusCommErrorCount        uint16 = 0x000C // 总线通信错误计数（CRC/LRC错误）
+	DiagBusExceptionCount        uint16 = 0x000D // 异常响应计数
+	DiagServerMessageCount       uint16 = 0x000E // 本站报文计数
+	DiagServerNoResponseCount    uint16 = 0x000F // 未响应报文计数（广播）
+	DiagServerNAKCount           uint16 = 0x0010 // NAK计数
+	DiagServerBusyCount          uint16 = 0x0011 // 忙计数
+	DiagBusCharacterOverrunCount uint16 = 0x0012 // 字符溢出计数
+)
+
+// DiagnosticCounters 诊断计数器
+type DiagnosticCounters struct {
+	BusMessages         uint16 `json:"busMessages"`
+	BusCommErrors       uint16 `json:"busCommErrors"`
+	BusExceptions       uint16 `json:"busExceptions"`
+	ServerMessages      uint16 `json:"serverMessages"`
+	ServerNoResponses   uint16 `json:"serverNoResponses"`
+	ServerNAKs          uint16 `json:"serverNAKs"`
+	ServerBusy          uint16 `json:"serverBusy"`
+	BusCharacterOverrun uint16 `json:"busCharacterOverrun"`
+}
+
+// Diagnostic 发送诊断请求 (功能码 0x08)，返回响应中子功能码之后的数据
+func (m *ModbusClient) Diagnostic(subFunction uint16, data []byte) ([]byte, error) {
+	return m.DiagnosticCtx(context.Background(), subFunction, data)
+}
+
+// DiagnosticCtx 发送诊断请求，ctx取消或到期时中止请求
+func (m *ModbusClient) DiagnosticCtx(ctx context.Context, subFunction uint16, data []byte) ([]byte, error) {
+	pdu := make([]byte, 3+len(data))
+	pdu[0] = 0x08                                     // 功能码
+	binary.BigEndian.PutUint16(pdu[1:3], subFunction) // 子功能码
+	copy(pdu[3:], data)                               // 数据
+
+	resp, err := m.sendAndReceive(ctx, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	// 响应回显子功能码
+	if len(resp) < 3 {
+		return nil, &FrameError{Reason: fmt.Sprintf("diagnostic response too short: %d bytes", len(resp))}
+	}
+	if echoed := binary.BigEndian.Uint16(resp[1:3]); echoed != subFunction {
+		return nil, &FrameError{Reason: fmt.Sprintf("diagnostic sub-function mismatch: expected 0x%04X, got 0x%04X", subFunction, echoed)}
+	}
+	return resp[3:], nil
+}
+
+// ReturnQueryData 回送测试，校验设备原样返回请求数据
+func (m *ModbusClient) ReturnQueryData(data []byte) error {
+	return m.ReturnQueryDataCtx(context.Background(), data)
+}
+
+// ReturnQueryDataCtx 回送测试，ctx取消或到期时中止请求
+func (m *ModbusClient) ReturnQueryDataCtx(ctx context.Context, data []byte) error {
+	echo, err := m.DiagnosticCtx(ctx, DiagReturnQueryData, data)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(echo, data) {
+		return fmt.Errorf("diagnostic echo mismatch: sent % X, got % X", data, echo)
+	}
+	return nil
+}
+
+// ClearDiagnosticCounters 清除诊断计数器和诊断寄存器
+func (m *ModbusClient) ClearDiagnosticCounters() error {
+	return m.ClearDiagnosticCountersCtx(context.Background())
+}
+
+// ClearDiagnosticCountersCtx 清除诊断计数器，ctx取消或到期时中止请求
+func (m *ModbusClient) ClearDiagnosticCountersCtx(ctx context.Context) error {
+	_, err := m.DiagnosticCtx(ctx, DiagClearCounters, []byte{0x00, 0x00})
+	return err
+}
+
+// ReadDiagnosticCounter 读取单个诊断计数器或诊断寄存器
+func (m *ModbusClient) ReadDiagnosticCounter(subFunction uint16) (uint16, error) {
+	return m.ReadDiagnosticCounterCtx(context.Background(), subFunction)
+}
+
+// ReadDiagnosticCounterCtx 读取单个诊断计数器，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadDiagnosticCounterCtx(ctx context.Context, subFunction uint16) (uint16, error) {
+	data, err := m.DiagnosticCtx(ctx, subFunction, []byte{0x00, 0x00})
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 2 {
+		return 0, &FrameError{Reason: fmt.Sprintf("diagnostic counter length %d", len(data))}
+	}
+	return binary.BigEndian.Uint16(data), nil
+}
+
+// ReadDiagnosticCounters 读取全部诊断计数器
+func (m *ModbusClient) ReadDiagnosticCounters() (*DiagnosticCounters, error) {
+	return m.ReadDiagnosticCountersCtx(context.Background())
+}
+
+// ReadDiagnosticCountersCtx 读取全部诊断计数器，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadDiagnosticCountersCtx(ctx context.Context) (*DiagnosticCounters, error) {
+	counters := &DiagnosticCounters{}
+	fields := []struct {
+		subFunction uint16
+		value       *uint16
+	}{
+		{DiagBusMessageCount, &counters.BusMessages},
+		{DiagBusCommErrorCount, &counters.BusCommErrors},
+		{DiagBusExceptionCount, &counters.BusExceptions},
+		{DiagServerMessageCount, &counters.ServerMessages},
+		{DiagServerNoResponseCount, &counters.ServerNoResponses},
+		{DiagServerNAKCount, &counters.ServerNAKs},
+		{DiagServerBusyCount, &counters.ServerBusy},
+		{DiagBusCharacterOverrunCount, &counters.BusCharacterOverrun},
+	}
+
+	for _, field := range fields {
+		value, err := m.ReadDiagnosticCounterCtx(ctx, field.subFunction)
+		if err != nil {
+			return nil, err
+		}
+		*field.value = value
+	}
+	return counters, nil
+}
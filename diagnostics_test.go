@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiagnosticsRoundTrip(t *testing.T) {
+	_, client := startTestSimulator(t, 14)
+
+	if err := client.ReturnQueryData([]byte{0xA5, 0x37, 0x00}); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	if err := client.ClearDiagnosticCounters(); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+
+	// 一次正常请求和一次异常响应
+	if _, err := client.ReadCoils(0, 14); err != nil {
+		t.Fatal(err)
+	}
+	var exception *ModbusException
+	if _, err := client.ReadCoils(100, 1); !errors.As(err, &exception) {
+		t.Fatalf("out-of-range read: got %v, want exception", err)
+	}
+
+	counters, err := client.ReadDiagnosticCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 清除之后：两次读线圈、之前的三个计数器请求，以及读取本计数器的请求本身
+	if counters.BusExceptions != 1 {
+		t.Errorf("bus exceptions = %d, want 1", counters.BusExceptions)
+	}
+	if counters.ServerMessages != 6 {
+		t.Errorf("server messages = %d, want 6", counters.ServerMessages)
+	}
+	if counters.BusCommErrors != 0 || counters.ServerNoResponses != 0 {
+		t.Errorf("unexpected error counters: %+v", counters)
+	}
+
+	// 不支持的子功能返回非法功能异常
+	if _, err := client.Diagnostic(0x0004, []byte{0x00, 0x00}); !errors.As(err, &exception) || exception.ExceptionCode != 0x01 {
+		t.Fatalf("unsupported sub-function: got %v, want illegal function", err)
+	}
+}
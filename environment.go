@@ -1,181 +1,107 @@
 package main
 
 import (
-	"encoding/binary"
-	"math"
-	"strconv"
+	"context"
+	"fmt"
+	"sync"
 	"time"
 )
 
-// EnvironmentMonitor 环境监测器
+// EnvironmentMonitor 环境监测器，读取模拟量通道并保存各通道最后读数
+// 本身不轮询：站点状态刷新循环每轮调用一次ReadChannels，并将同一组读数分发给界面、调速、报警和历史记录
 type EnvironmentMonitor struct {
-	client   *ModbusClient
-	ui       *WebUI
-	stopChan chan bool
+	tags       *TagClient
+	channels   []AnalogChannel // 模拟量通道
+	staleAfter time.Duration   // 读数超过该时长未更新即标记为过期
+
+	mu   sync.Mutex
+	last []AnalogReading // 各通道最后读数
 }
 
 // NewEnvironmentMonitor 创建新的环境监测器
-func NewEnvironmentMonitor(client *ModbusClient, ui *WebUI) *EnvironmentMonitor {
-	return &EnvironmentMonitor{
-		client:   client,
-		ui:       ui,
-		stopChan: make(chan bool),
+func NewEnvironmentMonitor(tags *TagClient, channels []AnalogChannel, staleAfter time.Duration) *EnvironmentMonitor {
+	em := &EnvironmentMonitor{
+		tags:       tags,
+		channels:   channels,
+		staleAfter: staleAfter,
+		last:       make([]AnalogReading, len(channels)),
 	}
-}
-
-// Start 开始环境数据轮询
-func (em *EnvironmentMonitor) Start() {
-	go em.pollEnvironment()
-}
-
-// Stop 停止环境数据轮询
-func (em *EnvironmentMonitor) Stop() {
-	em.stopChan <- true
-}
-
-// pollEnvironment 轮询环境数据
-func (em *EnvironmentMonitor) pollEnvironment() {
-	ticker := time.NewTicker(2 * time.Second) // 每2秒读取一次
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-em.stopChan:
-			return
-		case <-ticker.C:
-			em.readAndUpdateEnvironment()
-		}
+	for i := range channels {
+		em.last[i] = emptyAnalogReading(&channels[i], "尚无数据")
 	}
+	return em
 }
 
-// readAndUpdateEnvironment 读取并更新环境数据
-func (em *EnvironmentMonitor) readAndUpdateEnvironment() {
-	if em.client.conn == nil || em.ui == nil {
-		return
-	}
-
-	// 读取温度
-	temp, err := em.ReadTemperature()
-	if err == nil {
-		// 更新温度显示
-		em.ui.UpdateTemperature(temp)
-	}
-
-	// 读取湿度
-	humid, err := em.ReadHumidity()
-	if err == nil {
-		// 更新湿度显示
-		em.ui.UpdateHumidity(humid)
-	}
+// Channels 返回模拟量通道
+func (em *EnvironmentMonitor) Channels() []AnalogChannel {
+	return em.channels
 }
 
-// ReadTemperature 读取温度数据
-func (em *EnvironmentMonitor) ReadTemperature() (float64, error) {
-	if em.client.conn == nil {
-		return 0, nil
-	}
-
-	// 读取输入寄存器30033 (IW64，短地址32)
-	resp, err := em.client.ReadInputRegisters(32, 1)
-	if err != nil {
-		return 0, err
+// ReadChannels 读取全部模拟量通道
+// 单个通道读取失败时保留该通道的最后读数并标记为过期，返回最后一个错误
+func (em *EnvironmentMonitor) ReadChannels(ctx context.Context) ([]AnalogReading, error) {
+	now := time.Now()
+	readings := make([]AnalogReading, len(em.channels))
+	errs := make([]error, len(em.channels))
+	for i := range em.channels {
+		readings[i], errs[i] = em.readChannel(ctx, &em.channels[i], now)
 	}
 
-	// 解析温度值 - 尝试小端字节序
-	tempRaw := em.parseRegisterValueLittleEndian(resp)
-
-	// 转换为实际温度值
-	// 温度（℃） = (值 / 27648) × 120 − 40
-	temperature := (float64(tempRaw) / 27648.0) * 120.0 - 40.0
-
-	return temperature, nil
-}
-
-// ReadHumidity 读取湿度数据
-func (em *EnvironmentMonitor) ReadHumidity() (float64, error) {
-	if em.client.conn == nil {
-		return 0, nil
-	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
 
-	// 读取输入寄存器30034 (IW66，短地址33)
-	resp, err := em.client.ReadInputRegisters(33, 1)
-	if err != nil {
-		return 0, err
+	var lastErr error
+	for i, err := range errs {
+		if err != nil {
+			readings[i] = em.last[i].stale("读取失败: " + err.Error())
+			lastErr = err
+		}
+		em.last[i] = readings[i]
 	}
-
-	// 解析湿度值 - 尝试小端字节序
-	humidRaw := em.parseRegisterValueLittleEndian(resp)
-
-	// 转换为实际湿度值
-	// 湿度（%） = (值 / 27648) × 100
-	humidity := (float64(humidRaw) / 27648.0) * 100.0
-
-	return humidity, nil
+	return em.readingsLocked(now), lastErr
 }
 
-// parseRegisterValue 解析寄存器值（大端）
-func (em *EnvironmentMonitor) parseRegisterValue(resp []byte) uint16 {
-	if len(resp) < 3 {
-		return 0
-	}
-
-	// 第一个字节是字节数
-	byteCount := int(resp[0])
-	if byteCount < 2 {
-		return 0
-	}
-
-	// 大端解析两个字节
-	value := binary.BigEndian.Uint16(resp[1:3])
-	return value
+// Readings 返回各通道的最后读数，超过staleAfter未更新的读数标记为过期
+func (em *EnvironmentMonitor) Readings() []AnalogReading {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.readingsLocked(time.Now())
 }
 
-// parseRegisterValueLittleEndian 解析寄存器值（小端）
-func (em *EnvironmentMonitor) parseRegisterValueLittleEndian(resp []byte) uint16 {
-	if len(resp) < 3 {
-		return 0
-	}
+// StaleReadings 返回全部标记为过期的最后读数，用于连接断开时显示
+func (em *EnvironmentMonitor) StaleReadings(reason string) []AnalogReading {
+	em.mu.Lock()
+	defer em.mu.Unlock()
 
-	// 第一个字节是字节数
-	byteCount := int(resp[0])
-	if byteCount < 2 {
-		return 0
+	readings := make([]AnalogReading, len(em.last))
+	for i, reading := range em.last {
+		readings[i] = reading.stale(reason)
 	}
-
-	// 小端解析两个字节
-	value := binary.LittleEndian.Uint16(resp[1:3])
-	return value
-}
-
-// IsValidTemperature 检查温度值是否有效
-func (em *EnvironmentMonitor) IsValidTemperature(temp float64) bool {
-	// 简单的有效性检查
-	return temp >= -40.0 && temp <= 80.0
-}
-
-// IsValidHumidity 检查湿度值是否有效
-func (em *EnvironmentMonitor) IsValidHumidity(humid float64) bool {
-	// 简单的有效性检查
-	return humid >= 0.0 && humid <= 100.0
+	return readings
 }
 
-// FormatTemperature 格式化温度值
-func (em *EnvironmentMonitor) FormatTemperature(temp float64) string {
-	if em.IsValidTemperature(temp) {
-		return roundToOneDecimal(temp) + "℃"
+// readingsLocked 返回最后读数的副本并检查是否过期，调用方需持有mu
+func (em *EnvironmentMonitor) readingsLocked(now time.Time) []AnalogReading {
+	readings := make([]AnalogReading, len(em.last))
+	for i, reading := range em.last {
+		if em.staleAfter > 0 && (reading.Quality == QualityGood || reading.Quality == QualityUncertain) &&
+			now.Sub(reading.Timestamp) > em.staleAfter {
+			reading = reading.stale(fmt.Sprintf("超过%s未更新", em.staleAfter))
+		}
+		readings[i] = reading
 	}
-	return "无效"
+	return readings
 }
 
-// FormatHumidity 格式化湿度值
-func (em *EnvironmentMonitor) FormatHumidity(humid float64) string {
-	if em.IsValidHumidity(humid) {
-		return roundToOneDecimal(humid) + "%"
+// readChannel 读取单个通道的原始值并换算
+func (em *EnvironmentMonitor) readChannel(ctx context.Context, ch *AnalogChannel, now time.Time) (AnalogReading, error) {
+	tag, err := em.tags.DB().Lookup(ch.Name)
+	if err != nil {
+		return AnalogReading{}, err
 	}
-	return "无效"
+	raw, err := em.tags.ReadRawCtx(ctx, ch.Name)
+	if err != nil {
+		return AnalogReading{}, err
+	}
+	return newAnalogReading(ch, tag.Scaling, int16(raw), now), nil
 }
-
-// roundToOneDecimal 保留一位小数
-func roundToOneDecimal(value float64) string {
-	return strconv.FormatFloat(math.Round(value*10)/10, 'f', 1, 64)
-}
\ No newline at end of file
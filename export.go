@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Windows没有系统时区数据库，内置时区数据以支持按时区导出
+
+	"s7-1200-marquee/parquet"
+)
+
+// 导出的数据集
+const (
+	ExportSamples = "samples" // 模拟量采样
+	ExportEvents  = "events"  // 数字量变化
+	ExportRuns    = "runs"    // 跑马灯运行时段
+)
+
+// 导出格式
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// 运行时段导出时向前查找开始事件的范围，在导出开始时间之前启动的运行按此范围内的最后状态计算
+const runLookback = 24 * time.Hour
+
+// ExportOptions 历史数据导出参数
+type ExportOptions struct {
+	Dataset   string            // samples（默认）/events/runs
+	Format    string            // csv（默认）/parquet
+	From, To  time.Time         // 时间范围[From, To)
+	Tags      []string          // 只导出这些标签，为空时导出全部；runs忽略
+	Units     map[string]string // 标签的工程单位，写入samples的unit列
+	Delimiter rune              // CSV分隔符，默认逗号
+	Location  *time.Location    // CSV时间的时区，默认本地时区；Parquet始终为UTC时间戳
+}
+
+// exportTimeLayout CSV中的时间格式，便于表格软件识别
+const exportTimeLayout = "2006-01-02 15:04:05.000"
+
+// exportWriter 按格式写出行，CSV按时区格式化时间
+type exportWriter interface {
+	Write(values ...any) error
+	Close() error
+}
+
+// csvExportWriter CSV导出
+type csvExportWriter struct {
+	writer   *csv.Writer
+	location *time.Location
+	record   []string
+}
+
+func (w *csvExportWriter) Write(values ...any) error {
+	w.record = w.record[:0]
+	for _, value := range values {
+		var text string
+		switch v := value.(type) {
+		case time.Time:
+			text = v.In(w.location).Format(exportTimeLayout)
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case int32:
+			text = strconv.Itoa(int(v))
+		case bool:
+			text = strconv.FormatBool(v)
+		case string:
+			text = v
+		default:
+			text = fmt.Sprint(v)
+		}
+		w.record = append(w.record, text)
+	}
+	return w.writer.Write(w.record)
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// parquetExportWriter Parquet导出
+type parquetExportWriter struct {
+	writer *parquet.Writer
+}
+
+func (w *parquetExportWriter) Write(values ...any) error {
+	return w.writer.WriteRow(values...)
+}
+
+func (w *parquetExportWriter) Close() error {
+	return w.writer.Close()
+}
+
+// exportColumns 各数据集的列
+var exportColumns = map[string][]parquet.Column{
+	ExportSamples: {
+		{Name: "time", Type: parquet.TimestampMillis},
+		{Name: "tag", Type: parquet.String},
+		{Name: "value", Type: parquet.Double},
+		{Name: "unit", Type: parquet.String},
+		{Name: "quality", Type: parquet.String},
+		{Name: "min", Type: parquet.Double},
+		{Name: "max", Type: parquet.Double},
+		{Name: "count", Type: parquet.Int32},
+	},
+	ExportEvents: {
+		{Name: "time", Type: parquet.TimestampMillis},
+		{Name: "tag", Type: parquet.String},
+		{Name: "state", Type: parquet.Boolean},
+	},
+	ExportRuns: {
+		{Name: "start", Type: parquet.TimestampMillis},
+		{Name: "end", Type: parquet.TimestampMillis},
+		{Name: "duration_s", Type: parquet.Double},
+		{Name: "complete", Type: parquet.Boolean}, // false表示导出结束时仍在运行，end为导出结束时间
+	},
+}
+
+// newExportWriter 创建导出写入器并写出CSV表头
+func newExportWriter(w io.Writer, opts *ExportOptions) (exportWriter, error) {
+	columns := exportColumns[opts.Dataset]
+	if opts.Format == FormatParquet {
+		writer, err := parquet.NewWriter(w, columns, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetExportWriter{writer: writer}, nil
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.Delimiter
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+		if column.Type == parquet.TimestampMillis {
+			header[i] += " (" + opts.Location.String() + ")"
+		}
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{writer: writer, location: opts.Location}, nil
+}
+
+// normalize 检查导出参数并填充默认值
+func (opts *ExportOptions) normalize() error {
+	if opts.Dataset == "" {
+		opts.Dataset = ExportSamples
+	}
+	if _, ok := exportColumns[opts.Dataset]; !ok {
+		return fmt.Errorf("unknown dataset %q", opts.Dataset)
+	}
+	if opts.Format == "" {
+		opts.Format = FormatCSV
+	}
+	if opts.Format != FormatCSV && opts.Format != FormatParquet {
+		return fmt.Errorf("unknown format %q", opts.Format)
+	}
+	if !opts.From.Before(opts.To) {
+		return fmt.Errorf("export range is empty")
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = ','
+	}
+	if opts.Delimiter == '"' || opts.Delimiter == '\r' || opts.Delimiter == '\n' {
+		return fmt.Errorf("invalid delimiter %q", opts.Delimiter)
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	return nil
+}
+
+// ContentType 返回导出格式的MIME类型
+func (opts *ExportOptions) ContentType() string {
+	if opts.Format == FormatParquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileName 返回导出文件名，如 PLC-samples-20261016-0800-20261016-0900.csv
+func (opts *ExportOptions) FileName(station string) string {
+	const layout = "20060102-1504"
+	return fmt.Sprintf("%s-%s-%s-%s.%s", station, opts.Dataset,
+		opts.From.In(opts.Location).Format(layout), opts.To.In(opts.Location).Format(layout), opts.Format)
+}
+
+// ExportHistory 按时间顺序流式导出历史数据，返回写出的行数
+func ExportHistory(w io.Writer, h *Historian, opts ExportOptions) (int, error) {
+	if err := opts.normalize(); err != nil {
+		return 0, err
+	}
+	out, err := newExportWriter(w, &opts)
+	if err != nil {
+		return 0, err
+	}
+
+	tags := make(map[string]bool, len(opts.Tags))
+	for _, tag := range opts.Tags {
+		tags[tag] = true
+	}
+
+	rows := 0
+	switch opts.Dataset {
+	case ExportSamples, ExportEvents:
+		digital := opts.Dataset == ExportEvents
+		err = h.Scan("", opts.From, opts.To, func(record HistoryRecord) error {
+			if record.Digital != digital || (len(tags) > 0 && !tags[record.Tag]) {
+				return nil
+			}
+			rows++
+			if digital {
+				return out.Write(record.Timestamp(), record.Tag, record.Value != 0)
+			}
+			low, high := record.Value, record.Value
+			if record.Min != nil {
+				low = *record.Min
+			}
+			if record.Max != nil {
+				high = *record.Max
+			}
+			quality := record.Quality
+			if quality == "" {
+				quality = QualityGood
+			}
+			return out.Write(record.Timestamp(), record.Tag, record.Value, opts.Units[record.Tag],
+				string(quality), low, high, int32(record.samples()))
+		})
+	case ExportRuns:
+		rows, err = exportRuns(out, h, opts.From, opts.To)
+	}
+	if err != nil {
+		return rows, err
+	}
+	return rows, out.Close()
+}
+
+// exportRuns 由运行状态变化计算运行时段，截取到[from, to)内
+func exportRuns(out exportWriter, h *Historian, from, to time.Time) (int, error) {
+	rows := 0
+	var start time.Time // 当前运行的开始时间，零值表示未运行
+	emit := func(end time.Time, complete bool) error {
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			return nil
+		}
+		rows++
+		return out.Write(start, end, end.Sub(start).Seconds(), complete)
+	}
+
+	err := h.Scan(HistoryTagRunning, from.Add(-runLookback), to, func(record HistoryRecord) error {
+		running := record.Value != 0
+		switch {
+		case running && start.IsZero():
+			start = record.Timestamp()
+		case !running && !start.IsZero():
+			end := record.Timestamp()
+			if end.After(from) {
+				if err := emit(end, true); err != nil {
+					return err
+				}
+			}
+			start = time.Time{}
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	if !start.IsZero() {
+		err = emit(to, false)
+	}
+	return rows, err
+}
+
+// utcOffsetPattern 匹配 +08:00、-0530 形式的固定时区
+var utcOffsetPattern = regexp.MustCompile(`^(?:UTC)?([+-])(\d{2}):?(\d{2})$`)
+
+// ParseTimezone 解析时区：IANA名称（如 Asia/Shanghai）、UTC、Local 或 +08:00 形式的固定偏移，为空时为本地时区
+func ParseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	if match := utcOffsetPattern.FindStringSubmatch(strings.ToUpper(name)); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		offset := hours*3600 + minutes*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone("UTC"+match[1]+match[2]+":"+match[3], offset), nil
+	}
+	return time.LoadLocation(name)
+}
+
+// ParseDelimiter 解析CSV分隔符：单个字符，或 tab、semicolon、comma
+func ParseDelimiter(text string) (rune, error) {
+	switch strings.ToLower(text) {
+	case "", "comma":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	}
+	runes := []rune(text)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("invalid delimiter %q", text)
+	}
+	return runes[0], nil
+}
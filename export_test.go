@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newExportHistorian 创建临时目录中的历史记录：运行30秒，期间Lamp01亮灭一次，温湿度各采样两次
+func newExportHistorian(t *testing.T) (*Historian, time.Time) {
+	t.Helper()
+	config := DefaultConfig()
+	config.History = &HistoryConfig{Dir: t.TempDir()}
+	h := NewHistorian(config)
+
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	at := func(seconds float64) time.Time { return start.Add(time.Duration(seconds * float64(time.Second))) }
+	h.RecordBools([]string{HistoryTagRunning, "Lamp01"}, []bool{true, true}, at(0))
+	h.RecordAnalog([]AnalogReading{
+		{Name: "Temperature", Value: 21.5, Quality: QualityGood},
+		{Name: "Humidity", Value: 105.25, Quality: QualityUncertain},
+	}, at(1))
+	h.RecordBools([]string{"Lamp01"}, []bool{false}, at(2.5))
+	h.RecordAnalog([]AnalogReading{
+		{Name: "Temperature", Value: 22, Quality: QualityGood},
+		{Name: "Humidity", Quality: QualityBad},
+	}, at(7))
+	h.RecordBools([]string{HistoryTagRunning}, []bool{false}, at(30))
+	h.Stop()
+	return h, start
+}
+
+func TestExportHistoryCSV(t *testing.T) {
+	h, start := newExportHistorian(t)
+	shanghai := time.FixedZone("UTC+08:00", 8*3600)
+
+	tests := []struct {
+		name string
+		opts ExportOptions
+		want string
+	}{
+		{
+			name: "samples",
+			opts: ExportOptions{
+				Dataset: ExportSamples, From: start, To: start.Add(time.Minute),
+				Units: map[string]string{"Temperature": "℃"}, Delimiter: ';', Location: shanghai,
+			},
+			want: "time (UTC+08:00);tag;value;unit;quality;min;max;count\n" +
+				"2026-10-16 16:00:01.000;Temperature;21.5;℃;good;21.5;21.5;1\n" +
+				"2026-10-16 16:00:01.000;Humidity;105.25;;uncertain;105.25;105.25;1\n" +
+				"2026-10-16 16:00:07.000;Temperature;22;℃;good;22;22;1\n",
+		},
+		{
+			name: "events filtered by tag",
+			opts: ExportOptions{
+				Dataset: ExportEvents, From: start, To: start.Add(time.Minute),
+				Tags: []string{"Lamp01"}, Location: time.UTC,
+			},
+			want: "time (UTC),tag,state\n" +
+				"2026-10-16 08:00:00.000,Lamp01,true\n" +
+				"2026-10-16 08:00:02.500,Lamp01,false\n",
+		},
+		{
+			name: "complete run",
+			opts: ExportOptions{Dataset: ExportRuns, From: start, To: start.Add(time.Minute), Location: time.UTC},
+			want: "start (UTC),end (UTC),duration_s,complete\n" +
+				"2026-10-16 08:00:00.000,2026-10-16 08:00:30.000,30,true\n",
+		},
+		{
+			name: "run clipped to range",
+			opts: ExportOptions{Dataset: ExportRuns, From: start.Add(10 * time.Second), To: start.Add(20 * time.Second), Location: time.UTC},
+			want: "start (UTC),end (UTC),duration_s,complete\n" +
+				"2026-10-16 08:00:10.000,2026-10-16 08:00:20.000,10,false\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		rows, err := ExportHistory(&buf, h, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		if want := strings.Count(tt.want, "\n") - 1; rows != want {
+			t.Errorf("%s: %d rows, want %d", tt.name, rows, want)
+		}
+	}
+}
+
+func TestExportHistoryParquet(t *testing.T) {
+	h, start := newExportHistorian(t)
+	var buf bytes.Buffer
+	rows, err := ExportHistory(&buf, h, ExportOptions{Dataset: ExportEvents, Format: FormatParquet, From: start, To: start.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 4 {
+		t.Errorf("%d rows, want 4", rows)
+	}
+	if data := buf.Bytes(); !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Errorf("parquet export missing PAR1 magic")
+	}
+}
+
+func TestExportOptionsErrors(t *testing.T) {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	for _, opts := range []ExportOptions{
+		{Dataset: "alarms", From: start, To: start.Add(time.Hour)},
+		{Format: "xlsx", From: start, To: start.Add(time.Hour)},
+		{From: start, To: start},
+		{From: start, To: start.Add(time.Hour), Delimiter: '"'},
+	} {
+		if err := opts.normalize(); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+}
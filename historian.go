@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryConfig 历史数据记录设置
+type HistoryConfig struct {
+	Disabled             bool   `json:"disabled,omitempty"`             // 停用历史记录
+	Dir                  string `json:"dir,omitempty"`                  // 存储目录，默认为程序目录下的history，每个站点一个子目录
+	RetentionDays        int    `json:"retentionDays,omitempty"`        // 保留天数，默认30
+	SampleIntervalMs     int    `json:"sampleIntervalMs,omitempty"`     // 模拟量采样间隔，默认5000
+	DownsampleAfterDays  int    `json:"downsampleAfterDays,omitempty"`  // 超过该天数的模拟量采样降采样保存，默认7，负数表示不降采样
+	DownsampleIntervalMs int    `json:"downsampleIntervalMs,omitempty"` // 降采样时间间隔，默认60000
+}
+
+// HistorySettings 返回历史记录设置，未配置的项使用默认值
+func (c *Config) HistorySettings() HistoryConfig {
+	settings := HistoryConfig{}
+	if c.History != nil {
+		settings = *c.History
+	}
+	if settings.Dir == "" {
+		settings.Dir = "history"
+	}
+	if !filepath.IsAbs(settings.Dir) {
+		if ex, err := os.Executable(); err == nil {
+			settings.Dir = filepath.Join(filepath.Dir(ex), settings.Dir)
+		}
+	}
+	if settings.RetentionDays <= 0 {
+		settings.RetentionDays = 30
+	}
+	if settings.SampleIntervalMs <= 0 {
+		settings.SampleIntervalMs = 5000
+	}
+	if settings.DownsampleAfterDays == 0 {
+		settings.DownsampleAfterDays = 7
+	}
+	if settings.DownsampleIntervalMs <= 0 {
+		settings.DownsampleIntervalMs = 60000
+	}
+	return settings
+}
+
+// HistoryRecord 一条历史记录：数字量变化或模拟量采样
+type HistoryRecord struct {
+	Time    int64    `json:"t"`             // Unix毫秒
+	Tag     string   `json:"n"`             // 标签名
+	Value   float64  `json:"v"`             // 工程值，数字量为0/1，降采样记录为平均值
+	Digital bool     `json:"d,omitempty"`   // 数字量变化
+	Quality Quality  `json:"q,omitempty"`   // 质量，为空表示good
+	Min     *float64 `json:"min,omitempty"` // 降采样区间最小值
+	Max     *float64 `json:"max,omitempty"` // 降采样区间最大值
+	Count   int      `json:"c,omitempty"`   // 降采样区间内的采样数
+}
+
+// Timestamp 返回记录时间
+func (r *HistoryRecord) Timestamp() time.Time {
+	return time.UnixMilli(r.Time)
+}
+
+// samples 返回记录代表的采样数
+func (r *HistoryRecord) samples() int {
+	if r.Count > 0 {
+		return r.Count
+	}
+	return 1
+}
+
+// HistoryTagRunning 跑马灯运行状态的历史记录标签名
+const HistoryTagRunning = "MarqueeRunning"
+
+// 历史数据文件：每天（UTC）一个JSON Lines文件，降采样后改名为 .ds.jsonl
+const (
+	historyFileSuffix     = ".jsonl"
+	historyDownsampledExt = ".ds.jsonl"
+	historyDayLayout      = "2006-01-02"
+)
+
+// Historian 站点历史数据记录：追加写入按天分文件的记录，定期清理过期文件并降采样
+type Historian struct {
+	dir      string
+	settings HistoryConfig
+
+	mu         sync.Mutex
+	file       *os.File
+	writer     *bufio.Writer
+	day        string          // 当前写入文件对应的日期
+	lastBools  map[string]bool // 数字量最后记录的状态
+	lastSample time.Time       // 最近一次模拟量采样时间
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewHistorian 创建站点历史数据记录，仿真站点使用单独的目录
+func NewHistorian(config *Config) *Historian {
+	settings := config.HistorySettings()
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(config.StationName())
+	if config.simulated {
+		name += "-sim"
+	}
+	return &Historian{
+		dir:       filepath.Join(settings.Dir, name),
+		settings:  settings,
+		lastBools: make(map[string]bool),
+	}
+}
+
+// Enabled 是否启用历史记录
+func (h *Historian) Enabled() bool {
+	return !h.settings.Disabled
+}
+
+// Start 启动过期清理与降采样任务
+func (h *Historian) Start() {
+	if !h.Enabled() {
+		return
+	}
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if err := h.Maintain(time.Now()); err != nil {
+				log.Printf("历史数据维护失败: %v", err)
+			}
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止维护任务并关闭当前文件
+func (h *Historian) Stop() {
+	if h.stop != nil {
+		close(h.stop)
+		<-h.done
+		h.stop = nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeLocked()
+}
+
+// RecordBools 记录数字量，只写入与上次记录不同的状态
+func (h *Historian) RecordBools(names []string, values []bool, now time.Time) {
+	if !h.Enabled() {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var records []HistoryRecord
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		if last, ok := h.lastBools[name]; ok && last == values[i] {
+			continue
+		}
+		h.lastBools[name] = values[i]
+		value := 0.0
+		if values[i] {
+			value = 1
+		}
+		records = append(records, HistoryRecord{Time: now.UnixMilli(), Tag: name, Value: value, Digital: true})
+	}
+	h.appendLocked(records, now)
+}
+
+// RecordAnalog 按采样间隔记录模拟量读数，bad和stale读数不记录
+func (h *Historian) RecordAnalog(readings []AnalogReading, now time.Time) {
+	if !h.Enabled() {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now.Sub(h.lastSample) < time.Duration(h.settings.SampleIntervalMs)*time.Millisecond {
+		return
+	}
+	h.lastSample = now
+
+	var records []HistoryRecord
+	for _, reading := range readings {
+		if reading.Quality != QualityGood && reading.Quality != QualityUncertain {
+			continue
+		}
+		record := HistoryRecord{Time: now.UnixMilli(), Tag: reading.Name, Value: reading.Value}
+		if reading.Quality != QualityGood {
+			record.Quality = reading.Quality
+		}
+		records = append(records, record)
+	}
+	h.appendLocked(records, now)
+}
+
+// ForgetBools 清除数字量最后记录的状态，下次记录时无论是否变化都写入
+func (h *Historian) ForgetBools(names []string) {
+	h.mu.Lock()
+	for _, name := range names {
+		delete(h.lastBools, name)
+	}
+	h.mu.Unlock()
+}
+
+// appendLocked 追加记录到当日文件，调用方需持有mu
+func (h *Historian) appendLocked(records []HistoryRecord, now time.Time) {
+	if len(records) == 0 {
+		return
+	}
+
+	day := now.UTC().Format(historyDayLayout)
+	if h.file == nil || day != h.day {
+		h.closeLocked()
+		if err := os.MkdirAll(h.dir, 0755); err != nil {
+			log.Printf("创建历史数据目录失败: %v", err)
+			return
+		}
+		file, err := os.OpenFile(filepath.Join(h.dir, day+historyFileSuffix), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Printf("打开历史数据文件失败: %v", err)
+			return
+		}
+		h.file, h.writer, h.day = file, bufio.NewWriter(file), day
+	}
+
+	encoder := json.NewEncoder(h.writer)
+	for i := range records {
+		encoder.Encode(&records[i])
+	}
+	if err := h.writer.Flush(); err != nil {
+		log.Printf("写入历史数据失败: %v", err)
+	}
+}
+
+// closeLocked 关闭当前文件，调用方需持有mu
+func (h *Historian) closeLocked() {
+	if h.file == nil {
+		return
+	}
+	h.writer.Flush()
+	h.file.Close()
+	h.file, h.writer, h.day = nil, nil, ""
+}
+
+// historyFile 历史数据文件及其日期
+type historyFile struct {
+	path        string
+	day         time.Time
+	downsampled bool
+}
+
+// files 返回按日期排序的历史数据文件
+func (h *Historian) files() ([]historyFile, error) {
+	entries, err := os.ReadDir(h.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []historyFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, historyFileSuffix) {
+			continue
+		}
+		downsampled := strings.HasSuffix(name, historyDownsampledExt)
+		dayText := strings.TrimSuffix(strings.TrimSuffix(name, historyDownsampledExt), historyFileSuffix)
+		day, err := time.Parse(historyDayLayout, dayText)
+		if err != nil {
+			continue
+		}
+		files = append(files, historyFile{path: filepath.Join(h.dir, name), day: day, downsampled: downsampled})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].day.Before(files[j].day) })
+	return files, nil
+}
+
+// Scan 按时间顺序遍历[from, to)内的记录，tag为空时遍历全部标签
+// 只读取覆盖该时间范围的日文件，fn返回错误时停止遍历
+func (h *Historian) Scan(tag string, from, to time.Time, fn func(HistoryRecord) error) error {
+	files, err := h.files()
+	if err != nil {
+		return err
+	}
+
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+	for _, file := range files {
+		if !file.day.Before(to) || !file.day.Add(24*time.Hour).After(from) {
+			continue
+		}
+		if err := scanHistoryFile(file.path, func(record HistoryRecord) error {
+			if record.Time < fromMs || record.Time >= toMs || (tag != "" && record.Tag != tag) {
+				return nil
+			}
+			return fn(record)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanHistoryFile 逐行读取历史数据文件，跳过无法解析的行（如写入中断的最后一行）
+func scanHistoryFile(path string, fn func(HistoryRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record HistoryRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Maintain 删除超过保留天数的文件，并将超过降采样天数的文件降采样
+func (h *Historian) Maintain(now time.Time) error {
+	files, err := h.files()
+	if err != nil {
+		return err
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	retention := today.AddDate(0, 0, -h.settings.RetentionDays)
+	downsample := today.AddDate(0, 0, -h.settings.DownsampleAfterDays)
+	for _, file := range files {
+		switch {
+		case file.day.Before(retention):
+			if err := os.Remove(file.path); err != nil {
+				return err
+			}
+			log.Printf("删除过期历史数据: %s", filepath.Base(file.path))
+		case h.settings.DownsampleAfterDays > 0 && !file.downsampled && file.day.Before(downsample):
+			if err := h.downsampleFile(file); err != nil {
+				return fmt.Errorf("downsample %s: %w", filepath.Base(file.path), err)
+			}
+		}
+	}
+	return nil
+}
+
+// downsampleFile 将模拟量采样按降采样间隔合并为平均值、最小值和最大值，数字量变化原样保留
+func (h *Historian) downsampleFile(file historyFile) error {
+	interval := int64(h.settings.DownsampleIntervalMs)
+	type bucketKey struct {
+		tag   string
+		start int64
+	}
+	buckets := make(map[bucketKey]*historyBucket)
+	var records []HistoryRecord
+	err := scanHistoryFile(file.path, func(record HistoryRecord) error {
+		if record.Digital {
+			records = append(records, record)
+			return nil
+		}
+		key := bucketKey{record.Tag, record.Time - record.Time%interval}
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = newHistoryBucket()
+			buckets[key] = bucket
+		}
+		bucket.add(record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for key, bucket := range buckets {
+		records = append(records, bucket.record(key.tag, key.start))
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time < records[j].Time })
+
+	path := strings.TrimSuffix(file.path, historyFileSuffix) + historyDownsampledExt
+	out, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	for i := range records {
+		encoder.Encode(&records[i])
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	log.Printf("历史数据已降采样: %s（%d条）", filepath.Base(path), len(records))
+	return os.Remove(file.path)
+}
+
+// historyBucket 一个时间区间内的采样统计
+type historyBucket struct {
+	sum   float64
+	count int
+	min   float64
+	max   float64
+	last  float64
+	bad   bool // 区间内有质量非good的采样
+}
+
+func newHistoryBucket() *historyBucket {
+	return &historyBucket{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// add 累加一条记录，降采样记录按其采样数加权
+func (b *historyBucket) add(record HistoryRecord) {
+	n := record.samples()
+	b.sum += record.Value * float64(n)
+	b.count += n
+	b.last = record.Value
+	low, high := record.Value, record.Value
+	if record.Min != nil {
+		low = *record.Min
+	}
+	if record.Max != nil {
+		high = *record.Max
+	}
+	b.min = math.Min(b.min, low)
+	b.max = math.Max(b.max, high)
+	if record.Quality != "" && record.Quality != QualityGood {
+		b.bad = true
+	}
+}
+
+// average 返回平均值
+func (b *historyBucket) average() float64 {
+	return b.sum / float64(b.count)
+}
+
+// record 返回区间的降采样记录
+func (b *historyBucket) record(tag string, start int64) HistoryRecord {
+	min, max := b.min, b.max
+	record := HistoryRecord{Time: start, Tag: tag, Value: b.average(), Min: &min, Max: &max, Count: b.count}
+	if b.bad {
+		record.Quality = QualityUncertain
+	}
+	return record
+}
+
+// 查询聚合方式
+const (
+	AggregateRaw   = "raw"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+	AggregateLast  = "last"
+	AggregateCount = "count"
+)
+
+// HistoryPoint 查询结果中的一个点
+type HistoryPoint struct {
+	Time    time.Time `json:"t"`
+	Value   float64   `json:"v"`
+	Min     *float64  `json:"min,omitempty"`
+	Max     *float64  `json:"max,omitempty"`
+	Count   int       `json:"count,omitempty"`
+	Quality Quality   `json:"q,omitempty"`
+}
+
+// 原始数据查询最多返回的点数
+const maxHistoryRawPoints = 50000
+
+// Query 查询一个标签在[from, to)内的历史数据
+// aggregate为raw时返回原始记录（超过上限时截断并返回truncated），其他方式按step分组统计
+func (h *Historian) Query(tag string, from, to time.Time, aggregate string, step time.Duration) (points []HistoryPoint, truncated bool, err error) {
+	points = make([]HistoryPoint, 0)
+	if aggregate == "" || aggregate == AggregateRaw {
+		err = h.Scan(tag, from, to, func(record HistoryRecord) error {
+			if len(points) >= maxHistoryRawPoints {
+				truncated = true
+				return errStopScan
+			}
+			points = append(points, HistoryPoint{
+				Time: record.Timestamp(), Value: record.Value, Min: record.Min, Max: record.Max,
+				Count: record.Count, Quality: record.Quality,
+			})
+			return nil
+		})
+		if err == errStopScan {
+			err = nil
+		}
+		return points, truncated, err
+	}
+
+	switch aggregate {
+	case AggregateAvg, AggregateMin, AggregateMax, AggregateLast, AggregateCount:
+	default:
+		return nil, false, fmt.Errorf("unknown aggregate %q", aggregate)
+	}
+	// 记录时间精度为毫秒，分组间隔不足1ms时无法分组
+	if step < time.Millisecond {
+		return nil, false, fmt.Errorf("aggregate %s needs a step of at least 1ms, got %s", aggregate, step)
+	}
+
+	stepMs := step.Milliseconds()
+	fromMs := from.UnixMilli()
+	var bucket *historyBucket
+	var bucketStart int64
+	flush := func() {
+		if bucket == nil {
+			return
+		}
+		point := HistoryPoint{Time: time.UnixMilli(bucketStart), Count: bucket.count}
+		switch aggregate {
+		case AggregateAvg:
+			min, max := bucket.min, bucket.max
+			point.Value, point.Min, point.Max = bucket.average(), &min, &max
+		case AggregateMin:
+			point.Value = bucket.min
+		case AggregateMax:
+			point.Value = bucket.max
+		case AggregateLast:
+			point.Value = bucket.last
+		case AggregateCount:
+			point.Value = float64(bucket.count)
+		}
+		if bucket.bad {
+			point.Quality = QualityUncertain
+		}
+		points = append(points, point)
+	}
+	err = h.Scan(tag, from, to, func(record HistoryRecord) error {
+		start := fromMs + (record.Time-fromMs)/stepMs*stepMs
+		if bucket == nil || start != bucketStart {
+			flush()
+			bucket, bucketStart = newHistoryBucket(), start
+		}
+		bucket.add(record)
+		return nil
+	})
+	flush()
+	return points, false, err
+}
+
+// errStopScan 提前结束遍历
+var errStopScan = errors.New("stop scan")
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHistorian 在临时目录创建历史数据记录
+func newTestHistorian(t *testing.T, settings HistoryConfig) *Historian {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	config := DefaultConfig()
+	settings.Dir = t.TempDir()
+	config.History = &settings
+	h := NewHistorian(config)
+	t.Cleanup(h.Stop)
+	return h
+}
+
+// historyFileNames 返回历史数据目录中的文件名
+func historyFileNames(t *testing.T, h *Historian) []string {
+	t.Helper()
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// readHistoryFile 读取历史数据文件中的全部记录
+func readHistoryFile(t *testing.T, h *Historian, name string) []HistoryRecord {
+	t.Helper()
+	var records []HistoryRecord
+	if err := scanHistoryFile(filepath.Join(h.dir, name), func(record HistoryRecord) error {
+		records = append(records, record)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// describeRecords 将记录格式化为 标签=值 列表，便于比较
+func describeRecords(records []HistoryRecord) string {
+	parts := make([]string, len(records))
+	for i, r := range records {
+		parts[i] = fmt.Sprintf("%s=%g", r.Tag, r.Value)
+		if r.Quality != "" {
+			parts[i] += "(" + string(r.Quality) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestHistorianQueryStep(t *testing.T) {
+	config := DefaultConfig()
+	config.History = &HistoryConfig{Dir: t.TempDir()}
+	h := NewHistorian(config)
+
+	start := time.Now().Truncate(time.Second)
+	for i, value := range []bool{true, false, true, false} {
+		h.RecordBools([]string{"Lamp01"}, []bool{value}, start.Add(time.Duration(i)*time.Second))
+	}
+	h.Stop()
+
+	from, to := start, start.Add(4*time.Second)
+	for _, step := range []time.Duration{-time.Second, 0, time.Nanosecond, 999 * time.Microsecond} {
+		_, _, err := h.Query("Lamp01", from, to, AggregateCount, step)
+		if err == nil || !strings.Contains(err.Error(), "at least 1ms") {
+			t.Errorf("step %s: got %v, want error", step, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		step   time.Duration
+		points int
+	}{
+		{time.Millisecond, 4},
+		{2 * time.Second, 2},
+	} {
+		points, _, err := h.Query("Lamp01", from, to, AggregateCount, tt.step)
+		if err != nil {
+			t.Fatalf("step %s: %v", tt.step, err)
+		}
+		if len(points) != tt.points {
+			t.Errorf("step %s: got %d points, want %d", tt.step, len(points), tt.points)
+		}
+	}
+}
+
+func TestHistorianRecordBoolsOnChange(t *testing.T) {
+	h := newTestHistorian(t, HistoryConfig{})
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	names := []string{"Lamp01", "Lamp02"}
+
+	h.RecordBools(names, []bool{true, false}, now)
+	h.RecordBools(names, []bool{true, false}, now.Add(time.Second)) // 未变化
+	h.RecordBools(names, []bool{true, true}, now.Add(2*time.Second))
+	h.ForgetBools([]string{"Lamp01"})
+	h.RecordBools(names, []bool{true, true}, now.Add(3*time.Second)) // 清除后无论是否变化都写入
+	h.RecordBools(names, []bool{false}, now.Add(4*time.Second))      // 值少于标签时只记录前面的标签
+
+	records := readHistoryFile(t, h, "2026-03-01.jsonl")
+	if got, want := describeRecords(records), "Lamp01=1 Lamp02=0 Lamp02=1 Lamp01=1 Lamp01=0"; got != want {
+		t.Fatalf("records %s, want %s", got, want)
+	}
+	for _, record := range records {
+		if !record.Digital {
+			t.Errorf("record %+v not marked digital", record)
+		}
+	}
+	if !records[2].Timestamp().Equal(now.Add(2 * time.Second)) {
+		t.Errorf("record time %v", records[2].Timestamp())
+	}
+}
+
+func TestHistorianRecordAnalogInterval(t *testing.T) {
+	h := newTestHistorian(t, HistoryConfig{SampleIntervalMs: 1000})
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	readings := []AnalogReading{
+		{Name: "Temperature", Value: 20, Quality: QualityGood},
+		{Name: "Pressure", Value: 0, Quality: QualityBad},
+		{Name: "Level", Value: 5, Quality: QualityStale},
+		{Name: "Flow", Value: 3, Quality: QualityUncertain},
+	}
+
+	h.RecordAnalog(readings, now)
+	h.RecordAnalog(readings, now.Add(999*time.Millisecond)) // 未到采样间隔
+	h.RecordAnalog(readings, now.Add(time.Second))
+
+	records := readHistoryFile(t, h, "2026-03-01.jsonl")
+	if got, want := describeRecords(records), "Temperature=20 Flow=3(uncertain) Temperature=20 Flow=3(uncertain)"; got != want {
+		t.Fatalf("records %s, want %s", got, want)
+	}
+	if !records[2].Timestamp().Equal(now.Add(time.Second)) || records[2].Digital {
+		t.Errorf("second sample %+v", records[2])
+	}
+}
+
+func TestHistorianDayRollover(t *testing.T) {
+	h := newTestHistorian(t, HistoryConfig{})
+	// 按UTC日期分文件：东八区早上8点前仍属于UTC的前一天
+	zone := time.FixedZone("UTC+8", 8*60*60)
+	h.RecordBools([]string{"Lamp01"}, []bool{true}, time.Date(2026, 3, 2, 7, 59, 59, 0, zone))
+	h.RecordBools([]string{"Lamp01"}, []bool{false}, time.Date(2026, 3, 2, 8, 0, 0, 0, zone))
+	h.RecordBools([]string{"Lamp01"}, []bool{true}, time.Date(2026, 3, 2, 9, 0, 0, 0, zone))
+
+	if got := historyFileNames(t, h); !slices.Equal(got, []string{"2026-03-01.jsonl", "2026-03-02.jsonl"}) {
+		t.Fatalf("files %v", got)
+	}
+	if got := describeRecords(readHistoryFile(t, h, "2026-03-01.jsonl")); got != "Lamp01=1" {
+		t.Errorf("first day %s", got)
+	}
+	if got := describeRecords(readHistoryFile(t, h, "2026-03-02.jsonl")); got != "Lamp01=0 Lamp01=1" {
+		t.Errorf("second day %s", got)
+	}
+
+	// 跨天查询依次读取两个文件
+	points, _, err := h.Query("Lamp01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), AggregateRaw, 0)
+	if err != nil || len(points) != 3 {
+		t.Fatalf("query across days: %d points, %v", len(points), err)
+	}
+}
+
+func TestHistorianMaintain(t *testing.T) {
+	h := newTestHistorian(t, HistoryConfig{RetentionDays: 30, DownsampleAfterDays: 7, SampleIntervalMs: 1000, DownsampleIntervalMs: 60000})
+	temperature := func(value float64, quality Quality, at time.Time) {
+		h.RecordAnalog([]AnalogReading{{Name: "Temperature", Value: value, Quality: quality}}, at)
+	}
+
+	// 超过保留天数
+	temperature(1, QualityGood, time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC))
+	if err := os.WriteFile(filepath.Join(h.dir, "2026-02-27.ds.jsonl"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过降采样天数：第一分钟三个采样和一次数字量变化，第二分钟一个不确定的采样
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	temperature(10, QualityGood, day)
+	temperature(20, QualityGood, day.Add(20*time.Second))
+	h.RecordBools([]string{"Lamp01"}, []bool{true}, day.Add(30*time.Second))
+	temperature(60, QualityGood, day.Add(40*time.Second))
+	temperature(5, QualityUncertain, day.Add(70*time.Second))
+
+	// 未到降采样天数
+	temperature(7, QualityGood, time.Date(2026, 3, 25, 0, 0, 0, 0, time.UTC))
+	h.Stop()
+
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	if err := h.Maintain(now); err != nil {
+		t.Fatal(err)
+	}
+	if got := historyFileNames(t, h); !slices.Equal(got, []string{"2026-03-01.ds.jsonl", "2026-03-25.jsonl"}) {
+		t.Fatalf("files after maintain %v", got)
+	}
+
+	records := readHistoryFile(t, h, "2026-03-01.ds.jsonl")
+	if got, want := describeRecords(records), "Temperature=30 Lamp01=1 Temperature=5(uncertain)"; got != want {
+		t.Fatalf("downsampled records %s, want %s", got, want)
+	}
+	first, lamp, second := records[0], records[1], records[2]
+	if !first.Timestamp().Equal(day) || first.Count != 3 || *first.Min != 10 || *first.Max != 60 {
+		t.Errorf("first bucket %+v", first)
+	}
+	if !second.Timestamp().Equal(day.Add(time.Minute)) || second.Count != 1 || *second.Min != 5 || *second.Max != 5 {
+		t.Errorf("second bucket %+v", second)
+	}
+	// 数字量变化原样保留
+	if !lamp.Digital || !lamp.Timestamp().Equal(day.Add(30*time.Second)) || lamp.Count != 0 || lamp.Min != nil {
+		t.Errorf("digital record %+v", lamp)
+	}
+	if got := describeRecords(readHistoryFile(t, h, "2026-03-25.jsonl")); got != "Temperature=7" {
+		t.Errorf("recent file %s", got)
+	}
+
+	// 已降采样的文件不再处理，超过保留天数后删除
+	if err := h.Maintain(now); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHistoryFile(t, h, "2026-03-01.ds.jsonl"); len(got) != 3 {
+		t.Fatalf("downsampled twice: %s", describeRecords(got))
+	}
+	if err := h.Maintain(now.AddDate(0, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := historyFileNames(t, h); len(got) != 0 {
+		t.Fatalf("files after retention %v", got)
+	}
+}
+
+func TestHistorianQueryRawTruncated(t *testing.T) {
+	h := newTestHistorian(t, HistoryConfig{})
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for i := range maxHistoryRawPoints + 1 {
+		line, _ := json.Marshal(HistoryRecord{Time: day.UnixMilli() + int64(i), Tag: "Temperature", Value: float64(i)})
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(filepath.Join(h.dir, "2026-03-01.jsonl"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	to := day.Add(24 * time.Hour)
+	points, truncated, err := h.Query("Temperature", day, to, AggregateRaw, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || len(points) != maxHistoryRawPoints || points[len(points)-1].Value != maxHistoryRawPoints-1 {
+		t.Fatalf("%d points, truncated %v", len(points), truncated)
+	}
+
+	// 恰好达到上限时不截断
+	points, truncated, err = h.Query("Temperature", day.Add(time.Millisecond), to, AggregateRaw, 0)
+	if err != nil || truncated || len(points) != maxHistoryRawPoints {
+		t.Fatalf("%d points, truncated %v, %v", len(points), truncated, err)
+	}
+}
@@ -1,28 +1,40 @@
 package main
 
 import (
+	"context"
+	"log"
+	"sync/atomic"
 	"time"
 )
 
 // InputController 输入控制器
 type InputController struct {
-	client        *ModbusClient
-	marquee       *MarqueeController
-	ui            *WebUI
-	config        *Config
-	previousInputs []bool  // 上一次的输入状态，用于边沿检测
-	stopChan      chan bool
+	tags           *TagClient
+	marquee        *MarqueeController
+	ui             *StationView
+	config         *Config
+	inputs         []string        // 界面显示的输入点标签名
+	buttons        []string        // 按钮标签名：启动/速度切换、停止、花样切换
+	previousInputs []bool          // 上一次的按钮状态，用于边沿检测
+	resync         atomic.Bool     // 重连后首次读取仅作为边沿检测基准
+	ctx            context.Context // 停止时取消，同时中止在途请求
+	cancel         context.CancelFunc
 }
 
 // NewInputController 创建新的输入控制器
-func NewInputController(client *ModbusClient, marquee *MarqueeController, ui *WebUI, config *Config) *InputController {
+func NewInputController(tags *TagClient, marquee *MarqueeController, ui *StationView, config *Config) *InputController {
+	ctx, cancel := context.WithCancel(context.Background())
+	buttons := []string{TagStartButton, TagStopButton, TagPatternButton}
 	return &InputController{
-		client:        client,
+		tags:          tags,
 		marquee:       marquee,
 		ui:            ui,
 		config:        config,
-		previousInputs: make([]bool, 14),
-		stopChan:      make(chan bool),
+		inputs:        config.InputTagNames(),
+		buttons:       buttons,
+		previousInputs: make([]bool, len(buttons)),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 }
 
@@ -33,17 +45,30 @@ func (ic *InputController) Start() {
 
 // Stop 停止轮询输入点
 func (ic *InputController) Stop() {
-	ic.stopChan <- true
+	ic.cancel()
+}
+
+// WatchConnection 订阅连接状态，重连后重新建立边沿检测基准
+// 避免断线期间保持按下的按钮在重连后被误判为新的按下动作
+func (ic *InputController) WatchConnection(supervisor *ConnectionSupervisor) {
+	events := supervisor.Subscribe()
+	go func() {
+		for event := range events {
+			if event.State == StateConnected && event.Previous != StateConnected {
+				ic.resync.Store(true)
+			}
+		}
+	}()
 }
 
 // pollInputs 轮询输入点状态
 func (ic *InputController) pollInputs() {
-	ticker := time.NewTicker(time.Duration(ic.config.PollIntervalMs) * time.Millisecond)
+	ticker := time.NewTicker(ic.config.PollInterval())
 	defer ticker.Stop()
 	
 	for {
 		select {
-		case <-ic.stopChan:
+		case <-ic.ctx.Done():
 			return
 		case <-ticker.C:
 			ic.readAndProcessInputs()
@@ -53,56 +78,29 @@ func (ic *InputController) pollInputs() {
 
 // readAndProcessInputs 读取并处理输入点状态
 func (ic *InputController) readAndProcessInputs() {
-	if ic.client.conn == nil {
+	if !ic.tags.IsConnected() {
 		return
 	}
 	
-	// 读取DI状态 (地址10001-10014，短地址0-13)
-	resp, err := ic.client.ReadDiscreteInputs(0, 14)
+	// 输入点与按钮一并读取，同一数据区的标签合并为一次请求
+	names := append(append([]string(nil), ic.inputs...), ic.buttons...)
+	values, err := ic.tags.ReadBoolsCtx(ic.ctx, names)
 	if err != nil {
 		// TODO: 处理读取错误
 		return
 	}
-	
-	// 解析响应数据
-	inputs := ic.parseDiscreteInputs(resp)
+	inputs, buttons := values[:len(ic.inputs)], values[len(ic.inputs):]
 	
 	// 更新UI显示
 	ic.updateUI(inputs)
 	
-	// 处理按钮事件（边沿检测）
-	ic.processButtonEvents(inputs)
-	
-	// 保存当前状态用于下次边沿检测
-	copy(ic.previousInputs, inputs)
-}
-
-// parseDiscreteInputs 解析离散输入响应数据
-func (ic *InputController) parseDiscreteInputs(resp []byte) []bool {
-	if len(resp) < 2 {
-		return make([]bool, 14)
-	}
-	
-	// 第一个字节是字节数
-	byteCount := int(resp[0])
-	if byteCount < 2 {
-		return make([]bool, 14)
-	}
-	
-	// 解析输入状态
-	inputs := make([]bool, 14)
-	data := resp[1:]
-	
-	for i := 0; i < 14; i++ {
-		byteIndex := i / 8
-		bitIndex := i % 8
-		
-		if byteIndex < len(data) {
-			inputs[i] = (data[byteIndex] & (1 << bitIndex)) != 0
-		}
+	// 处理按钮事件（边沿检测），重连后的首次读取不触发
+	if !ic.resync.Swap(false) {
+		ic.processButtonEvents(buttons)
 	}
 	
-	return inputs
+	// 保存当前状态用于下次边沿检测
+	copy(ic.previousInputs, buttons)
 }
 
 // updateUI 更新UI显示
@@ -124,7 +122,7 @@ func (ic *InputController) updateUI(inputs []bool) {
 
 // processButtonEvents 处理按钮事件（边沿检测）
 func (ic *InputController) processButtonEvents(inputs []bool) {
-	// 检查启动/速度切换按钮（假设为第一个输入点 I0.0）
+	// 检查启动/速度切换按钮（StartButton，默认I0.0）
 	if len(inputs) > 0 && len(ic.previousInputs) > 0 {
 		// 上升沿检测
 		if inputs[0] && !ic.previousInputs[0] {
@@ -138,7 +136,7 @@ func (ic *InputController) processButtonEvents(inputs []bool) {
 		}
 	}
 	
-	// 检查停止按钮（假设为第二个输入点 I0.1）
+	// 检查停止按钮（StopButton，默认I0.1）
 	if len(inputs) > 1 && len(ic.previousInputs) > 1 {
 		// 上升沿检测
 		if inputs[1] && !ic.previousInputs[1] {
@@ -148,4 +146,13 @@ func (ic *InputController) processButtonEvents(inputs []bool) {
 			}
 		}
 	}
+
+	// 检查花样切换按钮（PatternButton，默认I0.2），运行与停止时均可切换
+	if len(inputs) > 2 && len(ic.previousInputs) > 2 {
+		// 上升沿检测
+		if inputs[2] && !ic.previousInputs[2] {
+			pattern := ic.marquee.NextPattern()
+			log.Printf("花样切换为: %s", pattern.Label)
+		}
+	}
 }
\ No newline at end of file
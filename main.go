@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,116 +27,348 @@ func main() {
 	multiWriter := io.MultiWriter(os.Stdout, logFile)
 	log.SetOutput(multiWriter)
 
+	// sim子命令：仅运行仿真器，供其他进程连接
+	if len(os.Args) > 1 && os.Args[1] == "sim" {
+		runSimulator(os.Args[2:])
+		return
+	}
+
+	// import-tags子命令：从TIA Portal导出文件导入标签表
+	if len(os.Args) > 1 && os.Args[1] == "import-tags" {
+		runImportTags(os.Args[2:])
+		return
+	}
+
+	// export子命令：导出历史数据为CSV或Parquet
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+
+	simulate := flag.Bool("simulate", false, "启动内置S7-1200仿真器并连接到仿真器")
+	simButtons := flag.Bool("sim-buttons", false, "仿真模式下未配置simulator.buttons时，使用演示按钮脚本定时按下I0.0")
+	flag.Parse()
+
 	// 加载配置
 	config, err := LoadConfig()
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
-	// 创建Modbus客户端
-	client := NewModbusClient(config)
+	stationConfigs := config.StationConfigs()
 
-	// 创建跑马灯控制器
-	marquee := NewMarqueeController(client, config)
+	// 仿真模式：为每个站点启动进程内仿真器并将连接指向仿真器
+	if *simulate {
+		for i, stationConfig := range stationConfigs {
+			simulator, err := startSimulator(stationConfig, i, *simButtons)
+			if err != nil {
+				log.Fatalf("启动仿真器失败: %v", err)
+			}
+			defer simulator.Stop()
+		}
+		config.simulated = true
+	}
 
-	// 创建手动控制器
-	manualController := NewManualController(client, marquee, nil)
+	// 按站点创建PLC连接与控制器
+	var stations []*Station
+	var views []*StationView
+	for _, stationConfig := range stationConfigs {
+		station, err := NewStation(stationConfig)
+		if err != nil {
+			log.Fatalf("创建站点失败: %v", err)
+		}
+		stations = append(stations, station)
+		views = append(views, station.view)
+	}
 
 	// 创建Web用户界面
-	ui := NewWebUI(client, marquee, manualController, config)
+	ui := NewWebUI(views)
 
 	// 显示界面
 	ui.Show()
 
-	// 创建输入控制器
-	inputController := NewInputController(client, marquee, ui, config)
+	// 启动各站点
+	for _, station := range stations {
+		station.Start()
+		defer station.Stop()
+	}
 
-	// 创建环境监测器
-	environmentMonitor := NewEnvironmentMonitor(client, ui)
-	defer environmentMonitor.Stop()
+	// 运行Web界面
+	ui.Run()
+}
 
-	// 启动状态更新goroutine
-	go func() {
-		for {
-			if client.IsConnected() {
-				ui.UpdateConnectionStatus("已连接")
-			} else {
-				ui.UpdateConnectionStatus("未连接")
-			}
+// startSimulator 为第index个站点启动进程内仿真器，并将站点配置指向仿真器地址
+// 多个站点时监听端口依次递增，便于外部工具按站点连接；demoButtons为true且未配置按钮脚本时使用演示脚本
+func startSimulator(config *Config, index int, demoButtons bool) (*Simulator, error) {
+	simConfig := DefaultSimulatorConfig()
+	if config.Simulator != nil {
+		copied := *config.Simulator
+		simConfig = &copied
+	}
+	if demoButtons && len(simConfig.Buttons) == 0 {
+		simConfig.Buttons = DemoButtonPresses()
+	}
+	if index > 0 {
+		listenAddr, err := offsetPort(simConfig.ListenAddr, index)
+		if err != nil {
+			return nil, err
+		}
+		simConfig.ListenAddr = listenAddr
+	}
+	coverBitTags(simConfig, config)
 
-			if marquee.IsRunning() {
-				ui.UpdateRunStatus("运行中")
-				ui.UpdateSpeedLevel(marquee.GetSpeedLevel())
-				ui.UpdateDelayValue(marquee.GetDelay())
-				ui.UpdateCurrentOutput(marquee.GetCurrentOutputAddress())
-			} else {
-				ui.UpdateRunStatus("停止")
-				ui.UpdateSpeedLevel(0)
-				ui.UpdateDelayValue(0)
-				ui.UpdateCurrentOutput("无")
-			}
+	// 仿真器报文格式与客户端传输方式保持一致
+	switch config.Transport {
+	case "", "tcp":
+		simConfig.Framing = "tcp"
+	case "rtuovertcp", "ascii":
+		simConfig.Framing = config.Transport
+	case "rtu":
+		return nil, fmt.Errorf("in-process simulator does not support serial transport, use the sim subcommand with -serial")
+	}
 
-			// 更新IO状态（从PLC读取实际数据）
-			if client.IsConnected() {
-				// 读取DQ状态 (线圈0-13)
-								if resp, err := client.ReadCoils(0, 14); err == nil {
-					dqStatus := parseCoilsResponse(resp, 14)
-					for i := 0; i < 14 && i < len(dqStatus); i++ {
-						if dqStatus[i] {
-							ui.UpdateDQStatus(i, "ON")
-						} else {
-							ui.UpdateDQStatus(i, "OFF")
-						}
-					}
-				}
-
-								// 读取DI状态 (离散输入 10001-10014)
-				if resp, err := client.ReadDiscreteInputs(0, 14); err == nil {
-					diStatus := parseDiscreteInputsResponse(resp, 14)
-					for i := 0; i < 14 && i < len(diStatus); i++ {
-						if diStatus[i] {
-							ui.UpdateDIStatus(i, "ON")
-						} else {
-							ui.UpdateDIStatus(i, "OFF")
-						}
-					}
-				}
-
-				// DI状态由InputController处理，这里不需要重复更新
-			} else {
-				// 未连接时显示OFF状态
-				for i := 0; i < 14; i++ {
-					ui.UpdateDQStatus(i, "OFF")
-					ui.UpdateDIStatus(i, "OFF")
-				}
-			}
+	simulator := NewSimulator(simConfig)
+	if err := simulator.Start(); err != nil {
+		return nil, err
+	}
 
-			// 只有连接PLC时才更新环境数据
-			if client.IsConnected() {
-				// 从PLC读取实际环境数据
-				if temp, err := environmentMonitor.ReadTemperature(); err == nil {
-					ui.UpdateTemperature(temp)
-				}
-				if humid, err := environmentMonitor.ReadHumidity(); err == nil {
-					ui.UpdateHumidity(humid)
-				}
-			} else {
-				// 未连接时显示固定值
-				ui.UpdateTemperature(25.0)
-				ui.UpdateHumidity(60.0)
-			}
+	host, port, err := net.SplitHostPort(simulator.Addr().String())
+	if err != nil {
+		simulator.Stop()
+		return nil, err
+	}
+	config.IP = host
+	config.Port, _ = strconv.Atoi(port)
+	config.AutoConnect = true
+	config.simulated = true
 
-			time.Sleep(200 * time.Millisecond)
+	return simulator, nil
+}
+
+// coverBitTags 扩大仿真器的线圈和离散输入数量，使其覆盖站点标签表中的全部位地址（如扩展模块上的Q8.0）
+func coverBitTags(simConfig *SimulatorConfig, config *Config) {
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		return // 标签表错误在创建站点时报告
+	}
+	for _, tag := range db.Tags() {
+		switch tag.Area {
+		case AreaCoil:
+			simConfig.Coils = max(simConfig.Coils, tag.Offset+1)
+		case AreaDiscreteInput:
+			simConfig.DiscreteInputs = max(simConfig.DiscreteInputs, tag.Offset+1)
 		}
-	}()
+	}
+}
 
-	// 启动输入轮询
-	inputController.Start()
-	defer inputController.Stop()
+// offsetPort 将地址中的端口号加上offset，端口为0（自动分配）时保持不变
+func offsetPort(address string, offset int) (string, error) {
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return "", err
+	}
+	if port == 0 {
+		return address, nil
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port+offset)), nil
+}
 
-	// 启动环境监测
-	environmentMonitor.Start()
+// runSimulator 运行独立仿真器，直到收到中断信号
+func runSimulator(args []string) {
+	flags := flag.NewFlagSet("sim", flag.ExitOnError)
+	listen := flags.String("listen", "", "监听地址，默认使用配置中的simulator.listenAddr")
+	serialDevice := flags.String("serial", "", "串口设备，指定后以Modbus RTU方式运行，串口参数使用配置中的serial")
+	buttons := flags.Bool("buttons", false, "未配置simulator.buttons时，使用演示按钮脚本定时按下I0.0")
+	flags.Parse(args)
 
-	// 运行Web界面
-	ui.Run()
-}
\ No newline at end of file
+	config, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	simConfig := config.Simulator
+	if simConfig == nil {
+		simConfig = DefaultSimulatorConfig()
+	}
+	if *listen != "" {
+		simConfig.ListenAddr = *listen
+	}
+	if *buttons && len(simConfig.Buttons) == 0 {
+		simConfig.Buttons = DemoButtonPresses()
+	}
+	for _, stationConfig := range config.StationConfigs() {
+		coverBitTags(simConfig, stationConfig)
+	}
+
+	simulator := NewSimulator(simConfig)
+	if *serialDevice != "" {
+		serial := config.SerialSettings()
+		serial.Device = *serialDevice
+		err = simulator.StartRTU(serial)
+	} else {
+		err = simulator.Start()
+	}
+	if err != nil {
+		log.Fatalf("启动仿真器失败: %v", err)
+	}
+	defer simulator.Stop()
+
+	// 等待中断信号
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+	log.Println("仿真器已停止")
+}
+
+// runImportTags 导入TIA Portal变量表或MB_SERVER数据块布局，输出与当前标签表的差异报告
+func runImportTags(args []string) {
+	flags := flag.NewFlagSet("import-tags", flag.ExitOnError)
+	dbLayout := flags.Bool("db", false, "按MB_SERVER保持寄存器数据块布局导入（名称、数据类型、偏移量）")
+	startRegister := flags.Int("start", 0, "数据块首字对应的保持寄存器短地址，仅-db时使用")
+	output := flags.String("o", "", "将导入的标签表写入JSON文件")
+	apply := flags.Bool("apply", false, "将导入的标签合并到配置文件")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalf("用法: import-tags [-db [-start n]] [-o tags.json] [-apply] <导出文件.csv>")
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("打开导入文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var result *TagImport
+	if *dbLayout {
+		result, err = ImportDBLayout(file, *startRegister)
+	} else {
+		result, err = ImportTIATags(file)
+	}
+	if err != nil {
+		log.Fatalf("导入失败: %v", err)
+	}
+
+	for _, issue := range result.Skipped {
+		fmt.Printf("跳过第%d行 %s: %s\n", issue.Line, issue.Name, issue.Reason)
+	}
+
+	imported, err := NewTagDB(result.Tags)
+	if err != nil {
+		log.Fatalf("导入的标签无效: %v", err)
+	}
+	current, err := NewTagDB(config.TagTable())
+	if err != nil {
+		log.Fatalf("当前标签表无效: %v", err)
+	}
+	DiffTags(current, imported).WriteReport(os.Stdout)
+
+	if *output != "" {
+		data, err := json.MarshalIndent(result.Tags, "", "  ")
+		if err != nil {
+			log.Fatalf("生成标签表失败: %v", err)
+		}
+		if err := os.WriteFile(*output, append(data, '\n'), 0644); err != nil {
+			log.Fatalf("写入标签表失败: %v", err)
+		}
+		fmt.Printf("标签表已写入 %s\n", *output)
+	}
+
+	if *apply {
+		ApplyImportedTags(config, result.Tags)
+		if _, err := NewTagDB(config.TagTable()); err != nil {
+			log.Fatalf("合并后的标签表无效: %v", err)
+		}
+		if err := config.SaveConfig(); err != nil {
+			log.Fatalf("保存配置失败: %v", err)
+		}
+		fmt.Println("标签已合并到配置文件")
+	}
+}
+
+// runExport 导出站点历史数据
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	stationName := flags.String("station", "", "站点名称，默认为第一个站点")
+	dataset := flags.String("dataset", ExportSamples, "数据集：samples（模拟量采样）/events（数字量变化）/runs（跑马灯运行时段）")
+	format := flags.String("format", FormatCSV, "格式：csv/parquet")
+	from := flags.String("from", "", "开始时间（RFC3339、Unix毫秒或 2006-01-02[ 15:04]），默认结束时间前24小时")
+	to := flags.String("to", "", "结束时间，默认当前时间")
+	tags := flags.String("tag", "", "只导出这些标签，逗号分隔")
+	delimiter := flags.String("delimiter", ",", "CSV分隔符：单个字符或 tab/semicolon")
+	timezone := flags.String("tz", "", "CSV时间的时区，如 Asia/Shanghai、UTC、+08:00，默认本地时区")
+	output := flags.String("o", "", "输出文件，默认按站点、数据集和时间范围命名")
+	simulated := flags.Bool("sim", false, "导出仿真模式记录的历史数据")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		log.Fatalf("用法: export [-station 名称] [-dataset samples|events|runs] [-format csv|parquet] [-from 时间] [-to 时间] [-tag a,b] [-delimiter ;] [-tz 时区] [-o 文件]")
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	var stationConfig *Config
+	for _, c := range config.StationConfigs() {
+		if *stationName == "" || c.StationName() == *stationName {
+			stationConfig = c
+			break
+		}
+	}
+	if stationConfig == nil {
+		log.Fatalf("未找到站点 %s", *stationName)
+	}
+	stationConfig.simulated = *simulated
+
+	opts := ExportOptions{Dataset: *dataset, Format: *format, Units: make(map[string]string)}
+	if opts.To, err = parseHistoryTime(*to, time.Now()); err != nil {
+		log.Fatalf("无效的结束时间: %v", err)
+	}
+	if opts.From, err = parseHistoryTime(*from, opts.To.Add(-24*time.Hour)); err != nil {
+		log.Fatalf("无效的开始时间: %v", err)
+	}
+	if *tags != "" {
+		opts.Tags = strings.Split(*tags, ",")
+	}
+	if opts.Delimiter, err = ParseDelimiter(*delimiter); err != nil {
+		log.Fatalf("无效的分隔符: %v", err)
+	}
+	if opts.Location, err = ParseTimezone(*timezone); err != nil {
+		log.Fatalf("无效的时区: %v", err)
+	}
+	for _, tag := range stationConfig.TagTable() {
+		opts.Units[tag.Name] = tag.Unit
+	}
+	if err := opts.normalize(); err != nil {
+		log.Fatalf("导出参数无效: %v", err)
+	}
+
+	path := *output
+	if path == "" {
+		path = opts.FileName(stationConfig.StationName())
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("创建导出文件失败: %v", err)
+	}
+	writer := bufio.NewWriter(file)
+	rows, err := ExportHistory(writer, NewHistorian(stationConfig), opts)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("导出失败: %v", err)
+	}
+	fmt.Printf("已导出 %d 行到 %s\n", rows, path)
+}
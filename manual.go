@@ -1,97 +1,93 @@
 package main
 
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errManualControlLocked 跑马灯运行时拒绝手动写输出
+var errManualControlLocked = errors.New("manual control locked while marquee is running")
+
 // ManualController 手动控制器
 type ManualController struct {
-	client  *ModbusClient
+	tags    *TagClient
 	marquee *MarqueeController
-	ui      *WebUI
+	ui      *StationView
+	lamps   []string // 输出点标签名
 }
 
 // NewManualController 创建新的手动控制器
-func NewManualController(client *ModbusClient, marquee *MarqueeController, ui *WebUI) *ManualController {
+func NewManualController(tags *TagClient, marquee *MarqueeController, ui *StationView) *ManualController {
 	return &ManualController{
-		client:  client,
+		tags:    tags,
 		marquee: marquee,
 		ui:      ui,
+		lamps:   marquee.lamps,
 	}
 }
 
-// SetOutput 设置指定输出点的状态
-func (mc *ManualController) SetOutput(index int, value bool) error {
+// SetOutput 设置指定输出点的状态，其余输出点保持不变
+// 跑马灯运行时返回errManualControlLocked
+func (mc *ManualController) SetOutput(ctx context.Context, index int, value bool) error {
 	if mc.marquee.IsRunning() {
 		// 跑马灯运行时不允许手动控制
-		return nil
-	}
-	
-	if mc.client.conn == nil {
-		return nil
+		return errManualControlLocked
 	}
-	
-	if index < 0 || index >= 14 {
-		return nil
+
+	if index < 0 || index >= len(mc.lamps) {
+		return fmt.Errorf("invalid output index: %d", index)
 	}
-	
-	// 读取当前所有输出点状态
-	currentOutputs := make([]bool, 14)
-	
-	// 设置指定输出点
-	currentOutputs[index] = value
-	
-	// 写入到PLC
-	_, err := mc.client.WriteMultipleCoils(0, currentOutputs)
-	return err
+
+	// 只写入指定输出点
+	return mc.tags.WriteBoolCtx(ctx, mc.lamps[index], value)
 }
 
 // SetAllOutputs 设置所有输出点的状态
-func (mc *ManualController) SetAllOutputs(values []bool) error {
+func (mc *ManualController) SetAllOutputs(ctx context.Context, values []bool) error {
 	if mc.marquee.IsRunning() {
 		// 跑马灯运行时不允许手动控制
 		return nil
 	}
 	
-	if mc.client.conn == nil {
+	if !mc.tags.IsConnected() {
 		return nil
 	}
 	
-	if len(values) != 14 {
+	if len(values) != len(mc.lamps) {
 		return nil
 	}
 	
 	// 写入到PLC
-	_, err := mc.client.WriteMultipleCoils(0, values)
-	return err
+	return mc.tags.WriteBoolsCtx(ctx, mc.lamps, values)
 }
 
 // ToggleOutput 设置指定输出点的状态（根据复选框状态）
-func (mc *ManualController) ToggleOutput(index int) error {
+func (mc *ManualController) ToggleOutput(ctx context.Context, index int) error {
 	if mc.marquee.IsRunning() {
 		// 跑马灯运行时不允许手动控制
 		return nil
 	}
 
-	if mc.client.conn == nil {
+	if !mc.tags.IsConnected() {
 		return nil
 	}
 
-	if index < 0 || index >= 14 {
+	if index < 0 || index >= len(mc.lamps) {
 		return nil
 	}
 
 	// 读取当前所有输出点状态
-	resp, err := mc.client.ReadCoils(0, 14)
+	currentOutputs, err := mc.tags.ReadBoolsCtx(ctx, mc.lamps)
 	if err != nil {
 		return err
 	}
 
-	// 解析当前输出状态
-	currentOutputs := parseCoilsResponse(resp, 14)
-
 	// 注意：这里不切换状态，而是等待WebUI传递实际状态
 	// WebUI会根据复选框的checked状态来设置值
 
 	// 写入到PLC
-	_, err = mc.client.WriteMultipleCoils(0, currentOutputs)
-	return err
+	return mc.tags.WriteBoolsCtx(ctx, mc.lamps, currentOutputs)
 }
 
 // IsManualControlAllowed 检查是否允许手动控制
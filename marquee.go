@@ -1,158 +1,388 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // MarqueeController 跑马灯控制器
 type MarqueeController struct {
-	client       *ModbusClient
-	config       *Config
-	currentIndex int        // 当前点亮的输出点索引
-	speedLevel   int        // 速度挡位 (1-3)
-	isRunning    bool       // 是否正在运行
-	stopChan     chan bool  // 停止信号通道
+	tags      *TagClient
+	config    *Config
+	lamps     []string           // 输出点标签名，按点亮顺序排列
+	runMu     sync.Mutex         // 串行化启动、停止与重连后的输出恢复
+	isRunning atomic.Bool        // 是否正在运行
+	cancel    context.CancelFunc // 停止运行循环并中止其在途写入
+	done      chan struct{}      // 运行循环退出时关闭
+
+	mu         sync.Mutex
+	speedMode  SpeedMode          // 调速方式，停止时为空
+	speedLevel int                // 速度挡位 (1-len(SpeedDelays))，不按挡位调速时为0
+	speed      speedRamp          // 当前速度及目标速度
+	pattern    PatternInfo        // 当前花样
+	newPlayer  func() framePlayer // 创建当前花样从头开始的播放状态
+	player     framePlayer
+	frame      []bool // 当前输出帧，停止时全部为false
+
+	timing stepTiming // 步进时序统计
 }
 
-// NewMarqueeController 创建新的跑马灯控制器
-func NewMarqueeController(client *ModbusClient, config *Config) *MarqueeController {
-	return &MarqueeController{
-		client:       client,
-		config:       config,
-		currentIndex: -1,
-		speedLevel:   0,
-		isRunning:    false,
-		stopChan:     make(chan bool),
+// NewMarqueeController 创建新的跑马灯控制器，初始为默认花样，配置中的花样由SetPattern选择
+func NewMarqueeController(tags *TagClient, config *Config) *MarqueeController {
+	lamps := config.LampTagNames()
+	m := &MarqueeController{
+		tags:   tags,
+		config: config,
+		lamps:  lamps,
+		frame:  make([]bool, len(lamps)),
 	}
+	pattern, newPlayer, err := m.load(DefaultPattern)
+	if err != nil {
+		// 默认花样为内置花样，只有未注册时才会找不到；退回单点流水，保证运行循环始终有播放状态
+		log.Printf("加载默认花样失败，使用单点流水: %v", err)
+		chase := &Pattern{Name: DefaultPattern, Label: "单点流水", Frame: chaseFrame}
+		pattern = PatternInfo{Name: chase.Name, Label: chase.Label}
+		newPlayer = func() framePlayer { return &patternPlayer{pattern: chase} }
+	}
+	m.pattern, m.newPlayer = pattern, newPlayer
+	m.player = m.newPlayer()
+	return m
 }
 
 // Start 启动跑马灯
 func (m *MarqueeController) Start() {
-	if m.isRunning {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	if m.isRunning.Load() {
 		return
 	}
 	
-	m.isRunning = true
-	m.speedLevel = 1 // 默认启动为1挡
+	m.isRunning.Store(true)
+
+	// 配置了调速模拟量通道时由模拟量调速，收到读数前按1挡运行；否则默认启动为1挡
+	m.mu.Lock()
+	m.speed = speedRamp{} // 启动时直接以目标速度运行，不经过渡
+	m.setSpeedLocked(SpeedByLevel, 1, float64(m.speedDelays()[0]))
+	if m.speedInput() != nil {
+		m.speedMode, m.speedLevel = SpeedByAnalog, 0
+	}
+	m.mu.Unlock()
+	m.timing.reset()
 	
 	// 启动跑马灯循环协程
-	go m.run()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go m.run(ctx, m.done)
 }
 
 // Stop 停止跑马灯
+// 等待运行循环退出后再清除输出点，避免最后一步的写入落在全部熄灭之后
 func (m *MarqueeController) Stop() {
-	if !m.isRunning {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	if !m.isRunning.Load() {
 		return
 	}
 	
-	m.isRunning = false
-	m.stopChan <- true
+	m.isRunning.Store(false)
+	m.cancel()
+	<-m.done
 	
 	// 清除所有输出点
 	m.clearAllOutputs()
 	
 	// 重置状态
-	m.currentIndex = -1
-	m.speedLevel = 0
+	m.mu.Lock()
+	m.player = m.newPlayer()
+	clear(m.frame)
+	m.speedMode, m.speedLevel, m.speed = "", 0, speedRamp{}
+	m.mu.Unlock()
 }
 
 // SwitchSpeed 切换速度挡位
 func (m *MarqueeController) SwitchSpeed() {
-	if !m.isRunning {
+	if !m.isRunning.Load() {
 		return
 	}
 	
-	// 按顺序切换挡位: 1→2→3→1
-	m.speedLevel++
-	if m.speedLevel > 3 {
-		m.speedLevel = 1
+	// 按顺序切换挡位: 1→2→…→N→1，N为speedDelays的长度；设定延时或模拟量调速时切换到1挡
+	delays := m.speedDelays()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	level := m.speedLevel + 1
+	if level > len(delays) {
+		level = 1
 	}
+	m.setSpeedLocked(SpeedByLevel, level, float64(delays[level-1]))
 }
 
-// GetDelay 获取当前挡位延时值
+// GetDelay 获取当前延时值（毫秒），速度过渡中为过渡中的值，停止时为1挡延时
 func (m *MarqueeController) GetDelay() int {
-	if m.config == nil || len(m.config.SpeedDelays) == 0 {
-		return 1000 // 默认延时
-	}
-	if m.speedLevel <= 0 || m.speedLevel > len(m.config.SpeedDelays) {
-		return 1000 // 默认延时
-	}
-	return m.config.SpeedDelays[m.speedLevel-1]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int(math.Round(m.delayLocked()))
 }
 
 // IsRunning 检查跑马灯是否正在运行
 func (m *MarqueeController) IsRunning() bool {
-	return m.isRunning
+	return m.isRunning.Load()
 }
 
 // GetSpeedLevel 获取当前速度挡位
 func (m *MarqueeController) GetSpeedLevel() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.speedLevel
 }
 
-// GetCurrentIndex 获取当前输出点索引
+// GetCurrentIndex 获取当前点亮的第一个输出点索引，没有点亮的输出点时返回-1
 func (m *MarqueeController) GetCurrentIndex() int {
-	return m.currentIndex
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, on := range m.frame {
+		if on {
+			return i
+		}
+	}
+	return -1
 }
 
-// GetCurrentOutputAddress 获取当前输出点地址
+// GetCurrentOutputAddress 获取当前点亮的输出点地址，多个点亮时以空格分隔
 func (m *MarqueeController) GetCurrentOutputAddress() string {
-	if m.currentIndex < 0 || m.currentIndex >= 14 {
+	var addresses []string
+	all := m.OutputAddresses()
+	for i, on := range m.Frame() {
+		if on && all[i] != "" {
+			addresses = append(addresses, all[i])
+		}
+	}
+	if len(addresses) == 0 {
 		return "无"
 	}
-	return fmt.Sprintf("Q%d.%d", m.currentIndex/8, m.currentIndex%8)
+	return strings.Join(addresses, " ")
 }
 
-// run 跑马灯主循环
-func (m *MarqueeController) run() {
-	// 输出点顺序: 1-14 (对应Q0.0-Q1.5)
-	outputs := make([]bool, 14)
-	
-	ticker := time.NewTicker(time.Duration(m.GetDelay()) * time.Millisecond)
-	defer ticker.Stop()
+// OutputAddresses 返回各输出点的地址，按点亮顺序排列
+func (m *MarqueeController) OutputAddresses() []string {
+	return m.tags.DB().Addresses(m.lamps)
+}
+
+// Frame 返回当前输出帧的副本
+func (m *MarqueeController) Frame() []bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bool(nil), m.frame...)
+}
+
+// Pattern 返回当前花样
+func (m *MarqueeController) Pattern() PatternInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pattern
+}
+
+// Patterns 返回可选的花样：内置花样在前，自定义序列在后，灯位数与输出点数量不符的序列不可选
+func (m *MarqueeController) Patterns() []PatternInfo {
+	var infos []PatternInfo
+	for _, p := range Patterns() {
+		infos = append(infos, PatternInfo{Name: p.Name, Label: p.Label})
+	}
+	if m.config != nil {
+		for _, seq := range m.config.SequenceList() {
+			if n := seq.LampCount(); n != 0 && n != len(m.lamps) {
+				continue
+			}
+			infos = append(infos, PatternInfo{Name: seq.Name, Label: seq.DisplayName(), Sequence: true})
+		}
+	}
+	return infos
+}
+
+// load 按名称查找内置花样或自定义序列，名称为空时为默认花样
+func (m *MarqueeController) load(name string) (PatternInfo, func() framePlayer, error) {
+	if pattern, err := LookupPattern(name); err == nil {
+		info := PatternInfo{Name: pattern.Name, Label: pattern.Label}
+		return info, func() framePlayer { return &patternPlayer{pattern: pattern} }, nil
+	}
+	if m.config != nil {
+		for _, config := range m.config.SequenceList() {
+			if config.Name != name {
+				continue
+			}
+			seq, err := CompileSequence(config, len(m.lamps))
+			if err != nil {
+				return PatternInfo{}, nil, fmt.Errorf("sequence %s: %w", name, err)
+			}
+			info := PatternInfo{Name: seq.Name, Label: seq.Label, Sequence: true}
+			return info, func() framePlayer { return seq.player() }, nil
+		}
+	}
+	return PatternInfo{}, nil, fmt.Errorf("unknown pattern %q", name)
+}
+
+// SetPattern 切换到内置花样或自定义序列并从第一步开始，自定义序列按配置中的当前内容重新编译
+func (m *MarqueeController) SetPattern(name string) error {
+	pattern, newPlayer, err := m.load(name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pattern = pattern
+	m.newPlayer = newPlayer
+	m.player = newPlayer()
+	if m.config != nil {
+		m.config.SetPattern(pattern.Name)
+	}
+	return nil
+}
+
+// NextPattern 按Patterns的顺序切换到下一个花样，返回切换后的花样
+func (m *MarqueeController) NextPattern() PatternInfo {
+	all := m.Patterns()
+	current := m.Pattern()
+	next := all[0]
+	for i, p := range all {
+		if p.Name == current.Name {
+			next = all[(i+1)%len(all)]
+			break
+		}
+	}
+	if err := m.SetPattern(next.Name); err != nil {
+		log.Printf("切换花样失败: %v", err)
+		return current
+	}
+	return next
+}
+
+// PreviewSequence 按本控制器的输出点数量编译序列，展开一个周期（最多limit帧）供预览
+// 返回的bool表示是否展开了完整周期
+func (m *MarqueeController) PreviewSequence(config SequenceConfig, limit int) ([]SequenceFrame, bool, error) {
+	seq, err := CompileSequence(config, len(m.lamps))
+	if err != nil {
+		return nil, false, err
+	}
+	frames, complete := seq.Preview(len(m.lamps), limit)
+	return frames, complete, nil
+}
+
+// WatchConnection 订阅连接状态，重连后立即恢复当前输出点
+func (m *MarqueeController) WatchConnection(supervisor *ConnectionSupervisor) {
+	events := supervisor.Subscribe()
+	go func() {
+		for event := range events {
+			if event.State == StateConnected && event.Previous != StateConnected {
+				m.restoreOutput()
+			}
+		}
+	}()
+}
+
+// restoreOutput 运行中时重新写入当前输出帧，与Stop互斥以免覆盖停止时的全部熄灭
+func (m *MarqueeController) restoreOutput() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	if m.isRunning.Load() {
+		m.writeCurrentOutput()
+	}
+}
+
+// writeCurrentOutput 将当前输出帧写入PLC
+func (m *MarqueeController) writeCurrentOutput() {
+	outputs := m.Frame()
+	if m.tags.IsConnected() {
+		m.tags.WriteBools(m.lamps, outputs)
+	}
+}
+
+// nextFrame 按当前花样生成下一帧并保存为当前输出帧，返回其副本和时长
+func (m *MarqueeController) nextFrame() ([]bool, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.frame)
+	var duration time.Duration
+	if len(m.frame) > 0 {
+		var err error
+		if duration, err = m.player.next(m.frame); err != nil {
+			// 序列编译时已试运行一个周期，只有极长的序列才可能在运行中出现死循环
+			log.Printf("花样 %s 停止输出: %v", m.pattern.Label, err)
+			m.player = m.newPlayer()
+		}
+	}
+	if duration <= 0 {
+		duration = time.Duration(m.delayLocked() * float64(time.Millisecond))
+	}
+	return append([]bool(nil), m.frame...), duration
+}
+
+// run 跑马灯主循环，ctx取消时退出并关闭done
+// 按单调时间轴调度：每一步的计划时刻为上一步的计划时刻加上一步的时长，写入耗时和定时器唤醒延迟不会累积
+func (m *MarqueeController) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	due := time.Now().Add(time.Duration(m.GetDelay()) * time.Millisecond)
+	timer := time.NewTimer(time.Until(due))
+	defer timer.Stop()
+	var writeErr error // 上一次写入的错误
 	
 	for {
 		select {
-		case <-m.stopChan:
+		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			if !m.isRunning {
+		case <-timer.C:
+			if !m.isRunning.Load() {
 				return
 			}
+			start := time.Now()
 			
-			// 更新定时器间隔
-			ticker.Reset(time.Duration(m.GetDelay()) * time.Millisecond)
+			// 按当前花样生成下一帧，下一步的计划时刻为本步计划时刻加上该帧时长
+			// 输出点顺序: Lamp01起按配置的输出点地址排列 (默认为Q0.0-Q1.5)
+			outputs, duration := m.nextFrame()
 			
-			// 关闭当前点亮的输出点
-			if m.currentIndex >= 0 {
-				outputs[m.currentIndex] = false
+			// 写入到PLC，连续失败时只在第一次记录日志
+			written := m.tags.IsConnected()
+			var err error
+			if written {
+				err = m.tags.WriteBoolsCtx(ctx, m.lamps, outputs)
+				if err != nil && writeErr == nil && ctx.Err() == nil {
+					log.Printf("写入输出点失败: %v", err)
+				}
+				writeErr = err
 			}
+			finished := time.Now()
+			m.timing.record(start.Sub(due), finished.Sub(start), written, err)
 			
-			// 移动到下一个输出点
-			m.currentIndex++
-			if m.currentIndex >= 14 {
-				m.currentIndex = 0
-			}
-			
-			// 点亮新的输出点
-			outputs[m.currentIndex] = true
-			
-			// 写入到PLC
-			if m.client.conn != nil {
-				// 使用短地址0对应逻辑地址1
-				m.client.WriteMultipleCoils(0, outputs)
+			// 写入完成时已超过下一步的计划时刻则立即执行下一步；落后超过一步时不补发错过的步，
+			// 从写入完成时刻重新对齐，下一步仍保持完整的一步时长
+			due = due.Add(duration)
+			if late := finished.Sub(due); late > 0 {
+				resync := late > duration
+				if resync {
+					due = finished.Add(duration)
+				}
+				m.timing.recordOverrun(resync)
 			}
+			timer.Reset(time.Until(due))
 		}
 	}
 }
 
+// TimingStats 返回自启动以来的步进时序统计
+func (m *MarqueeController) TimingStats() TimingStats {
+	return m.timing.stats()
+}
+
 // clearAllOutputs 清除所有输出点
 func (m *MarqueeController) clearAllOutputs() {
-	outputs := make([]bool, 14)
+	outputs := make([]bool, len(m.lamps))
 	
-	if m.client.conn != nil {
-		// 使用短地址0对应逻辑地址1
-		m.client.WriteMultipleCoils(0, outputs)
+	if m.tags.IsConnected() {
+		m.tags.WriteBools(m.lamps, outputs)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestMarquee 创建连接到仿真器的跑马灯控制器，步进延时为10ms
+func newTestMarquee(t *testing.T) (*Simulator, *MarqueeController) {
+	t.Helper()
+	config := DefaultConfig()
+	config.SpeedDelays = []int{10}
+	sim, client := startTestSimulator(t, len(config.LampTagNames()))
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim, NewMarqueeController(NewTagClient(client, db), config)
+}
+
+func TestMarqueeConcurrentStartStop(t *testing.T) {
+	sim, m := newTestMarquee(t)
+
+	for range 20 {
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				switch i % 4 {
+				case 0, 1:
+					m.Start()
+				case 2:
+					m.Stop()
+				default:
+					m.SwitchSpeed()
+					m.IsRunning()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	m.Start()
+	time.Sleep(50 * time.Millisecond)
+	m.Stop()
+	if m.IsRunning() {
+		t.Fatal("marquee still running after Stop")
+	}
+
+	// 停止后不能再有步进写入：并发Start多启动的运行循环会继续输出
+	steps := m.TimingStats().Steps
+	time.Sleep(50 * time.Millisecond)
+	if got := m.TimingStats().Steps; got != steps {
+		t.Fatalf("steps advanced from %d to %d after Stop", steps, got)
+	}
+	if coils := sim.Coils(); slices.Contains(coils, true) {
+		t.Fatalf("outputs not cleared after Stop: %v", coils)
+	}
+}
+
+func TestMarqueeResyncKeepsFullStep(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	const latency = 60 * time.Millisecond // 每次写入耗时超过一步，每一步都需要重新对齐
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var mu sync.Mutex
+	var arrivals []time.Time
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header, pdu, err := readMBAPFrame(conn)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			arrivals = append(arrivals, time.Now())
+			mu.Unlock()
+			time.Sleep(latency)
+			// 写单个/多个线圈的响应为功能码、地址和数量（或值）
+			conn.Write(encodeMBAPFrame(header.TransactionID, header.UnitID, pdu[:5]))
+		}
+	}()
+
+	config := DefaultConfig()
+	config.SpeedDelays = []int{int(delay / time.Millisecond)}
+	addr := listener.Addr().(*net.TCPAddr)
+	config.IP, config.Port = addr.IP.String(), addr.Port
+	client := NewModbusClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMarqueeController(NewTagClient(client, db), config)
+
+	m.Start()
+	time.Sleep(10 * (latency + delay))
+	mu.Lock()
+	steps := slices.Clone(arrivals)
+	mu.Unlock()
+	m.Stop()
+
+	if len(steps) < 4 {
+		t.Fatalf("only %d steps written", len(steps))
+	}
+	// 重新对齐后下一步在写入完成后再等待完整的一步，而不是立即执行
+	for i := 1; i < len(steps); i++ {
+		if gap := steps[i].Sub(steps[i-1]); gap < latency+delay*3/4 {
+			t.Errorf("step %d started %s after the previous one, want at least %s", i, gap, latency+delay)
+		}
+	}
+	if stats := m.TimingStats(); stats.Resyncs == 0 {
+		t.Errorf("no resyncs recorded: %+v", stats)
+	}
+}
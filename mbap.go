@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MBAP报文常量
+const (
+	mbapHeaderLength = 7   // MBAP头长度
+	mbapMinLength    = 2   // 长度字段最小值（Unit ID + 功能码）
+	mbapMaxLength    = 254 // 长度字段最大值（Unit ID + 253字节PDU）
+)
+
+// mbapHeader MBAP报文头
+type mbapHeader struct {
+	TransactionID uint16 // 事务ID
+	ProtocolID    uint16 // 协议ID，Modbus固定为0
+	Length        uint16 // 后续字节数（含Unit ID）
+	UnitID        byte   // 单元标识
+}
+
+// encodeMBAPFrame 构造带MBAP头的请求报文
+func encodeMBAPFrame(tid uint16, unitID byte, pdu []byte) []byte {
+	frame := make([]byte, mbapHeaderLength+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:2], tid)                // 事务ID
+	binary.BigEndian.PutUint16(frame[2:4], 0)                  // 协议ID
+	binary.BigEndian.PutUint16(frame[4:6], uint16(len(pdu)+1)) // 长度
+	frame[6] = unitID                                          // Unit ID
+	copy(frame[mbapHeaderLength:], pdu)
+	return frame
+}
+
+// readMBAPFrame 从数据流中读取一个完整的MBAP报文
+// 使用io.ReadFull保证TCP分段时也能读取到完整的报文头和PDU
+func readMBAPFrame(r io.Reader) (mbapHeader, []byte, error) {
+	var header mbapHeader
+
+	raw := make([]byte, mbapHeaderLength)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return header, nil, err
+	}
+
+	header.TransactionID = binary.BigEndian.Uint16(raw[0:2])
+	header.ProtocolID = binary.BigEndian.Uint16(raw[2:4])
+	header.Length = binary.BigEndian.Uint16(raw[4:6])
+	header.UnitID = raw[6]
+
+	// 校验协议ID与长度范围
+	if header.ProtocolID != 0 {
+		return header, nil, &FrameError{Reason: fmt.Sprintf("protocol ID %d", header.ProtocolID)}
+	}
+	if header.Length < mbapMinLength || header.Length > mbapMaxLength {
+		return header, nil, &FrameError{Reason: fmt.Sprintf("length %d out of range", header.Length)}
+	}
+
+	pdu := make([]byte, header.Length-1)
+	if _, err := io.ReadFull(r, pdu); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return header, nil, err
+	}
+
+	return header, pdu, nil
+}
+
+// tcpTransport Modbus TCP传输层（MBAP报文头）
+// 支持多个事务同时在途：请求按事务ID登记，由读取goroutine将响应分发给等待的调用方
+type tcpTransport struct {
+	conn   net.Conn
+	config *Config
+	tid    atomic.Uint32 // 事务ID计数器
+
+	window  chan struct{} // 在途事务令牌，容量即流水线窗口大小
+	writeMu sync.Mutex    // 保证请求报文完整写入，不与其他请求交错
+
+	mu      sync.Mutex
+	pending map[uint16]chan tcpResponse // 等待响应的事务
+	done    chan struct{}               // 连接失效后关闭
+	err     error                       // 连接失效原因
+}
+
+// tcpResponse 读取goroutine分发给调用方的响应
+type tcpResponse struct {
+	unitID byte
+	pdu    []byte
+	err    error
+}
+
+// dialTCP 建立Modbus TCP连接
+func dialTCP(ctx context.Context, config *Config) (*tcpTransport, error) {
+	conn, err := dialTCPConn(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return newTCPTransport(conn, config), nil
+}
+
+// newTCPTransport 在已建立的TCP连接上创建传输层并启动响应读取goroutine
+func newTCPTransport(conn net.Conn, config *Config) *tcpTransport {
+	t := &tcpTransport{
+		conn:    conn,
+		config:  config,
+		window:  make(chan struct{}, config.PipelineDepth()),
+		pending: make(map[uint16]chan tcpResponse),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop()
+	return t
+}
+
+// nextTID 获取下一个事务ID（跳过0）
+func (t *tcpTransport) nextTID() uint16 {
+	for {
+		if tid := uint16(t.tid.Add(1)); tid != 0 {
+			return tid
+		}
+	}
+}
+
+// Send 发送请求PDU并接收响应PDU
+// 窗口已满时等待其他事务完成；ctx取消后立即返回，迟到的响应由读取goroutine丢弃
+func (t *tcpTransport) Send(ctx context.Context, unitID byte, pdu []byte) ([]byte, error) {
+	// 获取在途事务令牌
+	select {
+	case t.window <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.done:
+		return nil, &LinkError{Err: t.err}
+	}
+	defer func() { <-t.window }()
+
+	// 登记事务，超时截止时间覆盖发送和等待响应
+	tid, respCh, err := t.register()
+	if err != nil {
+		return nil, err
+	}
+	defer t.unregister(tid)
+	timer := time.NewTimer(t.config.WriteTimeout() + t.config.ReadTimeout())
+	defer timer.Stop()
+
+	// 发送请求
+	t.writeMu.Lock()
+	t.conn.SetWriteDeadline(requestDeadline(ctx, t.config.WriteTimeout()))
+	_, err = t.conn.Write(encodeMBAPFrame(tid, unitID, pdu))
+	t.writeMu.Unlock()
+	if err != nil {
+		// 发送失败或只写出半帧，连接不可用
+		t.fail(err)
+		return nil, &LinkError{Err: err}
+	}
+
+	// 等待读取goroutine分发响应
+	// 超时后事务被注销，迟到的响应由读取goroutine丢弃
+	select {
+	case resp := <-respCh:
+		if resp.err != nil {
+			return nil, resp.err
+		}
+		// 验证Unit ID
+		if resp.unitID != unitID {
+			return nil, &FrameError{Reason: fmt.Sprintf("unit ID mismatch: expected %d, got %d", unitID, resp.unitID)}
+		}
+		return resp.pdu, nil
+	case <-timer.C:
+		return nil, os.ErrDeadlineExceeded
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.done:
+		return nil, &LinkError{Err: t.err}
+	}
+}
+
+// register 分配事务ID并登记等待通道
+func (t *tcpTransport) register() (uint16, chan tcpResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return 0, nil, &LinkError{Err: t.err}
+	}
+
+	// 跳过仍在等待响应的事务ID（仅在65535次请求内有事务未完成时发生）
+	tid := t.nextTID()
+	for t.pending[tid] != nil {
+		tid = t.nextTID()
+	}
+
+	respCh := make(chan tcpResponse, 1)
+	t.pending[tid] = respCh
+	return tid, respCh, nil
+}
+
+// unregister 注销事务
+func (t *tcpTransport) unregister(tid uint16) {
+	t.mu.Lock()
+	delete(t.pending, tid)
+	t.mu.Unlock()
+}
+
+// dispatch 将响应交给对应事务，返回false表示事务已超时或不存在
+func (t *tcpTransport) dispatch(tid uint16, resp tcpResponse) bool {
+	t.mu.Lock()
+	respCh := t.pending[tid]
+	delete(t.pending, tid)
+	t.mu.Unlock()
+
+	if respCh == nil {
+		return false
+	}
+	respCh <- resp
+	return true
+}
+
+// failPending 以同一错误结束所有在途事务
+func (t *tcpTransport) failPending(err error) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[uint16]chan tcpResponse)
+	t.mu.Unlock()
+
+	for _, respCh := range pending {
+		respCh <- tcpResponse{err: err}
+	}
+}
+
+// fail 标记连接失效并关闭，所有在途和后续事务返回链路错误
+func (t *tcpTransport) fail(err error) {
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return
+	}
+	t.err = err
+	close(t.done)
+	t.mu.Unlock()
+
+	t.conn.Close()
+}
+
+// readLoop 持续读取响应并按事务ID分发，连接失效时退出
+func (t *tcpTransport) readLoop() {
+	for {
+		header, pdu, err := readMBAPFrame(t.conn)
+		if err != nil {
+			var frameErr *FrameError
+			if errors.As(err, &frameErr) {
+				// 报文错误后无法确定属于哪个事务，在途事务的响应均已不可信
+				t.failPending(err)
+				// 流中可能残留半帧数据，丢弃后重新同步
+				if drainConn(t.conn) {
+					t.conn.SetReadDeadline(time.Time{})
+					continue
+				}
+			}
+			// 读取失败，连接不可用
+			t.fail(err)
+			return
+		}
+
+		// 找不到事务说明是之前超时请求的迟到响应，直接丢弃
+		if !t.dispatch(header.TransactionID, tcpResponse{unitID: header.UnitID, pdu: pdu}) {
+			log.Printf("丢弃过期响应: 事务ID %d", header.TransactionID)
+		}
+	}
+}
+
+// Close 关闭TCP连接
+// 在途事务立即返回链路错误
+func (t *tcpTransport) Close() error {
+	t.fail(net.ErrClosed)
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mbapRequest 假服务器收到的请求
+type mbapRequest struct {
+	header mbapHeader
+	pdu    []byte
+}
+
+// startFakeMBAPServer 启动只接受一个连接的假Modbus TCP服务器，serve在连接上按脚本收发报文
+// 返回连接到该服务器的传输层
+func startFakeMBAPServer(t testing.TB, config *Config, serve func(conn net.Conn)) *tcpTransport {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := newTCPTransport(conn, config)
+	t.Cleanup(func() {
+		transport.Close()
+		<-served
+	})
+	return transport
+}
+
+// readRequests 读取n个请求报文
+func readRequests(t testing.TB, conn net.Conn, n int) []mbapRequest {
+	t.Helper()
+	requests := make([]mbapRequest, n)
+	for i := range requests {
+		header, pdu, err := readMBAPFrame(conn)
+		if err != nil {
+			t.Errorf("read request: %v", err)
+			return nil
+		}
+		requests[i] = mbapRequest{header: header, pdu: pdu}
+	}
+	return requests
+}
+
+// echoResponse 以请求PDU作为响应PDU回送
+func echoResponse(conn net.Conn, req mbapRequest) error {
+	_, err := conn.Write(encodeMBAPFrame(req.header.TransactionID, req.header.UnitID, req.pdu))
+	return err
+}
+
+func testTransportConfig(window int) *Config {
+	config := DefaultConfig()
+	config.PipelineWindow = window
+	config.ReadTimeoutMs = 200
+	config.WriteTimeoutMs = 200
+	return config
+}
+
+func TestTCPTransportOutOfOrderResponses(t *testing.T) {
+	const window = 4
+	const rounds = 25
+	transport := startFakeMBAPServer(t, testTransportConfig(window), func(conn net.Conn) {
+		// 每轮收满一个窗口的请求后倒序应答
+		for range rounds {
+			requests := readRequests(t, conn, window)
+			for i := len(requests) - 1; i >= 0; i-- {
+				if err := echoResponse(conn, requests[i]); err != nil {
+					t.Errorf("write response: %v", err)
+					return
+				}
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	for w := range window {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rounds {
+				pdu := []byte{0x03, byte(w), byte(i)}
+				resp, err := transport.Send(context.Background(), 1, pdu)
+				if err != nil {
+					t.Errorf("worker %d request %d: %v", w, i, err)
+					return
+				}
+				if string(resp) != string(pdu) {
+					t.Errorf("worker %d request %d: got response % X, want % X", w, i, resp, pdu)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTCPTransportStaleTransactionID(t *testing.T) {
+	stale := make(chan struct{})
+	transport := startFakeMBAPServer(t, testTransportConfig(2), func(conn net.Conn) {
+		first := readRequests(t, conn, 1)
+		if first == nil {
+			return
+		}
+		// 第一个请求超时后才收到第二个请求，先回送过期响应再应答第二个请求
+		<-stale
+		second := readRequests(t, conn, 1)
+		if second == nil {
+			return
+		}
+		echoResponse(conn, first[0])
+		echoResponse(conn, mbapRequest{header: second[0].header, pdu: []byte{0x03, 0x02, 0xBE, 0xEF}})
+	})
+
+	_, err := transport.Send(context.Background(), 1, []byte{0x03, 0x01})
+	close(stale)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("first request: got %v, want timeout", err)
+	}
+
+	resp, err := transport.Send(context.Background(), 1, []byte{0x03, 0x02})
+	if err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+	if want := []byte{0x03, 0x02, 0xBE, 0xEF}; string(resp) != string(want) {
+		t.Fatalf("second request got stale response % X, want % X", resp, want)
+	}
+
+	transport.mu.Lock()
+	pending := len(transport.pending)
+	transport.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("%d transactions still pending", pending)
+	}
+}
+
+func TestTCPTransportFrameErrorResync(t *testing.T) {
+	transport := startFakeMBAPServer(t, testTransportConfig(2), func(conn net.Conn) {
+		first := readRequests(t, conn, 1)
+		if first == nil {
+			return
+		}
+		// 协议ID错误的报文头后跟半帧残留数据
+		bad := encodeMBAPFrame(first[0].header.TransactionID, 1, first[0].pdu)
+		bad[3] = 0x01
+		conn.Write(append(bad, 0x00, 0x07, 0x00))
+
+		second := readRequests(t, conn, 1)
+		if second == nil {
+			return
+		}
+		echoResponse(conn, second[0])
+		readMBAPFrame(conn) // 保持连接直到客户端关闭
+	})
+
+	_, err := transport.Send(context.Background(), 1, []byte{0x03, 0x01})
+	var frameErr *FrameError
+	if !errors.As(err, &frameErr) {
+		t.Fatalf("first request: got %v, want FrameError", err)
+	}
+
+	// 读取goroutine丢弃残留数据直到静默drainTimeout，之后连接继续可用
+	time.Sleep(3 * drainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := transport.Send(ctx, 1, []byte{0x03, 0x02})
+	if err != nil {
+		t.Fatalf("request after resync: %v", err)
+	}
+	if want := []byte{0x03, 0x02}; string(resp) != string(want) {
+		t.Fatalf("request after resync: got % X, want % X", resp, want)
+	}
+	select {
+	case <-transport.done:
+		t.Fatal("transport closed after frame error")
+	default:
+	}
+}
+
+// BenchmarkTCPTransportPipeline 对比不同流水线窗口下的请求吞吐，假服务器对每个请求延迟固定时间后应答
+func BenchmarkTCPTransportPipeline(b *testing.B) {
+	const latency = 200 * time.Microsecond
+	const workers = 16
+	for _, window := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("window=%d", window), func(b *testing.B) {
+			transport := startFakeMBAPServer(b, testTransportConfig(window), func(conn net.Conn) {
+				var writeMu sync.Mutex
+				for {
+					header, pdu, err := readMBAPFrame(conn)
+					if err != nil {
+						return
+					}
+					go func() {
+						time.Sleep(latency)
+						writeMu.Lock()
+						defer writeMu.Unlock()
+						echoResponse(conn, mbapRequest{header: header, pdu: pdu})
+					}()
+				}
+			})
+
+			var remaining atomic.Int64
+			remaining.Store(int64(b.N))
+			var wg sync.WaitGroup
+			b.ResetTimer()
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					pdu := []byte{0x01, 0x00, 0x00, 0x00, 0x10}
+					for remaining.Add(-1) >= 0 {
+						if _, err := transport.Send(context.Background(), 1, pdu); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
-	"net"
-	"time"
+	"sync"
 )
 
-// ModbusClient Modbus TCP客户端结构体
+// ModbusClient Modbus客户端结构体
+// 可被多个goroutine并发使用：Modbus TCP按流水线窗口并发发送，串口类传输按顺序串行发送
 type ModbusClient struct {
-	conn   net.Conn
-	config *Config
-	tid    uint16 // 事务ID
+	config    *Config
+	connMu    sync.RWMutex    // 保护transport和unitID
+	transport modbusTransport // 传输层（TCP或串口RTU）
+	unitID    byte            // 建立当前连接时的从站地址
+
+	disconnected chan error // 连接异常断开通知
 }
 
 // 地址类型常量
@@ -23,147 +28,236 @@ const (
 	HOLDING_REGISTERS_START_ADDR = 40001 // 保持寄存器起始地址
 )
 
+// ModbusException Modbus异常响应（功能码最高位置1）
+type ModbusException struct {
+	FunctionCode  byte // 原始功能码（已去除0x80标志位）
+	ExceptionCode byte // 异常码
+}
+
+// 异常码名称
+var modbusExceptionNames = map[byte]string{
+	0x01: "Illegal Function",
+	0x02: "Illegal Data Address",
+	0x03: "Illegal Data Value",
+	0x04: "Server Device Failure",
+	0x05: "Acknowledge",
+	0x06: "Server Device Busy",
+	0x07: "Negative Acknowledge",
+	0x08: "Memory Parity Error",
+	0x0A: "Gateway Path Unavailable",
+	0x0B: "Gateway Target Device Failed to Respond",
+}
+
+// Name 返回异常码名称
+func (e *ModbusException) Name() string {
+	if name, ok := modbusExceptionNames[e.ExceptionCode]; ok {
+		return name
+	}
+	return "Unknown Exception"
+}
+
+// Error 实现error接口
+func (e *ModbusException) Error() string {
+	return fmt.Sprintf("modbus exception 0x%02X (%s) for function 0x%02X", e.ExceptionCode, e.Name(), e.FunctionCode)
+}
+
 // NewModbusClient 创建新的Modbus客户端
 func NewModbusClient(config *Config) *ModbusClient {
 	return &ModbusClient{
-		config: config,
-		tid:    1,
+		config:       config,
+		disconnected: make(chan error, 1),
 	}
 }
 
-// Connect 建立TCP连接
+// Connect 建立连接（按配置选择TCP或串口传输）
 func (m *ModbusClient) Connect() error {
-	address := fmt.Sprintf("%s:%d", m.config.IP, m.config.Port)
-	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	return m.ConnectCtx(context.Background())
+}
+
+// ConnectCtx 建立连接，ctx取消时中止进行中的连接尝试
+func (m *ModbusClient) ConnectCtx(ctx context.Context) error {
+	// 按连接时的参数建立连接，之后修改参数需重新连接才生效
+	config := m.config.snapshot()
+	transport, err := dialTransport(ctx, config)
 	if err != nil {
 		return err
 	}
-	m.conn = conn
+
+	// 清除旧连接遗留的断线通知
+	select {
+	case <-m.disconnected:
+	default:
+	}
+
+	// 替换旧连接
+	m.connMu.Lock()
+	old := m.transport
+	m.transport = transport
+	m.unitID = byte(config.UnitID)
+	m.connMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
 	return nil
 }
 
 // Close 关闭连接
+// 关闭底层连接会使在途请求立即返回错误
 func (m *ModbusClient) Close() error {
-	if m.conn != nil {
-		err := m.conn.Close()
-		m.conn = nil
-		return err
+	m.connMu.Lock()
+	transport := m.transport
+	m.transport = nil
+	m.connMu.Unlock()
+
+	if transport != nil {
+		return transport.Close()
 	}
 	return nil
 }
 
 // Reconnect 断线重连
 func (m *ModbusClient) Reconnect() error {
+	return m.ReconnectCtx(context.Background())
+}
+
+// ReconnectCtx 断线重连，ctx取消时中止进行中的连接尝试
+func (m *ModbusClient) ReconnectCtx(ctx context.Context) error {
 	// 先关闭现有连接
 	m.Close()
 	
 	// 尝试重新连接
-	return m.Connect()
+	return m.ConnectCtx(ctx)
 }
 
 // IsConnected 检查是否已连接
 func (m *ModbusClient) IsConnected() bool {
-	return m.conn != nil
+	return m.currentTransport() != nil
 }
 
-// nextTID 获取下一个事务ID
-func (m *ModbusClient) nextTID() uint16 {
-	tid := m.tid
-	m.tid++
-	if m.tid == 0 {
-		m.tid = 1
-	}
-	return tid
+// Disconnected 返回连接异常断开通知通道
+// 仅在请求过程中发现连接失效时发送，主动Close不会触发
+func (m *ModbusClient) Disconnected() <-chan error {
+	return m.disconnected
+}
+
+// currentTransport 获取当前传输层连接
+func (m *ModbusClient) currentTransport() modbusTransport {
+	m.connMu.RLock()
+	defer m.connMu.RUnlock()
+	return m.transport
 }
 
 // sendAndReceive 发送请求并接收响应
-func (m *ModbusClient) sendAndReceive(pdu []byte) ([]byte, error) {
+func (m *ModbusClient) sendAndReceive(ctx context.Context, pdu []byte) ([]byte, error) {
+	if len(pdu) == 0 || len(pdu) > maxPDULength {
+		return nil, fmt.Errorf("invalid PDU length: %d", len(pdu))
+	}
+
 	// 检查连接状态
-	if m.conn == nil {
+	m.connMu.RLock()
+	transport, unitID := m.transport, m.unitID
+	m.connMu.RUnlock()
+	if transport == nil {
 		return nil, fmt.Errorf("not connected")
 	}
-	
-	// 构造MBAP头
-	tid := m.nextTID()
-	mbap := make([]byte, 7)
-	binary.BigEndian.PutUint16(mbap[0:2], tid)     // 事务ID
-	binary.BigEndian.PutUint16(mbap[2:4], 0)       // 协议ID
-	binary.BigEndian.PutUint16(mbap[4:6], uint16(len(pdu)+1)) // 长度
-	mbap[6] = byte(m.config.UnitID)                // Unit ID
-
-	// 组合请求
-	request := append(mbap, pdu...)
-
-	// 发送请求
-	_, err := m.conn.Write(request)
-	if err != nil {
-		// 发送失败，标记连接断开
-		m.conn = nil
-		return nil, err
-	}
 
-	// 读取MBAP头
-	respMBAP := make([]byte, 7)
-	_, err = m.conn.Read(respMBAP)
+	respPDU, err := transport.Send(ctx, unitID, pdu)
 	if err != nil {
-		// 读取失败，标记连接断开
-		m.conn = nil
+		// 链路失效，标记连接断开
+		var linkErr *LinkError
+		if errors.As(err, &linkErr) {
+			m.markBroken(transport, linkErr.Err)
+			return nil, linkErr.Err
+		}
 		return nil, err
 	}
 
-	// 解析响应长度
-	length := binary.BigEndian.Uint16(respMBAP[4:6])
-
-	// 读取PDU数据
-	respPDU := make([]byte, length-1)
-	_, err = m.conn.Read(respPDU)
-	if err != nil {
-		// 读取失败，标记连接断开
-		m.conn = nil
+	// 验证功能码
+	if err := validateResponsePDU(pdu, respPDU); err != nil {
 		return nil, err
 	}
 
-	// 验证事务ID
-	respTID := binary.BigEndian.Uint16(respMBAP[0:2])
-	if respTID != tid {
-		return nil, fmt.Errorf("transaction ID mismatch: expected %d, got %d", tid, respTID)
+	// 检查异常响应
+	if respPDU[0]&0x80 != 0 {
+		return nil, &ModbusException{FunctionCode: respPDU[0] & 0x7F, ExceptionCode: respPDU[1]}
 	}
 
 	return respPDU, nil
 }
 
+// markBroken 标记连接已断开并释放底层连接
+// 仅当transport仍是当前连接时才清除，避免误关闭期间新建立的连接
+func (m *ModbusClient) markBroken(transport modbusTransport, err error) {
+	m.connMu.Lock()
+	current := m.transport == transport
+	if current {
+		m.transport = nil
+	}
+	m.connMu.Unlock()
+	transport.Close()
+
+	// 通知连接监督器
+	if current {
+		select {
+		case m.disconnected <- err:
+		default:
+		}
+	}
+}
+
 // ReadCoils 读取线圈 (功能码 0x01)
 func (m *ModbusClient) ReadCoils(startAddr uint16, quantity uint16) ([]byte, error) {
+	return m.ReadCoilsCtx(context.Background(), startAddr, quantity)
+}
+
+// ReadCoilsCtx 读取线圈，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadCoilsCtx(ctx context.Context, startAddr uint16, quantity uint16) ([]byte, error) {
 	pdu := make([]byte, 5)
 	pdu[0] = 0x01                              // 功能码
 	binary.BigEndian.PutUint16(pdu[1:3], startAddr)   // 起始地址
 	binary.BigEndian.PutUint16(pdu[3:5], quantity)    // 线圈数量
 
-	return m.sendAndReceive(pdu)
+	return m.sendAndReceive(ctx, pdu)
 }
 
 // ReadDiscreteInputs 读取离散输入 (功能码 0x02)
 func (m *ModbusClient) ReadDiscreteInputs(startAddr uint16, quantity uint16) ([]byte, error) {
+	return m.ReadDiscreteInputsCtx(context.Background(), startAddr, quantity)
+}
+
+// ReadDiscreteInputsCtx 读取离散输入，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadDiscreteInputsCtx(ctx context.Context, startAddr uint16, quantity uint16) ([]byte, error) {
 	pdu := make([]byte, 5)
 	pdu[0] = 0x02                              // 功能码
 	binary.BigEndian.PutUint16(pdu[1:3], startAddr)   // 起始地址
 	binary.BigEndian.PutUint16(pdu[3:5], quantity)    // 输入数量
 
-	return m.sendAndReceive(pdu)
+	return m.sendAndReceive(ctx, pdu)
 }
 
 // ReadInputRegisters 读取输入寄存器 (功能码 0x04)
 func (m *ModbusClient) ReadInputRegisters(startAddr uint16, quantity uint16) ([]byte, error) {
+	return m.ReadInputRegistersCtx(context.Background(), startAddr, quantity)
+}
+
+// ReadInputRegistersCtx 读取输入寄存器，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadInputRegistersCtx(ctx context.Context, startAddr uint16, quantity uint16) ([]byte, error) {
 	pdu := make([]byte, 5)
 	pdu[0] = 0x04                              // 功能码
 	binary.BigEndian.PutUint16(pdu[1:3], startAddr)   // 起始地址
 	binary.BigEndian.PutUint16(pdu[3:5], quantity)    // 寄存器数量
 
-	return m.sendAndReceive(pdu)
+	return m.sendAndReceive(ctx, pdu)
 }
 
 // WriteSingleCoil 写入单个线圈 (功能码 0x05)
 func (m *ModbusClient) WriteSingleCoil(addr uint16, value bool) ([]byte, error) {
+	return m.WriteSingleCoilCtx(context.Background(), addr, value)
+}
+
+// WriteSingleCoilCtx 写入单个线圈，ctx取消或到期时中止请求
+func (m *ModbusClient) WriteSingleCoilCtx(ctx context.Context, addr uint16, value bool) ([]byte, error) {
 	pdu := make([]byte, 5)
 	pdu[0] = 0x05                              // 功能码
 	binary.BigEndian.PutUint16(pdu[1:3], addr)        // 地址
@@ -173,11 +267,16 @@ func (m *ModbusClient) WriteSingleCoil(addr uint16, value bool) ([]byte, error)
 		binary.BigEndian.PutUint16(pdu[3:5], 0x0000)  // OFF值
 	}
 
-	return m.sendAndReceive(pdu)
+	return m.sendAndReceive(ctx, pdu)
 }
 
 // WriteMultipleCoils 写入多个线圈 (功能码 0x0F)
 func (m *ModbusClient) WriteMultipleCoils(startAddr uint16, values []bool) ([]byte, error) {
+	return m.WriteMultipleCoilsCtx(context.Background(), startAddr, values)
+}
+
+// WriteMultipleCoilsCtx 写入多个线圈，ctx取消或到期时中止请求
+func (m *ModbusClient) WriteMultipleCoilsCtx(ctx context.Context, startAddr uint16, values []bool) ([]byte, error) {
 	// 计算字节数
 	byteCount := (len(values) + 7) / 8
 	
@@ -198,7 +297,122 @@ func (m *ModbusClient) WriteMultipleCoils(startAddr uint16, values []bool) ([]by
 	pdu[5] = byte(byteCount)                         // 字节数
 	copy(pdu[6:], coilBytes)                         // 线圈值
 
-	return m.sendAndReceive(pdu)
+	return m.sendAndReceive(ctx, pdu)
+}
+
+// ReadHoldingRegisters 读取保持寄存器 (功能码 0x03)
+func (m *ModbusClient) ReadHoldingRegisters(startAddr uint16, quantity uint16) ([]uint16, error) {
+	return m.ReadHoldingRegistersCtx(context.Background(), startAddr, quantity)
+}
+
+// ReadHoldingRegistersCtx 读取保持寄存器，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadHoldingRegistersCtx(ctx context.Context, startAddr uint16, quantity uint16) ([]uint16, error) {
+	if quantity < 1 || quantity > 125 {
+		return nil, fmt.Errorf("invalid register quantity: %d", quantity)
+	}
+
+	pdu := make([]byte, 5)
+	pdu[0] = 0x03                              // 功能码
+	binary.BigEndian.PutUint16(pdu[1:3], startAddr)   // 起始地址
+	binary.BigEndian.PutUint16(pdu[3:5], quantity)    // 寄存器数量
+
+	resp, err := m.sendAndReceive(ctx, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRegistersResponse(resp, int(quantity))
+}
+
+// WriteSingleRegister 写入单个保持寄存器 (功能码 0x06)
+func (m *ModbusClient) WriteSingleRegister(addr uint16, value uint16) error {
+	return m.WriteSingleRegisterCtx(context.Background(), addr, value)
+}
+
+// WriteSingleRegisterCtx 写入单个保持寄存器，ctx取消或到期时中止请求
+func (m *ModbusClient) WriteSingleRegisterCtx(ctx context.Context, addr uint16, value uint16) error {
+	pdu := make([]byte, 5)
+	pdu[0] = 0x06                              // 功能码
+	binary.BigEndian.PutUint16(pdu[1:3], addr)        // 地址
+	binary.BigEndian.PutUint16(pdu[3:5], value)       // 寄存器值
+
+	resp, err := m.sendAndReceive(ctx, pdu)
+	if err != nil {
+		return err
+	}
+
+	// 响应原样回送地址和寄存器值
+	return validateWriteEcho(pdu, resp)
+}
+
+// WriteMultipleRegisters 写入多个保持寄存器 (功能码 0x10)
+func (m *ModbusClient) WriteMultipleRegisters(startAddr uint16, values []uint16) error {
+	return m.WriteMultipleRegistersCtx(context.Background(), startAddr, values)
+}
+
+// WriteMultipleRegistersCtx 写入多个保持寄存器，ctx取消或到期时中止请求
+func (m *ModbusClient) WriteMultipleRegistersCtx(ctx context.Context, startAddr uint16, values []uint16) error {
+	if len(values) < 1 || len(values) > 123 {
+		return fmt.Errorf("invalid register quantity: %d", len(values))
+	}
+
+	// 构造PDU
+	byteCount := len(values) * 2
+	pdu := make([]byte, 6+byteCount)
+	pdu[0] = 0x10                                    // 功能码
+	binary.BigEndian.PutUint16(pdu[1:3], startAddr)         // 起始地址
+	binary.BigEndian.PutUint16(pdu[3:5], uint16(len(values))) // 寄存器数量
+	pdu[5] = byte(byteCount)                         // 字节数
+	putRegisters(pdu[6:], values)                    // 寄存器值
+
+	resp, err := m.sendAndReceive(ctx, pdu)
+	if err != nil {
+		return err
+	}
+
+	// 响应回送起始地址和寄存器数量
+	return validateWriteEcho(pdu[:5], resp)
+}
+
+// ReadWriteMultipleRegisters 读写多个保持寄存器 (功能码 0x17)
+// PLC先执行写操作，再返回读区域的寄存器值
+func (m *ModbusClient) ReadWriteMultipleRegisters(readAddr uint16, readQuantity uint16, writeAddr uint16, values []uint16) ([]uint16, error) {
+	return m.ReadWriteMultipleRegistersCtx(context.Background(), readAddr, readQuantity, writeAddr, values)
+}
+
+// ReadWriteMultipleRegistersCtx 读写多个保持寄存器，ctx取消或到期时中止请求
+func (m *ModbusClient) ReadWriteMultipleRegistersCtx(ctx context.Context, readAddr uint16, readQuantity uint16, writeAddr uint16, values []uint16) ([]uint16, error) {
+	if readQuantity < 1 || readQuantity > 125 {
+		return nil, fmt.Errorf("invalid read register quantity: %d", readQuantity)
+	}
+	if len(values) < 1 || len(values) > 121 {
+		return nil, fmt.Errorf("invalid write register quantity: %d", len(values))
+	}
+
+	// 构造PDU
+	byteCount := len(values) * 2
+	pdu := make([]byte, 10+byteCount)
+	pdu[0] = 0x17                                     // 功能码
+	binary.BigEndian.PutUint16(pdu[1:3], readAddr)           // 读起始地址
+	binary.BigEndian.PutUint16(pdu[3:5], readQuantity)       // 读寄存器数量
+	binary.BigEndian.PutUint16(pdu[5:7], writeAddr)          // 写起始地址
+	binary.BigEndian.PutUint16(pdu[7:9], uint16(len(values))) // 写寄存器数量
+	pdu[9] = byte(byteCount)                          // 写字节数
+	putRegisters(pdu[10:], values)                    // 写寄存器值
+
+	resp, err := m.sendAndReceive(ctx, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRegistersResponse(resp, int(readQuantity))
+}
+
+// putRegisters 按大端序写入寄存器值
+func putRegisters(dst []byte, values []uint16) {
+	for i, value := range values {
+		binary.BigEndian.PutUint16(dst[i*2:i*2+2], value)
+	}
 }
 
 // CalculateShortAddress 计算短地址（偏移量）
@@ -217,57 +431,53 @@ func CalculateShortAddress(logicAddr uint16, addrType uint16) uint16 {
 	}
 }
 
-// parseCoilsResponse 解析线圈响应数据
-func parseCoilsResponse(resp []byte, quantity int) []bool {
+// parseBitsResponse 解析线圈/离散输入响应数据并校验字节数（功能码 0x01/0x02）
+func parseBitsResponse(resp []byte, quantity int) ([]bool, error) {
 	if len(resp) < 2 {
-		return make([]bool, quantity)
+		return nil, fmt.Errorf("bit response too short: %d bytes", len(resp))
 	}
 
 	byteCount := int(resp[1])
 	data := resp[2:]
 
-	if len(data) < byteCount {
-		return make([]bool, quantity)
+	if byteCount != (quantity+7)/8 || len(data) < byteCount {
+		return nil, fmt.Errorf("bit response byte count mismatch: expected %d, got %d", (quantity+7)/8, byteCount)
 	}
 
-	coils := make([]bool, quantity)
+	bits := make([]bool, quantity)
+	for i := range bits {
+		bits[i] = data[i/8]&(1<<(i%8)) != 0
+	}
 
-	for i := 0; i < quantity; i++ {
-		byteIndex := i / 8
-		bitIndex := i % 8
+	return bits, nil
+}
 
-		if byteIndex < len(data) {
-			coils[i] = (data[byteIndex] & (1 << bitIndex)) != 0
-		}
+// validateWriteEcho 校验写请求的回送响应（功能码 0x06/0x10）
+// want为响应应当回送的功能码、地址和值/数量
+func validateWriteEcho(want []byte, resp []byte) error {
+	if !bytes.Equal(resp, want) {
+		return &FrameError{Reason: fmt.Sprintf("write response mismatch: expected % X, got % X", want, resp)}
 	}
-
-	log.Printf("Parsed Coils: %v", coils)
-	return coils
+	return nil
 }
 
-// parseDiscreteInputsResponse 解析离散输入响应数据
-func parseDiscreteInputsResponse(resp []byte, quantity int) []bool {
+// parseRegistersResponse 解析寄存器响应数据（功能码 0x03/0x04/0x17）
+func parseRegistersResponse(resp []byte, quantity int) ([]uint16, error) {
 	if len(resp) < 2 {
-		return make([]bool, quantity)
+		return nil, fmt.Errorf("register response too short: %d bytes", len(resp))
 	}
 
 	byteCount := int(resp[1])
 	data := resp[2:]
 
-	if len(data) < byteCount {
-		return make([]bool, quantity)
+	if byteCount != quantity*2 || len(data) < byteCount {
+		return nil, fmt.Errorf("register response byte count mismatch: expected %d, got %d", quantity*2, byteCount)
 	}
 
-	inputs := make([]bool, quantity)
-
-	for i := 0; i < quantity; i++ {
-		byteIndex := i / 8
-		bitIndex := i % 8
-
-		if byteIndex < len(data) {
-			inputs[i] = (data[byteIndex] & (1 << bitIndex)) != 0
-		}
+	registers := make([]uint16, quantity)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(data[i*2 : i*2+2])
 	}
 
-	return inputs
-}
\ No newline at end of file
+	return registers, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+// fakeRegisterServer 假Modbus TCP服务器，按功能码03/06读写内存中的保持寄存器
+type fakeRegisterServer struct {
+	listener  net.Listener
+	mu        sync.Mutex
+	registers [64]uint16
+	requests  int
+	unitID    byte // 最近一次请求的Unit ID
+	badEcho   bool // 写单个寄存器时回送错误的值
+	conns     []net.Conn
+}
+
+// startFakeRegisterServer 启动监听随机端口的假服务器，测试结束时关闭
+func startFakeRegisterServer(t *testing.T) *fakeRegisterServer {
+	t.Helper()
+	return startFakeRegisterServerAt(t, "127.0.0.1:0")
+}
+
+// startFakeRegisterServerAt 在指定地址启动假服务器
+func startFakeRegisterServerAt(t *testing.T, addr string) *fakeRegisterServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRegisterServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// dropConns 关闭所有已接受的连接，模拟链路中断
+func (s *fakeRegisterServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// serve 逐个处理请求报文，响应沿用请求的事务ID
+func (s *fakeRegisterServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header, pdu, err := readMBAPFrame(conn)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.unitID = header.UnitID
+		s.mu.Unlock()
+		if _, err := conn.Write(encodeMBAPFrame(header.TransactionID, header.UnitID, s.handle(pdu))); err != nil {
+			return
+		}
+	}
+}
+
+// handle 生成响应PDU，只支持测试用到的功能码
+func (s *fakeRegisterServer) handle(pdu []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	addr := int(binary.BigEndian.Uint16(pdu[1:3]))
+	value := int(binary.BigEndian.Uint16(pdu[3:5]))
+	switch pdu[0] {
+	case 0x03:
+		if addr+value > len(s.registers) {
+			return []byte{0x83, 0x02}
+		}
+		resp := []byte{0x03, byte(value * 2)}
+		for _, reg := range s.registers[addr : addr+value] {
+			resp = binary.BigEndian.AppendUint16(resp, reg)
+		}
+		return resp
+	case 0x06:
+		if addr >= len(s.registers) {
+			return []byte{0x86, 0x02}
+		}
+		s.registers[addr] = uint16(value)
+		if s.badEcho {
+			return []byte{0x06, pdu[1], pdu[2], pdu[3], pdu[4] ^ 0xFF}
+		}
+		return pdu
+	}
+	return []byte{pdu[0] | 0x80, 0x01}
+}
+
+// stop 关闭监听和所有连接，之后的连接尝试被拒绝
+func (s *fakeRegisterServer) stop() {
+	s.listener.Close()
+	s.dropConns()
+}
+
+// clientConfig 返回指向假服务器的客户端配置
+func (s *fakeRegisterServer) clientConfig() *Config {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	config := DefaultConfig()
+	config.IP, config.Port = addr.IP.String(), addr.Port
+	return config
+}
+
+// connectClient 创建连接到假服务器的客户端
+func (s *fakeRegisterServer) connectClient(t *testing.T) *ModbusClient {
+	t.Helper()
+	client := NewModbusClient(s.clientConfig())
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// TestModbusClientConcurrentReadWrite 多个goroutine共用一个客户端读写各自的寄存器
+// 请求串行化后每个goroutine都应读回自己写入的值；配合-race运行检查conn与事务ID的数据竞争
+func TestModbusClientConcurrentReadWrite(t *testing.T) {
+	const workers = 16
+	const rounds = 50
+	server := startFakeRegisterServer(t)
+	client := server.connectClient(t)
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addr := uint16(w)
+			for i := range rounds {
+				value := uint16(w<<8 | i)
+				if err := client.WriteSingleRegister(addr, value); err != nil {
+					t.Errorf("worker %d write %d: %v", w, i, err)
+					return
+				}
+				regs, err := client.ReadHoldingRegisters(addr, 1)
+				if err != nil {
+					t.Errorf("worker %d read %d: %v", w, i, err)
+					return
+				}
+				if len(regs) != 1 || regs[0] != value {
+					t.Errorf("worker %d read %d: got %v, want [%d]", w, i, regs, value)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if want := workers * rounds * 2; server.requests != want {
+		t.Fatalf("server handled %d requests, want %d", server.requests, want)
+	}
+}
+
+// TestModbusClientCloseDuringRequests 请求进行中关闭连接，其余请求应返回错误而不是挂起或panic
+func TestModbusClientCloseDuringRequests(t *testing.T) {
+	server := startFakeRegisterServer(t)
+	client := server.connectClient(t)
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				client.ReadHoldingRegisters(uint16(w), 1)
+			}
+		}()
+	}
+	client.Close()
+	wg.Wait()
+
+	if client.IsConnected() {
+		t.Fatal("client still connected after Close")
+	}
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("request after Close succeeded")
+	}
+}
+
+// TestModbusClientWriteEchoMismatch 写单个寄存器的回送与请求不一致时返回FrameError
+func TestModbusClientWriteEchoMismatch(t *testing.T) {
+	server := startFakeRegisterServer(t)
+	server.mu.Lock()
+	server.badEcho = true
+	server.mu.Unlock()
+	client := server.connectClient(t)
+
+	err := client.WriteSingleRegister(3, 0x1234)
+	var frameErr *FrameError
+	if !errors.As(err, &frameErr) {
+		t.Fatalf("got %v, want FrameError", err)
+	}
+}
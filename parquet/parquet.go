@@ -0,0 +1,352 @@
+// Package parquet 以流式方式写出Parquet文件
+//
+// 只实现导出所需的子集：所有列为必填（无定义/重复级别）、PLAIN编码、不压缩。
+// 行按行组缓存，每个行组写出后即释放，导出任意长度的数据只占用一个行组的内存。
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type 列类型
+type Type int
+
+// 列类型
+const (
+	Boolean         Type = iota // BOOLEAN
+	Int32                       // INT32
+	Int64                       // INT64
+	Double                      // DOUBLE
+	String                      // BYTE_ARRAY，UTF8
+	TimestampMillis             // INT64，UTC毫秒时间戳
+)
+
+// Column 列定义
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Parquet物理类型、转换类型与编码编号
+const (
+	physicalBoolean   = 0
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionRequired = 0
+	pageTypeData       = 0
+	codecUncompressed  = 0
+)
+
+// physical 返回列的物理类型
+func (t Type) physical() int32 {
+	switch t {
+	case Boolean:
+		return physicalBoolean
+	case Int32:
+		return physicalInt32
+	case Int64, TimestampMillis:
+		return physicalInt64
+	case Double:
+		return physicalDouble
+	default:
+		return physicalByteArray
+	}
+}
+
+// DefaultRowGroupSize 默认每个行组的行数
+const DefaultRowGroupSize = 65536
+
+var magic = []byte("PAR1")
+
+// ErrClosed 写入已关闭的Writer
+var ErrClosed = errors.New("parquet: writer closed")
+
+// columnChunk 已写出的列块位置
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// rowGroup 已写出的行组
+type rowGroup struct {
+	columns []columnChunk
+	rows    int64
+	size    int64
+}
+
+// Writer Parquet文件写入器
+type Writer struct {
+	out          io.Writer
+	offset       int64
+	columns      []Column
+	rowGroupSize int
+
+	values    [][]byte // 当前行组各列的PLAIN编码数据
+	bools     [][]bool // 当前行组BOOLEAN列的值，写出时按位打包
+	rows      int
+	rowGroups []rowGroup
+	totalRows int64
+	closed    bool
+	err       error
+}
+
+// NewWriter 创建写入器并写出文件头，rowGroupSize不大于0时使用默认值
+func NewWriter(out io.Writer, columns []Column, rowGroupSize int) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("parquet: no columns")
+	}
+	for _, column := range columns {
+		if column.Type < Boolean || column.Type > TimestampMillis {
+			return nil, fmt.Errorf("parquet: column %s has invalid type %d", column.Name, column.Type)
+		}
+	}
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+
+	w := &Writer{
+		out:          out,
+		columns:      columns,
+		rowGroupSize: rowGroupSize,
+		values:       make([][]byte, len(columns)),
+		bools:        make([][]bool, len(columns)),
+	}
+	if err := w.write(magic); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// write 写出数据并累计文件偏移，出错后后续写入均返回该错误
+func (w *Writer) write(data []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	n, err := w.out.Write(data)
+	w.offset += int64(n)
+	w.err = err
+	return err
+}
+
+// WriteRow 追加一行，values按列顺序给出：
+// Boolean为bool，Int32为int32，Int64为int64，Double为float64，String为string，TimestampMillis为time.Time
+func (w *Writer) WriteRow(values ...any) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(values), len(w.columns))
+	}
+
+	// 先检查全部值的类型，避免写入半行
+	for i, column := range w.columns {
+		ok := false
+		switch values[i].(type) {
+		case bool:
+			ok = column.Type == Boolean
+		case int32:
+			ok = column.Type == Int32
+		case int64:
+			ok = column.Type == Int64
+		case float64:
+			ok = column.Type == Double
+		case string:
+			ok = column.Type == String
+		case time.Time:
+			ok = column.Type == TimestampMillis
+		}
+		if !ok {
+			return fmt.Errorf("parquet: column %s: unexpected value %T", column.Name, values[i])
+		}
+	}
+
+	for i, value := range values {
+		buf := w.values[i]
+		switch v := value.(type) {
+		case bool:
+			w.bools[i] = append(w.bools[i], v)
+		case int32:
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(v))
+		case int64:
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+		case float64:
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		case string:
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(v)))
+			buf = append(buf, v...)
+		case time.Time:
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v.UnixMilli()))
+		}
+		w.values[i] = buf
+	}
+
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush 将缓存的行写出为一个行组
+func (w *Writer) Flush() error {
+	if w.closed {
+		return ErrClosed
+	}
+	if w.rows == 0 {
+		return w.err
+	}
+
+	group := rowGroup{rows: int64(w.rows)}
+	for i, column := range w.columns {
+		data := w.values[i]
+		if column.Type == Boolean {
+			data = packBools(w.bools[i])
+		}
+
+		header := newThriftWriter()
+		header.begin(0)
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.begin(5)
+		header.i32(1, int32(w.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.end()
+
+		chunk := columnChunk{offset: w.offset, size: int64(len(header.buf) + len(data))}
+		if err := w.write(header.buf); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+
+		w.values[i] = w.values[i][:0]
+		w.bools[i] = w.bools[i][:0]
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.totalRows += group.rows
+	w.rows = 0
+	return nil
+}
+
+// packBools 按PLAIN编码将布尔值打包，低位在前
+func packBools(values []bool) []byte {
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 1 << (i % 8)
+		}
+	}
+	return data
+}
+
+// Close 写出剩余的行和文件尾，不关闭底层Writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	meta := w.fileMetaData()
+	footer := binary.LittleEndian.AppendUint32(meta, uint32(len(meta)))
+	footer = append(footer, magic...)
+	return w.write(footer)
+}
+
+// fileMetaData 编码文件元数据（FileMetaData）
+func (w *Writer) fileMetaData() []byte {
+	t := newThriftWriter()
+	t.begin(0)
+	t.i32(1, 1) // version
+
+	// schema：根节点后依次为各列
+	t.list(2, thriftStruct, len(w.columns)+1)
+	t.begin(0)
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	for _, column := range w.columns {
+		t.begin(0)
+		t.i32(1, column.Type.physical())
+		t.i32(3, repetitionRequired)
+		t.string(4, column.Name)
+		switch column.Type {
+		case String:
+			t.i32(6, convertedUTF8)
+			t.begin(10) // LogicalType.STRING
+			t.begin(1)
+			t.end()
+			t.end()
+		case TimestampMillis:
+			t.i32(6, convertedTimestampMillis)
+			t.begin(10) // LogicalType.TIMESTAMP
+			t.begin(8)
+			t.bool(1, true) // isAdjustedToUTC
+			t.begin(2)      // unit
+			t.begin(1)      // MILLIS
+			t.end()
+			t.end()
+			t.end()
+			t.end()
+		}
+		t.end()
+	}
+
+	t.i64(3, w.totalRows)
+
+	t.list(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.begin(0)
+		t.list(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			column := w.columns[i]
+			t.begin(0)
+			t.i64(2, chunk.offset)
+			t.begin(3) // ColumnMetaData
+			t.i32(1, column.Type.physical())
+			t.list(2, thriftI32, 2)
+			t.i32Elem(encodingPlain)
+			t.i32Elem(encodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.rawString(column.Name)
+			t.i32(4, codecUncompressed)
+			t.i64(5, group.rows)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.rows)
+		t.end()
+	}
+
+	t.string(6, "s7-1200-marquee")
+	t.end()
+	return t.buf
+}
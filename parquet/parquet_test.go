@@ -0,0 +1,443 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// thriftReader Thrift紧凑协议解码器，结构体解码为字段编号到值的映射，仅用于校验写出的元数据
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		panic("thrift: unexpected end of data")
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		panic("thrift: invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftBoolTrue:
+		return true
+	case thriftBoolFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		if r.pos+n > len(r.buf) {
+			panic("thrift: binary exceeds data")
+		}
+		s := string(r.buf[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size, elemType := int(header>>4), header&0x0F
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]any, size)
+		for i := range list {
+			list[i] = r.value(elemType)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	default:
+		panic(fmt.Sprintf("thrift: unsupported type %d", typ))
+	}
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		fields[id] = r.value(header & 0x0F)
+	}
+}
+
+// decodeStruct 从data[offset:]解码一个结构体，返回结构体及其编码长度
+func decodeStruct(t *testing.T, data []byte, offset int64) (fields map[int16]any, n int) {
+	t.Helper()
+	r := &thriftReader{buf: data[offset:]}
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("decode struct at %d: %v", offset, err)
+		}
+	}()
+	return r.structure(), r.pos
+}
+
+// field 按字段编号路径取值
+func field[T any](t *testing.T, s map[int16]any, ids ...int16) T {
+	t.Helper()
+	var value any = s
+	for _, id := range ids {
+		m, ok := value.(map[int16]any)
+		if !ok {
+			t.Fatalf("field %v: not a struct", ids)
+		}
+		if value, ok = m[id]; !ok {
+			t.Fatalf("field %v: missing", ids)
+		}
+	}
+	v, ok := value.(T)
+	if !ok {
+		t.Fatalf("field %v: got %T, want %T", ids, value, v)
+	}
+	return v
+}
+
+// fileMetaData 检查文件头尾的魔数和尾部长度，解码文件元数据
+func fileMetaData(t *testing.T, file []byte) (meta map[int16]any, metaStart int64) {
+	t.Helper()
+	if len(file) < 12 || !bytes.Equal(file[:4], magic) || !bytes.Equal(file[len(file)-4:], magic) {
+		t.Fatalf("file missing PAR1 magic: % X", file[:min(len(file), 8)])
+	}
+	footerLength := int64(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	metaStart = int64(len(file)) - 8 - footerLength
+	if metaStart < 4 {
+		t.Fatalf("footer length %d exceeds file size %d", footerLength, len(file))
+	}
+	meta, n := decodeStruct(t, file[:len(file)-8], metaStart)
+	if int64(n) != footerLength {
+		t.Fatalf("FileMetaData is %d bytes, footer length says %d", n, footerLength)
+	}
+	return meta, metaStart
+}
+
+// decodePlain 解码一列PLAIN编码的值
+func decodePlain(t *testing.T, typ Type, data []byte, count int) []any {
+	t.Helper()
+	values := make([]any, 0, count)
+	for range count {
+		switch typ {
+		case Boolean:
+			i := len(values)
+			values = append(values, data[i/8]&(1<<(i%8)) != 0)
+			continue
+		case Int32:
+			values = append(values, int32(binary.LittleEndian.Uint32(data)))
+			data = data[4:]
+		case Int64:
+			values = append(values, int64(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case TimestampMillis:
+			values = append(values, time.UnixMilli(int64(binary.LittleEndian.Uint64(data))).UTC())
+			data = data[8:]
+		case Double:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case String:
+			n := binary.LittleEndian.Uint32(data)
+			values = append(values, string(data[4:4+n]))
+			data = data[4+n:]
+		}
+	}
+	if typ != Boolean && len(data) != 0 {
+		t.Fatalf("%d bytes left after %d values", len(data), count)
+	}
+	return values
+}
+
+var testColumns = []Column{
+	{Name: "on", Type: Boolean},
+	{Name: "count", Type: Int32},
+	{Name: "total", Type: Int64},
+	{Name: "value", Type: Double},
+	{Name: "tag", Type: String},
+	{Name: "time", Type: TimestampMillis},
+}
+
+// testRow 生成第i行的测试数据
+func testRow(i int) []any {
+	return []any{
+		i%3 == 0,
+		int32(-i),
+		int64(i) << 40,
+		float64(i) / 3,
+		fmt.Sprintf("tag-%d-温度", i),
+		time.Date(2026, 10, 16, 8, 0, i, int(i)*int(time.Millisecond), time.UTC),
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	const rows = 11
+	const rowGroupSize = 4
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testColumns, rowGroupSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rows {
+		if err := w.WriteRow(testRow(i)...); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	meta, metaStart := fileMetaData(t, file)
+
+	if version := field[int64](t, meta, 1); version != 1 {
+		t.Errorf("version = %d", version)
+	}
+	if numRows := field[int64](t, meta, 3); numRows != rows {
+		t.Errorf("num_rows = %d, want %d", numRows, rows)
+	}
+	if createdBy := field[string](t, meta, 6); createdBy != "s7-1200-marquee" {
+		t.Errorf("created_by = %q", createdBy)
+	}
+
+	// schema：根节点及各列的物理类型、名称和转换类型
+	schema := field[[]any](t, meta, 2)
+	if len(schema) != len(testColumns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(testColumns)+1)
+	}
+	root := schema[0].(map[int16]any)
+	if field[string](t, root, 4) != "schema" || field[int64](t, root, 5) != int64(len(testColumns)) {
+		t.Errorf("schema root = %v", root)
+	}
+	for i, column := range testColumns {
+		element := schema[i+1].(map[int16]any)
+		if name := field[string](t, element, 4); name != column.Name {
+			t.Errorf("schema %d name = %q, want %q", i, name, column.Name)
+		}
+		if typ := field[int64](t, element, 1); typ != int64(column.Type.physical()) {
+			t.Errorf("column %s physical type = %d", column.Name, typ)
+		}
+		if repetition := field[int64](t, element, 3); repetition != repetitionRequired {
+			t.Errorf("column %s repetition = %d", column.Name, repetition)
+		}
+		converted, ok := element[6]
+		switch column.Type {
+		case String:
+			if converted != int64(convertedUTF8) {
+				t.Errorf("column %s converted type = %v", column.Name, converted)
+			}
+		case TimestampMillis:
+			if converted != int64(convertedTimestampMillis) || !field[bool](t, element, 10, 8, 1) {
+				t.Errorf("column %s timestamp annotation = %v", column.Name, element)
+			}
+		default:
+			if ok {
+				t.Errorf("column %s unexpected converted type %v", column.Name, converted)
+			}
+		}
+	}
+
+	// 行组与列块：列块首尾相接，从魔数之后一直排到元数据之前
+	groups := field[[]any](t, meta, 4)
+	if want := (rows + rowGroupSize - 1) / rowGroupSize; len(groups) != want {
+		t.Fatalf("%d row groups, want %d", len(groups), want)
+	}
+	decoded := make([][]any, len(testColumns))
+	next := int64(len(magic))
+	for g, value := range groups {
+		group := value.(map[int16]any)
+		groupRows := field[int64](t, group, 3)
+		if want := int64(min(rowGroupSize, rows-g*rowGroupSize)); groupRows != want {
+			t.Errorf("row group %d has %d rows, want %d", g, groupRows, want)
+		}
+		chunks := field[[]any](t, group, 1)
+		if len(chunks) != len(testColumns) {
+			t.Fatalf("row group %d has %d column chunks", g, len(chunks))
+		}
+		var groupSize int64
+		for i, value := range chunks {
+			column := testColumns[i]
+			chunk := value.(map[int16]any)
+			offset := field[int64](t, chunk, 2)
+			if offset != next {
+				t.Fatalf("row group %d column %s at offset %d, want %d", g, column.Name, offset, next)
+			}
+			if dataOffset := field[int64](t, chunk, 3, 9); dataOffset != offset {
+				t.Errorf("column %s data_page_offset %d, file_offset %d", column.Name, dataOffset, offset)
+			}
+			if path := field[[]any](t, chunk, 3, 3); len(path) != 1 || path[0] != column.Name {
+				t.Errorf("column %s path_in_schema = %v", column.Name, path)
+			}
+			if codec := field[int64](t, chunk, 3, 4); codec != codecUncompressed {
+				t.Errorf("column %s codec = %d", column.Name, codec)
+			}
+			if numValues := field[int64](t, chunk, 3, 5); numValues != groupRows {
+				t.Errorf("column %s num_values = %d, want %d", column.Name, numValues, groupRows)
+			}
+			size := field[int64](t, chunk, 3, 6)
+			if compressed := field[int64](t, chunk, 3, 7); compressed != size {
+				t.Errorf("column %s compressed size %d, uncompressed %d", column.Name, compressed, size)
+			}
+
+			// 数据页：页头之后为PLAIN编码的值
+			header, n := decodeStruct(t, file, offset)
+			pageSize := field[int64](t, header, 2)
+			if field[int64](t, header, 1) != pageTypeData || field[int64](t, header, 3) != pageSize {
+				t.Errorf("column %s page header = %v", column.Name, header)
+			}
+			if int64(n)+pageSize != size {
+				t.Fatalf("column %s: header %d + page %d bytes, chunk size %d", column.Name, n, pageSize, size)
+			}
+			if numValues := field[int64](t, header, 5, 1); numValues != groupRows {
+				t.Errorf("column %s page num_values = %d", column.Name, numValues)
+			}
+			page := file[offset+int64(n) : offset+size]
+			decoded[i] = append(decoded[i], decodePlain(t, column.Type, page, int(groupRows))...)
+
+			next += size
+			groupSize += size
+		}
+		if total := field[int64](t, group, 2); total != groupSize {
+			t.Errorf("row group %d total_byte_size = %d, want %d", g, total, groupSize)
+		}
+	}
+	if next != metaStart {
+		t.Errorf("column chunks end at %d, FileMetaData starts at %d", next, metaStart)
+	}
+
+	for i := range rows {
+		want := testRow(i)
+		for c, column := range testColumns {
+			if got := decoded[c][i]; !valuesEqual(got, want[c]) {
+				t.Errorf("row %d column %s = %v, want %v", i, column.Name, got, want[c])
+			}
+		}
+	}
+}
+
+// valuesEqual 比较解码值与写入值，时间按毫秒比较
+func valuesEqual(got, want any) bool {
+	if wantTime, ok := want.(time.Time); ok {
+		gotTime, ok := got.(time.Time)
+		return ok && gotTime.Equal(wantTime.Truncate(time.Millisecond))
+	}
+	return got == want
+}
+
+func TestWriterEmptyFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testColumns[:1], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	meta, metaStart := fileMetaData(t, buf.Bytes())
+	if metaStart != int64(len(magic)) {
+		t.Errorf("FileMetaData starts at %d, want right after the magic", metaStart)
+	}
+	if numRows := field[int64](t, meta, 3); numRows != 0 {
+		t.Errorf("num_rows = %d", numRows)
+	}
+	if groups := field[[]any](t, meta, 4); len(groups) != 0 {
+		t.Errorf("%d row groups in empty file", len(groups))
+	}
+}
+
+func TestWriterManyColumns(t *testing.T) {
+	// 超过14个元素的列表使用长格式列表头
+	columns := make([]Column, 20)
+	row := make([]any, len(columns))
+	for i := range columns {
+		columns[i] = Column{Name: fmt.Sprintf("c%02d", i), Type: Int32}
+		row[i] = int32(i * 1000)
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(row...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, _ := fileMetaData(t, buf.Bytes())
+	if schema := field[[]any](t, meta, 2); len(schema) != len(columns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(columns)+1)
+	}
+	chunks := field[[]any](t, field[[]any](t, meta, 4)[0].(map[int16]any), 1)
+	last := chunks[len(chunks)-1].(map[int16]any)
+	offset := field[int64](t, last, 2)
+	header, n := decodeStruct(t, buf.Bytes(), offset)
+	page := buf.Bytes()[offset+int64(n) : offset+int64(n)+field[int64](t, header, 2)]
+	if got := decodePlain(t, Int32, page, 1); got[0] != row[len(row)-1] {
+		t.Errorf("last column = %v, want %v", got[0], row[len(row)-1])
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, nil, 0); err == nil {
+		t.Error("no columns: expected error")
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, []Column{{Name: "x", Type: TimestampMillis + 1}}, 0); err == nil {
+		t.Error("invalid column type: expected error")
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testColumns, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(testRow(0)[:3]...); err == nil {
+		t.Error("short row: expected error")
+	}
+	row := testRow(0)
+	row[len(row)-1] = "not a time"
+	if err := w.WriteRow(row...); err == nil {
+		t.Error("wrong value type: expected error")
+	}
+	// 类型错误的行不能写入一半
+	if w.rows != 0 || slices.ContainsFunc(w.values, func(v []byte) bool { return len(v) > 0 }) || len(w.bools[0]) != 0 {
+		t.Error("rejected row was partially buffered")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(testRow(1)...); !errors.Is(err, ErrClosed) {
+		t.Errorf("write after close: got %v", err)
+	}
+	if err := w.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("close twice: got %v", err)
+	}
+}
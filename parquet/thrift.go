@@ -0,0 +1,101 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift紧凑协议的类型编号
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// thriftWriter Thrift紧凑协议编码器，只实现Parquet元数据用到的类型
+type thriftWriter struct {
+	buf  []byte
+	last []int16 // 各层结构体上一个字段的编号，用于字段编号差值编码
+}
+
+// newThriftWriter 创建编码器，顶层结构体同样以begin(0)开始、end结束
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+// field 写入字段头：编号差值1-15时与类型合为一个字节，否则单独写入编号
+func (w *thriftWriter) field(id int16, typ byte) {
+	delta := id - w.last[len(w.last)-1]
+	if delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+	w.last[len(w.last)-1] = id
+}
+
+// varint 写入zigzag编码的变长整数
+func (w *thriftWriter) varint(v int64) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v<<1^v>>63))
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftBoolTrue)
+	} else {
+		w.field(id, thriftBoolFalse)
+	}
+}
+
+func (w *thriftWriter) string(id int16, v string) {
+	w.field(id, thriftBinary)
+	w.rawString(v)
+}
+
+// rawString 写入不带字段头的字符串（列表元素）
+func (w *thriftWriter) rawString(v string) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+// list 写入列表头，元素随后按顺序写入
+func (w *thriftWriter) list(id int16, elemType byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size)<<4|elemType)
+	} else {
+		w.buf = append(w.buf, 0xF0|elemType)
+		w.buf = binary.AppendUvarint(w.buf, uint64(size))
+	}
+}
+
+// i32Elem 写入i32列表元素
+func (w *thriftWriter) i32Elem(v int32) {
+	w.varint(int64(v))
+}
+
+// begin 开始结构体字段；id为0时开始列表中的结构体元素
+func (w *thriftWriter) begin(id int16) {
+	if id != 0 {
+		w.field(id, thriftStruct)
+	}
+	w.last = append(w.last, 0)
+}
+
+// end 结束结构体
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.last[:len(w.last)-1]
+}
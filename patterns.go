@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// FrameFunc 生成跑马灯第step步的输出帧
+// frame长度为输出点数量且已全部清零，step从0开始逐步递增，花样自行按周期取模
+type FrameFunc func(step int, frame []bool)
+
+// Pattern 跑马灯花样
+type Pattern struct {
+	Name  string    // 配置和接口中使用的名称
+	Label string    // 界面显示名称
+	Frame FrameFunc // 帧生成函数
+}
+
+// PatternInfo 可选的花样：内置花样或自定义序列
+type PatternInfo struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Sequence bool   `json:"sequence,omitempty"` // 是否为自定义序列
+}
+
+// framePlayer 跑马灯帧的播放状态
+type framePlayer interface {
+	// next 将下一帧写入已清零的frame，返回该帧的时长，0表示按当前速度挡位
+	next(frame []bool) (time.Duration, error)
+}
+
+// patternPlayer 内置花样的播放状态
+type patternPlayer struct {
+	pattern *Pattern
+	step    int
+}
+
+func (p *patternPlayer) next(frame []bool) (time.Duration, error) {
+	p.pattern.Frame(p.step, frame)
+	p.step++
+	return 0, nil
+}
+
+// DefaultPattern 默认花样：单点从第一个输出点依次点亮到最后一个后循环
+const DefaultPattern = "chase"
+
+// patterns 已注册的花样，按注册顺序排列，DI按钮按此顺序切换
+var patterns []*Pattern
+
+func init() {
+	RegisterPattern(Pattern{Name: "chase", Label: "单点流水", Frame: chaseFrame})
+	RegisterPattern(Pattern{Name: "reverse", Label: "反向流水", Frame: reverseFrame})
+	RegisterPattern(Pattern{Name: "pingpong", Label: "往返", Frame: pingPongFrame})
+	RegisterPattern(Pattern{Name: "fill", Label: "逐个点亮/熄灭", Frame: fillFrame})
+	RegisterPattern(Pattern{Name: "twodot", Label: "双点流水", Frame: twoDotFrame})
+	RegisterPattern(Pattern{Name: "oddeven", Label: "奇偶交替", Frame: oddEvenFrame})
+	RegisterPattern(Pattern{Name: "centerout", Label: "中心扩散", Frame: centerOutFrame})
+	RegisterPattern(Pattern{Name: "random", Label: "随机", Frame: randomFrame})
+	RegisterPattern(Pattern{Name: "binary", Label: "二进制计数", Frame: binaryFrame})
+}
+
+// RegisterPattern 注册花样，名称重复或缺少帧生成函数时panic
+func RegisterPattern(p Pattern) {
+	if p.Name == "" || p.Frame == nil {
+		panic("pattern requires a name and a frame function")
+	}
+	if _, err := LookupPattern(p.Name); err == nil {
+		panic(fmt.Sprintf("pattern %q registered twice", p.Name))
+	}
+	patterns = append(patterns, &p)
+}
+
+// Patterns 返回全部已注册的花样
+func Patterns() []*Pattern {
+	return patterns
+}
+
+// LookupPattern 按名称查找花样，名称为空时返回默认花样
+func LookupPattern(name string) (*Pattern, error) {
+	if name == "" {
+		name = DefaultPattern
+	}
+	for _, p := range patterns {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown pattern %q", name)
+}
+
+// chaseFrame 单点从第一个输出点流向最后一个
+func chaseFrame(step int, frame []bool) {
+	frame[step%len(frame)] = true
+}
+
+// reverseFrame 单点从最后一个输出点流向第一个
+func reverseFrame(step int, frame []bool) {
+	n := len(frame)
+	frame[n-1-step%n] = true
+}
+
+// pingPongFrame 单点在两端之间往返，端点不重复停留
+func pingPongFrame(step int, frame []bool) {
+	n := len(frame)
+	if n == 1 {
+		frame[0] = true
+		return
+	}
+	pos := step % (2*n - 2)
+	if pos >= n {
+		pos = 2*n - 2 - pos
+	}
+	frame[pos] = true
+}
+
+// fillFrame 从第一个输出点起逐个点亮直至全亮，再从第一个起逐个熄灭直至全灭
+func fillFrame(step int, frame []bool) {
+	n := len(frame)
+	pos := step % (2 * n)
+	for i := range frame {
+		if pos < n {
+			frame[i] = i <= pos
+		} else {
+			frame[i] = i > pos-n
+		}
+	}
+}
+
+// twoDotFrame 相距半圈的两个点同向流动
+func twoDotFrame(step int, frame []bool) {
+	n := len(frame)
+	frame[step%n] = true
+	frame[(step+n/2)%n] = true
+}
+
+// oddEvenFrame 奇数点与偶数点交替点亮
+func oddEvenFrame(step int, frame []bool) {
+	for i := range frame {
+		frame[i] = i%2 == step%2
+	}
+}
+
+// centerOutFrame 一对点从中间向两端扩散，输出点为奇数时从中间一点开始
+func centerOutFrame(step int, frame []bool) {
+	n := len(frame)
+	k := step % ((n + 1) / 2)
+	frame[(n-1)/2-k] = true
+	frame[n/2+k] = true
+}
+
+// randomFrame 每步随机点亮一个输出点
+func randomFrame(step int, frame []bool) {
+	frame[rand.IntN(len(frame))] = true
+}
+
+// binaryFrame 按二进制计数，第一个输出点为最低位
+func binaryFrame(step int, frame []bool) {
+	for i := range frame {
+		if i < 63 {
+			frame[i] = step>>i&1 == 1
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// renderFrames 生成前steps帧，每帧以 * 表示点亮、. 表示熄灭，帧之间以空格分隔
+func renderFrames(fn FrameFunc, lamps, steps int) string {
+	frames := make([]string, steps)
+	frame := make([]bool, lamps)
+	for step := range steps {
+		clear(frame)
+		fn(step, frame)
+		var b strings.Builder
+		for _, on := range frame {
+			if on {
+				b.WriteByte('*')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		frames[step] = b.String()
+	}
+	return strings.Join(frames, " ")
+}
+
+func TestPatternFrames(t *testing.T) {
+	tests := []struct {
+		pattern string
+		lamps   int
+		want    string
+	}{
+		{"chase", 3, "*.. .*. ..* *.."},
+		{"chase", 1, "* *"},
+		{"reverse", 3, "..* .*. *.. ..*"},
+		{"reverse", 1, "* *"},
+		// 往返在端点转向，不重复输出端点帧
+		{"pingpong", 4, "*... .*.. ..*. ...* ..*. .*.. *... .*.."},
+		{"pingpong", 2, "*. .* *. .*"},
+		{"pingpong", 1, "* *"},
+		{"fill", 3, "*.. **. *** .** ..* ... *.."},
+		{"fill", 1, "* . *"},
+		{"twodot", 4, "*.*. .*.* *.*. .*.*"},
+		{"twodot", 5, "*.*.. .*.*. ..*.* *..*. .*..* *.*.."},
+		{"twodot", 1, "* *"},
+		{"oddeven", 3, "*.* .*. *.*"},
+		{"oddeven", 1, "* . *"},
+		{"centerout", 6, "..**.. .*..*. *....* ..**.."},
+		// 输出点为奇数时从中间一点开始
+		{"centerout", 5, "..*.. .*.*. *...* ..*.."},
+		{"centerout", 1, "* *"},
+		{"binary", 3, "... *.. .*. **. ..* *.* .** *** ..."},
+	}
+	for _, tt := range tests {
+		p, err := LookupPattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := renderFrames(p.Frame, tt.lamps, len(strings.Fields(tt.want))); got != tt.want {
+			t.Errorf("%s with %d lamps:\n got %s\nwant %s", tt.pattern, tt.lamps, got, tt.want)
+		}
+	}
+}
+
+func TestBinaryAndRandomFrames(t *testing.T) {
+	// 超过63个输出点时高位保持熄灭
+	frame := make([]bool, 70)
+	binaryFrame(-1, frame)
+	for i, on := range frame {
+		if on != (i < 63) {
+			t.Fatalf("binary frame for -1: lamp %d = %v", i, on)
+		}
+	}
+
+	// 随机花样每步只点亮一个输出点，长时间运行后每个点都会点亮
+	lit := make([]bool, 4)
+	frame = make([]bool, 4)
+	for step := range 200 {
+		clear(frame)
+		randomFrame(step, frame)
+		count := 0
+		for i, on := range frame {
+			if on {
+				count++
+				lit[i] = true
+			}
+		}
+		if count != 1 {
+			t.Fatalf("random frame %v lights %d lamps", frame, count)
+		}
+	}
+	for i, on := range lit {
+		if !on {
+			t.Errorf("lamp %d never lit in 200 random frames", i)
+		}
+	}
+}
+
+func TestLookupPattern(t *testing.T) {
+	p, err := LookupPattern("")
+	if err != nil || p.Name != DefaultPattern {
+		t.Fatalf("default pattern %v, %v", p, err)
+	}
+	if _, err := LookupPattern("sparkle"); err == nil || !strings.Contains(err.Error(), `unknown pattern "sparkle"`) {
+		t.Fatalf("unknown pattern: %v", err)
+	}
+
+	names := make([]string, len(Patterns()))
+	for i, p := range Patterns() {
+		names[i] = p.Name
+	}
+	if got := strings.Join(names, ","); got != "chase,reverse,pingpong,fill,twodot,oddeven,centerout,random,binary" {
+		t.Errorf("pattern order %s", got)
+	}
+}
+
+func TestRegisterPatternRejectsInvalid(t *testing.T) {
+	count := len(Patterns())
+	tests := []struct {
+		name    string
+		pattern Pattern
+		want    string
+	}{
+		{"duplicate", Pattern{Name: "chase", Label: "重复", Frame: reverseFrame}, `pattern "chase" registered twice`},
+		{"missing name", Pattern{Label: "无名", Frame: chaseFrame}, "requires a name"},
+		{"missing frame", Pattern{Name: "blank"}, "requires a name and a frame function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if msg, _ := r.(string); !strings.Contains(msg, tt.want) {
+					t.Fatalf("panic %v, want %q", r, tt.want)
+				}
+			}()
+			RegisterPattern(tt.pattern)
+		})
+	}
+
+	if len(Patterns()) != count {
+		t.Fatalf("rejected pattern registered: %d patterns, want %d", len(Patterns()), count)
+	}
+	if p, _ := LookupPattern("chase"); p.Label != "单点流水" {
+		t.Fatalf("duplicate replaced original pattern: %+v", p)
+	}
+}
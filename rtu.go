@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RTU报文常量
+const (
+	rtuMinFrameLength = 4   // 地址 + 功能码 + CRC
+	rtuMaxFrameLength = 256 // 地址 + 253字节PDU + CRC
+)
+
+// crc16 计算Modbus RTU CRC16校验值（多项式0xA001，初值0xFFFF）
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&0x0001 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// encodeRTUFrame 构造RTU报文：地址 + PDU + CRC（低字节在前）
+func encodeRTUFrame(unitID byte, pdu []byte) []byte {
+	frame := make([]byte, 0, len(pdu)+3)
+	frame = append(frame, unitID)
+	frame = append(frame, pdu...)
+	crc := crc16(frame)
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+// decodeRTUFrame 校验RTU报文CRC并拆分出地址和PDU
+func decodeRTUFrame(frame []byte) (byte, []byte, error) {
+	if len(frame) < rtuMinFrameLength {
+		return 0, nil, &FrameError{Reason: fmt.Sprintf("RTU frame too short: %d bytes", len(frame))}
+	}
+
+	n := len(frame) - 2
+	expected := crc16(frame[:n])
+	actual := uint16(frame[n]) | uint16(frame[n+1])<<8
+	if expected != actual {
+		return 0, nil, &FrameError{Reason: fmt.Sprintf("CRC mismatch: expected 0x%04X, got 0x%04X", expected, actual)}
+	}
+
+	return frame[0], frame[1:n], nil
+}
+
+// rtuResponseLength 根据已接收的字节计算响应帧长度
+// complete为true时n为完整帧长度；否则n为继续判断所需的最少字节数，-1表示只能依靠帧间静默判断
+func rtuResponseLength(buf []byte) (n int, complete bool) {
+	if len(buf) < 3 {
+		return 3, false
+	}
+
+	functionCode := buf[1]
+	switch {
+	case functionCode&0x80 != 0:
+		return 5, true // 地址 + 功能码 + 异常码 + CRC
+	case functionCode >= 0x01 && functionCode <= 0x04, functionCode == 0x17:
+		return 5 + int(buf[2]), true // 地址 + 功能码 + 字节数 + 数据 + CRC
+	case functionCode == 0x05, functionCode == 0x06, functionCode == 0x0F, functionCode == 0x10:
+		return 8, true // 地址 + 功能码 + 4字节回显 + CRC
+	default:
+		return -1, false
+	}
+}
+
+// rtuRequestLength 根据已接收的字节计算请求帧长度，返回值含义同rtuResponseLength
+func rtuRequestLength(buf []byte) (n int, complete bool) {
+	if len(buf) < 2 {
+		return 2, false
+	}
+
+	switch buf[1] {
+	case 0x01, 0x02, 0x03, 0x04, 0x05, 0x06:
+		return 8, true
+	case 0x0F, 0x10:
+		if len(buf) < 7 {
+			return 7, false
+		}
+		return 9 + int(buf[6]), true
+	case 0x2B:
+		return 7, true // 地址 + 功能码 + MEI类型 + 访问类型 + 对象ID + CRC
+	case 0x17:
+		if len(buf) < 11 {
+			return 11, false
+		}
+		return 13 + int(buf[10]), true
+	default:
+		return -1, false
+	}
+}
+
+// readRTUFrame 读取一个完整的RTU报文
+// 能根据功能码确定长度时按长度精确读取，否则以帧间静默时间作为帧结束标志
+// deadline为零值时表示一直等待首个字节
+func readRTUFrame(port deadlineConn, lengthFn func([]byte) (int, bool), deadline time.Time, silence time.Duration) ([]byte, error) {
+	buf := make([]byte, 0, rtuMaxFrameLength)
+
+	for {
+		n, complete := lengthFn(buf)
+		if n < 0 {
+			return readRTUUntilSilence(port, buf, deadline, silence)
+		}
+		if n > rtuMaxFrameLength {
+			return nil, &FrameError{Reason: fmt.Sprintf("RTU frame length %d out of range", n)}
+		}
+
+		if len(buf) < n {
+			port.SetReadDeadline(deadline)
+			chunk := buf[len(buf):n]
+			if _, err := io.ReadFull(port, chunk); err != nil {
+				if errors.Is(err, io.EOF) && len(buf) > 0 {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			buf = buf[:n]
+		}
+
+		if complete {
+			return buf, nil
+		}
+	}
+}
+
+// readRTUUntilSilence 持续读取直到出现帧间静默
+func readRTUUntilSilence(port deadlineConn, buf []byte, deadline time.Time, silence time.Duration) ([]byte, error) {
+	chunk := make([]byte, rtuMaxFrameLength)
+	for {
+		port.SetReadDeadline(earliest(deadline, time.Now().Add(silence)))
+		n, err := port.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if len(buf) > rtuMaxFrameLength {
+			return nil, &FrameError{Reason: "RTU frame exceeds maximum length"}
+		}
+		if err != nil {
+			if isTimeout(err) && len(buf) >= rtuMinFrameLength && (deadline.IsZero() || time.Now().Before(deadline)) {
+				return buf, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// earliest 返回两个截止时间中较早的一个，零值表示不限制
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// rtuSilence 计算3.5个字符的帧间静默时间
+// 波特率高于19200时按规范固定为1.75ms
+func rtuSilence(serial SerialConfig) time.Duration {
+	if serial.BaudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+
+	// 每个字符的位数：起始位 + 数据位 + 校验位 + 停止位
+	bits := 1 + serial.DataBits + serial.StopBits
+	if serial.Parity != "N" {
+		bits++
+	}
+	charTime := time.Duration(bits) * time.Second / time.Duration(serial.BaudRate)
+	return charTime * 7 / 2
+}
+
+// rtuTransport Modbus RTU传输层
+type rtuTransport struct {
+	port         deadlineConn
+	config       *Config
+	silence      time.Duration // 帧间静默时间 (t3.5)
+	lastActivity time.Time     // 最近一次收发完成的时间
+	busy         chan struct{} // 串行化事务，等待期间可被ctx取消
+}
+
+// openRTU 打开串口并建立Modbus RTU传输层
+func openRTU(config *Config) (*rtuTransport, error) {
+	serial := config.SerialSettings()
+	port, err := openSerialPort(serial)
+	if err != nil {
+		return nil, err
+	}
+	return newRTUTransport(port, config, rtuSilence(serial)), nil
+}
+
+// newRTUTransport 在已打开的字节流上创建RTU传输层
+func newRTUTransport(port deadlineConn, config *Config, silence time.Duration) *rtuTransport {
+	return &rtuTransport{
+		port:    port,
+		config:  config,
+		silence: silence,
+		busy:    make(chan struct{}, 1),
+	}
+}
+
+// Send 发送请求PDU并接收响应PDU
+// 请求发出后线路上必然会出现响应，为保持帧同步不中途放弃，ctx只能缩短读取截止时间
+func (t *rtuTransport) Send(ctx context.Context, unitID byte, pdu []byte) ([]byte, error) {
+	// 串行链路同一时刻只能有一个事务在途
+	select {
+	case t.busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-t.busy }()
+
+	// 保证与上一帧之间至少间隔t3.5
+	if wait := time.Until(t.lastActivity.Add(t.silence)); wait > 0 {
+		time.Sleep(wait)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 发送请求
+	t.port.SetWriteDeadline(requestDeadline(ctx, t.config.WriteTimeout()))
+	if _, err := t.port.Write(encodeRTUFrame(unitID, pdu)); err != nil {
+		return nil, &LinkError{Err: err}
+	}
+
+	// 读取响应
+	frame, err := readRTUFrame(t.port, rtuResponseLength, requestDeadline(ctx, t.config.ReadTimeout()), t.silence)
+	t.lastActivity = time.Now()
+	if err != nil {
+		return nil, contextError(ctx, t.resync(err))
+	}
+
+	address, respPDU, err := decodeRTUFrame(frame)
+	if err != nil {
+		return nil, t.resync(err)
+	}
+
+	// 验证从站地址
+	if address != unitID {
+		return nil, t.resync(&FrameError{Reason: fmt.Sprintf("slave address mismatch: expected %d, got %d", unitID, address)})
+	}
+
+	return respPDU, nil
+}
+
+// resync 出错后丢弃线路上的残留数据，无法恢复时返回链路错误
+func (t *rtuTransport) resync(err error) error {
+	if !isResyncable(err) {
+		return &LinkError{Err: err}
+	}
+	if !drainConn(t.port) {
+		return &LinkError{Err: err}
+	}
+	t.lastActivity = time.Now()
+	return err
+}
+
+// Close 关闭串口
+func (t *rtuTransport) Close() error {
+	return t.port.Close()
+}
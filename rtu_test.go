@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// chunkedConn 按预设分段返回数据的假字节流，用于模拟串口上一帧被拆成多次读取
+// 数据读完后返回超时错误，相当于线路静默
+type chunkedConn struct {
+	chunks  [][]byte
+	written bytes.Buffer
+}
+
+func (c *chunkedConn) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n := copy(p, c.chunks[0])
+	if c.chunks[0] = c.chunks[0][n:]; len(c.chunks[0]) == 0 {
+		c.chunks = c.chunks[1:]
+	}
+	return n, nil
+}
+
+func (c *chunkedConn) Write(p []byte) (int, error)      { return c.written.Write(p) }
+func (c *chunkedConn) Close() error                     { return nil }
+func (c *chunkedConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *chunkedConn) SetWriteDeadline(time.Time) error { return nil }
+
+// splitFrame 按给定的分段长度拆分报文，最后一段为剩余部分
+func splitFrame(frame []byte, sizes ...int) [][]byte {
+	var chunks [][]byte
+	for _, size := range sizes {
+		chunks = append(chunks, frame[:size])
+		frame = frame[size:]
+	}
+	return append(chunks, frame)
+}
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want uint16
+	}{
+		{"empty", nil, 0xFFFF},
+		{"check string", []byte("123456789"), 0x4B37},
+		{"read holding registers", []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}, 0xCDC5},
+		{"write single coil", []byte{0x11, 0x05, 0x00, 0xAC, 0xFF, 0x00}, 0x8B4E},
+	}
+	for _, tt := range tests {
+		if got := crc16(tt.data); got != tt.want {
+			t.Errorf("%s: crc16 = 0x%04X, want 0x%04X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRTUFrameRoundTrip(t *testing.T) {
+	pdu := []byte{0x03, 0x00, 0x00, 0x00, 0x0A}
+	frame := encodeRTUFrame(0x01, pdu)
+	if want := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}; !bytes.Equal(frame, want) {
+		t.Fatalf("encodeRTUFrame = % X, want % X", frame, want)
+	}
+
+	address, decoded, err := decodeRTUFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != 0x01 || !bytes.Equal(decoded, pdu) {
+		t.Fatalf("decodeRTUFrame = %d, % X", address, decoded)
+	}
+
+	var frameErr *FrameError
+	corrupted := bytes.Clone(frame)
+	corrupted[3] ^= 0x01
+	if _, _, err := decodeRTUFrame(corrupted); !errors.As(err, &frameErr) {
+		t.Errorf("corrupted frame: got %v, want FrameError", err)
+	}
+	if _, _, err := decodeRTUFrame(frame[:3]); !errors.As(err, &frameErr) {
+		t.Errorf("short frame: got %v, want FrameError", err)
+	}
+}
+
+func TestReadRTUFrameSplitReads(t *testing.T) {
+	tests := []struct {
+		name     string
+		lengthFn func([]byte) (int, bool)
+		frame    []byte
+		sizes    []int
+		trailing []byte // 帧后紧跟的下一帧数据，按长度读取时不能多读
+	}{
+		{"read response", rtuResponseLength, encodeRTUFrame(0x01, []byte{0x03, 0x04, 0x00, 0x0A, 0x00, 0x0B}), []int{1, 3, 3}, []byte{0x01}},
+		{"exception response", rtuResponseLength, encodeRTUFrame(0x01, []byte{0x83, 0x02}), []int{2, 1}, []byte{0x01}},
+		{"write echo", rtuResponseLength, encodeRTUFrame(0x01, []byte{0x0F, 0x00, 0x10, 0x00, 0x08}), []int{1, 1, 1, 1, 1}, []byte{0x01}},
+		{"write multiple request", rtuRequestLength, encodeRTUFrame(0x01, []byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x0A, 0x01, 0x02}), []int{2, 4, 5}, []byte{0x01}},
+		{"unknown function by silence", rtuResponseLength, encodeRTUFrame(0x01, []byte{0x41, 0x01, 0x02}), []int{2, 2}, nil},
+	}
+	for _, tt := range tests {
+		chunks := splitFrame(tt.frame, tt.sizes...)
+		if tt.trailing != nil {
+			chunks = append(chunks, tt.trailing)
+		}
+		conn := &chunkedConn{chunks: chunks}
+		got, err := readRTUFrame(conn, tt.lengthFn, time.Time{}, time.Millisecond)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.frame) {
+			t.Errorf("%s: got % X, want % X", tt.name, got, tt.frame)
+		}
+	}
+}
+
+func TestReadRTUFrameTruncated(t *testing.T) {
+	frame := encodeRTUFrame(0x01, []byte{0x03, 0x04, 0x00, 0x0A, 0x00, 0x0B})
+	conn := &chunkedConn{chunks: [][]byte{frame[:5]}}
+	if _, err := readRTUFrame(conn, rtuResponseLength, time.Time{}, time.Millisecond); !isTimeout(err) {
+		t.Fatalf("truncated frame: got %v, want timeout", err)
+	}
+}
+
+func TestRTUTransportSendSplitResponse(t *testing.T) {
+	response := encodeRTUFrame(0x01, []byte{0x03, 0x02, 0x12, 0x34})
+	conn := &chunkedConn{chunks: splitFrame(response, 2, 2)}
+	transport := newRTUTransport(conn, testTransportConfig(1), time.Millisecond)
+
+	pdu, err := transport.Send(context.Background(), 0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x03, 0x02, 0x12, 0x34}; !bytes.Equal(pdu, want) {
+		t.Fatalf("response PDU = % X, want % X", pdu, want)
+	}
+	if want := encodeRTUFrame(0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01}); !bytes.Equal(conn.written.Bytes(), want) {
+		t.Fatalf("request frame = % X, want % X", conn.written.Bytes(), want)
+	}
+}
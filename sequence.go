@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SequenceConfig 自定义跑马灯序列，按行保存源码
+//
+// 每行一条语句，# 之后为注释：
+//
+//	frame <灯位> [时长]    输出一帧。灯位为与输出点数量等长的 *、1（亮）和 .、0（灭），或 all、none；
+//	                      时长如 200ms、1.5s，纯数字为毫秒，省略时按当前速度挡位
+//	repeat <次数> … end    重复执行块内语句，可嵌套
+//	label <名称>           标记位置
+//	goto <名称> [次数]     跳转到标记；给出次数时跳转该次数后继续向下执行，否则每次都跳转
+//
+// 执行到末尾后从头开始循环。
+type SequenceConfig struct {
+	Name  string   `json:"name"`            // 名称，不能与内置花样重名
+	Label string   `json:"label,omitempty"` // 界面显示名称，默认为名称
+	Lines []string `json:"lines"`           // 源码，每个元素为一行
+}
+
+// DisplayName 返回序列的显示名称
+func (c *SequenceConfig) DisplayName() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Name
+}
+
+// LampCount 返回序列中第一个逐点给出的帧的灯位数，即序列适用的输出点数量
+// 只用all/none的序列返回0，适用于任意数量的输出点
+func (c *SequenceConfig) LampCount() int {
+	for _, text := range c.Lines {
+		if j := strings.IndexByte(text, '#'); j >= 0 {
+			text = text[:j]
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 || strings.ToLower(fields[0]) != "frame" {
+			continue
+		}
+		if lamps := strings.ToLower(fields[1]); lamps != "all" && lamps != "none" {
+			return len([]rune(lamps))
+		}
+	}
+	return 0
+}
+
+// SequenceError 序列中一行的错误
+type SequenceError struct {
+	Line    int    `json:"line"` // 行号（从1开始），0表示整个序列
+	Message string `json:"message"`
+}
+
+func (e SequenceError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// SequenceErrors 编译序列时发现的全部错误
+type SequenceErrors []SequenceError
+
+func (e SequenceErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// 序列的数值范围
+const (
+	minSequenceDuration = 10 * time.Millisecond
+	maxSequenceDuration = time.Hour
+	maxSequenceCount    = 100000 // repeat与goto的次数上限
+	maxSequenceJumps    = 100000 // 连续执行而不输出帧的跳转次数上限，超过即视为死循环
+)
+
+// sequenceName 序列名称只允许字母、数字、下划线和连字符，便于在配置和接口中引用
+var sequenceName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// sequenceStep 编译后的一条指令：frame不为nil时输出一帧，否则为跳转
+type sequenceStep struct {
+	line     int
+	frame    []bool
+	duration time.Duration // 0表示按当前速度挡位
+	target   int           // 跳转目标指令序号
+	count    int           // 跳转次数，0表示每次都跳转
+}
+
+// Sequence 编译后的序列
+type Sequence struct {
+	Name  string
+	Label string
+	steps []sequenceStep
+}
+
+// validateSequenceName 检查序列名称，名称不能与内置花样重名
+func validateSequenceName(name string) error {
+	if !sequenceName.MatchString(name) {
+		return fmt.Errorf("invalid sequence name %q, use letters, digits, _ and -", name)
+	}
+	if _, err := LookupPattern(name); err == nil {
+		return fmt.Errorf("sequence name %q is a built-in pattern", name)
+	}
+	return nil
+}
+
+// CompileSequence 按输出点数量编译序列，返回带行号的全部错误（SequenceErrors）
+func CompileSequence(config SequenceConfig, lamps int) (*Sequence, error) {
+	var errs SequenceErrors
+	fail := func(line int, format string, args ...any) {
+		errs = append(errs, SequenceError{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	type block struct {
+		line, start, frames, count int
+	}
+	type jump struct {
+		line, step int
+		label      string
+	}
+	seq := &Sequence{Name: config.Name, Label: config.DisplayName()}
+	var blocks []block
+	var jumps []jump
+	labels := make(map[string]int)
+	labelLines := make(map[string]int)
+	frames := 0
+
+	for i, text := range config.Lines {
+		line := i + 1
+		if j := strings.IndexByte(text, '#'); j >= 0 {
+			text = text[:j]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		keyword, args := strings.ToLower(fields[0]), fields[1:]
+
+		switch keyword {
+		case "frame":
+			if len(args) < 1 || len(args) > 2 {
+				fail(line, "frame needs lamps and an optional duration")
+				continue
+			}
+			frame, err := parseSequenceFrame(args[0], lamps)
+			if err != nil {
+				fail(line, "%v", err)
+				continue
+			}
+			var duration time.Duration
+			if len(args) == 2 {
+				if duration, err = parseSequenceDuration(args[1]); err != nil {
+					fail(line, "%v", err)
+					continue
+				}
+			}
+			seq.steps = append(seq.steps, sequenceStep{line: line, frame: frame, duration: duration})
+			frames++
+
+		case "repeat":
+			count := 0
+			if len(args) != 1 {
+				fail(line, "repeat needs a count")
+			} else if n, err := parseSequenceCount(args[0]); err != nil {
+				fail(line, "%v", err)
+			} else {
+				count = n
+			}
+			// 次数有误时仍然入栈，使对应的end不再报错
+			blocks = append(blocks, block{line: line, start: len(seq.steps), frames: frames, count: count})
+
+		case "end":
+			if len(args) != 0 {
+				fail(line, "end takes no arguments")
+			}
+			if len(blocks) == 0 {
+				fail(line, "end without repeat")
+				continue
+			}
+			b := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			if frames == b.frames {
+				fail(b.line, "repeat block has no frames")
+				continue
+			}
+			if b.count > 1 {
+				seq.steps = append(seq.steps, sequenceStep{line: line, target: b.start, count: b.count - 1})
+			}
+
+		case "label":
+			if len(args) != 1 {
+				fail(line, "label needs a name")
+				continue
+			}
+			if first, ok := labelLines[args[0]]; ok {
+				fail(line, "label %s already defined on line %d", args[0], first)
+				continue
+			}
+			labels[args[0]] = len(seq.steps)
+			labelLines[args[0]] = line
+
+		case "goto":
+			if len(args) < 1 || len(args) > 2 {
+				fail(line, "goto needs a label and an optional count")
+				continue
+			}
+			step := sequenceStep{line: line}
+			if len(args) == 2 {
+				n, err := parseSequenceCount(args[1])
+				if err != nil {
+					fail(line, "%v", err)
+					continue
+				}
+				step.count = n
+			}
+			jumps = append(jumps, jump{line: line, step: len(seq.steps), label: args[0]})
+			seq.steps = append(seq.steps, step)
+
+		default:
+			fail(line, "unknown statement %q, want frame, repeat, end, label or goto", fields[0])
+		}
+	}
+
+	for _, b := range blocks {
+		fail(b.line, "repeat without end")
+	}
+	for _, j := range jumps {
+		target, ok := labels[j.label]
+		if !ok {
+			fail(j.line, "unknown label %s", j.label)
+			continue
+		}
+		seq.steps[j.step].target = target
+	}
+	if frames == 0 && len(errs) == 0 {
+		fail(0, "sequence has no frames")
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return nil, errs
+	}
+
+	// 试运行一个完整周期（以无限跳转循环播放的序列最多试运行maxSequenceJumps帧），找出不输出帧的死循环
+	player := seq.player()
+	frame := make([]bool, lamps)
+	for i := 0; i < maxSequenceJumps && player.wraps == 0; i++ {
+		if _, err := player.next(frame); err != nil {
+			return nil, SequenceErrors{err.(SequenceError)}
+		}
+	}
+	return seq, nil
+}
+
+// parseSequenceFrame 解析灯位：* 或 1 为亮，. 或 0 为灭，all/none 为全亮/全灭
+func parseSequenceFrame(text string, lamps int) ([]bool, error) {
+	frame := make([]bool, lamps)
+	switch strings.ToLower(text) {
+	case "all":
+		for i := range frame {
+			frame[i] = true
+		}
+		return frame, nil
+	case "none":
+		return frame, nil
+	}
+
+	if n := len([]rune(text)); n != lamps {
+		return nil, fmt.Errorf("frame %s has %d lamps, want %d", text, n, lamps)
+	}
+	for i, c := range text {
+		switch c {
+		case '*', '1':
+			frame[i] = true
+		case '.', '0':
+		default:
+			return nil, fmt.Errorf("invalid lamp %q in frame %s, use * or 1 for on and . or 0 for off", c, text)
+		}
+	}
+	return frame, nil
+}
+
+// parseSequenceDuration 解析帧时长：200ms、1.5s 等，纯数字为毫秒
+func parseSequenceDuration(text string) (time.Duration, error) {
+	duration, err := time.ParseDuration(text)
+	if ms, convErr := strconv.Atoi(text); convErr == nil {
+		duration, err = time.Duration(ms)*time.Millisecond, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s", text)
+	}
+	if duration < minSequenceDuration || duration > maxSequenceDuration {
+		return 0, fmt.Errorf("duration %s out of range %s-%s", text, minSequenceDuration, maxSequenceDuration)
+	}
+	return duration, nil
+}
+
+// parseSequenceCount 解析repeat与goto的次数
+func parseSequenceCount(text string) (int, error) {
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 1 || n > maxSequenceCount {
+		return 0, fmt.Errorf("invalid count %s, want 1-%d", text, maxSequenceCount)
+	}
+	return n, nil
+}
+
+// player 创建从头开始的播放状态
+func (s *Sequence) player() *sequencePlayer {
+	return &sequencePlayer{steps: s.steps, counters: make([]int, len(s.steps))}
+}
+
+// SequenceFrame 预览中的一帧
+type SequenceFrame struct {
+	Line       int    `json:"line"`       // 产生该帧的行号
+	Lamps      []bool `json:"lamps"`      // 各输出点状态
+	DurationMs int64  `json:"durationMs"` // 0表示按当前速度挡位
+}
+
+// Preview 展开序列的帧，直到完成一个周期或达到limit帧，返回帧及是否完成了完整周期
+func (s *Sequence) Preview(lamps, limit int) ([]SequenceFrame, bool) {
+	player := s.player()
+	var frames []SequenceFrame
+	for len(frames) < limit {
+		frame := make([]bool, lamps)
+		duration, _ := player.next(frame) // 编译时已确认不会出现死循环
+		if player.wraps > 0 {
+			return frames, true
+		}
+		frames = append(frames, SequenceFrame{
+			Line:       player.line,
+			Lamps:      frame,
+			DurationMs: duration.Milliseconds(),
+		})
+	}
+	return frames, false
+}
+
+// sequencePlayer 序列的播放状态
+type sequencePlayer struct {
+	steps    []sequenceStep
+	pc       int   // 下一条指令
+	counters []int // 各跳转指令已跳转的次数
+	line     int   // 最近一帧所在行号
+	wraps    int   // 从末尾回到开头的次数
+}
+
+// next 执行到下一帧并写入frame，返回该帧的时长
+// 回到开头时重置全部计数，每个周期的执行过程完全相同
+func (p *sequencePlayer) next(frame []bool) (time.Duration, error) {
+	for jumps := 0; ; {
+		if p.pc >= len(p.steps) {
+			p.pc = 0
+			p.wraps++
+			clear(p.counters)
+		}
+		step := &p.steps[p.pc]
+		if step.frame != nil {
+			copy(frame, step.frame)
+			p.line = step.line
+			p.pc++
+			return step.duration, nil
+		}
+
+		if jumps++; jumps > maxSequenceJumps {
+			return 0, SequenceError{Line: step.line, Message: "loop produces no frames"}
+		}
+		switch {
+		case step.count == 0:
+			p.pc = step.target
+		case p.counters[p.pc] < step.count:
+			p.counters[p.pc]++
+			p.pc = step.target
+		default:
+			p.counters[p.pc] = 0
+			p.pc++
+		}
+	}
+}
+
+// validateSequences 按各序列自身的灯位数检查全部序列，名称不能重复
+// 各站点输出点数量可以不同，序列只在灯位数相符的站点中可选，见MarqueeController.Patterns
+func validateSequences(sequences []SequenceConfig) error {
+	names := make(map[string]bool, len(sequences))
+	for _, config := range sequences {
+		if err := validateSequenceName(config.Name); err != nil {
+			return err
+		}
+		if names[config.Name] {
+			return fmt.Errorf("duplicate sequence %q", config.Name)
+		}
+		names[config.Name] = true
+		if _, err := CompileSequence(config, max(config.LampCount(), 1)); err != nil {
+			return fmt.Errorf("sequence %s: %w", config.Name, err)
+		}
+	}
+	return nil
+}
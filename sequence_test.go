@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// playSequence 编译序列并播放n帧，返回各帧所在的行号
+func playSequence(t *testing.T, lines []string, lamps, n int) []int {
+	t.Helper()
+	seq, err := CompileSequence(SequenceConfig{Name: "test", Lines: lines}, lamps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := seq.player()
+	frame := make([]bool, lamps)
+	var order []int
+	for range n {
+		if _, err := player.next(frame); err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, player.line)
+	}
+	return order
+}
+
+func TestSequenceExecutionOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []int // 依次输出的帧所在行号，跨越周期末尾
+	}{
+		{"frames loop", []string{
+			"frame *...",
+			"",
+			"  # 注释行",
+			"FRAME .*.. # 关键字不区分大小写",
+		}, []int{1, 4, 1, 4}},
+		{"nested repeat", []string{
+			"frame *...",
+			"repeat 2",
+			"  frame .*..",
+			"  repeat 2",
+			"    frame ..*.",
+			"  end",
+			"end",
+			"frame ...*",
+		}, []int{1, 3, 5, 5, 3, 5, 5, 8, 1, 3, 5, 5}},
+		{"repeat once", []string{
+			"repeat 1",
+			"  frame *...",
+			"end",
+			"frame .*..",
+		}, []int{2, 4, 2, 4}},
+		{"goto with count", []string{
+			"label top",
+			"frame *...",
+			"frame .*..",
+			"goto top 2",
+			"frame ****",
+		}, []int{2, 3, 2, 3, 2, 3, 5, 2, 3, 2, 3, 2, 3, 5}},
+		{"goto forever", []string{
+			"frame all",
+			"label loop",
+			"frame *...",
+			"frame .*..",
+			"goto loop",
+			"frame none",
+		}, []int{1, 3, 4, 3, 4, 3, 4}},
+		{"goto forward", []string{
+			"frame *...",
+			"goto skip",
+			"frame .*..",
+			"label skip",
+			"frame ..*.",
+		}, []int{1, 5, 1, 5}},
+		{"goto into repeat", []string{
+			"repeat 3",
+			"  label inner",
+			"  frame *...",
+			"end",
+			"goto inner 1",
+			"frame ....",
+		}, []int{3, 3, 3, 3, 3, 3, 6, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := playSequence(t, tt.lines, 4, len(tt.want))
+			if fmt.Sprint(order) != fmt.Sprint(tt.want) {
+				t.Fatalf("frame lines %v, want %v", order, tt.want)
+			}
+		})
+	}
+}
+
+func TestSequenceFramesAndDurations(t *testing.T) {
+	seq, err := CompileSequence(SequenceConfig{Name: "test", Lines: []string{
+		"frame *.1.0 250",
+		"frame all 1.5s",
+		"frame none",
+	}}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, complete := seq.Preview(5, 10)
+	if !complete || len(frames) != 3 {
+		t.Fatalf("preview %d frames, complete %v", len(frames), complete)
+	}
+	want := []struct {
+		lamps    string
+		duration int64
+	}{
+		{"[true false true false false]", 250},
+		{"[true true true true true]", 1500},
+		{"[false false false false false]", 0},
+	}
+	for i, w := range want {
+		if got := fmt.Sprint(frames[i].Lamps); got != w.lamps || frames[i].DurationMs != w.duration {
+			t.Errorf("frame %d = %s %dms, want %s %dms", i, got, frames[i].DurationMs, w.lamps, w.duration)
+		}
+	}
+
+	// 预览达到上限时不完整
+	if frames, complete := seq.Preview(5, 2); complete || len(frames) != 2 {
+		t.Errorf("limited preview %d frames, complete %v", len(frames), complete)
+	}
+}
+
+func TestCompileSequenceErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []SequenceError // Message为错误信息中应包含的内容
+	}{
+		{"unknown lamp", []string{"frame *x.."}, []SequenceError{{1, `invalid lamp 'x'`}}},
+		{"lamp count", []string{"frame *...", "frame **"}, []SequenceError{{2, "has 2 lamps, want 4"}}},
+		{"repeat without end", []string{"frame *...", "repeat 2", "frame .*.."}, []SequenceError{{2, "repeat without end"}}},
+		{"end without repeat", []string{"frame *...", "end"}, []SequenceError{{2, "end without repeat"}}},
+		{"empty repeat", []string{"frame *...", "repeat 2", "end"}, []SequenceError{{2, "repeat block has no frames"}}},
+		{"missing label", []string{"frame *...", "goto nowhere"}, []SequenceError{{2, "unknown label nowhere"}}},
+		{"duplicate label", []string{"label a", "frame *...", "label a"}, []SequenceError{{3, "already defined on line 1"}}},
+		{"unknown statement", []string{"frame *...", "wait 100"}, []SequenceError{{2, `unknown statement "wait"`}}},
+		{"duration", []string{"frame *... 5ms", "frame .*.. 2h", "frame ..*. soon"}, []SequenceError{
+			{1, "out of range"}, {2, "out of range"}, {3, "invalid duration soon"},
+		}},
+		{"count", []string{"repeat 0", "frame *...", "end", "goto x many", "label x"}, []SequenceError{
+			{1, "invalid count 0"}, {4, "invalid count many"},
+		}},
+		{"no frames", []string{"# 只有注释"}, []SequenceError{{0, "sequence has no frames"}}},
+		// 全部错误按行号排列；帧有误时所在的repeat块也视为没有帧
+		{"sorted", []string{"repeat 2", "frame *", "goto nowhere", "end", "end"}, []SequenceError{
+			{1, "repeat block has no frames"}, {2, "has 1 lamps"}, {3, "unknown label"}, {5, "end without repeat"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileSequence(SequenceConfig{Name: "test", Lines: tt.lines}, 4)
+			var errs SequenceErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("error %v, want SequenceErrors", err)
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors %v, want %d", errs, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if errs[i].Line != want.Line || !strings.Contains(errs[i].Message, want.Message) {
+					t.Errorf("error %d = %v, want line %d: %s", i, errs[i], want.Line, want.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileSequenceRejectsDeadLoop(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		line  int
+	}{
+		{"goto self", []string{"frame *...", "label spin", "goto spin"}, 3},
+		{"goto back without frames", []string{"label a", "goto b", "frame *...", "label b", "goto a"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileSequence(SequenceConfig{Name: "test", Lines: tt.lines}, 4)
+			var errs SequenceErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != tt.line || errs[0].Message != "loop produces no frames" {
+				t.Fatalf("error %v, want line %d: loop produces no frames", err, tt.line)
+			}
+		})
+	}
+
+	// 有次数的跳转最终会继续向下执行，不是死循环
+	if _, err := CompileSequence(SequenceConfig{Name: "test", Lines: []string{"frame *...", "label a", "goto a 100"}}, 4); err != nil {
+		t.Fatalf("bounded goto rejected: %v", err)
+	}
+}
+
+func TestValidateSequenceName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"wave_2-a", true},
+		{"Knight", true},
+		{"", false},
+		{"my wave", false},
+		{"波浪", false},
+		{"a.b", false},
+		{DefaultPattern, false}, // 与内置花样重名
+	}
+	for _, tt := range tests {
+		if err := validateSequenceName(tt.name); (err == nil) != tt.valid {
+			t.Errorf("validateSequenceName(%q) = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+
+	err := validateSequences([]SequenceConfig{
+		{Name: "a", Lines: []string{"frame *."}},
+		{Name: "a", Lines: []string{"frame all"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "duplicate sequence") {
+		t.Errorf("duplicate sequences: %v", err)
+	}
+}
+
+func TestSequenceConfigConcurrentEdit(t *testing.T) {
+	root := DefaultConfig()
+	root.Devices = []DeviceConfig{{Name: "A", IP: "127.0.0.1"}, {Name: "B", IP: "127.0.0.2"}}
+	stations := root.StationConfigs()
+	m := NewMarqueeController(nil, stations[0])
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			station := stations[i%2]
+			for j := range 50 {
+				name := fmt.Sprintf("seq%d", j%5)
+				station.SetSequence(SequenceConfig{Name: name, Lines: []string{"frame all", "frame none"}})
+				station.DeleteSequence(name)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				for _, info := range m.Patterns() {
+					if info.Sequence {
+						m.SetPattern(info.Name) // 序列可能已被删除
+					}
+				}
+				m.Pattern()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stations[1].SetSequence(SequenceConfig{Name: "keep", Lines: []string{"frame all"}})
+	if list := stations[0].SequenceList(); len(list) != 1 || list[0].Name != "keep" {
+		t.Fatalf("sequences after edits %+v", list)
+	}
+
+	// 删除正在使用的序列时，站点和设备条目中的花样恢复为默认
+	if err := m.SetPattern("keep"); err != nil {
+		t.Fatal(err)
+	}
+	root.Devices[0].Pattern = "keep"
+	if !stations[0].DeleteSequence("keep") || stations[0].Pattern != "" || root.Devices[0].Pattern != "" {
+		t.Fatalf("pattern after delete: station %q, device %q", stations[0].Pattern, root.Devices[0].Pattern)
+	}
+	if stations[0].DeleteSequence("keep") {
+		t.Fatal("deleted missing sequence")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+// SerialConfig 串口参数
+type SerialConfig struct {
+	Device   string `json:"device"`   // 设备路径，如 COM3 或 /dev/ttyUSB0
+	BaudRate int    `json:"baudRate"` // 波特率
+	DataBits int    `json:"dataBits"` // 数据位，RTU固定为8
+	Parity   string `json:"parity"`   // 校验位：N/E/O
+	StopBits int    `json:"stopBits"` // 停止位：1/2
+}
+
+// SerialSettings 返回串口参数，未配置的字段使用Modbus RTU默认值 (9600 8E1)
+func (c *Config) SerialSettings() SerialConfig {
+	serial := SerialConfig{}
+	if c.Serial != nil {
+		serial = *c.Serial
+	}
+	if serial.BaudRate <= 0 {
+		serial.BaudRate = 9600
+	}
+	if serial.DataBits <= 0 {
+		serial.DataBits = 8
+	}
+	if serial.Parity == "" {
+		serial.Parity = "E"
+	}
+	if serial.StopBits <= 0 {
+		serial.StopBits = 1
+	}
+	return serial
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// 波特率常量映射
+var linuxBaudRates = map[int]uint32{
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+}
+
+// openSerialPort 以原始模式打开串口
+// 文件描述符为非阻塞模式，由Go运行时轮询，因此支持读写超时
+func openSerialPort(serial SerialConfig) (deadlineConn, error) {
+	baud, ok := linuxBaudRates[serial.BaudRate]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate: %d", serial.BaudRate)
+	}
+
+	fd, err := syscall.Open(serial.Device, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: serial.Device, Err: err}
+	}
+
+	termios, err := serialTermios(serial, baud)
+	if err == nil {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(termios)))
+		if errno != 0 {
+			err = fmt.Errorf("configure %s: %w", serial.Device, errno)
+		}
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), serial.Device), nil
+}
+
+// serialTermios 构造原始模式的串口参数
+// 波特率只通过Cflag的CBAUD位设置：TCSETS不使用Ispeed/Ospeed，且mips系列的syscall.Termios没有这两个字段
+func serialTermios(serial SerialConfig, baud uint32) (*syscall.Termios, error) {
+	termios := &syscall.Termios{
+		Cflag: syscall.CREAD | syscall.CLOCAL | baud,
+	}
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	// 数据位
+	switch serial.DataBits {
+	case 7:
+		termios.Cflag |= syscall.CS7
+	case 8:
+		termios.Cflag |= syscall.CS8
+	default:
+		return nil, fmt.Errorf("unsupported data bits: %d", serial.DataBits)
+	}
+
+	// 校验位
+	switch serial.Parity {
+	case "N":
+	case "E":
+		termios.Cflag |= syscall.PARENB
+		termios.Iflag |= syscall.INPCK
+	case "O":
+		termios.Cflag |= syscall.PARENB | syscall.PARODD
+		termios.Iflag |= syscall.INPCK
+	default:
+		return nil, fmt.Errorf("unsupported parity: %s", serial.Parity)
+	}
+
+	// 停止位
+	switch serial.StopBits {
+	case 1:
+	case 2:
+		termios.Cflag |= syscall.CSTOPB
+	default:
+		return nil, fmt.Errorf("unsupported stop bits: %d", serial.StopBits)
+	}
+
+	return termios, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// openPTY 打开一对伪终端，返回主端和从端设备路径；系统不支持伪终端时跳过测试
+// 从端由被测代码按串口方式打开并设置为原始模式
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	fd, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		syscall.Close(fd)
+		t.Skipf("unlock pty: %v", errno)
+	}
+	var index uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&index))); errno != 0 {
+		syscall.Close(fd)
+		t.Skipf("get pty number: %v", errno)
+	}
+
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+	t.Cleanup(func() { master.Close() })
+	return master, fmt.Sprintf("/dev/pts/%d", index)
+}
+
+// ptySerialConfig 返回使用伪终端从端的串口配置
+func ptySerialConfig(device string) *Config {
+	config := DefaultConfig()
+	config.Transport = "rtu"
+	config.Serial = &SerialConfig{Device: device, BaudRate: 115200}
+	config.ReadTimeoutMs = 500
+	return config
+}
+
+// quietSimulator 创建不输出日志的仿真器
+func quietSimulator(t *testing.T) *Simulator {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return NewSimulator(nil)
+}
+
+func TestRTUOverPTY(t *testing.T) {
+	master, device := openPTY(t)
+	sim := quietSimulator(t)
+
+	// 先由客户端打开从端完成原始模式设置，再在主端提供服务
+	config := ptySerialConfig(device)
+	client := NewModbusClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	go sim.ServeRTU(master, byte(config.UnitID), rtuSilence(config.SerialSettings()))
+
+	want := []bool{true, false, true, true, false, false, true, false, false, true}
+	if _, err := client.WriteMultipleCoils(2, want); err != nil {
+		t.Fatalf("write coils: %v", err)
+	}
+	resp, err := client.ReadCoils(2, uint16(len(want)))
+	if err != nil {
+		t.Fatalf("read coils: %v", err)
+	}
+	coils, err := parseBitsResponse(resp, len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if coils[i] != want[i] || sim.Coils()[2+i] != want[i] {
+			t.Fatalf("coil %d: read %v, simulator %v, want %v", 2+i, coils[i], sim.Coils()[2+i], want[i])
+		}
+	}
+
+	registers := []uint16{0x0000, 0x1234, 0xFFFF}
+	if err := client.WriteMultipleRegisters(10, registers); err != nil {
+		t.Fatalf("write registers: %v", err)
+	}
+	got, err := client.ReadHoldingRegisters(10, uint16(len(registers)))
+	if err != nil {
+		t.Fatalf("read registers: %v", err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(registers) {
+		t.Fatalf("registers = %v, want %v", got, registers)
+	}
+
+	// 异常响应经RTU帧原样返回
+	_, err = client.ReadHoldingRegisters(99, 2)
+	var exception *ModbusException
+	if !errors.As(err, &exception) || exception.ExceptionCode != 0x02 {
+		t.Fatalf("out-of-range read: got %v, want illegal data address", err)
+	}
+}
+
+func TestASCIIOverPTY(t *testing.T) {
+	master, device := openPTY(t)
+	sim := quietSimulator(t)
+
+	config := ptySerialConfig(device)
+	port, err := openSerialPort(config.SerialSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := newASCIITransport(port, config)
+	defer transport.Close()
+	go sim.ServeASCII(master, byte(config.UnitID))
+
+	ctx := context.Background()
+	write := []byte{0x06, 0x00, 0x05, 0xAB, 0xCD}
+	resp, err := transport.Send(ctx, byte(config.UnitID), write)
+	if err != nil {
+		t.Fatalf("write register: %v", err)
+	}
+	if string(resp) != string(write) {
+		t.Fatalf("write echo = % X, want % X", resp, write)
+	}
+
+	resp, err = transport.Send(ctx, byte(config.UnitID), []byte{0x03, 0x00, 0x05, 0x00, 0x01})
+	if err != nil {
+		t.Fatalf("read register: %v", err)
+	}
+	if want := []byte{0x03, 0x02, 0xAB, 0xCD}; string(resp) != string(want) {
+		t.Fatalf("read response = % X, want % X", resp, want)
+	}
+}
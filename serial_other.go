@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openSerialPort 当前平台不支持串口
+func openSerialPort(serial SerialConfig) (deadlineConn, error) {
+	return nil, fmt.Errorf("serial port is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	kernel32            = syscall.NewLazyDLL("kernel32.dll")
+	procGetCommState    = kernel32.NewProc("GetCommState")
+	procSetCommState    = kernel32.NewProc("SetCommState")
+	procSetCommTimeouts = kernel32.NewProc("SetCommTimeouts")
+	procPurgeComm       = kernel32.NewProc("PurgeComm")
+)
+
+// PurgeComm标志
+const (
+	purgeRxClear = 0x0008
+	purgeTxClear = 0x0004
+)
+
+// dcb Windows串口设备控制块
+type dcb struct {
+	DCBlength  uint32
+	BaudRate   uint32
+	Flags      uint32 // 位域，bit0 fBinary，bit1 fParity
+	wReserved  uint16
+	XonLim     uint16
+	XoffLim    uint16
+	ByteSize   byte
+	Parity     byte // 0=无校验 1=奇校验 2=偶校验
+	StopBits   byte // 0=1位 2=2位
+	XonChar    byte
+	XoffChar   byte
+	ErrorChar  byte
+	EofChar    byte
+	EvtChar    byte
+	wReserved1 uint16
+}
+
+// commTimeouts Windows串口超时设置（毫秒）
+type commTimeouts struct {
+	ReadIntervalTimeout         uint32
+	ReadTotalTimeoutMultiplier  uint32
+	ReadTotalTimeoutConstant    uint32
+	WriteTotalTimeoutMultiplier uint32
+	WriteTotalTimeoutConstant   uint32
+}
+
+// windowsSerialPort Windows串口，用COMMTIMEOUTS模拟读写超时
+type windowsSerialPort struct {
+	handle syscall.Handle
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// openSerialPort 打开并配置串口
+func openSerialPort(serial SerialConfig) (deadlineConn, error) {
+	// COM10及以上必须使用设备命名空间路径
+	path := serial.Device
+	if !strings.HasPrefix(path, `\\.\`) {
+		path = `\\.\` + path
+	}
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(pathPtr, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: serial.Device, Err: err}
+	}
+
+	if err := configureCommState(handle, serial); err != nil {
+		syscall.CloseHandle(handle)
+		return nil, err
+	}
+	procPurgeComm.Call(uintptr(handle), purgeRxClear|purgeTxClear)
+
+	return &windowsSerialPort{handle: handle}, nil
+}
+
+// configureCommState 设置波特率、数据位、校验位和停止位
+func configureCommState(handle syscall.Handle, serial SerialConfig) error {
+	var state dcb
+	state.DCBlength = uint32(unsafe.Sizeof(state))
+	if r, _, err := procGetCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&state))); r == 0 {
+		return fmt.Errorf("GetCommState: %w", err)
+	}
+
+	state.BaudRate = uint32(serial.BaudRate)
+	state.ByteSize = byte(serial.DataBits)
+	state.Flags = 0x0001 // fBinary，关闭流控
+
+	switch serial.Parity {
+	case "N":
+		state.Parity = 0
+	case "O":
+		state.Parity = 1
+		state.Flags |= 0x0002
+	case "E":
+		state.Parity = 2
+		state.Flags |= 0x0002
+	default:
+		return fmt.Errorf("unsupported parity: %s", serial.Parity)
+	}
+
+	switch serial.StopBits {
+	case 1:
+		state.StopBits = 0
+	case 2:
+		state.StopBits = 2
+	default:
+		return fmt.Errorf("unsupported stop bits: %d", serial.StopBits)
+	}
+
+	if r, _, err := procSetCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&state))); r == 0 {
+		return fmt.Errorf("SetCommState: %w", err)
+	}
+	return nil
+}
+
+// Read 读取数据，截止时间内无数据时返回超时错误
+func (p *windowsSerialPort) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	deadline := p.readDeadline
+	p.mu.Unlock()
+
+	// ReadIntervalTimeout与ReadTotalTimeoutMultiplier均为MAXDWORD时，
+	// 有数据立即返回，否则最多等待ReadTotalTimeoutConstant
+	timeouts := commTimeouts{
+		ReadIntervalTimeout:        0xFFFFFFFF,
+		ReadTotalTimeoutMultiplier: 0xFFFFFFFF,
+		ReadTotalTimeoutConstant:   0xFFFFFFFE,
+	}
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timeouts.ReadTotalTimeoutConstant = uint32((remaining + time.Millisecond - 1) / time.Millisecond)
+	}
+	if err := p.setTimeouts(&timeouts); err != nil {
+		return 0, err
+	}
+
+	var n uint32
+	if err := syscall.ReadFile(p.handle, b, &n, nil); err != nil {
+		return int(n), err
+	}
+	if n == 0 && len(b) > 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	return int(n), nil
+}
+
+// Write 写入数据
+func (p *windowsSerialPort) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	deadline := p.writeDeadline
+	p.mu.Unlock()
+
+	timeouts := commTimeouts{}
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timeouts.WriteTotalTimeoutConstant = uint32((remaining + time.Millisecond - 1) / time.Millisecond)
+	}
+	if err := p.setTimeouts(&timeouts); err != nil {
+		return 0, err
+	}
+
+	var n uint32
+	if err := syscall.WriteFile(p.handle, b, &n, nil); err != nil {
+		return int(n), err
+	}
+	if int(n) < len(b) {
+		return int(n), os.ErrDeadlineExceeded
+	}
+	return int(n), nil
+}
+
+// setTimeouts 应用串口超时设置
+func (p *windowsSerialPort) setTimeouts(timeouts *commTimeouts) error {
+	if r, _, err := procSetCommTimeouts.Call(uintptr(p.handle), uintptr(unsafe.Pointer(timeouts))); r == 0 {
+		return fmt.Errorf("SetCommTimeouts: %w", err)
+	}
+	return nil
+}
+
+// SetReadDeadline 设置读取截止时间
+func (p *windowsSerialPort) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	p.readDeadline = t
+	p.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline 设置写入截止时间
+func (p *windowsSerialPort) SetWriteDeadline(t time.Time) error {
+	p.mu.Lock()
+	p.writeDeadline = t
+	p.mu.Unlock()
+	return nil
+}
+
+// Close 关闭串口
+func (p *windowsSerialPort) Close() error {
+	return syscall.CloseHandle(p.handle)
+}
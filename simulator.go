@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// SimulatorConfig 仿真器配置
+type SimulatorConfig struct {
+	ListenAddr       string           `json:"listenAddr"`       // 监听地址
+	Framing          string           `json:"framing"`          // TCP报文格式：tcp（默认）/rtuovertcp/ascii
+	UnitID           int              `json:"unitId"`           // RTU从站地址
+	Coils            int              `json:"coils"`            // 线圈数量 (Q区)
+	DiscreteInputs   int              `json:"discreteInputs"`   // 离散输入数量 (I区)
+	InputRegisters   int              `json:"inputRegisters"`   // 输入寄存器数量 (IW区，短地址n对应IW(2n))
+	HoldingRegisters int              `json:"holdingRegisters"` // 保持寄存器数量 (MB_SERVER保持区)
+	Buttons          []SimButtonPress `json:"buttons"`          // 脚本化按钮动作
+	Waveforms        []SimWaveform    `json:"waveforms"`        // 输入寄存器波形
+	DeviceObjects    map[byte]string  `json:"deviceObjects"`    // 设备标识对象 (功能码 0x2B / MEI 0x0E)
+}
+
+// SimButtonPress 脚本化按钮动作
+type SimButtonPress struct {
+	Input      int `json:"input"`      // 离散输入短地址
+	AtMs       int `json:"atMs"`       // 启动后首次按下的时间
+	DurationMs int `json:"durationMs"` // 按下持续时间
+	RepeatMs   int `json:"repeatMs"`   // 重复周期，0表示只按一次
+}
+
+// SimWaveform 输入寄存器波形，数值为S7原始值 (0-27648)
+type SimWaveform struct {
+	Register int     `json:"register"` // 输入寄存器短地址
+	Shape    string  `json:"shape"`    // sine/triangle/square/constant
+	Min      float64 `json:"min"`      // 最小原始值
+	Max      float64 `json:"max"`      // 最大原始值
+	PeriodMs int     `json:"periodMs"` // 周期
+}
+
+// DefaultSimulatorConfig 返回默认仿真器配置
+func DefaultSimulatorConfig() *SimulatorConfig {
+	return &SimulatorConfig{
+		ListenAddr:       "127.0.0.1:5020",
+		UnitID:           1,
+		Coils:            14,
+		DiscreteInputs:   14,
+		InputRegisters:   64,
+		HoldingRegisters: 100,
+		Waveforms: []SimWaveform{
+			{Register: 32, Shape: "sine", Min: 12672, Max: 17280, PeriodMs: 60000}, // IW64 温度 15-35℃
+			{Register: 33, Shape: "sine", Min: 11059, Max: 19354, PeriodMs: 90000}, // IW66 湿度 40-70%
+		},
+		DeviceObjects: map[byte]string{
+			DeviceObjectVendorName:          "SIEMENS (simulated)",
+			DeviceObjectProductCode:         "6ES7 214-1AG40-0XB0",
+			DeviceObjectMajorMinorRevision:  "V4.5",
+			DeviceObjectVendorURL:           "https://github.com/ChuwuYo/S7-1200-OwnDis",
+			DeviceObjectProductName:         "S7-1200 Modbus Simulator",
+			DeviceObjectModelName:           "CPU 1214C DC/DC/DC",
+			DeviceObjectUserApplicationName: "s7-1200-marquee",
+			0x80:                            "MB_SERVER",
+		},
+	}
+}
+
+// DemoButtonPresses 返回演示用按钮脚本：3秒后按下I0.0，之后每10秒一次（启动/速度切换）
+// 默认不启用，由-sim-buttons或sim -buttons在未配置buttons时使用
+func DemoButtonPresses() []SimButtonPress {
+	return []SimButtonPress{
+		{Input: 0, AtMs: 3000, DurationMs: 300, RepeatMs: 10000},
+	}
+}
+
+// Simulator S7-1200 Modbus TCP仿真服务器
+type Simulator struct {
+	config    *SimulatorConfig
+	listener  net.Listener
+	started   time.Time
+	startOnce sync.Once
+	stopOnce  sync.Once
+
+	mu               sync.RWMutex
+	coils            []bool
+	discreteInputs   []bool // 手动设置的输入状态
+	inputRegisters   []uint16
+	holdingRegisters []uint16
+	diagCounters     [8]uint16 // 诊断计数器，下标为子功能码减0x0B
+
+	conns    map[io.Closer]struct{} // 客户端连接与串口
+	stopChan chan bool
+}
+
+// NewSimulator 创建新的仿真器
+func NewSimulator(config *SimulatorConfig) *Simulator {
+	if config == nil {
+		config = DefaultSimulatorConfig()
+	}
+	return &Simulator{
+		config:           config,
+		coils:            make([]bool, config.Coils),
+		discreteInputs:   make([]bool, config.DiscreteInputs),
+		inputRegisters:   make([]uint16, config.InputRegisters),
+		holdingRegisters: make([]uint16, config.HoldingRegisters),
+		conns:            make(map[io.Closer]struct{}),
+		stopChan:         make(chan bool),
+	}
+}
+
+// Start 开始监听并处理客户端连接
+func (s *Simulator) Start() error {
+	listener, err := net.Listen("tcp", s.config.ListenAddr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.startBackground()
+
+	log.Printf("Modbus仿真器监听于 %s (%s)", listener.Addr(), s.framing())
+	go s.acceptLoop()
+	return nil
+}
+
+// StartRTU 在串口上以Modbus RTU方式提供服务
+func (s *Simulator) StartRTU(serial SerialConfig) error {
+	port, err := openSerialPort(serial)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.conns[port] = struct{}{}
+	s.mu.Unlock()
+	s.startBackground()
+
+	log.Printf("Modbus RTU仿真器运行于 %s (%d %d%s%d)", serial.Device, serial.BaudRate, serial.DataBits, serial.Parity, serial.StopBits)
+	go s.ServeRTU(port, byte(s.config.UnitID), rtuSilence(serial))
+	return nil
+}
+
+// startBackground 启动脚本计时与波形更新（只执行一次）
+func (s *Simulator) startBackground() {
+	s.startOnce.Do(func() {
+		s.mu.Lock()
+		s.started = time.Now()
+		s.mu.Unlock()
+		go s.runWaveforms()
+	})
+}
+
+// Stop 停止仿真器并断开所有客户端，可重复调用
+func (s *Simulator) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+		if s.listener != nil {
+			s.listener.Close()
+		}
+
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	})
+}
+
+// Addr 返回实际监听地址
+func (s *Simulator) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Coils 获取当前线圈状态
+func (s *Simulator) Coils() []bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]bool(nil), s.coils...)
+}
+
+// SetDiscreteInput 设置离散输入状态
+func (s *Simulator) SetDiscreteInput(index int, value bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index >= 0 && index < len(s.discreteInputs) {
+		s.discreteInputs[index] = value
+	}
+}
+
+// SetInputRegister 设置输入寄存器原始值
+func (s *Simulator) SetInputRegister(index int, value uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index >= 0 && index < len(s.inputRegisters) {
+		s.inputRegisters[index] = value
+	}
+}
+
+// acceptLoop 接受客户端连接
+func (s *Simulator) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serve(conn)
+	}
+}
+
+// framing 返回TCP报文格式
+func (s *Simulator) framing() string {
+	if s.config.Framing == "" {
+		return "tcp"
+	}
+	return s.config.Framing
+}
+
+// serve 处理单个客户端的请求
+func (s *Simulator) serve(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	switch s.framing() {
+	case "rtuovertcp":
+		s.ServeRTU(conn, byte(s.config.UnitID), rtuOverTCPSilence)
+		return
+	case "ascii":
+		s.ServeASCII(conn, byte(s.config.UnitID))
+		return
+	}
+
+	for {
+		header, pdu, err := readMBAPFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("仿真器读取请求失败: %v", err)
+			}
+			return
+		}
+
+		s.countDiagnostic(DiagBusMessageCount)
+		resp := s.handlePDU(pdu)
+		if _, err := conn.Write(encodeMBAPFrame(header.TransactionID, header.UnitID, resp)); err != nil {
+			return
+		}
+	}
+}
+
+// ServeRTU 在字节流上处理RTU请求，直到读取失败
+// CRC错误或地址不匹配的报文按规范静默丢弃，广播地址0只执行不响应
+func (s *Simulator) ServeRTU(port deadlineConn, unitID byte, silence time.Duration) {
+	for {
+		frame, err := readRTUFrame(port, rtuRequestLength, time.Time{}, silence)
+		if err != nil {
+			if isResyncable(err) {
+				drainConn(port)
+				continue
+			}
+			return
+		}
+
+		address, pdu, err := decodeRTUFrame(frame)
+		if err != nil {
+			log.Printf("仿真器丢弃RTU请求: %v", err)
+			s.countDiagnostic(DiagBusCommErrorCount)
+			drainConn(port)
+			continue
+		}
+		s.countDiagnostic(DiagBusMessageCount)
+		if address != unitID && address != 0 {
+			continue
+		}
+
+		resp := s.handlePDU(pdu)
+		if address == 0 {
+			s.countDiagnostic(DiagServerNoResponseCount)
+			continue
+		}
+
+		// 响应前保持帧间静默
+		time.Sleep(silence)
+		port.SetWriteDeadline(time.Time{})
+		if _, err := port.Write(encodeRTUFrame(unitID, resp)); err != nil {
+			return
+		}
+	}
+}
+
+// ServeASCII 在字节流上处理ASCII请求，直到读取失败
+func (s *Simulator) ServeASCII(conn deadlineConn, unitID byte) {
+	reader := bufio.NewReaderSize(conn, asciiMaxFrameLength)
+	for {
+		conn.SetReadDeadline(time.Time{})
+		body, err := readASCIIFrame(reader)
+		if err != nil {
+			if isResyncable(err) {
+				continue
+			}
+			return
+		}
+
+		address, pdu, err := decodeASCIIFrame(body)
+		if err != nil {
+			log.Printf("仿真器丢弃ASCII请求: %v", err)
+			s.countDiagnostic(DiagBusCommErrorCount)
+			continue
+		}
+		s.countDiagnostic(DiagBusMessageCount)
+		if address != unitID && address != 0 {
+			continue
+		}
+
+		resp := s.handlePDU(pdu)
+		if address == 0 {
+			s.countDiagnostic(DiagServerNoResponseCount)
+			continue
+		}
+
+		if _, err := conn.Write(encodeASCIIFrame(unitID, resp)); err != nil {
+			return
+		}
+	}
+}
+
+// handlePDU 处理请求PDU并返回响应PDU，同时更新诊断计数器
+func (s *Simulator) handlePDU(pdu []byte) []byte {
+	s.countDiagnostic(DiagServerMessageCount)
+	resp := s.dispatchPDU(pdu)
+	if resp[0]&0x80 != 0 {
+		s.countDiagnostic(DiagBusExceptionCount)
+	}
+	return resp
+}
+
+// dispatchPDU 按功能码分发请求
+func (s *Simulator) dispatchPDU(pdu []byte) []byte {
+	functionCode := pdu[0]
+
+	switch functionCode {
+	case 0x01, 0x02:
+		return s.readBits(pdu)
+	case 0x03, 0x04:
+		return s.readRegisters(pdu)
+	case 0x05:
+		return s.writeSingleCoil(pdu)
+	case 0x06:
+		return s.writeSingleRegister(pdu)
+	case 0x0F:
+		return s.writeMultipleCoils(pdu)
+	case 0x10:
+		return s.writeMultipleRegisters(pdu)
+	case 0x17:
+		return s.readWriteMultipleRegisters(pdu)
+	case 0x08:
+		return s.diagnostic(pdu)
+	case 0x2B:
+		return s.readDeviceIdentification(pdu)
+	default:
+		return exceptionPDU(functionCode, 0x01)
+	}
+}
+
+// readBits 处理读线圈/读离散输入 (功能码 0x01/0x02)
+func (s *Simulator) readBits(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	start := int(binary.BigEndian.Uint16(pdu[1:3]))
+	quantity := int(binary.BigEndian.Uint16(pdu[3:5]))
+	if quantity < 1 || quantity > 2000 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var bits []bool
+	if pdu[0] == 0x01 {
+		bits = s.coils
+	} else {
+		bits = s.currentInputsLocked()
+	}
+	if start+quantity > len(bits) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+
+	byteCount := (quantity + 7) / 8
+	resp := make([]byte, 2+byteCount)
+	resp[0] = pdu[0]
+	resp[1] = byte(byteCount)
+	for i := 0; i < quantity; i++ {
+		if bits[start+i] {
+			resp[2+i/8] |= 1 << (i % 8)
+		}
+	}
+	return resp
+}
+
+// readRegisters 处理读保持寄存器/读输入寄存器 (功能码 0x03/0x04)
+func (s *Simulator) readRegisters(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	start := int(binary.BigEndian.Uint16(pdu[1:3]))
+	quantity := int(binary.BigEndian.Uint16(pdu[3:5]))
+	if quantity < 1 || quantity > 125 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	registers := s.holdingRegisters
+	if pdu[0] == 0x04 {
+		registers = s.inputRegisters
+	}
+	if start+quantity > len(registers) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+
+	return registersPDU(pdu[0], registers[start:start+quantity])
+}
+
+// writeSingleCoil 处理写单个线圈 (功能码 0x05)
+func (s *Simulator) writeSingleCoil(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	addr := int(binary.BigEndian.Uint16(pdu[1:3]))
+	value := binary.BigEndian.Uint16(pdu[3:5])
+	if value != 0xFF00 && value != 0x0000 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if addr >= len(s.coils) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+	s.coils[addr] = value == 0xFF00
+
+	return append([]byte(nil), pdu...)
+}
+
+// writeSingleRegister 处理写单个保持寄存器 (功能码 0x06)
+func (s *Simulator) writeSingleRegister(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	addr := int(binary.BigEndian.Uint16(pdu[1:3]))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if addr >= len(s.holdingRegisters) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+	s.holdingRegisters[addr] = binary.BigEndian.Uint16(pdu[3:5])
+
+	return append([]byte(nil), pdu...)
+}
+
+// writeMultipleCoils 处理写多个线圈 (功能码 0x0F)
+func (s *Simulator) writeMultipleCoils(pdu []byte) []byte {
+	if len(pdu) < 6 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	start := int(binary.BigEndian.Uint16(pdu[1:3]))
+	quantity := int(binary.BigEndian.Uint16(pdu[3:5]))
+	byteCount := int(pdu[5])
+	if quantity < 1 || quantity > 1968 || byteCount != (quantity+7)/8 || len(pdu) != 6+byteCount {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start+quantity > len(s.coils) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+	for i := 0; i < quantity; i++ {
+		s.coils[start+i] = pdu[6+i/8]&(1<<(i%8)) != 0
+	}
+
+	return append([]byte(nil), pdu[:5]...)
+}
+
+// writeMultipleRegisters 处理写多个保持寄存器 (功能码 0x10)
+func (s *Simulator) writeMultipleRegisters(pdu []byte) []byte {
+	if len(pdu) < 6 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	start := int(binary.BigEndian.Uint16(pdu[1:3]))
+	quantity := int(binary.BigEndian.Uint16(pdu[3:5]))
+	byteCount := int(pdu[5])
+	if quantity < 1 || quantity > 123 || byteCount != quantity*2 || len(pdu) != 6+byteCount {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start+quantity > len(s.holdingRegisters) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+	for i := 0; i < quantity; i++ {
+		s.holdingRegisters[start+i] = binary.BigEndian.Uint16(pdu[6+i*2 : 8+i*2])
+	}
+
+	return append([]byte(nil), pdu[:5]...)
+}
+
+// readWriteMultipleRegisters 处理读写多个保持寄存器 (功能码 0x17)
+func (s *Simulator) readWriteMultipleRegisters(pdu []byte) []byte {
+	if len(pdu) < 10 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	readStart := int(binary.BigEndian.Uint16(pdu[1:3]))
+	readQuantity := int(binary.BigEndian.Uint16(pdu[3:5]))
+	writeStart := int(binary.BigEndian.Uint16(pdu[5:7]))
+	writeQuantity := int(binary.BigEndian.Uint16(pdu[7:9]))
+	byteCount := int(pdu[9])
+	if readQuantity < 1 || readQuantity > 125 || writeQuantity < 1 || writeQuantity > 121 ||
+		byteCount != writeQuantity*2 || len(pdu) != 10+byteCount {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if readStart+readQuantity > len(s.holdingRegisters) || writeStart+writeQuantity > len(s.holdingRegisters) {
+		return exceptionPDU(pdu[0], 0x02)
+	}
+
+	// 先写后读
+	for i := 0; i < writeQuantity; i++ {
+		s.holdingRegisters[writeStart+i] = binary.BigEndian.Uint16(pdu[10+i*2 : 12+i*2])
+	}
+	return registersPDU(pdu[0], s.holdingRegisters[readStart:readStart+readQuantity])
+}
+
+// currentInputsLocked 计算当前离散输入状态（手动设置叠加脚本按钮），调用方需持有mu
+func (s *Simulator) currentInputsLocked() []bool {
+	inputs := append([]bool(nil), s.discreteInputs...)
+	elapsed := int(time.Since(s.started) / time.Millisecond)
+
+	for _, press := range s.config.Buttons {
+		if press.Input < 0 || press.Input >= len(inputs) || elapsed < press.AtMs {
+			continue
+		}
+		offset := elapsed - press.AtMs
+		if press.RepeatMs > 0 {
+			offset %= press.RepeatMs
+		}
+		if offset < press.DurationMs {
+			inputs[press.Input] = true
+		}
+	}
+	return inputs
+}
+
+// runWaveforms 周期性更新输入寄存器波形
+func (s *Simulator) runWaveforms() {
+	if len(s.config.Waveforms) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		s.updateWaveforms(time.Since(s.started))
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateWaveforms 根据经过时间计算并写入波形值
+func (s *Simulator) updateWaveforms(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, wave := range s.config.Waveforms {
+		if wave.Register < 0 || wave.Register >= len(s.inputRegisters) {
+			continue
+		}
+		s.inputRegisters[wave.Register] = uint16(math.Round(wave.valueAt(elapsed)))
+	}
+}
+
+// valueAt 计算波形在指定时刻的值
+func (w SimWaveform) valueAt(elapsed time.Duration) float64 {
+	if w.PeriodMs <= 0 || w.Shape == "constant" {
+		return w.Min
+	}
+
+	// 相位 0-1
+	phase := math.Mod(float64(elapsed/time.Millisecond), float64(w.PeriodMs)) / float64(w.PeriodMs)
+
+	var level float64 // 0-1
+	switch w.Shape {
+	case "triangle":
+		level = 1 - math.Abs(2*phase-1)
+	case "square":
+		if phase >= 0.5 {
+			level = 1
+		}
+	default:
+		level = (1 - math.Cos(2*math.Pi*phase)) / 2
+	}
+	return w.Min + (w.Max-w.Min)*level
+}
+
+// diagnostic 处理诊断请求 (功能码 0x08)
+func (s *Simulator) diagnostic(pdu []byte) []byte {
+	if len(pdu) < 3 {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	subFunction := binary.BigEndian.Uint16(pdu[1:3])
+
+	switch {
+	case subFunction == DiagReturnQueryData:
+		// 原样回送
+		return append([]byte(nil), pdu...)
+	case subFunction == DiagReturnDiagnosticRegister:
+		return diagnosticPDU(subFunction, 0)
+	case subFunction == DiagClearCounters:
+		s.mu.Lock()
+		s.diagCounters = [8]uint16{}
+		s.mu.Unlock()
+		return append([]byte(nil), pdu...)
+	case subFunction >= DiagBusMessageCount && subFunction <= DiagBusCharacterOverrunCount:
+		s.mu.RLock()
+		value := s.diagCounters[subFunction-DiagBusMessageCount]
+		s.mu.RUnlock()
+		return diagnosticPDU(subFunction, value)
+	default:
+		return exceptionPDU(pdu[0], 0x01)
+	}
+}
+
+// countDiagnostic 诊断计数器加一
+func (s *Simulator) countDiagnostic(subFunction uint16) {
+	s.mu.Lock()
+	s.diagCounters[subFunction-DiagBusMessageCount]++
+	s.mu.Unlock()
+}
+
+// 仿真器单个响应最多返回的设备标识对象数，使流式续读在仿真时也能被覆盖
+const simMaxDeviceObjects = 3
+
+// readDeviceIdentification 处理读设备标识请求 (功能码 0x2B / MEI 0x0E)
+func (s *Simulator) readDeviceIdentification(pdu []byte) []byte {
+	if len(pdu) != 4 || pdu[1] != meiReadDeviceID {
+		return exceptionPDU(pdu[0], 0x03)
+	}
+	code, objectID := DeviceIDCode(pdu[2]), pdu[3]
+
+	// 各访问类型可读取的对象ID上限
+	var last byte
+	switch code {
+	case DeviceIDBasic:
+		last = DeviceObjectMajorMinorRevision
+	case DeviceIDRegular:
+		last = 0x7F
+	case DeviceIDExtended, DeviceIDSpecific:
+		last = 0xFF
+	default:
+		return exceptionPDU(pdu[0], 0x03)
+	}
+
+	objects := s.config.DeviceObjects
+	if _, ok := objects[objectID]; !ok {
+		if code == DeviceIDSpecific {
+			return exceptionPDU(pdu[0], 0x02)
+		}
+		// 起始对象不存在时从头读取
+		objectID = 0
+	}
+
+	resp := []byte{pdu[0], meiReadDeviceID, byte(code), 0x83, 0x00, 0x00, 0x00}
+	ids := (&DeviceIdentification{Objects: objects}).ObjectIDs()
+	count := 0
+	for _, id := range ids {
+		if id < objectID || id > last {
+			continue
+		}
+		value := objects[id]
+		if count == simMaxDeviceObjects || len(resp)+2+len(value) > maxPDULength {
+			// 剩余对象由客户端续读
+			resp[4], resp[5] = 0xFF, id
+			break
+		}
+		resp = append(resp, id, byte(len(value)))
+		resp = append(resp, value...)
+		count++
+		if code == DeviceIDSpecific {
+			break
+		}
+	}
+	resp[6] = byte(count)
+	return resp
+}
+
+// diagnosticPDU 构造诊断计数器响应PDU
+func diagnosticPDU(subFunction uint16, value uint16) []byte {
+	resp := make([]byte, 5)
+	resp[0] = 0x08
+	binary.BigEndian.PutUint16(resp[1:3], subFunction)
+	binary.BigEndian.PutUint16(resp[3:5], value)
+	return resp
+}
+
+// registersPDU 构造寄存器读取响应PDU
+func registersPDU(functionCode byte, registers []uint16) []byte {
+	resp := make([]byte, 2+len(registers)*2)
+	resp[0] = functionCode
+	resp[1] = byte(len(registers) * 2)
+	putRegisters(resp[2:], registers)
+	return resp
+}
+
+// exceptionPDU 构造异常响应PDU
+func exceptionPDU(functionCode byte, exceptionCode byte) []byte {
+	return []byte{functionCode | 0x80, exceptionCode}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// readRequestPDU 构造读请求PDU（功能码 0x01-0x04）
+func readRequestPDU(functionCode byte, start, quantity uint16) []byte {
+	pdu := []byte{functionCode, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(pdu[1:3], start)
+	binary.BigEndian.PutUint16(pdu[3:5], quantity)
+	return pdu
+}
+
+// simInputsAt 读取仿真器启动elapsed之后的全部离散输入
+func simInputsAt(t *testing.T, sim *Simulator, elapsed time.Duration) []bool {
+	t.Helper()
+	sim.mu.Lock()
+	sim.started = time.Now().Add(-elapsed)
+	sim.mu.Unlock()
+
+	resp := sim.handlePDU(readRequestPDU(0x02, 0, uint16(sim.config.DiscreteInputs)))
+	bits, err := parseBitsResponse(resp, sim.config.DiscreteInputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bits
+}
+
+func TestDefaultSimulatorHasNoButtonScript(t *testing.T) {
+	if buttons := DefaultSimulatorConfig().Buttons; len(buttons) != 0 {
+		t.Fatalf("default simulator presses buttons: %+v", buttons)
+	}
+}
+
+func TestSimulatorScriptedButtonPresses(t *testing.T) {
+	config := DefaultSimulatorConfig()
+	config.Buttons = []SimButtonPress{
+		{Input: 0, AtMs: 1000, DurationMs: 300, RepeatMs: 5000},
+		{Input: 3, AtMs: 2000, DurationMs: 500}, // 只按一次
+		{Input: 99, AtMs: 0, DurationMs: 1000},  // 超出范围的输入被忽略
+	}
+	sim := NewSimulator(config)
+	sim.SetDiscreteInput(5, true)
+
+	tests := []struct {
+		elapsed time.Duration
+		input0  bool
+		input3  bool
+	}{
+		{0, false, false},
+		{999 * time.Millisecond, false, false},
+		{1000 * time.Millisecond, true, false},
+		{1299 * time.Millisecond, true, false},
+		{1300 * time.Millisecond, false, false},
+		{2200 * time.Millisecond, false, true},
+		{2500 * time.Millisecond, false, false},
+		{6100 * time.Millisecond, true, false},
+		{12400 * time.Millisecond, false, false},
+	}
+	for _, tt := range tests {
+		inputs := simInputsAt(t, sim, tt.elapsed)
+		if inputs[0] != tt.input0 || inputs[3] != tt.input3 {
+			t.Errorf("at %v: I0.0=%v I0.3=%v, want %v %v", tt.elapsed, inputs[0], inputs[3], tt.input0, tt.input3)
+		}
+		if !inputs[5] {
+			t.Errorf("at %v: manually set input lost", tt.elapsed)
+		}
+	}
+}
+
+func TestSimWaveformValueAt(t *testing.T) {
+	tests := []struct {
+		shape   string
+		elapsed time.Duration
+		want    float64
+	}{
+		{"sine", 0, 100},
+		{"sine", 500 * time.Millisecond, 200},
+		{"sine", 250 * time.Millisecond, 150},
+		{"sine", 1000 * time.Millisecond, 100},
+		{"triangle", 250 * time.Millisecond, 150},
+		{"triangle", 500 * time.Millisecond, 200},
+		{"triangle", 750 * time.Millisecond, 150},
+		{"square", 499 * time.Millisecond, 100},
+		{"square", 500 * time.Millisecond, 200},
+		{"constant", 500 * time.Millisecond, 100},
+	}
+	for _, tt := range tests {
+		wave := SimWaveform{Shape: tt.shape, Min: 100, Max: 200, PeriodMs: 1000}
+		if got := wave.valueAt(tt.elapsed); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("%s at %v = %v, want %v", tt.shape, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestSimulatorWaveformRegisters(t *testing.T) {
+	config := DefaultSimulatorConfig()
+	sim := NewSimulator(config)
+	sim.updateWaveforms(30 * time.Second) // 温度周期60s，此时位于波峰
+
+	// IW64/IW66 对应输入寄存器短地址32/33
+	resp := sim.handlePDU(readRequestPDU(0x04, 32, 2))
+	registers, err := parseRegistersResponse(resp, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registers[0] != 17280 {
+		t.Errorf("IW64 at temperature peak = %d, want 17280", registers[0])
+	}
+	humidity := config.Waveforms[1]
+	if want := uint16(humidity.valueAt(30*time.Second) + 0.5); registers[1] != want {
+		t.Errorf("IW66 = %d, want %d", registers[1], want)
+	}
+	if registers[1] < 11059 || registers[1] > 19354 {
+		t.Errorf("IW66 = %d outside humidity range", registers[1])
+	}
+
+	// 超出寄存器区的读取返回非法地址异常
+	if resp := sim.handlePDU(readRequestPDU(0x04, 63, 2)); resp[0] != 0x84 || resp[1] != 0x02 {
+		t.Errorf("out-of-range read = % X, want exception 02", resp)
+	}
+}
+
+func TestSimulatorDeviceObjects(t *testing.T) {
+	sim := NewSimulator(nil)
+	objects := DefaultSimulatorConfig().DeviceObjects
+
+	for _, id := range []byte{DeviceObjectVendorName, DeviceObjectProductCode, DeviceObjectModelName, 0x80} {
+		resp := sim.handlePDU([]byte{0x2B, meiReadDeviceID, byte(DeviceIDSpecific), id})
+		// 功能码, MEI, 访问类型, 一致性等级, 后续标志, 下一对象, 对象数, 对象ID, 长度, 值
+		if len(resp) < 9 || resp[0] != 0x2B || resp[6] != 1 || resp[7] != id {
+			t.Fatalf("object 0x%02X: response % X", id, resp)
+		}
+		if value := string(resp[9 : 9+int(resp[8])]); value != objects[id] {
+			t.Errorf("object 0x%02X = %q, want %q", id, value, objects[id])
+		}
+	}
+
+	// 不存在的单个对象返回非法地址异常
+	if resp := sim.handlePDU([]byte{0x2B, meiReadDeviceID, byte(DeviceIDSpecific), 0x7F}); resp[0] != 0xAB || resp[1] != 0x02 {
+		t.Errorf("missing object = % X, want exception 02", resp)
+	}
+}
+
+func TestSimulatorStopTwice(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	config := DefaultSimulatorConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	sim := NewSimulator(config)
+	if err := sim.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Stop()
+	sim.Stop()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// SpeedMode 调速方式
+type SpeedMode string
+
+// 调速方式
+const (
+	SpeedByLevel  SpeedMode = "level"  // 按speedDelays中的挡位
+	SpeedByDelay  SpeedMode = "delay"  // 直接设定延时
+	SpeedByAnalog SpeedMode = "analog" // 由模拟量输入（如电位器）连续调节
+)
+
+// 延时的取值范围（毫秒）
+const (
+	minSpeedDelayMs = 10
+	maxSpeedDelayMs = 60000
+)
+
+// SpeedInputConfig 由模拟量通道连续调速：通道工程值下限对应最慢，上限对应最快，按速度（每秒步数）线性换算
+type SpeedInputConfig struct {
+	Channel    string `json:"channel"`              // 模拟量通道名称，见analogChannels
+	MinDelayMs int    `json:"minDelayMs,omitempty"` // 最快时的延时，默认为speedDelays中的最小值
+	MaxDelayMs int    `json:"maxDelayMs,omitempty"` // 最慢时的延时，默认为speedDelays中的最大值
+}
+
+// SpeedState 跑马灯的调速状态
+type SpeedState struct {
+	Mode          SpeedMode `json:"mode"`                  // 调速方式，停止时为空
+	Level         int       `json:"level"`                 // 速度挡位，不按挡位调速时为0
+	Levels        []int     `json:"levels"`                // 各挡位的延时
+	DelayMs       int       `json:"delayMs"`               // 当前延时，过渡中为过渡中的值
+	TargetDelayMs int       `json:"targetDelayMs"`         // 目标延时
+	RampMs        int       `json:"rampMs"`                // 速度过渡时间，0表示立即切换
+	AnalogInput   string    `json:"analogInput,omitempty"` // 调速模拟量通道，未配置时为空
+}
+
+// validateSpeedConfig 检查挡位延时、过渡时间和调速模拟量通道
+func validateSpeedConfig(config *Config, channels []AnalogChannel) error {
+	for i, delay := range config.SpeedDelays {
+		if delay < minSpeedDelayMs || delay > maxSpeedDelayMs {
+			return fmt.Errorf("speed level %d: delay %dms out of range %d-%dms", i+1, delay, minSpeedDelayMs, maxSpeedDelayMs)
+		}
+	}
+	if config.SpeedRampMs < 0 || config.SpeedRampMs > maxSpeedDelayMs {
+		return fmt.Errorf("speed ramp %dms out of range 0-%dms", config.SpeedRampMs, maxSpeedDelayMs)
+	}
+
+	input := config.SpeedInput
+	if input == nil {
+		return nil
+	}
+	if !slices.ContainsFunc(channels, func(ch AnalogChannel) bool { return ch.Name == input.Channel }) {
+		return fmt.Errorf("speed input: unknown analog channel %q", input.Channel)
+	}
+	for _, delay := range []int{input.MinDelayMs, input.MaxDelayMs} {
+		if delay != 0 && (delay < minSpeedDelayMs || delay > maxSpeedDelayMs) {
+			return fmt.Errorf("speed input: delay %dms out of range %d-%dms", delay, minSpeedDelayMs, maxSpeedDelayMs)
+		}
+	}
+	if fastest, slowest := config.SpeedInputRange(); fastest > slowest {
+		return fmt.Errorf("speed input: minDelayMs %d greater than maxDelayMs %d", fastest, slowest)
+	}
+	return nil
+}
+
+// SpeedLevelDelays 返回各挡位的延时，未配置时只有1000ms一挡
+func (c *Config) SpeedLevelDelays() []int {
+	if len(c.SpeedDelays) == 0 {
+		return []int{1000}
+	}
+	return c.SpeedDelays
+}
+
+// SpeedInputRange 返回模拟量调速的最快和最慢延时
+func (c *Config) SpeedInputRange() (fastest, slowest int) {
+	delays := c.SpeedLevelDelays()
+	fastest, slowest = slices.Min(delays), slices.Max(delays)
+	if c.SpeedInput != nil && c.SpeedInput.MinDelayMs != 0 {
+		fastest = c.SpeedInput.MinDelayMs
+	}
+	if c.SpeedInput != nil && c.SpeedInput.MaxDelayMs != 0 {
+		slowest = c.SpeedInput.MaxDelayMs
+	}
+	return fastest, slowest
+}
+
+// speedRamp 速度过渡：当前速度按一阶惯性趋近目标速度，约rampMs后到达
+// 速度以每秒步数表示，目标在过渡中改变时从当前速度继续平滑过渡
+type speedRamp struct {
+	current float64   // 当前速度，0表示尚未开始（直接取目标速度）
+	target  float64   // 目标速度
+	updated time.Time // current的计算时间
+}
+
+// retarget 设置目标延时（毫秒）
+func (r *speedRamp) retarget(delayMs float64, now time.Time, ramp time.Duration) {
+	r.delay(now, ramp) // 先按旧目标推进到now
+	r.target = 1000 / delayMs
+}
+
+// delay 返回now时刻的延时（毫秒）
+func (r *speedRamp) delay(now time.Time, ramp time.Duration) float64 {
+	switch {
+	case r.target == 0:
+		return 0
+	case r.current == 0 || ramp <= 0:
+		r.current = r.target
+	default:
+		// 3个时间常数后与目标相差约5%，之后直接到达目标
+		elapsed := now.Sub(r.updated)
+		if elapsed >= ramp {
+			r.current = r.target
+		} else if elapsed > 0 {
+			r.current = r.target + (r.current-r.target)*math.Exp(-3*float64(elapsed)/float64(ramp))
+		}
+	}
+	r.updated = now
+	return 1000 / r.current
+}
+
+// speedInput 返回调速模拟量通道配置，未配置时返回nil
+func (m *MarqueeController) speedInput() *SpeedInputConfig {
+	if m.config == nil {
+		return nil
+	}
+	return m.config.SpeedInput
+}
+
+// speedDelays 返回各挡位的延时
+func (m *MarqueeController) speedDelays() []int {
+	if m.config == nil {
+		return []int{1000}
+	}
+	return m.config.SpeedLevelDelays()
+}
+
+// speedRampDuration 返回速度过渡时间
+func (m *MarqueeController) speedRampDuration() time.Duration {
+	if m.config == nil {
+		return 0
+	}
+	return time.Duration(m.config.SpeedRampMs) * time.Millisecond
+}
+
+// setSpeedLocked 切换调速方式并设置目标延时，调用方持有mu
+func (m *MarqueeController) setSpeedLocked(mode SpeedMode, level int, delayMs float64) {
+	m.speedMode = mode
+	m.speedLevel = level
+	m.speed.retarget(delayMs, time.Now(), m.speedRampDuration())
+}
+
+// delayLocked 返回当前延时（毫秒），停止时为1挡延时，调用方持有mu
+func (m *MarqueeController) delayLocked() float64 {
+	if delay := m.speed.delay(time.Now(), m.speedRampDuration()); delay > 0 {
+		return delay
+	}
+	return float64(m.speedDelays()[0])
+}
+
+// SetSpeedLevel 按挡位调速，level从1开始
+func (m *MarqueeController) SetSpeedLevel(level int) error {
+	if !m.isRunning.Load() {
+		return fmt.Errorf("marquee is not running")
+	}
+	delays := m.speedDelays()
+	if level < 1 || level > len(delays) {
+		return fmt.Errorf("speed level %d out of range 1-%d", level, len(delays))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setSpeedLocked(SpeedByLevel, level, float64(delays[level-1]))
+	return nil
+}
+
+// SetDelay 直接设定延时（毫秒）
+func (m *MarqueeController) SetDelay(delayMs int) error {
+	if !m.isRunning.Load() {
+		return fmt.Errorf("marquee is not running")
+	}
+	if delayMs < minSpeedDelayMs || delayMs > maxSpeedDelayMs {
+		return fmt.Errorf("delay %dms out of range %d-%dms", delayMs, minSpeedDelayMs, maxSpeedDelayMs)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setSpeedLocked(SpeedByDelay, 0, float64(delayMs))
+	return nil
+}
+
+// SetAnalogSpeed 切换为模拟量调速，收到第一个有效读数前保持当前速度
+func (m *MarqueeController) SetAnalogSpeed() error {
+	if !m.isRunning.Load() {
+		return fmt.Errorf("marquee is not running")
+	}
+	if m.speedInput() == nil {
+		return fmt.Errorf("no speed input configured")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.speedMode = SpeedByAnalog
+	m.speedLevel = 0
+	return nil
+}
+
+// ApplySpeedInput 模拟量调速时按调速通道的读数设置目标速度，坏值和过期读数保持当前速度
+func (m *MarqueeController) ApplySpeedInput(readings []AnalogReading) {
+	input := m.speedInput()
+	if input == nil {
+		return
+	}
+	var channel *AnalogChannel
+	for _, ch := range m.config.AnalogChannelList() {
+		if ch.Name == input.Channel {
+			channel = &ch
+			break
+		}
+	}
+	if channel == nil {
+		return
+	}
+	for _, reading := range readings {
+		if reading.Name != channel.Name || (reading.Quality != QualityGood && reading.Quality != QualityUncertain) {
+			continue
+		}
+		// 按速度线性换算：位置0为最慢，1为最快，超出量程时取端点
+		position := (reading.Value - channel.EngMin) / (channel.EngMax - channel.EngMin)
+		position = min(max(position, 0), 1)
+		fastest, slowest := m.config.SpeedInputRange()
+		speed := 1/float64(slowest) + position*(1/float64(fastest)-1/float64(slowest))
+
+		m.mu.Lock()
+		if m.isRunning.Load() && m.speedMode == SpeedByAnalog {
+			m.speed.retarget(1/speed, time.Now(), m.speedRampDuration())
+		}
+		m.mu.Unlock()
+	}
+}
+
+// SpeedState 返回当前调速状态
+func (m *MarqueeController) SpeedState() SpeedState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := SpeedState{
+		Mode:   m.speedMode,
+		Level:  m.speedLevel,
+		Levels: m.speedDelays(),
+	}
+	if m.isRunning.Load() {
+		state.DelayMs = int(math.Round(m.delayLocked()))
+		state.TargetDelayMs = int(math.Round(1000 / m.speed.target))
+	}
+	if m.config != nil {
+		state.RampMs = m.config.SpeedRampMs
+	}
+	if input := m.speedInput(); input != nil {
+		state.AnalogInput = input.Channel
+	}
+	return state
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// speedTestConfig 三挡速度并由电位器通道Pot（0-100%）调速
+func speedTestConfig() *Config {
+	config := DefaultConfig()
+	config.SpeedDelays = []int{1000, 500, 100}
+	config.AnalogChannels = append(DefaultAnalogChannels(), AnalogChannel{Name: "Pot", Alias: "IW68", EngMin: 0, EngMax: 100, Unit: "%"})
+	config.SpeedInput = &SpeedInputConfig{Channel: "Pot"}
+	return config
+}
+
+// startSpeedTestMarquee 创建连接到仿真器的跑马灯控制器并启动
+func startSpeedTestMarquee(t *testing.T, config *Config) *MarqueeController {
+	t.Helper()
+	_, client := startTestSimulator(t, len(config.LampTagNames()))
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMarqueeController(NewTagClient(client, db), config)
+	m.Start()
+	t.Cleanup(m.Stop)
+	return m
+}
+
+func TestSpeedRamp(t *testing.T) {
+	start := time.Now()
+	ramp := time.Second
+	var r speedRamp
+	if got := r.delay(start, ramp); got != 0 {
+		t.Fatalf("delay before first target = %v, want 0", got)
+	}
+
+	// 第一个目标直接到达，不经过渡
+	r.retarget(1000, start, ramp)
+	if got := r.delay(start, ramp); got != 1000 {
+		t.Fatalf("initial delay = %v, want 1000", got)
+	}
+
+	// 速度（每秒步数）按一阶惯性从1趋近10，ramp时与目标相差约5%
+	r.retarget(100, start, ramp)
+	tests := []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 1000},
+		{ramp / 3, 1000 / (10 - 9*math.Exp(-1))},
+		{ramp * 2 / 3, 1000 / (10 - 9*math.Exp(-2))},
+		{ramp, 1000 / (10 - 9*math.Exp(-3))},
+		{3 * ramp, 100},
+	}
+	for _, tt := range tests {
+		if got := r.delay(start.Add(tt.elapsed), ramp); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("delay at %v = %.2f, want %.2f", tt.elapsed, got, tt.want)
+		}
+	}
+
+	// 两次计算相隔超过过渡时间时直接到达目标
+	r.retarget(1000, start.Add(3*ramp), ramp)
+	if got := r.delay(start.Add(4*ramp), ramp); got != 1000 {
+		t.Errorf("delay after full ramp = %v, want 1000", got)
+	}
+	r.retarget(100, start.Add(4*ramp), ramp)
+	r.delay(start.Add(5*ramp), ramp)
+
+	// 过渡中改变目标时从当前速度继续
+	r.retarget(1000, start.Add(5*ramp), ramp)
+	mid := r.delay(start.Add(5*ramp+ramp/3), ramp)
+	r.retarget(100, start.Add(5*ramp+ramp/3), ramp)
+	if got := r.delay(start.Add(5*ramp+ramp/3), ramp); got != mid {
+		t.Errorf("delay jumped from %.2f to %.2f on retarget", mid, got)
+	}
+
+	// 过渡时间为0时立即切换
+	r.retarget(500, start.Add(6*ramp), 0)
+	if got := r.delay(start.Add(6*ramp), 0); got != 500 {
+		t.Errorf("delay without ramp = %v, want 500", got)
+	}
+}
+
+func TestValidateSpeedConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string // 为空表示有效
+	}{
+		{"default", func(c *Config) {}, ""},
+		{"no speed input", func(c *Config) { c.SpeedInput = nil }, ""},
+		{"ten levels", func(c *Config) { c.SpeedDelays = []int{2000, 1500, 1000, 800, 600, 400, 300, 200, 100, 10} }, ""},
+		{"delay too short", func(c *Config) { c.SpeedDelays = []int{1000, 9} }, "speed level 2: delay 9ms out of range"},
+		{"delay too long", func(c *Config) { c.SpeedDelays = []int{60001} }, "speed level 1: delay 60001ms"},
+		{"negative ramp", func(c *Config) { c.SpeedRampMs = -1 }, "speed ramp -1ms out of range"},
+		{"ramp too long", func(c *Config) { c.SpeedRampMs = 60001 }, "speed ramp 60001ms"},
+		{"unknown channel", func(c *Config) { c.SpeedInput.Channel = "Knob" }, `unknown analog channel "Knob"`},
+		{"input delay", func(c *Config) { c.SpeedInput.MinDelayMs = 5 }, "speed input: delay 5ms out of range"},
+		{"input range inverted", func(c *Config) { c.SpeedInput.MinDelayMs = 2000 }, "minDelayMs 2000 greater than maxDelayMs 1000"},
+		{"input range", func(c *Config) { c.SpeedInput.MinDelayMs, c.SpeedInput.MaxDelayMs = 50, 5000 }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := speedTestConfig()
+			tt.modify(config)
+			err := validateSpeedConfig(config, config.AnalogChannelList())
+			if tt.want == "" && err != nil {
+				t.Fatalf("valid config rejected: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Fatalf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeedInputRange(t *testing.T) {
+	config := speedTestConfig()
+	if fastest, slowest := config.SpeedInputRange(); fastest != 100 || slowest != 1000 {
+		t.Errorf("default range %d-%d, want 100-1000", fastest, slowest)
+	}
+	config.SpeedInput.MaxDelayMs = 3000
+	if fastest, slowest := config.SpeedInputRange(); fastest != 100 || slowest != 3000 {
+		t.Errorf("range %d-%d, want 100-3000", fastest, slowest)
+	}
+	config.SpeedDelays = nil
+	config.SpeedInput = nil
+	if fastest, slowest := config.SpeedInputRange(); fastest != 1000 || slowest != 1000 {
+		t.Errorf("range without levels %d-%d, want 1000-1000", fastest, slowest)
+	}
+}
+
+func TestMarqueeSpeedLevels(t *testing.T) {
+	config := speedTestConfig()
+	config.SpeedInput = nil
+	m := startSpeedTestMarquee(t, config)
+
+	// 按挡位依次切换并回到1挡
+	for _, want := range []int{2, 3, 1} {
+		m.SwitchSpeed()
+		if state := m.SpeedState(); state.Mode != SpeedByLevel || state.Level != want || state.TargetDelayMs != config.SpeedDelays[want-1] {
+			t.Fatalf("after switch: %+v, want level %d", state, want)
+		}
+	}
+
+	if err := m.SetSpeedLevel(3); err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []int{0, 4} {
+		if err := m.SetSpeedLevel(level); err == nil {
+			t.Errorf("speed level %d accepted", level)
+		}
+	}
+
+	if err := m.SetDelay(250); err != nil {
+		t.Fatal(err)
+	}
+	if state := m.SpeedState(); state.Mode != SpeedByDelay || state.Level != 0 || state.TargetDelayMs != 250 {
+		t.Fatalf("after SetDelay: %+v", state)
+	}
+	for _, delay := range []int{minSpeedDelayMs - 1, maxSpeedDelayMs + 1} {
+		if err := m.SetDelay(delay); err == nil {
+			t.Errorf("delay %dms accepted", delay)
+		}
+	}
+	if err := m.SetAnalogSpeed(); err == nil {
+		t.Error("analog speed accepted without speed input")
+	}
+
+	m.Stop()
+	if err := m.SetSpeedLevel(1); err == nil {
+		t.Error("speed level set while stopped")
+	}
+	if state := m.SpeedState(); state.Mode != "" || m.GetDelay() != 1000 {
+		t.Errorf("stopped state %+v, delay %d", state, m.GetDelay())
+	}
+}
+
+func TestMarqueeApplySpeedInput(t *testing.T) {
+	m := startSpeedTestMarquee(t, speedTestConfig())
+	if state := m.SpeedState(); state.Mode != SpeedByAnalog || state.TargetDelayMs != 1000 {
+		t.Fatalf("initial state %+v, want analog at level 1 delay", state)
+	}
+
+	pot := func(value float64, quality Quality) []AnalogReading {
+		return []AnalogReading{
+			{Name: "Temperature", Value: 0, Quality: QualityGood},
+			{Name: "Pot", Value: value, Quality: quality},
+		}
+	}
+	// 按速度线性换算：50%时速度为1和10步每秒的平均值
+	tests := []struct {
+		value   float64
+		quality Quality
+		want    int
+	}{
+		{100, QualityGood, 100},
+		{0, QualityGood, 1000},
+		{50, QualityGood, 182},
+		{150, QualityUncertain, 100}, // 超出量程取端点，不确定的读数参与调速
+		{-20, QualityUncertain, 1000},
+		{100, QualityGood, 100},
+		{0, QualityBad, 100}, // 坏值和过期读数保持当前速度
+		{0, QualityStale, 100},
+	}
+	for _, tt := range tests {
+		m.ApplySpeedInput(pot(tt.value, tt.quality))
+		if got := m.SpeedState().TargetDelayMs; got != tt.want {
+			t.Errorf("pot %g %s: target delay %d, want %d", tt.value, tt.quality, got, tt.want)
+		}
+	}
+
+	// 手动调速后忽略模拟量，切换回模拟量调速后恢复
+	if err := m.SetSpeedLevel(2); err != nil {
+		t.Fatal(err)
+	}
+	m.ApplySpeedInput(pot(100, QualityGood))
+	if state := m.SpeedState(); state.Mode != SpeedByLevel || state.TargetDelayMs != 500 {
+		t.Fatalf("analog reading applied in level mode: %+v", state)
+	}
+	if err := m.SetAnalogSpeed(); err != nil {
+		t.Fatal(err)
+	}
+	m.ApplySpeedInput(pot(0, QualityGood))
+	if state := m.SpeedState(); state.Mode != SpeedByAnalog || state.TargetDelayMs != 1000 {
+		t.Fatalf("analog reading not applied after SetAnalogSpeed: %+v", state)
+	}
+}
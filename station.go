@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Station 跑马灯站点：一台PLC及其连接、控制器和界面状态
+type Station struct {
+	name        string
+	config      *Config
+	client      *ModbusClient
+	tags        *TagClient
+	supervisor  *ConnectionSupervisor
+	marquee     *MarqueeController
+	input       *InputController
+	environment *EnvironmentMonitor
+	alarms      *AlarmEngine
+	history     *Historian
+	view        *StationView
+	cancel      context.CancelFunc // 停止状态刷新循环
+}
+
+// NewStation 按站点配置创建PLC连接与全部控制器，标签表无效时返回错误
+func NewStation(config *Config) (*Station, error) {
+	s := &Station{
+		name:   config.StationName(),
+		config: config,
+	}
+
+	// 检查输出点、输入点、模拟量通道、自定义序列和调速设置并建立标签库
+	channels := config.AnalogChannelList()
+	if _, err := config.LampAddresses(); err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+	if _, err := config.InputAddresses(); err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+	if err := validateSequences(config.SequenceList()); err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+	if err := validateSpeedConfig(config, channels); err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+	if err := validateAnalogChannels(channels, db); err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+
+	// 创建Modbus客户端及标签读写接口
+	s.client = NewModbusClient(config)
+	s.tags = NewTagClient(s.client, db)
+
+	// 创建连接监督器
+	s.supervisor = NewConnectionSupervisor(s.client, config)
+
+	// 创建跑马灯控制器
+	s.marquee = NewMarqueeController(s.tags, config)
+	if err := s.marquee.SetPattern(config.Pattern); err != nil {
+		return nil, fmt.Errorf("station %s: %w", s.name, err)
+	}
+
+	// 创建手动控制器
+	manualController := NewManualController(s.tags, s.marquee, nil)
+
+	// 创建报警引擎
+	s.alarms = NewAlarmEngine(s.name, channels)
+
+	// 创建历史数据记录
+	s.history = NewHistorian(config)
+
+	// 创建站点界面状态
+	s.view = NewStationView(s.name, s.client, s.tags, s.alarms, s.history, s.marquee, manualController, config)
+
+	// 创建输入控制器
+	s.input = NewInputController(s.tags, s.marquee, s.view, config)
+
+	// 创建环境监测器
+	s.environment = NewEnvironmentMonitor(s.tags, channels, config.AnalogStaleAfter())
+
+	s.view.WatchConnection(s.supervisor)
+	s.marquee.WatchConnection(s.supervisor)
+	s.input.WatchConnection(s.supervisor)
+
+	return s, nil
+}
+
+// Start 启动连接监督、状态刷新和轮询，按配置自动连接
+func (s *Station) Start() {
+	s.supervisor.Start()
+	if s.config.AutoConnect {
+		go s.supervisor.Connect()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.refreshStatus(ctx)
+
+	// 启动输入轮询
+	s.input.Start()
+
+	// 启动历史数据维护
+	s.history.Start()
+}
+
+// Stop 停止站点的全部后台任务
+func (s *Station) Stop() {
+	s.input.Stop()
+	s.cancel()
+	s.supervisor.Stop()
+	s.history.Stop()
+}
+
+// refreshStatus 按配置的轮询周期刷新界面状态，ctx取消时退出
+func (s *Station) refreshStatus(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval())
+	defer ticker.Stop()
+
+	for {
+		// 连接状态由连接监督器事件更新
+		s.view.UpdatePattern(s.marquee.Pattern().Name)
+		if s.marquee.IsRunning() {
+			s.view.UpdateRunStatus("运行中")
+			s.view.UpdateSpeedLevel(s.marquee.GetSpeedLevel())
+			s.view.UpdateSpeedMode(s.marquee.SpeedState().Mode)
+			s.view.UpdateDelayValue(s.marquee.GetDelay())
+			s.view.UpdateCurrentOutput(s.marquee.GetCurrentOutputAddress())
+		} else {
+			s.view.UpdateRunStatus("停止")
+			s.view.UpdateSpeedLevel(0)
+			s.view.UpdateSpeedMode("")
+			s.view.UpdateDelayValue(0)
+			s.view.UpdateCurrentOutput("无")
+		}
+
+		// 记录跑马灯运行状态变化，用于导出运行时段
+		now := time.Now()
+		s.history.RecordBools([]string{HistoryTagRunning}, []bool{s.marquee.IsRunning()}, now)
+
+		// 更新IO状态（从PLC读取实际数据）
+		var modbusErr error
+		if s.client.IsConnected() {
+			// 读取DQ状态 (跑马灯输出点标签)
+			if dqStatus, err := s.tags.ReadBoolsCtx(ctx, s.marquee.lamps); err != nil {
+				modbusErr = err
+			} else {
+				s.history.RecordBools(s.marquee.lamps, dqStatus, now)
+				for i := 0; i < len(dqStatus); i++ {
+					if dqStatus[i] {
+						s.view.UpdateDQStatus(i, "ON")
+					} else {
+						s.view.UpdateDQStatus(i, "OFF")
+					}
+				}
+			}
+
+			// 读取DI状态 (数字输入点标签)
+			if diStatus, err := s.tags.ReadBoolsCtx(ctx, s.input.inputs); err != nil {
+				modbusErr = err
+			} else {
+				s.history.RecordBools(s.input.inputs, diStatus, now)
+				for i := 0; i < len(diStatus); i++ {
+					if diStatus[i] {
+						s.view.UpdateDIStatus(i, "ON")
+					} else {
+						s.view.UpdateDIStatus(i, "OFF")
+					}
+				}
+			}
+
+			// DI状态由InputController处理，这里不需要重复更新
+		} else {
+			// 未连接时显示OFF状态，重新连接后重新记录全部IO状态
+			s.history.ForgetBools(s.marquee.lamps)
+			s.history.ForgetBools(s.input.inputs)
+			for i := range s.marquee.lamps {
+				s.view.UpdateDQStatus(i, "OFF")
+			}
+			for i := range s.input.inputs {
+				s.view.UpdateDIStatus(i, "OFF")
+			}
+		}
+
+		// 只有连接PLC时才读取模拟量通道，断开时显示标记为过期的最后读数
+		// 每轮只读取一次，同一组读数分发给界面、调速、报警和历史记录
+		if s.client.IsConnected() {
+			readings, err := s.environment.ReadChannels(ctx)
+			if err != nil {
+				modbusErr = err
+			}
+			s.view.UpdateAnalog(readings)
+			s.marquee.ApplySpeedInput(readings)
+			s.alarms.Evaluate(readings, now)
+			s.history.RecordAnalog(readings, now)
+		} else {
+			s.view.UpdateAnalog(s.environment.StaleReadings("PLC未连接"))
+		}
+
+		// 记录本轮最近一次通信错误（含Modbus异常）
+		s.view.UpdateModbusError(modbusErr)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
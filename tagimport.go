@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"s7-1200-marquee/codec"
+)
+
+// TagImport 标签导入结果
+type TagImport struct {
+	Tags    []Tag            // 可映射到Modbus的标签
+	Skipped []TagImportIssue // 跳过的行
+}
+
+// TagImportIssue 导入时跳过的行及原因
+type TagImportIssue struct {
+	Line   int    // CSV行号（从1开始，含表头）
+	Name   string // 变量名
+	Reason string
+}
+
+// tiaDataTypes TIA Portal数据类型到标签数据类型的映射
+var tiaDataTypes = map[string]TagDataType{
+	"BOOL":  TypeBool,
+	"INT":   TypeInt16,
+	"UINT":  TypeUint16,
+	"WORD":  TypeUint16,
+	"DINT":  TypeInt32,
+	"UDINT": TypeUint32,
+	"DWORD": TypeUint32,
+	"REAL":  TypeFloat32,
+	"LREAL": TypeFloat64,
+}
+
+// tiaStringType 匹配 String 与 String[n]，未指定长度时为254
+var tiaStringType = regexp.MustCompile(`^(?i)string(?:\[(\d+)\])?$`)
+
+// tagCSVColumns 可识别的表头名称（小写），支持英文与中文界面导出
+var tagCSVColumns = map[string][]string{
+	"name":    {"name", "名称"},
+	"type":    {"data type", "datatype", "数据类型"},
+	"address": {"logical address", "address", "逻辑地址", "地址"},
+	"offset":  {"offset", "偏移量"},
+	"comment": {"comment", "注释"},
+}
+
+// tiaDefaultColumns 无表头的PLC变量表导出（.sdf）的列顺序：名称、变量表、数据类型、地址、注释
+var tiaDefaultColumns = map[string]int{"name": 0, "type": 2, "address": 3, "comment": 4}
+
+// tagCSV 已识别列的CSV内容
+type tagCSV struct {
+	columns   map[string]int
+	rows      [][]string
+	firstLine int // 第一行数据的行号
+}
+
+// field 返回行中指定列的值，列不存在时返回空串
+func (t *tagCSV) field(row []string, column string) string {
+	index, ok := t.columns[column]
+	if !ok || index >= len(row) {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(row[index]), `"`)
+}
+
+// readTagCSV 读取CSV并识别列，自动判断分隔符（, ; 或制表符）
+func readTagCSV(r io.Reader) (*tagCSV, error) {
+	reader := bufio.NewReader(r)
+
+	// 跳过UTF-8 BOM
+	if bom, err := reader.Peek(3); err == nil && string(bom) == "\xEF\xBB\xBF" {
+		reader.Discard(3)
+	}
+
+	firstLine, err := reader.Peek(reader.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if i := strings.IndexByte(string(firstLine), '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = detectCSVDelimiter(string(firstLine))
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty tag table")
+	}
+
+	table := &tagCSV{columns: make(map[string]int), rows: records, firstLine: 1}
+	for i, cell := range records[0] {
+		header := strings.ToLower(strings.TrimSpace(cell))
+		for column, names := range tagCSVColumns {
+			for _, name := range names {
+				if _, seen := table.columns[column]; !seen && header == name {
+					table.columns[column] = i
+				}
+			}
+		}
+	}
+
+	if _, ok := table.columns["name"]; ok {
+		table.rows = records[1:]
+		table.firstLine = 2
+	} else {
+		table.columns = tiaDefaultColumns
+	}
+	return table, nil
+}
+
+// detectCSVDelimiter 按首行中出现次数最多的分隔符判断CSV分隔符
+func detectCSVDelimiter(line string) rune {
+	best, bestCount := ',', strings.Count(line, ",")
+	for _, delimiter := range []rune{';', '\t'} {
+		if count := strings.Count(line, string(delimiter)); count > bestCount {
+			best, bestCount = delimiter, count
+		}
+	}
+	return best
+}
+
+// ImportTIATags 导入TIA Portal PLC变量表导出（CSV，或由XLSX另存的CSV）
+// %Q/%I位地址映射为线圈/离散输入，%IW/%ID映射为输入寄存器，%MW/%MD映射为保持寄存器（MB_HOLD_REG指向MW0时）
+func ImportTIATags(r io.Reader) (*TagImport, error) {
+	table, err := readTagCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := table.columns["address"]; !ok {
+		return nil, fmt.Errorf("tag table has no address column")
+	}
+
+	result := &TagImport{}
+	seen := make(map[string]int) // 变量名 -> 首次出现的行号
+	for i, row := range table.rows {
+		line := table.firstLine + i
+		name := table.field(row, "name")
+		if name == "" {
+			continue
+		}
+		skip := func(reason string) {
+			result.Skipped = append(result.Skipped, TagImportIssue{Line: line, Name: name, Reason: reason})
+		}
+		if first, ok := seen[name]; ok {
+			skip(fmt.Sprintf("duplicate name, first defined on line %d", first))
+			continue
+		}
+		seen[name] = line
+
+		address := strings.TrimPrefix(strings.ToUpper(table.field(row, "address")), "%")
+		area, _, defaultType, err := ParseS7Address(address)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+
+		dataType := defaultType
+		if typeName := table.field(row, "type"); typeName != "" {
+			var ok bool
+			dataType, ok = tiaDataTypes[strings.ToUpper(typeName)]
+			if !ok {
+				skip(fmt.Sprintf("unsupported data type %s", typeName))
+				continue
+			}
+		}
+		if area.IsBit() != (dataType == TypeBool) || dataType.Registers() > defaultType.Registers() {
+			skip(fmt.Sprintf("data type %s does not fit address %s", dataType, address))
+			continue
+		}
+
+		result.Tags = append(result.Tags, Tag{
+			Name:     name,
+			Alias:    address,
+			DataType: dataType,
+			Comment:  table.field(row, "comment"),
+		})
+	}
+	return result, nil
+}
+
+// ImportDBLayout 导入MB_SERVER保持寄存器数据块（MB_HOLD_REG）的变量布局
+// 需包含名称、数据类型和偏移量列（非优化块访问），startRegister为数据块首字对应的保持寄存器短地址
+func ImportDBLayout(r io.Reader, startRegister int) (*TagImport, error) {
+	table, err := readTagCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := table.columns["offset"]; !ok {
+		return nil, fmt.Errorf("DB layout has no offset column, disable optimized block access and export again")
+	}
+
+	result := &TagImport{}
+	seen := make(map[string]int) // 变量名 -> 首次出现的行号
+	for i, row := range table.rows {
+		line := table.firstLine + i
+		name := table.field(row, "name")
+		if name == "" {
+			continue
+		}
+		skip := func(reason string) {
+			result.Skipped = append(result.Skipped, TagImportIssue{Line: line, Name: name, Reason: reason})
+		}
+		if first, ok := seen[name]; ok {
+			skip(fmt.Sprintf("duplicate name, first defined on line %d", first))
+			continue
+		}
+		seen[name] = line
+
+		typeName := table.field(row, "type")
+		dataType, ok := tiaDataTypes[strings.ToUpper(typeName)]
+		length := 0
+		if match := tiaStringType.FindStringSubmatch(typeName); match != nil {
+			dataType, ok, length = TypeString, true, codec.MaxStringLength
+			if match[1] != "" {
+				length, _ = strconv.Atoi(match[1])
+			}
+		}
+		if !ok {
+			skip(fmt.Sprintf("unsupported data type %s", typeName))
+			continue
+		}
+		if dataType == TypeBool {
+			skip("bit members cannot be mapped to holding registers")
+			continue
+		}
+		if length > maxStringTagLength {
+			skip(fmt.Sprintf("string length %d exceeds %d", length, maxStringTagLength))
+			continue
+		}
+
+		// 偏移量格式为 字节.位，如 4.0
+		offsetText := table.field(row, "offset")
+		byteText, bitText, _ := strings.Cut(offsetText, ".")
+		byteOffset, err := strconv.Atoi(byteText)
+		if err != nil || (bitText != "" && bitText != "0") {
+			skip(fmt.Sprintf("invalid offset %q", offsetText))
+			continue
+		}
+		if byteOffset%2 != 0 {
+			skip(fmt.Sprintf("offset %d is not word aligned", byteOffset))
+			continue
+		}
+
+		result.Tags = append(result.Tags, Tag{
+			Name:     name,
+			Area:     AreaHoldingRegister,
+			Offset:   startRegister + byteOffset/2,
+			DataType: dataType,
+			Length:   length,
+			Comment:  table.field(row, "comment"),
+		})
+	}
+	return result, nil
+}
+
+// TagDiff 导入标签与当前标签表的差异
+type TagDiff struct {
+	Added     []*Tag      // 当前标签表中没有的标签
+	Changed   []TagChange // 地址、数据类型或字符串长度不同的标签
+	Missing   []*Tag      // 当前标签表中有、导入结果中没有的标签
+	Unchanged int
+}
+
+// TagChange 同名标签的变化
+type TagChange struct {
+	Old *Tag
+	New *Tag
+}
+
+// DiffTags 比较当前标签表与导入标签（均为已解析的标签库）
+func DiffTags(current, imported *TagDB) *TagDiff {
+	diff := &TagDiff{}
+	for _, tag := range imported.Tags() {
+		old, err := current.Lookup(tag.Name)
+		switch {
+		case err != nil:
+			diff.Added = append(diff.Added, tag)
+		case old.Area != tag.Area || old.Offset != tag.Offset || old.DataType != tag.DataType || old.Length != tag.Length:
+			diff.Changed = append(diff.Changed, TagChange{Old: old, New: tag})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for _, tag := range current.Tags() {
+		if _, err := imported.Lookup(tag.Name); err != nil {
+			diff.Missing = append(diff.Missing, tag)
+		}
+	}
+	return diff
+}
+
+// describeTag 返回标签的地址描述，如 IW64 → 30033 int16
+func describeTag(tag *Tag) string {
+	return fmt.Sprintf("%s → %05d %s", tag.Address(), ModbusLogicalAddress(tag.Area, tag.Offset), tag.DataType)
+}
+
+// WriteReport 输出差异报告
+func (d *TagDiff) WriteReport(w io.Writer) {
+	fmt.Fprintf(w, "新增 %d，变更 %d，未变 %d，未包含 %d\n", len(d.Added), len(d.Changed), d.Unchanged, len(d.Missing))
+	for _, tag := range d.Added {
+		fmt.Fprintf(w, "+ %-20s %s\n", tag.Name, describeTag(tag))
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(w, "~ %-20s %s  =>  %s\n", change.New.Name, describeTag(change.Old), describeTag(change.New))
+	}
+	if len(d.Missing) > 0 {
+		names := make([]string, len(d.Missing))
+		for i, tag := range d.Missing {
+			names[i] = tag.Name
+		}
+		fmt.Fprintf(w, "未包含（保持不变）: %s\n", strings.Join(names, ", "))
+	}
+}
+
+// ApplyImportedTags 将导入标签合并到配置的自定义标签中
+// 导入标签覆盖同名标签；导入结果没有换算参数、单位和字节序时沿用当前标签表中的设置
+func ApplyImportedTags(config *Config, imported []Tag) {
+	current := make(map[string]Tag)
+	for _, tag := range config.TagTable() {
+		current[tag.Name] = tag
+	}
+
+	merged := make([]Tag, 0, len(imported))
+	for _, tag := range imported {
+		if old, ok := current[tag.Name]; ok {
+			if tag.Scaling == nil {
+				tag.Scaling = old.Scaling
+			}
+			if tag.Unit == "" {
+				tag.Unit = old.Unit
+			}
+			if tag.ByteOrder == "" {
+				tag.ByteOrder = old.ByteOrder
+			}
+		}
+		merged = append(merged, tag)
+	}
+	config.Tags = mergeTags(config.Tags, merged)
+}
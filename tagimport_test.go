@@ -0,0 +1,239 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// tiaExport 英文界面导出的PLC变量表，包含可导入和应跳过的各类行
+const tiaExport = "\xEF\xBB\xBF" + `Name,Path,Data Type,Logical Address,Comment
+Lamp1,Default tag table,Bool,%Q0.1,第一盏灯
+Temperature,Default tag table,Int,%IW64,
+Flow,Default tag table,Real,%ID68,流量
+,Default tag table,Bool,%Q0.2,
+Unknown,Default tag table,Bool,%X1.0,
+Odd,Default tag table,Int,%MW3,
+Far,Default tag table,Int,%MW20000,
+Marker,Default tag table,Bool,%M0.0,
+Wide,Default tag table,Real,%IW70,
+Timer,Default tag table,IEC_TIMER,%MW10,
+Lamp1,Default tag table,Bool,%Q0.2,
+LampAlias,Default tag table,Bool,%Q0.1,与Lamp1同一地址
+`
+
+func TestImportTIATags(t *testing.T) {
+	result, err := ImportTIATags(strings.NewReader(tiaExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantTags := []Tag{
+		{Name: "Lamp1", Alias: "Q0.1", DataType: TypeBool, Comment: "第一盏灯"},
+		{Name: "Temperature", Alias: "IW64", DataType: TypeInt16},
+		{Name: "Flow", Alias: "ID68", DataType: TypeFloat32, Comment: "流量"},
+		{Name: "LampAlias", Alias: "Q0.1", DataType: TypeBool, Comment: "与Lamp1同一地址"},
+	}
+	if len(result.Tags) != len(wantTags) {
+		t.Fatalf("imported %d tags, want %d: %+v", len(result.Tags), len(wantTags), result.Tags)
+	}
+	for i, want := range wantTags {
+		got := result.Tags[i]
+		if got.Name != want.Name || got.Alias != want.Alias || got.DataType != want.DataType || got.Comment != want.Comment {
+			t.Errorf("tag %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	wantSkipped := []struct {
+		line   int
+		name   string
+		reason string
+	}{
+		{6, "Unknown", "invalid S7 address"},
+		{7, "Odd", "not word aligned"},
+		{8, "Far", "outside Modbus address range"},
+		{9, "Marker", "has no Modbus mapping"},
+		{10, "Wide", "does not fit address IW70"},
+		{11, "Timer", "unsupported data type IEC_TIMER"},
+		{12, "Lamp1", "duplicate name, first defined on line 2"},
+	}
+	if len(result.Skipped) != len(wantSkipped) {
+		t.Fatalf("skipped %d rows, want %d: %+v", len(result.Skipped), len(wantSkipped), result.Skipped)
+	}
+	for i, want := range wantSkipped {
+		got := result.Skipped[i]
+		if got.Line != want.line || got.Name != want.name || !strings.Contains(got.Reason, want.reason) {
+			t.Errorf("skipped %d = %+v, want line %d %s: %s", i, got, want.line, want.name, want.reason)
+		}
+	}
+
+	// 导入结果可直接建立标签库，同一地址的不同变量名都保留
+	db, err := NewTagDB(result.Tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{"Lamp1": 1, "LampAlias": 1, "Temperature": 32, "Flow": 34} {
+		tag, err := db.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Offset != want {
+			t.Errorf("%s offset = %d, want %d", name, tag.Offset, want)
+		}
+	}
+}
+
+func TestImportTIATagsFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"comma", "Name,Data Type,Logical Address,Comment\nLamp1,Bool,%Q0.1,灯\nLevel,Int,%IW64,\n"},
+		{"semicolon", "Name;Path;Data Type;Logical Address\nLamp1;Default;Bool;%Q0.1\nLevel;Default;Int;%IW64\n"},
+		{"chinese header", "名称\t变量表\t数据类型\t逻辑地址\t注释\nLamp1\t默认变量表\tBool\t%Q0.1\t灯\nLevel\t默认变量表\tInt\t%IW64\t\n"},
+		{"address only", "Name,Address\nLamp1,Q0.1\nLevel,iw64\n"},
+		{"headerless sdf", "\"Lamp1\"\t\"Default tag table\"\t\"Bool\"\t\"%Q0.1\"\t\"灯\"\n\"Level\"\t\"Default tag table\"\t\"Int\"\t\"%IW64\"\t\"\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ImportTIATags(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Skipped) != 0 || len(result.Tags) != 2 {
+				t.Fatalf("tags %+v, skipped %+v", result.Tags, result.Skipped)
+			}
+			lamp, level := result.Tags[0], result.Tags[1]
+			if lamp.Name != "Lamp1" || lamp.Alias != "Q0.1" || lamp.DataType != TypeBool {
+				t.Errorf("lamp = %+v", lamp)
+			}
+			if level.Name != "Level" || level.Alias != "IW64" || level.DataType != TypeInt16 {
+				t.Errorf("level = %+v", level)
+			}
+		})
+	}
+
+	if _, err := ImportTIATags(strings.NewReader("Name,Data Type\nLamp1,Bool\n")); err == nil {
+		t.Error("table without address column accepted")
+	}
+	if _, err := ImportTIATags(strings.NewReader("")); err == nil {
+		t.Error("empty table accepted")
+	}
+}
+
+func TestImportDBLayout(t *testing.T) {
+	const layout = `Name,Data type,Offset,Comment
+Speed,Int,0.0,速度
+Total,DInt,2.0,
+Flag,Bool,4.0,
+Label,String[10],6.0,
+Odd,Int,5.0,
+Bad,Int,x,
+Text,String,18.0,
+Note,String[240],18.0,
+Speed,Real,274.0,
+Mode,Struct,280.0,
+`
+	result, err := ImportDBLayout(strings.NewReader(layout), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantTags := []Tag{
+		{Name: "Speed", Area: AreaHoldingRegister, Offset: 100, DataType: TypeInt16, Comment: "速度"},
+		{Name: "Total", Area: AreaHoldingRegister, Offset: 101, DataType: TypeInt32},
+		{Name: "Label", Area: AreaHoldingRegister, Offset: 103, DataType: TypeString, Length: 10},
+		{Name: "Note", Area: AreaHoldingRegister, Offset: 109, DataType: TypeString, Length: 240},
+	}
+	if len(result.Tags) != len(wantTags) {
+		t.Fatalf("imported %d tags, want %d: %+v", len(result.Tags), len(wantTags), result.Tags)
+	}
+	for i, want := range wantTags {
+		if got := result.Tags[i]; got != want {
+			t.Errorf("tag %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	wantSkipped := map[int]string{
+		4:  "bit members",
+		6:  "not word aligned",
+		7:  "invalid offset",
+		8:  "string length 254 exceeds 240", // 未指定长度的String为254个字符
+		10: "duplicate name, first defined on line 2",
+		11: "unsupported data type Struct",
+	}
+	if len(result.Skipped) != len(wantSkipped) {
+		t.Fatalf("skipped %+v", result.Skipped)
+	}
+	for _, issue := range result.Skipped {
+		if want, ok := wantSkipped[issue.Line]; !ok || !strings.Contains(issue.Reason, want) {
+			t.Errorf("skipped line %d %s: %s, want %q", issue.Line, issue.Name, issue.Reason, want)
+		}
+	}
+
+	if _, err := ImportDBLayout(strings.NewReader("Name,Data type\nSpeed,Int\n"), 0); err == nil {
+		t.Error("layout without offset column accepted")
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	current, err := NewTagDB([]Tag{
+		{Name: "Lamp1", Alias: "Q0.1"},
+		{Name: "Level", Alias: "IW64"},
+		{Name: "Setpoint", Alias: "MW0"},
+		{Name: "Counter", Alias: "MW4"},
+		{Name: "Name", Area: AreaHoldingRegister, Offset: 10, DataType: TypeString, Length: 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := NewTagDB([]Tag{
+		{Name: "Lamp1", Alias: "Q0.1"},
+		{Name: "Level", Alias: "IW66"},                                  // 地址变化
+		{Name: "Counter", Alias: "MD4"},                                 // 数据类型变化
+		{Name: "Name", Alias: "MW20", DataType: TypeString, Length: 16}, // 长度变化
+		{Name: "Pressure", Alias: "ID68", DataType: TypeFloat32},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := DiffTags(current, imported)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "Pressure" {
+		t.Errorf("added = %v", diff.Added)
+	}
+	if len(diff.Changed) != 3 {
+		t.Fatalf("changed = %+v", diff.Changed)
+	}
+	for i, name := range []string{"Level", "Counter", "Name"} {
+		if change := diff.Changed[i]; change.Old.Name != name || change.New.Name != name {
+			t.Errorf("change %d = %s -> %s, want %s", i, change.Old.Name, change.New.Name, name)
+		}
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0].Name != "Setpoint" {
+		t.Errorf("missing = %v", diff.Missing)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", diff.Unchanged)
+	}
+
+	var report strings.Builder
+	diff.WriteReport(&report)
+	want := `新增 1，变更 3，未变 1，未包含 1
++ Pressure             ID68 → 30035 float32
+~ Level                IW64 → 30033 int16  =>  IW66 → 30034 int16
+~ Counter              MW4 → 40003 int16  =>  MD4 → 40003 int32
+~ Name                 holdingRegister[10] → 40011 string  =>  MW20 → 40011 string
+未包含（保持不变）: Setpoint
+`
+	if report.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", report.String(), want)
+	}
+
+	// 相同标签表没有差异，报告只有汇总行
+	diff = DiffTags(current, current)
+	report.Reset()
+	diff.WriteReport(&report)
+	if want := "新增 0，变更 0，未变 5，未包含 0\n"; report.String() != want {
+		t.Errorf("identical tables report %q, want %q", report.String(), want)
+	}
+}
@@ -0,0 +1,823 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"s7-1200-marquee/codec"
+)
+
+// TagArea 标签所在的Modbus数据区
+type TagArea string
+
+// 数据区
+const (
+	AreaCoil            TagArea = "coil"            // 线圈 (Q区)，功能码 01/05/15
+	AreaDiscreteInput   TagArea = "discreteInput"   // 离散输入 (I区)，功能码 02
+	AreaInputRegister   TagArea = "inputRegister"   // 输入寄存器 (IW区)，功能码 04
+	AreaHoldingRegister TagArea = "holdingRegister" // 保持寄存器 (MB_SERVER保持区)，功能码 03/06/16
+)
+
+// IsBit 判断数据区是否按位寻址
+func (a TagArea) IsBit() bool {
+	return a == AreaCoil || a == AreaDiscreteInput
+}
+
+// Writable 判断数据区是否可写
+func (a TagArea) Writable() bool {
+	return a == AreaCoil || a == AreaHoldingRegister
+}
+
+// TagDataType 标签数据类型
+type TagDataType string
+
+// 数据类型，寄存器类型的编解码由codec包完成
+const (
+	TypeBool    TagDataType = "bool"                     // BOOL
+	TypeInt16   TagDataType = TagDataType(codec.Int16)   // INT
+	TypeUint16  TagDataType = TagDataType(codec.Uint16)  // WORD/UINT
+	TypeInt32   TagDataType = TagDataType(codec.Int32)   // DINT
+	TypeUint32  TagDataType = TagDataType(codec.Uint32)  // DWORD/UDINT
+	TypeFloat32 TagDataType = TagDataType(codec.Float32) // REAL
+	TypeFloat64 TagDataType = TagDataType(codec.Float64) // LREAL
+	TypeBCD16   TagDataType = TagDataType(codec.BCD16)   // 4位BCD码
+	TypeBCD32   TagDataType = TagDataType(codec.BCD32)   // 8位BCD码
+	TypeString  TagDataType = TagDataType(codec.String)  // S7 STRING[length]
+)
+
+// maxStringTagLength 字符串标签的最大字符数
+// 连同2字节头部占121个寄存器，一次读（FC03/04最多125个）或写（FC16最多123个、FC23最多121个）即可完成
+const maxStringTagLength = 240
+
+// Registers 返回固定长度数据类型占用的寄存器数，bool和string返回0
+func (t TagDataType) Registers() int {
+	if t == TypeString {
+		return 0
+	}
+	n, err := codec.Type(t).Registers(0)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// TagScaling 线性换算：工程值 = EngMin + (原始值 - RawMin) × (EngMax - EngMin) / (RawMax - RawMin)
+type TagScaling struct {
+	RawMin float64 `json:"rawMin"`
+	RawMax float64 `json:"rawMax"`
+	EngMin float64 `json:"engMin"`
+	EngMax float64 `json:"engMax"`
+}
+
+// ToEngineering 原始值转换为工程值
+func (s *TagScaling) ToEngineering(raw float64) float64 {
+	if s == nil || s.RawMax == s.RawMin {
+		return raw
+	}
+	return s.EngMin + (raw-s.RawMin)*(s.EngMax-s.EngMin)/(s.RawMax-s.RawMin)
+}
+
+// ToRaw 工程值转换为原始值
+func (s *TagScaling) ToRaw(value float64) float64 {
+	if s == nil || s.EngMax == s.EngMin {
+		return value
+	}
+	return s.RawMin + (value-s.EngMin)*(s.RawMax-s.RawMin)/(s.EngMax-s.EngMin)
+}
+
+// Tag 标签：符号名到Modbus地址的映射
+type Tag struct {
+	Name      string      `json:"name"`
+	Alias     string      `json:"alias,omitempty"`     // S7地址，如 Q0.3、IW64；未指定area时由其推导地址
+	Area      TagArea     `json:"area,omitempty"`      // 数据区
+	Offset    int         `json:"offset,omitempty"`    // Modbus短地址（位区为位序号，寄存器区为寄存器序号）
+	DataType  TagDataType `json:"dataType,omitempty"`  // 数据类型，位区固定为bool，寄存器区默认int16
+	Scaling   *TagScaling `json:"scaling,omitempty"`   // 线性换算，为空时工程值等于原始值
+	Unit      string      `json:"unit,omitempty"`      // 工程单位
+	Comment   string      `json:"comment,omitempty"`   // 注释（导入时取自TIA Portal变量注释）
+	ByteOrder string      `json:"byteOrder,omitempty"` // 多寄存器字节序：ABCD（默认）/CDAB/BADC/DCBA
+	Length    int         `json:"length,omitempty"`    // string类型的最大字符数
+
+	codec *codec.Codec // 寄存器标签的编解码器，建立标签库时创建
+}
+
+// Registers 返回标签占用的寄存器数，位标签返回0
+func (t *Tag) Registers() int {
+	if t.codec == nil {
+		return 0
+	}
+	return t.codec.Registers()
+}
+
+// Address 返回标签地址的可读表示
+func (t *Tag) Address() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return fmt.Sprintf("%s[%d]", t.Area, t.Offset)
+}
+
+// s7AddressPattern S7绝对地址：区域 + 宽度 + 字节地址 [+ 位地址]
+var s7AddressPattern = regexp.MustCompile(`^%?([IQM])([WD]?)(\d+)(?:\.([0-7]))?$`)
+
+// ParseS7Address 按MB_SERVER映射规则将S7地址转换为Modbus数据区和短地址
+//   - Qx.y  → 线圈 x×8+y+1          (00001起)
+//   - Ix.y  → 离散输入 x×8+y+10001  (10001起)
+//   - IWn   → 输入寄存器 n/2+30001  (30001起，IW0对应30001)
+//   - IDn   → 输入寄存器 n/2+30001，占2个寄存器
+//   - MWn   → 保持寄存器 n/2+40001  (40001起，保持区映射到MW0)
+//   - MDn   → 保持寄存器 n/2+40001，占2个寄存器
+//
+// 短地址由逻辑地址经CalculateShortAddress得出；返回值dataType为该地址宽度的默认数据类型
+func ParseS7Address(address string) (area TagArea, offset int, dataType TagDataType, err error) {
+	match := s7AddressPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(address)))
+	if match == nil {
+		return "", 0, "", fmt.Errorf("invalid S7 address %q", address)
+	}
+	region, width, bit := match[1], match[2], match[4]
+	byteAddr, err := strconv.Atoi(match[3])
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid S7 address %q", address)
+	}
+
+	var index int
+	switch {
+	case width == "" && bit != "" && (region == "Q" || region == "I"):
+		bitIndex, _ := strconv.Atoi(bit)
+		area, index, dataType = AreaCoil, byteAddr*8+bitIndex, TypeBool
+		if region == "I" {
+			area = AreaDiscreteInput
+		}
+	case width != "" && bit == "" && (region == "I" || region == "M"):
+		if byteAddr%2 != 0 {
+			return "", 0, "", fmt.Errorf("S7 address %q is not word aligned", address)
+		}
+		area, index, dataType = AreaInputRegister, byteAddr/2, TypeInt16
+		if region == "M" {
+			area = AreaHoldingRegister
+		}
+		if width == "D" {
+			dataType = TypeInt32
+		}
+	default:
+		return "", 0, "", fmt.Errorf("S7 address %q has no Modbus mapping", address)
+	}
+
+	// 每个数据区的逻辑地址为5位，如 30001-39999
+	if index+max(dataType.Registers(), 1) > modbusAreaSize {
+		return "", 0, "", fmt.Errorf("S7 address %q outside Modbus address range", address)
+	}
+	startAddr := areaStartAddr(area)
+	logical := ModbusLogicalAddress(area, index)
+	return area, int(CalculateShortAddress(uint16(logical), startAddr)), dataType, nil
+}
+
+// 每个数据区的地址数量（5位逻辑地址，如 40001-49999）
+const modbusAreaSize = 9999
+
+// areaStartAddr 返回数据区的逻辑起始地址
+func areaStartAddr(area TagArea) uint16 {
+	switch area {
+	case AreaDiscreteInput:
+		return DISCRETE_INPUTS_START_ADDR
+	case AreaInputRegister:
+		return INPUT_REGISTERS_START_ADDR
+	case AreaHoldingRegister:
+		return HOLDING_REGISTERS_START_ADDR
+	default:
+		return COILS_START_ADDR
+	}
+}
+
+// ModbusLogicalAddress 返回短地址对应的逻辑地址，如输入寄存器短地址32对应30033
+func ModbusLogicalAddress(area TagArea, offset int) int {
+	return int(areaStartAddr(area)) + offset
+}
+
+// TagDB 标签库
+type TagDB struct {
+	tags   []*Tag
+	byName map[string]*Tag
+}
+
+// NewTagDB 校验标签并建立标签库
+// 未指定数据区的标签由S7地址推导数据区、短地址和默认数据类型
+func NewTagDB(tags []Tag) (*TagDB, error) {
+	db := &TagDB{byName: make(map[string]*Tag)}
+	for i := range tags {
+		tag := tags[i]
+		if tag.Name == "" {
+			return nil, fmt.Errorf("tag %d: missing name", i+1)
+		}
+		if _, exists := db.byName[tag.Name]; exists {
+			return nil, fmt.Errorf("tag %q: duplicate name", tag.Name)
+		}
+
+		if tag.Area == "" {
+			if tag.Alias == "" {
+				return nil, fmt.Errorf("tag %q: either area or alias is required", tag.Name)
+			}
+			area, offset, dataType, err := ParseS7Address(tag.Alias)
+			if err != nil {
+				return nil, fmt.Errorf("tag %q: %w", tag.Name, err)
+			}
+			tag.Area, tag.Offset = area, offset
+			if tag.DataType == "" {
+				tag.DataType = dataType
+			}
+		}
+
+		if err := validateTag(&tag); err != nil {
+			return nil, fmt.Errorf("tag %q: %w", tag.Name, err)
+		}
+		db.tags = append(db.tags, &tag)
+		db.byName[tag.Name] = &tag
+	}
+	return db, nil
+}
+
+// validateTag 检查数据区、数据类型与地址范围，并补全默认数据类型
+func validateTag(tag *Tag) error {
+	switch tag.Area {
+	case AreaCoil, AreaDiscreteInput:
+		if tag.DataType == "" {
+			tag.DataType = TypeBool
+		}
+		if tag.DataType != TypeBool {
+			return fmt.Errorf("data type %s not allowed in %s area", tag.DataType, tag.Area)
+		}
+	case AreaInputRegister, AreaHoldingRegister:
+		if tag.DataType == "" {
+			tag.DataType = TypeInt16
+		}
+		if tag.DataType == TypeBool {
+			return fmt.Errorf("data type %s not allowed in %s area", tag.DataType, tag.Area)
+		}
+		order, err := codec.ParseByteOrder(tag.ByteOrder)
+		if err != nil {
+			return err
+		}
+		tag.codec, err = codec.New(codec.Type(tag.DataType), order, tag.Length)
+		if err != nil {
+			return err
+		}
+		if tag.DataType == TypeString && tag.Length > maxStringTagLength {
+			return fmt.Errorf("string length %d exceeds %d, too long for a single request", tag.Length, maxStringTagLength)
+		}
+	default:
+		return fmt.Errorf("unknown area %q", tag.Area)
+	}
+
+	if tag.Offset < 0 || tag.Offset+max(tag.Registers(), 1) > 65536 {
+		return fmt.Errorf("offset %d out of range", tag.Offset)
+	}
+	return nil
+}
+
+// Lookup 按名称查找标签
+func (db *TagDB) Lookup(name string) (*Tag, error) {
+	tag, ok := db.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tag %q", name)
+	}
+	return tag, nil
+}
+
+// Tags 返回全部标签（按定义顺序）
+func (db *TagDB) Tags() []*Tag {
+	return db.tags
+}
+
+// Addresses 返回各标签的地址，未知标签为空字符串
+func (db *TagDB) Addresses(names []string) []string {
+	addresses := make([]string, len(names))
+	for i, name := range names {
+		if tag, err := db.Lookup(name); err == nil {
+			addresses[i] = tag.Address()
+		}
+	}
+	return addresses
+}
+
+// 控制器使用的标签名
+const (
+	TagStartButton   = "StartButton"   // 启动/速度切换按钮
+	TagStopButton    = "StopButton"    // 停止按钮
+	TagPatternButton = "PatternButton" // 花样切换按钮
+)
+
+// 默认的跑马灯输出点与数字输入点数量，即CPU 1214C本体的Q0.0-Q1.5与I0.0-I1.5
+const (
+	lampCount  = 14
+	inputCount = 14
+)
+
+// 一组数字量点的数量上限
+const maxIOPoints = 256
+
+// IOConfig 一组数字量点的地址：按地址列表排列，或从起始地址起连续分配
+type IOConfig struct {
+	Count     int      `json:"count,omitempty"`     // 点数，默认14
+	Start     string   `json:"start,omitempty"`     // 起始地址，默认Q0.0/I0.0
+	Addresses []string `json:"addresses,omitempty"` // 地址列表，可含范围如 Q0.0-Q0.7、Q8.7-Q8.0（倒序）；给出时忽略count和start
+}
+
+// BitAddresses 展开Q区或I区（region为"Q"或"I"）的位地址并按顺序返回
+// 配置为nil时为从0.0起的defaultCount点；地址不在该区、重复或超过数量上限时返回错误
+func (c *IOConfig) BitAddresses(region string, defaultCount int) ([]string, error) {
+	var indexes []int
+	if c != nil && len(c.Addresses) > 0 {
+		for _, item := range c.Addresses {
+			first, last, isRange := strings.Cut(item, "-")
+			from, err := parseBitIndex(first, region)
+			if err != nil {
+				return nil, err
+			}
+			to := from
+			if isRange {
+				if to, err = parseBitIndex(last, region); err != nil {
+					return nil, err
+				}
+			}
+			step := 1
+			if to < from {
+				step = -1
+			}
+			for i := from; len(indexes) <= maxIOPoints; i += step {
+				indexes = append(indexes, i)
+				if i == to {
+					break
+				}
+			}
+		}
+	} else {
+		count, start := defaultCount, 0
+		if c != nil && c.Count != 0 {
+			count = c.Count
+		}
+		if c != nil && c.Start != "" {
+			var err error
+			if start, err = parseBitIndex(c.Start, region); err != nil {
+				return nil, err
+			}
+		}
+		if count < 1 || count > maxIOPoints {
+			return nil, fmt.Errorf("count %d out of range 1-%d", count, maxIOPoints)
+		}
+		for i := 0; i < count; i++ {
+			indexes = append(indexes, start+i)
+		}
+	}
+	if len(indexes) > maxIOPoints {
+		return nil, fmt.Errorf("more than %d addresses", maxIOPoints)
+	}
+
+	addresses := make([]string, len(indexes))
+	seen := make(map[int]bool, len(indexes))
+	for i, index := range indexes {
+		addresses[i] = fmt.Sprintf("%s%d.%d", region, index/8, index%8)
+		if index >= modbusAreaSize {
+			return nil, fmt.Errorf("address %s outside Modbus address range", addresses[i])
+		}
+		if seen[index] {
+			return nil, fmt.Errorf("duplicate address %s", addresses[i])
+		}
+		seen[index] = true
+	}
+	return addresses, nil
+}
+
+// parseBitIndex 解析Q区或I区的位地址，返回从0.0起的位序号
+func parseBitIndex(address string, region string) (int, error) {
+	area, offset, _, err := ParseS7Address(address)
+	if err != nil {
+		return 0, err
+	}
+	want := AreaCoil
+	if region == "I" {
+		want = AreaDiscreteInput
+	}
+	if area != want {
+		return 0, fmt.Errorf("address %q is not a %s bit address", address, region)
+	}
+	return offset, nil
+}
+
+// numberedTagNames 生成带两位序号的标签名，如 Lamp01
+func numberedTagNames(prefix string, count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%02d", prefix, i+1)
+	}
+	return names
+}
+
+// DefaultTags 返回默认标签表（S7-1200 MB_SERVER默认映射）
+// 跑马灯输出点与数字输入点按给定地址依次命名为Lamp01、Lamp02…和Input01、Input02…
+func DefaultTags(lamps, inputs []string) []Tag {
+	var tags []Tag
+
+	// 跑马灯输出，默认Q0.0-Q1.5
+	for i, name := range numberedTagNames("Lamp", len(lamps)) {
+		tags = append(tags, Tag{Name: name, Alias: lamps[i]})
+	}
+
+	// 数字输入，默认I0.0-I1.5
+	for i, name := range numberedTagNames("Input", len(inputs)) {
+		tags = append(tags, Tag{Name: name, Alias: inputs[i]})
+	}
+
+	// 模拟量通道的标签由通道配置生成，见AnalogChannel.Tag
+	return append(tags,
+		Tag{Name: TagStartButton, Alias: "I0.0"},
+		Tag{Name: TagStopButton, Alias: "I0.1"},
+		Tag{Name: TagPatternButton, Alias: "I0.2"},
+	)
+}
+
+// mergeTags 以同名覆盖的方式将自定义标签合并到默认标签表
+func mergeTags(defaults []Tag, custom []Tag) []Tag {
+	merged := append([]Tag(nil), defaults...)
+	index := make(map[string]int, len(merged))
+	for i, tag := range merged {
+		index[tag.Name] = i
+	}
+	for _, tag := range custom {
+		if i, ok := index[tag.Name]; ok {
+			merged[i] = tag
+			continue
+		}
+		index[tag.Name] = len(merged)
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+// TagClient 基于标签名的读写接口，封装ModbusClient的地址与数据换算
+type TagClient struct {
+	client *ModbusClient
+	db     *TagDB
+}
+
+// NewTagClient 创建标签读写客户端
+func NewTagClient(client *ModbusClient, db *TagDB) *TagClient {
+	return &TagClient{client: client, db: db}
+}
+
+// DB 返回标签库
+func (tc *TagClient) DB() *TagDB {
+	return tc.db
+}
+
+// IsConnected 检查底层连接状态
+func (tc *TagClient) IsConnected() bool {
+	return tc.client.IsConnected()
+}
+
+// ReadBool 读取位标签
+func (tc *TagClient) ReadBool(name string) (bool, error) {
+	return tc.ReadBoolCtx(context.Background(), name)
+}
+
+// ReadBoolCtx 读取位标签，ctx取消或到期时中止请求
+func (tc *TagClient) ReadBoolCtx(ctx context.Context, name string) (bool, error) {
+	values, err := tc.ReadBoolsCtx(ctx, []string{name})
+	if err != nil {
+		return false, err
+	}
+	return values[0], nil
+}
+
+// WriteBool 写入位标签
+func (tc *TagClient) WriteBool(name string, value bool) error {
+	return tc.WriteBoolCtx(context.Background(), name, value)
+}
+
+// WriteBoolCtx 写入位标签，ctx取消或到期时中止请求
+func (tc *TagClient) WriteBoolCtx(ctx context.Context, name string, value bool) error {
+	return tc.WriteBoolsCtx(ctx, []string{name}, []bool{value})
+}
+
+// ReadBools 批量读取位标签，结果顺序与names一致
+func (tc *TagClient) ReadBools(names []string) ([]bool, error) {
+	return tc.ReadBoolsCtx(context.Background(), names)
+}
+
+// ReadBoolsCtx 批量读取位标签，ctx取消或到期时中止请求
+// 同一数据区中地址相近的标签合并为一次请求
+func (tc *TagClient) ReadBoolsCtx(ctx context.Context, names []string) ([]bool, error) {
+	tags, err := tc.lookupAll(names, func(tag *Tag) error {
+		if !tag.Area.IsBit() {
+			return fmt.Errorf("tag %q is not a bit tag", tag.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]bool, len(tags))
+	for _, area := range []TagArea{AreaCoil, AreaDiscreteInput} {
+		for _, span := range tagSpans(tags, area, maxReadBits) {
+			var resp []byte
+			if area == AreaCoil {
+				resp, err = tc.client.ReadCoilsCtx(ctx, uint16(span.start), uint16(span.quantity))
+			} else {
+				resp, err = tc.client.ReadDiscreteInputsCtx(ctx, uint16(span.start), uint16(span.quantity))
+			}
+			if err != nil {
+				return nil, err
+			}
+			bits, err := parseBitsResponse(resp, span.quantity)
+			if err != nil {
+				return nil, err
+			}
+			for _, i := range span.indexes {
+				values[i] = bits[tags[i].Offset-span.start]
+			}
+		}
+	}
+	return values, nil
+}
+
+// WriteBools 批量写入线圈标签
+func (tc *TagClient) WriteBools(names []string, values []bool) error {
+	return tc.WriteBoolsCtx(context.Background(), names, values)
+}
+
+// WriteBoolsCtx 批量写入线圈标签，ctx取消或到期时中止请求
+// 区间在地址间隔处拆开，每段连续地址合并为一次写多个线圈请求，单个地址使用写单个线圈
+func (tc *TagClient) WriteBoolsCtx(ctx context.Context, names []string, values []bool) error {
+	if len(names) != len(values) {
+		return fmt.Errorf("tag count %d does not match value count %d", len(names), len(values))
+	}
+	tags, err := tc.lookupAll(names, func(tag *Tag) error {
+		if tag.Area != AreaCoil {
+			return fmt.Errorf("tag %q is not a writable bit tag", tag.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, span := range tagSpans(tags, AreaCoil, maxWriteBits) {
+		// 不连续的区间按间隔拆开写入，避免覆盖未列出的线圈
+		for _, run := range span.contiguousRuns(tags) {
+			if run.quantity == 1 {
+				i := run.indexes[len(run.indexes)-1] // 地址重复时以最后一个值为准
+				if _, err := tc.client.WriteSingleCoilCtx(ctx, uint16(tags[i].Offset), values[i]); err != nil {
+					return err
+				}
+				continue
+			}
+
+			bits := make([]bool, run.quantity)
+			for _, i := range run.indexes {
+				bits[tags[i].Offset-run.start] = values[i]
+			}
+			if _, err := tc.client.WriteMultipleCoilsCtx(ctx, uint16(run.start), bits); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadValue 读取标签的工程值（已按换算参数转换），位标签返回0或1
+func (tc *TagClient) ReadValue(name string) (float64, error) {
+	return tc.ReadValueCtx(context.Background(), name)
+}
+
+// ReadValueCtx 读取标签的工程值，ctx取消或到期时中止请求
+func (tc *TagClient) ReadValueCtx(ctx context.Context, name string) (float64, error) {
+	tag, err := tc.db.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if tag.Area.IsBit() {
+		value, err := tc.ReadBoolCtx(ctx, name)
+		if err != nil || !value {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	raw, err := tc.readRaw(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	return tag.Scaling.ToEngineering(raw), nil
+}
+
+// ReadRaw 读取寄存器标签换算前的原始值
+func (tc *TagClient) ReadRaw(name string) (float64, error) {
+	return tc.ReadRawCtx(context.Background(), name)
+}
+
+// ReadRawCtx 读取寄存器标签换算前的原始值，ctx取消或到期时中止请求
+func (tc *TagClient) ReadRawCtx(ctx context.Context, name string) (float64, error) {
+	tag, err := tc.db.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if tag.Area.IsBit() {
+		return 0, fmt.Errorf("tag %q is not a register tag", tag.Name)
+	}
+	return tc.readRaw(ctx, tag)
+}
+
+// readRaw 读取并解码寄存器标签的原始值
+func (tc *TagClient) readRaw(ctx context.Context, tag *Tag) (float64, error) {
+	registers, err := tc.readRegisters(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	raw, err := tag.codec.DecodeFloat(registers)
+	if err != nil {
+		return 0, fmt.Errorf("tag %q: %w", tag.Name, err)
+	}
+	return raw, nil
+}
+
+// WriteValue 按工程值写入标签（按换算参数转换为原始值）
+func (tc *TagClient) WriteValue(name string, value float64) error {
+	return tc.WriteValueCtx(context.Background(), name, value)
+}
+
+// WriteValueCtx 按工程值写入标签，ctx取消或到期时中止请求
+func (tc *TagClient) WriteValueCtx(ctx context.Context, name string, value float64) error {
+	tag, err := tc.db.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if !tag.Area.Writable() {
+		return fmt.Errorf("tag %q is read-only", tag.Name)
+	}
+
+	if tag.Area == AreaCoil {
+		return tc.WriteBoolCtx(ctx, name, value != 0)
+	}
+
+	registers, err := tag.codec.EncodeFloat(tag.Scaling.ToRaw(value))
+	if err != nil {
+		return fmt.Errorf("tag %q: %w", tag.Name, err)
+	}
+	return tc.writeRegisters(ctx, tag, registers)
+}
+
+// ReadString 读取字符串标签
+func (tc *TagClient) ReadString(name string) (string, error) {
+	return tc.ReadStringCtx(context.Background(), name)
+}
+
+// ReadStringCtx 读取字符串标签，ctx取消或到期时中止请求
+func (tc *TagClient) ReadStringCtx(ctx context.Context, name string) (string, error) {
+	tag, err := tc.db.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if tag.DataType != TypeString {
+		return "", fmt.Errorf("tag %q is not a string tag", tag.Name)
+	}
+
+	registers, err := tc.readRegisters(ctx, tag)
+	if err != nil {
+		return "", err
+	}
+	value, err := tag.codec.DecodeString(registers)
+	if err != nil {
+		return "", fmt.Errorf("tag %q: %w", tag.Name, err)
+	}
+	return value, nil
+}
+
+// WriteString 写入字符串标签
+func (tc *TagClient) WriteString(name string, value string) error {
+	return tc.WriteStringCtx(context.Background(), name, value)
+}
+
+// WriteStringCtx 写入字符串标签，ctx取消或到期时中止请求
+func (tc *TagClient) WriteStringCtx(ctx context.Context, name string, value string) error {
+	tag, err := tc.db.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if tag.DataType != TypeString || !tag.Area.Writable() {
+		return fmt.Errorf("tag %q is not a writable string tag", tag.Name)
+	}
+
+	registers, err := tag.codec.EncodeString(value)
+	if err != nil {
+		return fmt.Errorf("tag %q: %w", tag.Name, err)
+	}
+	return tc.writeRegisters(ctx, tag, registers)
+}
+
+// writeRegisters 写入寄存器标签，单个寄存器使用写单个寄存器
+func (tc *TagClient) writeRegisters(ctx context.Context, tag *Tag, registers []uint16) error {
+	if len(registers) == 1 {
+		return tc.client.WriteSingleRegisterCtx(ctx, uint16(tag.Offset), registers[0])
+	}
+	return tc.client.WriteMultipleRegistersCtx(ctx, uint16(tag.Offset), registers)
+}
+
+// readRegisters 读取寄存器标签占用的全部寄存器
+func (tc *TagClient) readRegisters(ctx context.Context, tag *Tag) ([]uint16, error) {
+	quantity := tag.Registers()
+	if tag.Area == AreaHoldingRegister {
+		return tc.client.ReadHoldingRegistersCtx(ctx, uint16(tag.Offset), uint16(quantity))
+	}
+
+	resp, err := tc.client.ReadInputRegistersCtx(ctx, uint16(tag.Offset), uint16(quantity))
+	if err != nil {
+		return nil, err
+	}
+	return parseRegistersResponse(resp, quantity)
+}
+
+// lookupAll 按名称查找全部标签并逐个校验
+func (tc *TagClient) lookupAll(names []string, check func(*Tag) error) ([]*Tag, error) {
+	tags := make([]*Tag, len(names))
+	for i, name := range names {
+		tag, err := tc.db.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := check(tag); err != nil {
+			return nil, err
+		}
+		tags[i] = tag
+	}
+	return tags, nil
+}
+
+// 单次请求的最大位数量（Modbus规范）
+const (
+	maxReadBits  = 2000
+	maxWriteBits = 1968
+)
+
+// tagSpan 一次请求覆盖的地址区间
+type tagSpan struct {
+	start    int   // 起始短地址
+	quantity int   // 区间长度
+	distinct int   // 区间内不同地址的数量，等于quantity时区间连续
+	indexes  []int // 落在区间内的标签下标
+}
+
+// tagSpans 将指定数据区的标签按地址排序并划分为不超过limit的区间
+// 地址重复的标签共用同一个位置
+func tagSpans(tags []*Tag, area TagArea, limit int) []tagSpan {
+	var indexes []int
+	for i, tag := range tags {
+		if tag.Area == area {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool { return tags[indexes[a]].Offset < tags[indexes[b]].Offset })
+
+	var spans []tagSpan
+	for _, i := range indexes {
+		offset := tags[i].Offset
+		if n := len(spans); n > 0 && offset-spans[n-1].start < limit {
+			span := &spans[n-1]
+			if offset-span.start+1 > span.quantity {
+				span.quantity = offset - span.start + 1
+				span.distinct++
+			}
+			span.indexes = append(span.indexes, i)
+			continue
+		}
+		spans = append(spans, tagSpan{start: offset, quantity: 1, distinct: 1, indexes: []int{i}})
+	}
+	return spans
+}
+
+// contiguousRuns 在地址间隔处拆分区间，返回的每段地址连续
+func (span tagSpan) contiguousRuns(tags []*Tag) []tagSpan {
+	if span.distinct == span.quantity {
+		return []tagSpan{span}
+	}
+
+	var runs []tagSpan
+	for _, i := range span.indexes {
+		offset := tags[i].Offset
+		if n := len(runs); n > 0 && offset <= runs[n-1].start+runs[n-1].quantity {
+			run := &runs[n-1]
+			if offset == run.start+run.quantity {
+				run.quantity++
+				run.distinct++
+			}
+			run.indexes = append(run.indexes, i)
+			continue
+		}
+		runs = append(runs, tagSpan{start: offset, quantity: 1, distinct: 1, indexes: []int{i}})
+	}
+	return runs
+}
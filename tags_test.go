@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"s7-1200-marquee/codec"
+)
+
+// startTestSimulator 启动监听随机端口的仿真器并返回已连接的客户端
+func startTestSimulator(tb testing.TB, coils int) (*Simulator, *ModbusClient) {
+	tb.Helper()
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	simConfig := DefaultSimulatorConfig()
+	simConfig.ListenAddr = "127.0.0.1:0"
+	simConfig.Coils = coils
+	simConfig.Waveforms = nil
+	sim := NewSimulator(simConfig)
+	if err := sim.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(sim.Stop)
+
+	config := DefaultConfig()
+	addr := sim.Addr().(*net.TCPAddr)
+	config.IP, config.Port = addr.IP.String(), addr.Port
+	client := NewModbusClient(config)
+	if err := client.Connect(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { client.Close() })
+	return sim, client
+}
+
+// coilTags 生成n个线圈标签，地址间隔为stride
+func coilTags(n, stride int) ([]Tag, []string) {
+	tags := make([]Tag, n)
+	names := make([]string, n)
+	for i := range tags {
+		names[i] = fmt.Sprintf("Coil%d", i)
+		tags[i] = Tag{Name: names[i], Area: AreaCoil, Offset: i * stride}
+	}
+	return tags, names
+}
+
+func TestTagClientWriteBoolsContiguousRuns(t *testing.T) {
+	sim, client := startTestSimulator(t, 16)
+	offsets := []int{9, 0, 1, 2, 5, 6, 12, 1}
+	tags := make([]Tag, len(offsets))
+	names := make([]string, len(offsets))
+	values := make([]bool, len(offsets))
+	for i, offset := range offsets {
+		names[i] = fmt.Sprintf("Coil%d", i)
+		tags[i] = Tag{Name: names[i], Area: AreaCoil, Offset: offset}
+		values[i] = offset != 2
+	}
+	values[1], values[7] = true, false // 地址重复时以后出现的值为准
+	db, err := NewTagDB(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未列出的线圈保持原值
+	sim.mu.Lock()
+	sim.coils[3], sim.coils[11] = true, true
+	sim.diagCounters = [8]uint16{}
+	sim.mu.Unlock()
+
+	if err := NewTagClient(client, db).WriteBools(names, values); err != nil {
+		t.Fatal(err)
+	}
+
+	want := make([]bool, 16)
+	for _, offset := range []int{0, 3, 5, 6, 9, 11, 12} {
+		want[offset] = true
+	}
+	if got := sim.Coils(); !slices.Equal(got, want) {
+		t.Errorf("coils = %v, want %v", got, want)
+	}
+
+	// 0-2、5-6各一次写多个线圈，9和12各一次写单个线圈
+	sim.mu.RLock()
+	requests := sim.diagCounters[0]
+	sim.mu.RUnlock()
+	if requests != 4 {
+		t.Errorf("sent %d requests, want 4", requests)
+	}
+}
+
+func TestTagSpanContiguousRuns(t *testing.T) {
+	tags, _ := coilTags(4, 1)
+	tags = append(tags, Tag{Name: "Gap", Area: AreaCoil, Offset: 7}, Tag{Name: "Dup", Area: AreaCoil, Offset: 2})
+	db, err := NewTagDB(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tagSpans(db.tags, AreaCoil, maxWriteBits)
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	runs := spans[0].contiguousRuns(db.tags)
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	if runs[0].start != 0 || runs[0].quantity != 4 || len(runs[0].indexes) != 5 {
+		t.Errorf("first run = %+v", runs[0])
+	}
+	if runs[1].start != 7 || runs[1].quantity != 1 || len(runs[1].indexes) != 1 {
+		t.Errorf("second run = %+v", runs[1])
+	}
+}
+
+// BenchmarkTagClientReadBools 对比按tagSpans合并读取与逐个标签读取
+func BenchmarkTagClientReadBools(b *testing.B) {
+	const count = 128
+	for _, stride := range []int{1, 3} {
+		tags, names := coilTags(count, stride)
+		_, client := startTestSimulator(b, count*stride)
+		db, err := NewTagDB(tags)
+		if err != nil {
+			b.Fatal(err)
+		}
+		tc := NewTagClient(client, db)
+
+		b.Run(fmt.Sprintf("stride=%d/batched", stride), func(b *testing.B) {
+			for range b.N {
+				if _, err := tc.ReadBools(names); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("stride=%d/per-tag", stride), func(b *testing.B) {
+			for range b.N {
+				for _, name := range names {
+					if _, err := tc.ReadBool(name); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTagSpans 标签分区本身的开销
+func BenchmarkTagSpans(b *testing.B) {
+	tags, _ := coilTags(1024, 2)
+	db, err := NewTagDB(tags)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		tagSpans(db.tags, AreaCoil, maxReadBits)
+	}
+}
+
+func TestIOConfigBitAddresses(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		config *IOConfig
+		want   string
+	}{
+		{"default", "Q", nil, "[Q0.0 Q0.1 Q0.2 Q0.3 Q0.4 Q0.5 Q0.6 Q0.7 Q1.0 Q1.1 Q1.2 Q1.3 Q1.4 Q1.5]"},
+		{"count and start", "Q", &IOConfig{Count: 6, Start: "Q2.4"}, "[Q2.4 Q2.5 Q2.6 Q2.7 Q3.0 Q3.1]"},
+		{"start only", "I", &IOConfig{Start: "%I8.0", Count: 3}, "[I8.0 I8.1 I8.2]"},
+		{"list with ranges", "Q", &IOConfig{Addresses: []string{"Q0.0-Q0.2", "Q8.7-Q8.5", "Q4.0"}, Count: 99}, "[Q0.0 Q0.1 Q0.2 Q8.7 Q8.6 Q8.5 Q4.0]"},
+		{"input list", "I", &IOConfig{Addresses: []string{"I1.0", "I0.0-I0.1"}}, "[I1.0 I0.0 I0.1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.BitAddresses(tt.region, 14)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Fatalf("addresses %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIOConfigRejectsInvalidAddresses(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		config *IOConfig
+		want   string
+	}{
+		{"input in output list", "Q", &IOConfig{Addresses: []string{"Q0.0", "I0.1"}}, "not a Q bit address"},
+		{"output in input list", "I", &IOConfig{Addresses: []string{"Q0.0"}}, "not a I bit address"},
+		{"word address", "Q", &IOConfig{Addresses: []string{"QW0"}}, "has no Modbus mapping"},
+		{"marker", "Q", &IOConfig{Addresses: []string{"M0.0"}}, "has no Modbus mapping"},
+		{"bad bit", "Q", &IOConfig{Addresses: []string{"Q0.8"}}, "invalid S7 address"},
+		{"open range", "Q", &IOConfig{Addresses: []string{"Q0.0-"}}, "invalid S7 address"},
+		{"mixed range", "Q", &IOConfig{Addresses: []string{"Q0.0-I0.3"}}, "not a Q bit address"},
+		{"duplicate", "Q", &IOConfig{Addresses: []string{"Q0.0-Q0.3", "Q0.2"}}, "duplicate address Q0.2"},
+		{"too many", "Q", &IOConfig{Addresses: []string{"Q0.0-Q40.0"}}, "more than 256 addresses"},
+		{"bad start", "I", &IOConfig{Start: "Q0.0"}, "not a I bit address"},
+		{"count too large", "Q", &IOConfig{Count: 257}, "count 257 out of range"},
+		{"negative count", "Q", &IOConfig{Count: -1}, "count -1 out of range"},
+		{"past area end", "Q", &IOConfig{Start: "Q1249.0", Count: 8}, "outside Modbus address range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.BitAddresses(tt.region, 14)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("addresses %v, error %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigIOMapping(t *testing.T) {
+	config := DefaultConfig()
+	config.Outputs = &IOConfig{Addresses: []string{"Q2.0-Q2.2"}}
+	config.Inputs = &IOConfig{Start: "I4.0", Count: 2}
+
+	if names := config.LampTagNames(); fmt.Sprint(names) != "[Lamp01 Lamp02 Lamp03]" {
+		t.Fatalf("lamp tags %v", names)
+	}
+	if names := config.InputTagNames(); fmt.Sprint(names) != "[Input01 Input02]" {
+		t.Fatalf("input tags %v", names)
+	}
+	db, err := NewTagDB(config.TagTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Addresses([]string{"Lamp01", "Lamp03", "Input02"}); fmt.Sprint(got) != "[Q2.0 Q2.2 I4.1]" {
+		t.Fatalf("addresses %v", got)
+	}
+
+	// 地址有误时站点无法创建，错误指明输出点或输入点
+	for _, tt := range []struct {
+		outputs, inputs *IOConfig
+		want            string
+	}{
+		{&IOConfig{Addresses: []string{"Q0.0", "Q0.0"}}, nil, "outputs: duplicate address Q0.0"},
+		{nil, &IOConfig{Addresses: []string{"I0.0", "Q0.1"}}, "inputs: address"},
+	} {
+		config := DefaultConfig()
+		config.Outputs, config.Inputs = tt.outputs, tt.inputs
+		if _, err := NewStation(config); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewStation error %v, want %q", err, tt.want)
+		}
+	}
+}
+
+func TestStringTagLength(t *testing.T) {
+	tests := []struct {
+		length    int
+		registers int // 0表示应被拒绝
+	}{
+		{1, 2},
+		{maxStringTagLength, 121},
+		{maxStringTagLength + 1, 0},
+		{codec.MaxStringLength, 0},
+	}
+	for _, tt := range tests {
+		db, err := NewTagDB([]Tag{{Name: "Text", Area: AreaHoldingRegister, DataType: TypeString, Length: tt.length}})
+		if tt.registers == 0 {
+			if err == nil || !strings.Contains(err.Error(), "too long for a single request") {
+				t.Errorf("length %d: error %v, want rejection", tt.length, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("length %d: %v", tt.length, err)
+		}
+		if got := db.Tags()[0].Registers(); got != tt.registers {
+			t.Errorf("length %d: %d registers, want %d", tt.length, got, tt.registers)
+		}
+	}
+
+	// 最长的字符串标签一次读写完成
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	simConfig := DefaultSimulatorConfig()
+	simConfig.ListenAddr = "127.0.0.1:0"
+	simConfig.HoldingRegisters = 200
+	simConfig.Waveforms = nil
+	sim := NewSimulator(simConfig)
+	if err := sim.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Stop)
+	config := DefaultConfig()
+	addr := sim.Addr().(*net.TCPAddr)
+	config.IP, config.Port = addr.IP.String(), addr.Port
+	client := NewModbusClient(config)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	db, err := NewTagDB([]Tag{{Name: "Text", Area: AreaHoldingRegister, DataType: TypeString, Length: maxStringTagLength}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := NewTagClient(client, db)
+	text := strings.Repeat("x", maxStringTagLength)
+	if err := tc.WriteString("Text", text); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tc.ReadString("Text"); err != nil || got != text {
+		t.Fatalf("read back %q, %v", got, err)
+	}
+}
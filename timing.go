@@ -0,0 +1,125 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// timingWindow 时序统计保留的最近步数
+const timingWindow = 1000
+
+// DurationSummary 一组时长样本的统计，单位为毫秒
+type DurationSummary struct {
+	Count int     `json:"count"` // 样本数，最多为最近timingWindow步
+	MinMs float64 `json:"minMs"`
+	AvgMs float64 `json:"avgMs"`
+	P99Ms float64 `json:"p99Ms"`
+	MaxMs float64 `json:"maxMs"`
+}
+
+// TimingStats 跑马灯步进时序统计，自启动起累计
+type TimingStats struct {
+	Steps        int64           `json:"steps"`        // 已输出的步数
+	Overruns     int64           `json:"overruns"`     // 写入完成时已超过下一步计划时刻的步数
+	Resyncs      int64           `json:"resyncs"`      // 落后超过一步而重新对齐时间轴的次数
+	WriteErrors  int64           `json:"writeErrors"`  // 写入PLC失败的步数
+	Jitter       DurationSummary `json:"jitter"`       // 每步实际开始时刻相对计划时刻的延迟
+	WriteLatency DurationSummary `json:"writeLatency"` // 每步写入PLC的耗时，未连接或写入失败时不记录
+}
+
+// durationSamples 最近timingWindow个时长样本（环形缓冲）
+type durationSamples struct {
+	values []time.Duration
+	next   int
+}
+
+func (s *durationSamples) add(d time.Duration) {
+	if len(s.values) < timingWindow {
+		s.values = append(s.values, d)
+		return
+	}
+	s.values[s.next] = d
+	s.next = (s.next + 1) % timingWindow
+}
+
+// summary 计算最小值、平均值、p99（最近秩法）和最大值
+func (s *durationSamples) summary() DurationSummary {
+	n := len(s.values)
+	if n == 0 {
+		return DurationSummary{}
+	}
+	sorted := slices.Clone(s.values)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return DurationSummary{
+		Count: n,
+		MinMs: ms(sorted[0]),
+		AvgMs: ms(total) / float64(n),
+		P99Ms: ms(sorted[(n*99+99)/100-1]),
+		MaxMs: ms(sorted[n-1]),
+	}
+}
+
+// stepTiming 记录跑马灯每一步的调度延迟与写入耗时
+type stepTiming struct {
+	mu          sync.Mutex
+	steps       int64
+	overruns    int64
+	resyncs     int64
+	writeErrors int64
+	jitter      durationSamples
+	latency     durationSamples
+}
+
+// reset 清空统计，启动跑马灯时调用
+func (t *stepTiming) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps, t.overruns, t.resyncs, t.writeErrors = 0, 0, 0, 0
+	t.jitter, t.latency = durationSamples{}, durationSamples{}
+}
+
+// record 记录一步：jitter为开始时刻相对计划时刻的延迟，written表示是否写入了PLC，err为写入错误
+// 写入失败的耗时多为超时或断线，不计入写入耗时统计
+func (t *stepTiming) record(jitter, latency time.Duration, written bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps++
+	t.jitter.add(jitter)
+	switch {
+	case !written:
+	case err != nil:
+		t.writeErrors++
+	default:
+		t.latency.add(latency)
+	}
+}
+
+// recordOverrun 记录一次超时，resync表示时间轴已重新对齐
+func (t *stepTiming) recordOverrun(resync bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.overruns++
+	if resync {
+		t.resyncs++
+	}
+}
+
+// stats 返回统计结果
+func (t *stepTiming) stats() TimingStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TimingStats{
+		Steps:        t.steps,
+		Overruns:     t.overruns,
+		Resyncs:      t.resyncs,
+		WriteErrors:  t.writeErrors,
+		Jitter:       t.jitter.summary(),
+		WriteLatency: t.latency.summary(),
+	}
+}
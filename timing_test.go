@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// samplesOf 按给定毫秒数依次添加样本
+func samplesOf(ms ...int) *durationSamples {
+	var s durationSamples
+	for _, v := range ms {
+		s.add(time.Duration(v) * time.Millisecond)
+	}
+	return &s
+}
+
+// sequenceMs 返回1到n的毫秒数
+func sequenceMs(n int) []int {
+	ms := make([]int, n)
+	for i := range ms {
+		ms[i] = i + 1
+	}
+	return ms
+}
+
+func TestDurationSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples *durationSamples
+		want    DurationSummary
+	}{
+		{"empty", samplesOf(), DurationSummary{}},
+		{"single", samplesOf(5), DurationSummary{Count: 1, MinMs: 5, AvgMs: 5, P99Ms: 5, MaxMs: 5}},
+		// 最近秩法：p99取第ceil(0.99n)个样本，样本少于100个时即为最大值
+		{"small sample", samplesOf(9, 1, 5, 3, 7), DurationSummary{Count: 5, MinMs: 1, AvgMs: 5, P99Ms: 9, MaxMs: 9}},
+		{"hundred", samplesOf(sequenceMs(100)...), DurationSummary{Count: 100, MinMs: 1, AvgMs: 50.5, P99Ms: 99, MaxMs: 100}},
+		{"hundred and one", samplesOf(sequenceMs(101)...), DurationSummary{Count: 101, MinMs: 1, AvgMs: 51, P99Ms: 100, MaxMs: 101}},
+		{"thousand", samplesOf(sequenceMs(1000)...), DurationSummary{Count: 1000, MinMs: 1, AvgMs: 500.5, P99Ms: 990, MaxMs: 1000}},
+		// 超过窗口后最早的样本被覆盖
+		{"window", samplesOf(sequenceMs(1500)...), DurationSummary{Count: 1000, MinMs: 501, AvgMs: 1000.5, P99Ms: 1490, MaxMs: 1500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.samples.summary(); got != tt.want {
+				t.Fatalf("summary = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepTimingWriteErrors(t *testing.T) {
+	var timing stepTiming
+	timing.record(time.Millisecond, 2*time.Millisecond, true, nil)
+	timing.record(time.Millisecond, time.Second, true, errors.New("timeout"))
+	timing.record(time.Millisecond, 0, false, nil)
+	timing.recordOverrun(true)
+
+	stats := timing.stats()
+	if stats.Steps != 3 || stats.Jitter.Count != 3 || stats.Overruns != 1 || stats.Resyncs != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+	// 失败的写入只计数，不计入写入耗时
+	if stats.WriteErrors != 1 || stats.WriteLatency.Count != 1 || stats.WriteLatency.MaxMs != 2 {
+		t.Fatalf("write stats: %d errors, latency %+v", stats.WriteErrors, stats.WriteLatency)
+	}
+
+	timing.reset()
+	if stats := timing.stats(); stats != (TimingStats{}) {
+		t.Fatalf("stats after reset %+v", stats)
+	}
+}
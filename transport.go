@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// 传输层常量
+const (
+	maxPDULength  = 253 // PDU最大长度
+	drainTimeout  = 50 * time.Millisecond
+	maxDrainBytes = 64 * 1024 // 单次重新同步最多丢弃的字节数
+
+	// RTU over TCP没有可靠的字符间隔，以较长的静默时间判断未知长度报文的结束
+	rtuOverTCPSilence = 20 * time.Millisecond
+)
+
+// modbusTransport Modbus传输层，负责报文封装、收发与地址/校验和检查
+// 实现需保证Send可被多个goroutine并发调用
+type modbusTransport interface {
+	// Send 发送请求PDU并返回响应PDU
+	// ctx取消或到期时尽快返回ctx.Err()，截止时间早于配置超时时以ctx为准
+	Send(ctx context.Context, unitID byte, pdu []byte) ([]byte, error)
+	// Close 关闭底层连接
+	Close() error
+}
+
+// deadlineConn 支持读写超时的字节流（TCP连接或串口）
+type deadlineConn interface {
+	io.ReadWriteCloser
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// dialTransport 按配置建立传输层连接
+//   - tcp:        Modbus TCP (MBAP报文头)
+//   - rtu:        串口Modbus RTU
+//   - rtuovertcp: 通过TCP透传的RTU报文（串口服务器）
+//   - ascii:      通过TCP透传的ASCII报文（串口服务器）
+func dialTransport(ctx context.Context, config *Config) (modbusTransport, error) {
+	switch config.Transport {
+	case "", "tcp":
+		return dialTCP(ctx, config)
+	case "rtu":
+		return openRTU(config)
+	case "rtuovertcp":
+		conn, err := dialTCPConn(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return newRTUTransport(conn, config, rtuOverTCPSilence), nil
+	case "ascii":
+		conn, err := dialTCPConn(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return newASCIITransport(conn, config), nil
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", config.Transport)
+	}
+}
+
+// dialTCPConn 按配置的地址建立TCP连接，ctx取消时立即放弃
+func dialTCPConn(ctx context.Context, config *Config) (net.Conn, error) {
+	address := net.JoinHostPort(config.IP, strconv.Itoa(config.Port))
+	dialer := net.Dialer{Timeout: config.ConnectTimeout()}
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// requestDeadline 计算请求截止时间：配置超时与ctx截止时间中较早的一个
+func requestDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// contextError 读取超时由ctx截止时间引起时返回ctx.Err()，便于调用方区分
+// 链路错误保持不变
+func contextError(ctx context.Context, err error) error {
+	var linkErr *LinkError
+	if ctx.Err() != nil && !errors.As(err, &linkErr) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// LinkError 底层链路失效，需要重新建立连接
+type LinkError struct {
+	Err error
+}
+
+// Error 实现error接口
+func (e *LinkError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回底层错误
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+// FrameError 报文格式错误，出现后需要重新同步数据流
+type FrameError struct {
+	Reason string
+}
+
+// Error 实现error接口
+func (e *FrameError) Error() string {
+	return "invalid modbus frame: " + e.Reason
+}
+
+// validateResponsePDU 校验响应功能码与请求一致
+func validateResponsePDU(request []byte, response []byte) error {
+	if len(response) == 0 {
+		return &FrameError{Reason: "empty PDU"}
+	}
+	if response[0]&0x7F != request[0] {
+		return &FrameError{Reason: fmt.Sprintf("function code mismatch: expected 0x%02X, got 0x%02X", request[0], response[0])}
+	}
+	if response[0]&0x80 != 0 && len(response) != 2 {
+		return &FrameError{Reason: fmt.Sprintf("exception PDU length %d", len(response))}
+	}
+	return nil
+}
+
+// drainConn 丢弃连接中残留的数据，用于错误报文后的重新同步
+// 返回值表示连接是否仍然可用，持续收到垃圾数据时视为不可用
+func drainConn(conn deadlineConn) bool {
+	buf := make([]byte, 512)
+	drained := 0
+	for drained < maxDrainBytes {
+		conn.SetReadDeadline(time.Now().Add(drainTimeout))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return isTimeout(err)
+		}
+		drained += n
+	}
+	return false
+}
+
+// isResyncable 判断错误是否可通过丢弃残留数据恢复（报文错误或超时）
+func isResyncable(err error) bool {
+	var frameErr *FrameError
+	return errors.As(err, &frameErr) || isTimeout(err)
+}
+
+// isTimeout 判断错误是否为超时
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -2,66 +2,174 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // WebUI Web用户界面
 type WebUI struct {
 	server   *http.Server
 	template *template.Template
-	mu       sync.RWMutex
+	stations []*StationView // 按配置顺序排列的站点
+}
+
+// StationView 单个站点的界面状态，由该站点的控制器更新
+type StationView struct {
+	name string
+	mu   sync.RWMutex
 
 	// 控制器引用
-	modbusClient     *ModbusClient
+	modbusClient      *ModbusClient
+	tags              *TagClient
+	alarms            *AlarmEngine
+	history           *Historian
 	marqueeController *MarqueeController
-	manualController *ManualController
-	config           *Config
+	manualController  *ManualController
+	supervisor        *ConnectionSupervisor
+	config            *Config
 
 	// 状态数据
 	connectionStatus string
 	runStatus        string
 	speedLevel       int
+	speedMode        SpeedMode // 调速方式
 	delayValue       int
 	currentOutput    string
-	dqStatus         [14]string
-	diStatus         [14]string
-	temperature      float64
-	humidity         float64
+	pattern          string // 当前花样名称
+	dqStatus         []string
+	diStatus         []string
+	dqAddresses      []string // 各输出点地址，按点亮顺序排列
+	diAddresses      []string // 各输入点地址
+	analog           []AnalogReading // 模拟量通道最新读数
+	modbusError      error // 最近一次通信错误
+	connectionError  error // 最近一次连接错误
+	reconnectCount   int   // 断线重连次数
 }
 
-// NewWebUI 创建新的Web用户界面
-func NewWebUI(modbusClient *ModbusClient, marqueeController *MarqueeController, manualController *ManualController, config *Config) *WebUI {
-	ui := &WebUI{
-		modbusClient:     modbusClient,
+// modbusErrorInfo Modbus错误的JSON表示
+type modbusErrorInfo struct {
+	Message       string `json:"message"`
+	FunctionCode  byte   `json:"functionCode,omitempty"`
+	ExceptionCode byte   `json:"exceptionCode,omitempty"`
+	ExceptionName string `json:"exceptionName,omitempty"`
+}
+
+// newModbusErrorInfo 将错误转换为JSON表示，异常响应附带功能码与异常码
+func newModbusErrorInfo(err error) *modbusErrorInfo {
+	if err == nil {
+		return nil
+	}
+
+	info := &modbusErrorInfo{Message: err.Error()}
+	var exception *ModbusException
+	if errors.As(err, &exception) {
+		info.FunctionCode = exception.FunctionCode
+		info.ExceptionCode = exception.ExceptionCode
+		info.ExceptionName = exception.Name()
+	}
+	return info
+}
+
+// writeModbusError 输出包含Modbus异常信息的JSON错误
+func writeModbusError(w http.ResponseWriter, prefix string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Error     string           `json:"error"`
+		Exception *modbusErrorInfo `json:"exception,omitempty"`
+	}{
+		Error:     prefix + err.Error(),
+		Exception: newModbusErrorInfo(err),
+	})
+}
+
+// NewStationView 创建站点界面状态
+func NewStationView(name string, modbusClient *ModbusClient, tags *TagClient, alarms *AlarmEngine, history *Historian, marqueeController *MarqueeController, manualController *ManualController, config *Config) *StationView {
+	view := &StationView{
+		name:              name,
+		modbusClient:      modbusClient,
+		tags:              tags,
+		alarms:            alarms,
+		history:           history,
 		marqueeController: marqueeController,
-		manualController: manualController,
-		config:           config,
-		connectionStatus: "未连接",
-		runStatus:        "停止",
-		speedLevel:       0,
-		delayValue:       0,
-		currentOutput:    "无",
-		temperature:      25.0,
-		humidity:         60.0,
+		manualController:  manualController,
+		config:            config,
+		connectionStatus:  "未连接",
+		runStatus:         "停止",
+		speedLevel:        0,
+		delayValue:        0,
+		currentOutput:     "无",
+	}
+	if marqueeController != nil {
+		view.pattern = marqueeController.Pattern().Name
+	}
+	if config != nil {
+		for _, ch := range config.AnalogChannelList() {
+			view.analog = append(view.analog, emptyAnalogReading(&ch, "尚无数据"))
+		}
 	}
 
-	// 初始化IO状态
-	for i := 0; i < 14; i++ {
-		ui.dqStatus[i] = "OFF"
-		ui.diStatus[i] = "OFF"
+	// 初始化IO状态，地址取自标签库，自定义标签可覆盖默认地址
+	if config != nil && tags != nil {
+		view.dqAddresses = tags.DB().Addresses(config.LampTagNames())
+		view.diAddresses = tags.DB().Addresses(config.InputTagNames())
+	}
+	view.dqStatus = make([]string, len(view.dqAddresses))
+	for i := range view.dqStatus {
+		view.dqStatus[i] = "OFF"
 	}
+	view.diStatus = make([]string, len(view.diAddresses))
+	for i := range view.diStatus {
+		view.diStatus[i] = "OFF"
+	}
+	return view
+}
 
+// NewWebUI 创建新的Web用户界面
+func NewWebUI(stations []*StationView) *WebUI {
+	ui := &WebUI{stations: stations}
 	ui.initTemplate()
 	ui.startServer()
 	return ui
 }
 
+// station 根据请求参数station查找站点，未指定时返回第一个站点
+func (ui *WebUI) station(w http.ResponseWriter, r *http.Request) *StationView {
+	name := r.URL.Query().Get("station")
+	if name == "" {
+		return ui.stations[0]
+	}
+	for _, view := range ui.stations {
+		if view.name == name {
+			return view
+		}
+	}
+	http.Error(w, "Unknown station", http.StatusNotFound)
+	return nil
+}
+
+// WatchConnection 订阅连接监督器的状态变化事件
+func (view *StationView) WatchConnection(supervisor *ConnectionSupervisor) {
+	view.supervisor = supervisor
+	events := supervisor.Subscribe()
+	go func() {
+		for event := range events {
+			view.mu.Lock()
+			view.connectionStatus = event.State.Label()
+			view.connectionError = event.Err
+			view.reconnectCount = event.ReconnectCount
+			view.mu.Unlock()
+		}
+	}()
+}
+
 // initTemplate 初始化HTML模板
 func (ui *WebUI) initTemplate() {
 	funcMap := template.FuncMap{
@@ -210,6 +318,15 @@ func (ui *WebUI) initTemplate() {
 	            flex-wrap: wrap;
 	        }
 
+	        .button-group .station-select {
+	            height: 48px;
+	            margin-top: 0;
+	        }
+
+	        .button-group input.station-select {
+	            width: 140px;
+	        }
+
 	        .md-button {
 	            min-width: 120px;
 	            height: 48px;
@@ -307,6 +424,170 @@ func (ui *WebUI) initTemplate() {
 	            box-shadow: 0 0 0 3px rgba(25, 118, 210, 0.1);
 	        }
 
+	        /* 自定义序列编辑器 */
+	        .sequence-editor {
+	            width: 100%;
+	            height: 220px;
+	            padding: 12px 16px;
+	            font-family: Consolas, 'Courier New', monospace;
+	            font-size: 14px;
+	            line-height: 1.6;
+	            resize: vertical;
+	        }
+
+	        .sequence-errors {
+	            margin: 8px 0 0 0;
+	            color: var(--md-sys-color-error);
+	            font-size: 14px;
+	            white-space: pre-line;
+	        }
+
+	        .sequence-preview {
+	            display: flex;
+	            flex-wrap: wrap;
+	            gap: 8px;
+	            margin: 16px 0 8px 0;
+	        }
+
+	        .sequence-lamp {
+	            width: 40px;
+	            height: 40px;
+	            border-radius: 50%;
+	            display: flex;
+	            align-items: center;
+	            justify-content: center;
+	            font-size: 11px;
+	            background: var(--md-sys-color-surface-variant);
+	            color: var(--md-sys-color-on-surface-variant);
+	            transition: background 0.1s, box-shadow 0.1s;
+	        }
+
+	        .sequence-lamp.on {
+	            background: #ffca28;
+	            color: #5d4037;
+	            box-shadow: 0 0 12px rgba(255, 202, 40, 0.8);
+	        }
+
+	        /* 站点选择与总览 */
+	        .station-select {
+	            height: 40px;
+	            padding: 0 16px;
+	            margin-top: 12px;
+	            border: 2px solid var(--md-sys-color-surface-variant);
+	            border-radius: 12px;
+	            font-size: 16px;
+	            background: var(--md-sys-color-surface);
+	            color: var(--md-sys-color-on-surface);
+	        }
+
+	        .station-grid {
+	            display: grid;
+	            grid-template-columns: repeat(auto-fill, minmax(180px, 1fr));
+	            gap: 12px;
+	        }
+
+	        .station-tile {
+	            border-radius: 16px;
+	            padding: 16px;
+	            border: 2px solid var(--md-sys-color-surface-variant);
+	            cursor: pointer;
+	            transition: all 0.3s cubic-bezier(0.4, 0, 0.2, 1);
+	        }
+
+	        .station-tile.selected {
+	            border-color: var(--md-sys-color-primary);
+	            background: var(--md-sys-color-primary-container);
+	        }
+
+	        .station-tile .status-value {
+	            font-size: 14px;
+	        }
+
+	        /* 报警横幅 */
+	        .alarm-banner {
+	            border-radius: 16px;
+	            padding: 16px 24px;
+	            margin-bottom: 24px;
+	            background: var(--md-sys-color-surface-variant);
+	            color: var(--md-sys-color-on-surface);
+	            border-left: 8px solid var(--md-sys-color-outline);
+	        }
+
+	        .alarm-banner.unacked {
+	            background: var(--md-sys-color-error-container);
+	            color: var(--md-sys-color-on-error-container);
+	            border-left-color: var(--md-sys-color-error);
+	        }
+
+	        .alarm-summary {
+	            display: flex;
+	            justify-content: space-between;
+	            align-items: center;
+	            font-weight: 600;
+	            margin-bottom: 8px;
+	        }
+
+	        .alarm-row {
+	            display: flex;
+	            align-items: center;
+	            gap: 12px;
+	            padding: 6px 0;
+	            font-size: 14px;
+	            border-top: 1px solid rgba(0, 0, 0, 0.08);
+	        }
+
+	        .alarm-row .alarm-message { flex: 1; }
+	        .alarm-row.shelved { opacity: 0.5; }
+	        .alarm-row.active-unacked .alarm-message { font-weight: 600; animation: alarmBlink 1s step-start infinite; }
+
+	        .alarm-priority {
+	            min-width: 40px;
+	            text-align: center;
+	            border-radius: 8px;
+	            padding: 2px 6px;
+	            color: #ffffff;
+	            font-size: 12px;
+	        }
+
+	        .alarm-priority.p1 { background: #b71c1c; }
+	        .alarm-priority.p2 { background: #d32f2f; }
+	        .alarm-priority.p3 { background: #f57c00; }
+	        .alarm-priority.p4 { background: #9e9d24; }
+
+	        .alarm-action {
+	            border: 1px solid currentColor;
+	            background: transparent;
+	            color: inherit;
+	            border-radius: 12px;
+	            padding: 2px 10px;
+	            cursor: pointer;
+	            font-size: 12px;
+	        }
+
+	        @keyframes alarmBlink {
+	            50% { opacity: 0.4; }
+	        }
+
+	        /* 设备信息 */
+	        .device-table {
+	            width: 100%;
+	            border-collapse: collapse;
+	            margin-bottom: 24px;
+	            font-size: 14px;
+	        }
+
+	        .device-table td {
+	            padding: 8px 12px;
+	            border-bottom: 1px solid var(--md-sys-color-surface-variant);
+	            color: var(--md-sys-color-on-surface);
+	        }
+
+	        .device-table td:first-child {
+	            width: 40%;
+	            color: var(--md-sys-color-on-surface-variant);
+	            font-weight: 500;
+	        }
+
 	        /* IO状态卡片容器 */
 	        .io-cards-container {
 	            display: grid;
@@ -428,6 +709,65 @@ func (ui *WebUI) initTemplate() {
 	            color: var(--md-sys-color-on-surface);
 	        }
 
+	        .env-quality {
+	            font-size: 12px;
+	            margin-top: 6px;
+	            min-height: 16px;
+	            color: var(--md-sys-color-on-surface-variant);
+	        }
+
+	        /* 读数质量：坏值、不确定、过期 */
+	        .env-item.quality-bad {
+	            background: var(--md-sys-color-error-container);
+	        }
+
+	        .env-item.quality-bad .env-value,
+	        .env-item.quality-bad .env-quality {
+	            color: var(--md-sys-color-error);
+	        }
+
+	        .env-item.quality-uncertain {
+	            outline: 2px dashed #b26a00;
+	            outline-offset: -2px;
+	        }
+
+	        .env-item.quality-uncertain .env-value,
+	        .env-item.quality-uncertain .env-quality {
+	            color: #b26a00;
+	        }
+
+	        .env-item.quality-stale .env-value {
+	            opacity: 0.45;
+	            font-style: italic;
+	        }
+
+	        /* 趋势图 */
+	        .trend-header {
+	            display: flex;
+	            justify-content: space-between;
+	            align-items: center;
+	            margin-bottom: 16px;
+	        }
+
+	        .trend-header .env-title {
+	            margin: 0;
+	        }
+
+	        .trend-header .station-select {
+	            margin-top: 0;
+	        }
+
+	        .trend-chart {
+	            margin-bottom: 16px;
+	        }
+
+	        .trend-chart canvas {
+	            width: 100%;
+	            height: 160px;
+	            background: var(--md-sys-color-surface-variant);
+	            border-radius: 12px;
+	        }
+
 	        /* 手动控制卡片 */
 	        .manual-card {
 	            background: var(--md-sys-color-surface);
@@ -516,8 +856,34 @@ func (ui *WebUI) initTemplate() {
         <!-- 应用标题栏 -->
         <div class="app-bar">
             <h1 class="app-title">S7-1200 跑马灯控制程序</h1>
+            {{if gt (len .Stations) 1}}
+            <div style="text-align: center;">
+                <select class="station-select" id="stationSelect" onchange="selectStation(this.value)">
+                    {{range .Stations}}
+                    <option value="{{.}}" {{if eq . $.Station}}selected{{end}}>{{.}}</option>
+                    {{end}}
+                </select>
+            </div>
+            {{end}}
+        </div>
+
+        <!-- 报警横幅 -->
+        <div class="alarm-banner" id="alarmBanner" style="display: none;">
+            <div class="alarm-summary">
+                <span id="alarmSummary"></span>
+                <button class="alarm-action" onclick="ackAlarm('')">全部确认</button>
+            </div>
+            <div id="alarmList"></div>
         </div>
 
+        {{if gt (len .Stations) 1}}
+        <!-- 站点总览 -->
+        <div class="control-section">
+            <h2 class="control-title">站点总览</h2>
+            <div class="station-grid" id="stationGrid"></div>
+        </div>
+        {{end}}
+
         <!-- 状态卡片组 -->
         <div class="status-cards">
             <div class="status-card">
@@ -525,6 +891,7 @@ func (ui *WebUI) initTemplate() {
                 <div class="status-value {{if eq .ConnectionStatus "已连接"}}connected{{else if eq .ConnectionStatus "连接失败"}}error{{else}}stopped{{end}}" id="connectionStatus">
                     {{.ConnectionStatus}}
                 </div>
+                <div class="status-label" id="connectionDetail" style="margin: 8px 0 0 0; text-transform: none;"></div>
             </div>
             <div class="status-card">
                 <div class="status-label">运行状态</div>
@@ -540,10 +907,18 @@ func (ui *WebUI) initTemplate() {
                 <div class="status-label">延时值</div>
                 <div class="status-value" id="delayValue">{{.DelayValue}}ms</div>
             </div>
+            <div class="status-card">
+                <div class="status-label">步进抖动 (p99)</div>
+                <div class="status-value" id="stepJitter">-</div>
+            </div>
             <div class="status-card">
                 <div class="status-label">当前输出点</div>
                 <div class="status-value" id="currentOutput">{{.CurrentOutput}}</div>
             </div>
+            <div class="status-card">
+                <div class="status-label">通信错误</div>
+                <div class="status-value" id="modbusError">无</div>
+            </div>
         </div>
 
         <!-- 控制按钮区域 -->
@@ -553,6 +928,46 @@ func (ui *WebUI) initTemplate() {
                 <button class="md-button filled" onclick="startMarquee()">启动</button>
                 <button class="md-button outlined" onclick="stopMarquee()">停止</button>
                 <button class="md-button outlined" onclick="switchSpeed()">速度切换</button>
+                <select class="station-select" id="speedSelect" title="调速" onchange="setSpeed(this.value)">
+                    {{range $i, $delay := .Speed.Levels}}<option value="{{add $i 1}}">{{add $i 1}}挡 ({{$delay}}ms)</option>{{end}}
+                    <option value="delay" hidden>设定延时</option>
+                    {{if .Speed.AnalogInput}}<option value="analog">模拟量调速</option>{{end}}
+                </select>
+                <input class="station-select" type="number" id="delayInput" min="10" max="60000" step="10" placeholder="延时 (ms)" title="延时 (ms)">
+                <button class="md-button outlined" onclick="setDelay()">设定延时</button>
+                <select class="station-select" id="patternSelect" title="花样" onchange="setPattern(this.value)">
+                    {{range .Patterns}}<option value="{{.Name}}"{{if eq .Name $.Pattern}} selected{{end}}>{{.Label}}</option>{{end}}
+                </select>
+            </div>
+        </div>
+
+        <!-- 自定义序列 -->
+        <div class="config-card">
+            <h2 class="config-title">自定义序列</h2>
+            <div class="form-grid">
+                <div class="form-field">
+                    <div class="form-label">序列</div>
+                    <select class="form-input" id="sequenceSelect" onchange="loadSequence(this.value)">
+                        <option value="">新建序列</option>
+                    </select>
+                </div>
+                <div class="form-field">
+                    <div class="form-label">名称</div>
+                    <input type="text" class="form-input" id="sequenceName" placeholder="字母、数字、_ 或 -">
+                </div>
+                <div class="form-field">
+                    <div class="form-label">显示名称</div>
+                    <input type="text" class="form-input" id="sequenceLabel" placeholder="默认为名称">
+                </div>
+            </div>
+            <textarea class="form-input sequence-editor" id="sequenceSource" spellcheck="false" oninput="schedulePreview()"
+                placeholder="# 每行一条语句，# 之后为注释&#10;frame *............. 200ms&#10;repeat 3&#10;  frame all 100ms&#10;  frame none 100ms&#10;end"></textarea>
+            <div class="sequence-errors" id="sequenceErrors"></div>
+            <div class="sequence-preview" id="sequencePreview"></div>
+            <div class="form-label" id="sequencePreviewInfo"></div>
+            <div class="button-group">
+                <button class="md-button filled" onclick="saveSequence()">保存序列</button>
+                <button class="md-button outlined" onclick="deleteSequence()">删除序列</button>
             </div>
         </div>
 
@@ -562,15 +977,15 @@ func (ui *WebUI) initTemplate() {
             <div class="form-grid">
                 <div class="form-field">
                     <div class="form-label">IP 地址</div>
-                    <input type="text" class="form-input" id="ipInput" value="192.168.0.10" placeholder="请输入PLC IP地址">
+                    <input type="text" class="form-input" id="ipInput" value="{{.IP}}" placeholder="请输入PLC IP地址">
                 </div>
                 <div class="form-field">
                     <div class="form-label">端口号</div>
-                    <input type="text" class="form-input" id="portInput" value="502" placeholder="请输入端口号">
+                    <input type="text" class="form-input" id="portInput" value="{{.Port}}" placeholder="请输入端口号">
                 </div>
                 <div class="form-field">
                     <div class="form-label">Unit ID</div>
-                    <input type="text" class="form-input" id="unitIdInput" value="1" placeholder="请输入设备ID">
+                    <input type="text" class="form-input" id="unitIdInput" value="{{.UnitID}}" placeholder="请输入设备ID">
                 </div>
             </div>
             <div class="button-group">
@@ -580,6 +995,32 @@ func (ui *WebUI) initTemplate() {
             </div>
         </div>
 
+        <!-- 设备信息 -->
+        <div class="config-card">
+            <h2 class="config-title">设备信息</h2>
+            <div class="form-grid">
+                <div class="form-field">
+                    <div class="form-label">厂商</div>
+                    <div class="status-value" id="deviceVendor">-</div>
+                </div>
+                <div class="form-field">
+                    <div class="form-label">产品代码</div>
+                    <div class="status-value" id="deviceProductCode">-</div>
+                </div>
+                <div class="form-field">
+                    <div class="form-label">版本</div>
+                    <div class="status-value" id="deviceRevision">-</div>
+                </div>
+            </div>
+            <table class="device-table" id="deviceObjects"></table>
+            <table class="device-table" id="deviceDiagnostics"></table>
+            <div class="button-group">
+                <button class="md-button filled" onclick="readDeviceInfo()">读取设备标识</button>
+                <button class="md-button outlined" onclick="runDiagnostics()">诊断测试</button>
+                <button class="md-button outlined" onclick="clearDiagnostics()">清除计数器</button>
+            </div>
+        </div>
+
         <!-- IO状态显示区域 -->
         <div class="io-cards-container">
             <!-- 数字输出状态 -->
@@ -590,11 +1031,7 @@ func (ui *WebUI) initTemplate() {
                 </div>
                 <div class="io-grid" id="dqGrid">
                     {{range $i, $status := .DQStatus}}
-                    {{if lt $i 8}}
-                    <div class="io-item {{$status}}">Q0.{{$i}}</div>
-                    {{else}}
-                    <div class="io-item {{$status}}">Q1.{{sub $i 8}}</div>
-                    {{end}}
+                    <div class="io-item {{$status}}">{{index $.DQAddresses $i}}</div>
                     {{end}}
                 </div>
             </div>
@@ -607,11 +1044,7 @@ func (ui *WebUI) initTemplate() {
                 </div>
                 <div class="io-grid" id="diGrid">
                     {{range $i, $status := .DIStatus}}
-                    {{if lt $i 8}}
-                    <div class="io-item {{$status}}">I0.{{$i}}</div>
-                    {{else}}
-                    <div class="io-item {{$status}}">I1.{{sub $i 8}}</div>
-                    {{end}}
+                    <div class="io-item {{$status}}">{{index $.DIAddresses $i}}</div>
                     {{end}}
                 </div>
             </div>
@@ -620,16 +1053,34 @@ func (ui *WebUI) initTemplate() {
         <!-- 环境数据 -->
         <div class="env-card">
             <h2 class="env-title">环境监测</h2>
-            <div class="env-data">
-                <div class="env-item">
-                    <div class="env-label">温度</div>
-                    <div class="env-value" id="temperature">{{.Temperature}}°C</div>
-                </div>
-                <div class="env-item">
-                    <div class="env-label">湿度</div>
-                    <div class="env-value" id="humidity">{{.Humidity}}%</div>
+            <div class="env-data" id="envData">
+                {{range .Analog}}
+                <div class="env-item quality-{{.Quality}}" data-channel="{{.Name}}">
+                    <div class="env-label">{{.Label}}</div>
+                    <div class="env-value">{{.Text}}</div>
+                    <div class="env-quality">{{if ne .Quality "good"}}{{.Quality.Label}} · {{.Reason}}{{end}}</div>
                 </div>
+                {{end}}
+            </div>
+        </div>
+
+        <!-- 趋势图 -->
+        <div class="env-card">
+            <div class="trend-header">
+                <h2 class="env-title">趋势</h2>
+                <select class="station-select" id="trendRange" onchange="updateTrends()">
+                    <option value="3600000">最近1小时</option>
+                    <option value="21600000">最近6小时</option>
+                    <option value="86400000">最近24小时</option>
+                    <option value="604800000">最近7天</option>
+                </select>
+            </div>
+            {{range .Analog}}
+            <div class="trend-chart">
+                <div class="env-label">{{.Label}}</div>
+                <canvas data-tag="{{.Name}}" data-unit="{{.Unit}}"></canvas>
             </div>
+            {{end}}
         </div>
 
         <!-- 手动控制 -->
@@ -642,7 +1093,7 @@ func (ui *WebUI) initTemplate() {
                     <label style="display: flex; align-items: center; cursor: pointer;">
                         <input type="checkbox" class="manual-checkbox" onchange="toggleOutput({{$i}})" {{if eq $status "ON"}}checked{{end}}>
                         <span style="margin-left: 8px; font-weight: 500;">
-                            {{if lt $i 8}}Q0.{{$i}}{{else}}Q1.{{sub $i 8}}{{end}}
+                            {{index $.DQAddresses $i}}
                         </span>
                     </label>
                 </div>
@@ -652,21 +1103,79 @@ func (ui *WebUI) initTemplate() {
     </div>
 
     <script>
+        // 当前站点，所有接口请求通过station参数指定
+        const station = {{.Station}};
+        const multiStation = {{len .Stations}} > 1;
+
+        function api(path) {
+            return path + (path.includes('?') ? '&' : '?') + 'station=' + encodeURIComponent(station);
+        }
+
+        function selectStation(name) {
+            location.search = '?station=' + encodeURIComponent(name);
+        }
+
         // 自动刷新状态
         setInterval(updateStatus, 1000);
+        updateAlarms();
+        setInterval(updateAlarms, 1000);
+        updateTrends();
+        setInterval(updateTrends, 10000);
+        loadSequences();
+        if (multiStation) {
+            updateOverview();
+            setInterval(updateOverview, 1000);
+        }
+
+        function updateOverview() {
+            fetch('/stations')
+                .then(response => response.json())
+                .then(stations => {
+                    const grid = document.getElementById('stationGrid');
+                    grid.innerHTML = '';
+                    stations.forEach(s => {
+                        const tile = document.createElement('div');
+                        tile.className = 'station-tile' + (s.name === station ? ' selected' : '');
+                        tile.onclick = () => selectStation(s.name);
+
+                        const title = document.createElement('div');
+                        title.className = 'status-label';
+                        title.textContent = s.name;
+                        tile.appendChild(title);
+
+                        const conn = document.createElement('div');
+                        conn.className = 'status-value';
+                        conn.textContent = s.connectionStatus;
+                        tile.appendChild(conn);
+                        updateStatusCardClass(conn, s.connectionStatus);
+
+                        const run = document.createElement('div');
+                        run.className = 'status-value';
+                        run.textContent = s.runStatus + (s.speedLevel ? ' · ' + s.speedLevel + '挡' : '');
+                        tile.appendChild(run);
+                        updateStatusCardClass(run, s.runStatus);
+
+                        grid.appendChild(tile);
+                    });
+                })
+                .catch(err => console.error('站点总览更新失败:', err));
+        }
 
         function updateStatus() {
-            fetch('/status')
+            fetch(api('/status'))
                 .then(response => response.json())
                 .then(data => {
                     // 更新状态卡片
                     updateStatusCard('connectionStatus', data.ConnectionStatus);
+                    updateConnectionDetail(data.ConnectionError, data.ReconnectCount);
                     updateStatusCard('runStatus', data.RunStatus);
-                    document.getElementById('speedLevel').textContent = data.SpeedLevel;
+                    updateSpeed(data.SpeedMode, data.SpeedLevel);
+                    updateTiming(data.Timing);
                     document.getElementById('delayValue').textContent = data.DelayValue + 'ms';
                     document.getElementById('currentOutput').textContent = data.CurrentOutput;
-                    document.getElementById('temperature').textContent = data.Temperature + '°C';
-                    document.getElementById('humidity').textContent = data.Humidity + '%';
+                    updatePattern(data.Pattern);
+                    updateAnalog(data.Analog || []);
+                    updateModbusError(data.ModbusError);
 
                     // 更新IO状态
                     updateIOStatus('dqGrid', data.DQStatus);
@@ -676,10 +1185,222 @@ func (ui *WebUI) initTemplate() {
                 .catch(err => console.error('状态更新失败:', err));
         }
 
+        function updateAnalog(readings) {
+            const container = document.getElementById('envData');
+            const items = container.querySelectorAll('.env-item');
+            // 通道数量或顺序变化时重建
+            const rebuild = items.length !== readings.length ||
+                readings.some((r, i) => items[i].dataset.channel !== r.name);
+            if (rebuild) {
+                container.innerHTML = '';
+                readings.forEach(r => {
+                    const item = document.createElement('div');
+                    item.className = 'env-item';
+                    item.dataset.channel = r.name;
+                    const label = document.createElement('div');
+                    label.className = 'env-label';
+                    label.textContent = r.label;
+                    const value = document.createElement('div');
+                    value.className = 'env-value';
+                    const quality = document.createElement('div');
+                    quality.className = 'env-quality';
+                    item.appendChild(label);
+                    item.appendChild(value);
+                    item.appendChild(quality);
+                    container.appendChild(item);
+                });
+            }
+            container.querySelectorAll('.env-item').forEach((item, i) => {
+                const r = readings[i];
+                item.className = 'env-item quality-' + r.quality;
+                const value = item.querySelector('.env-value');
+                value.textContent = r.text;
+                value.title = r.timestamp ? '原始值: ' + r.raw + ' | 采样时间: ' + new Date(r.timestamp).toLocaleString() : '';
+                item.querySelector('.env-quality').textContent =
+                    r.quality === 'good' ? '' : (qualityLabels[r.quality] || r.quality) + ' · ' + r.reason;
+            });
+        }
+
+        const qualityLabels = { good: '正常', uncertain: '不确定', bad: '坏值', stale: '过期' };
+
+        const alarmStateLabels = { 'active-unacked': '未确认', 'active-acked': '已确认', 'cleared-unacked': '已恢复未确认' };
+        const alarmPriorityLabels = { 1: '紧急', 2: '高', 3: '中', 4: '低' };
+
+        function updateAlarms() {
+            fetch(api('/alarms'))
+                .then(response => response.json())
+                .then(data => {
+                    const banner = document.getElementById('alarmBanner');
+                    if (data.alarms.length === 0) {
+                        banner.style.display = 'none';
+                        return;
+                    }
+                    banner.style.display = '';
+
+                    const shelved = data.alarms.filter(a => a.shelvedUntil).length;
+                    const unackedShown = data.alarms.some(a => !a.shelvedUntil && a.state !== 'active-acked');
+                    banner.classList.toggle('unacked', unackedShown);
+                    document.getElementById('alarmSummary').textContent =
+                        '报警：越限 ' + data.active + ' 条，未确认 ' + data.unacked + ' 条' + (shelved ? '，搁置 ' + shelved + ' 条' : '');
+
+                    const list = document.getElementById('alarmList');
+                    list.innerHTML = '';
+                    data.alarms.forEach(a => {
+                        const row = document.createElement('div');
+                        row.className = 'alarm-row ' + a.state + (a.shelvedUntil ? ' shelved' : '');
+
+                        const priority = document.createElement('span');
+                        priority.className = 'alarm-priority p' + a.priority;
+                        priority.textContent = alarmPriorityLabels[a.priority] || a.priority;
+                        row.appendChild(priority);
+
+                        const message = document.createElement('span');
+                        message.className = 'alarm-message';
+                        message.textContent = a.message + '  当前值 ' + a.value.toFixed(1) + a.unit + ' / 限值 ' + a.limit + a.unit;
+                        row.appendChild(message);
+
+                        const state = document.createElement('span');
+                        if (a.shelvedUntil) {
+                            state.textContent = '搁置至 ' + new Date(a.shelvedUntil).toLocaleTimeString();
+                        } else {
+                            state.textContent = (alarmStateLabels[a.state] || a.state) +
+                                (a.activatedAt ? ' · ' + new Date(a.activatedAt).toLocaleTimeString() : '');
+                        }
+                        row.appendChild(state);
+
+                        if (a.state !== 'active-acked' && !a.shelvedUntil) {
+                            row.appendChild(alarmButton('确认', () => ackAlarm(a.id)));
+                        }
+                        if (a.shelvedUntil) {
+                            row.appendChild(alarmButton('取消搁置', () => shelveAlarm(a.id, 0)));
+                        } else {
+                            row.appendChild(alarmButton('搁置1小时', () => shelveAlarm(a.id, 60)));
+                        }
+                        list.appendChild(row);
+                    });
+                })
+                .catch(err => console.error('报警更新失败:', err));
+        }
+
+        function updateTrends() {
+            const range = parseInt(document.getElementById('trendRange').value);
+            const to = Date.now();
+            const from = to - range;
+            document.querySelectorAll('.trend-chart canvas').forEach(canvas => {
+                const url = '/history?tag=' + encodeURIComponent(canvas.dataset.tag) +
+                    '&from=' + from + '&to=' + to + '&aggregate=avg&points=300';
+                fetch(api(url))
+                    .then(response => response.json())
+                    .then(data => {
+                        if (data.error) {
+                            drawTrend(canvas, [], from, to, 0, data.error);
+                            return;
+                        }
+                        drawTrend(canvas, data.points, from, to, data.stepMs, '');
+                    })
+                    .catch(err => console.error('趋势更新失败:', err));
+            });
+        }
+
+        // drawTrend 绘制平均值曲线及最小/最大值区间，相邻点间隔超过3个step时断开曲线
+        function drawTrend(canvas, points, from, to, stepMs, message) {
+            const ratio = window.devicePixelRatio || 1;
+            const width = canvas.clientWidth, height = canvas.clientHeight;
+            canvas.width = width * ratio;
+            canvas.height = height * ratio;
+            const ctx = canvas.getContext('2d');
+            ctx.scale(ratio, ratio);
+            ctx.clearRect(0, 0, width, height);
+            ctx.font = '12px sans-serif';
+            ctx.fillStyle = '#41484d';
+
+            if (points.length === 0) {
+                ctx.fillText(message || '暂无数据', 12, height / 2);
+                return;
+            }
+
+            let low = Math.min(...points.map(p => p.min ?? p.v));
+            let high = Math.max(...points.map(p => p.max ?? p.v));
+            if (high - low < 1e-6) {
+                low -= 1;
+                high += 1;
+            }
+            const left = 56, right = 12, top = 12, bottom = 22;
+            const x = t => left + (new Date(t).getTime() - from) / (to - from) * (width - left - right);
+            const y = v => top + (high - v) / (high - low) * (height - top - bottom);
+
+            // 坐标轴标注
+            const unit = canvas.dataset.unit;
+            ctx.fillText(high.toFixed(1) + unit, 4, top + 8);
+            ctx.fillText(low.toFixed(1) + unit, 4, height - bottom);
+            ctx.fillText(new Date(from).toLocaleString(), left, height - 6);
+            const end = new Date(to).toLocaleString();
+            ctx.fillText(end, width - right - ctx.measureText(end).width, height - 6);
+
+            // 最小/最大值区间
+            ctx.fillStyle = 'rgba(25, 118, 210, 0.15)';
+            ctx.beginPath();
+            points.forEach((p, i) => (i === 0 ? ctx.moveTo : ctx.lineTo).call(ctx, x(p.t), y(p.max ?? p.v)));
+            points.slice().reverse().forEach(p => ctx.lineTo(x(p.t), y(p.min ?? p.v)));
+            ctx.closePath();
+            ctx.fill();
+
+            // 平均值曲线
+            ctx.strokeStyle = '#1976d2';
+            ctx.lineWidth = 1.5;
+            ctx.beginPath();
+            points.forEach((p, i) => {
+                const gap = i > 0 && new Date(p.t) - new Date(points[i - 1].t) > 3 * stepMs;
+                (i === 0 || gap ? ctx.moveTo : ctx.lineTo).call(ctx, x(p.t), y(p.v));
+            });
+            ctx.stroke();
+        }
+
+        function alarmButton(text, onClick) {
+            const button = document.createElement('button');
+            button.className = 'alarm-action';
+            button.textContent = text;
+            button.onclick = onClick;
+            return button;
+        }
+
+        function ackAlarm(id) {
+            fetch(api('/alarms/ack'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ id: id })
+            })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                    }
+                    updateAlarms();
+                });
+        }
+
+        function shelveAlarm(id, minutes) {
+            fetch(api('/alarms/shelve'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ id: id, minutes: minutes })
+            })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                    }
+                    updateAlarms();
+                });
+        }
+
         function updateStatusCard(elementId, status) {
             const element = document.getElementById(elementId);
             element.textContent = status;
+            updateStatusCardClass(element, status);
+        }
 
+        function updateStatusCardClass(element, status) {
             // 移除所有状态类
             element.className = element.className.replace(/\b(connected|running|stopped|error)\b/g, '');
 
@@ -688,15 +1409,38 @@ func (ui *WebUI) initTemplate() {
                 element.classList.add('connected');
             } else if (status === '运行中') {
                 element.classList.add('running');
-            } else if (status === '停止' || status === '未连接' || status === '连接失败') {
+            } else if (status === '停止' || status === '未连接' || status === '连接失败' || status === '连接中' || status === '等待重连') {
                 element.classList.add('stopped');
             }
         }
 
-        function updateManualControlCheckboxes(statusArray) {
-            const grid = document.getElementById('manualGrid');
-            const checkboxes = grid.querySelectorAll('input[type="checkbox"]');
-            checkboxes.forEach((checkbox, index) => {
+        function updateConnectionDetail(connectionError, reconnectCount) {
+            let detail = '重连次数: ' + reconnectCount;
+            if (connectionError) {
+                detail += ' | ' + connectionError;
+            }
+            document.getElementById('connectionDetail').textContent = detail;
+        }
+
+        function updateModbusError(modbusError) {
+            const element = document.getElementById('modbusError');
+            if (!modbusError) {
+                element.textContent = '无';
+                element.classList.remove('stopped');
+                return;
+            }
+            if (modbusError.exceptionCode) {
+                element.textContent = '异常 0x' + modbusError.exceptionCode.toString(16).padStart(2, '0').toUpperCase() + ' ' + modbusError.exceptionName;
+            } else {
+                element.textContent = modbusError.message;
+            }
+            element.classList.add('stopped');
+        }
+
+        function updateManualControlCheckboxes(statusArray) {
+            const grid = document.getElementById('manualGrid');
+            const checkboxes = grid.querySelectorAll('input[type="checkbox"]');
+            checkboxes.forEach((checkbox, index) => {
                 checkbox.checked = (statusArray[index] === 'ON');
             });
         }
@@ -717,7 +1461,7 @@ func (ui *WebUI) initTemplate() {
             const port = document.getElementById('portInput').value;
             const unitId = document.getElementById('unitIdInput').value;
 
-            fetch('/connect', {
+            fetch(api('/connect'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ ip, port, unitId })
@@ -730,7 +1474,7 @@ func (ui *WebUI) initTemplate() {
         }
 
         function disconnectPLC() {
-            fetch('/disconnect', { method: 'POST' })
+            fetch(api('/disconnect'), { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     alert(data.message);
@@ -743,7 +1487,7 @@ func (ui *WebUI) initTemplate() {
             const port = document.getElementById('portInput').value;
             const unitId = document.getElementById('unitIdInput').value;
 
-            fetch('/save-config', {
+            fetch(api('/save-config'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ ip, port, unitId })
@@ -762,7 +1506,7 @@ func (ui *WebUI) initTemplate() {
         }
 
         function startMarquee() {
-            fetch('/start', { method: 'POST' })
+            fetch(api('/start'), { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     alert(data.message);
@@ -771,7 +1515,7 @@ func (ui *WebUI) initTemplate() {
         }
 
         function stopMarquee() {
-            fetch('/stop', { method: 'POST' })
+            fetch(api('/stop'), { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     alert(data.message);
@@ -780,7 +1524,7 @@ func (ui *WebUI) initTemplate() {
         }
 
         function switchSpeed() {
-            fetch('/switch-speed', { method: 'POST' })
+            fetch(api('/switch-speed'), { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     alert(data.message);
@@ -788,11 +1532,239 @@ func (ui *WebUI) initTemplate() {
                 });
         }
 
+        // 调速方式的显示名称，按挡位调速时显示挡位
+        const speedModeLabels = { delay: '设定延时', analog: '模拟量' };
+
+        function updateSpeed(mode, level) {
+            document.getElementById('speedLevel').textContent = speedModeLabels[mode] || level;
+            const select = document.getElementById('speedSelect');
+            if (document.activeElement !== select && mode) {
+                select.value = mode === 'level' ? String(level) : mode;
+            }
+        }
+
+        function updateTiming(timing) {
+            const element = document.getElementById('stepJitter');
+            if (!timing || !timing.steps) {
+                element.textContent = '-';
+                element.title = '';
+                return;
+            }
+            element.textContent = timing.jitter.p99Ms.toFixed(1) + 'ms';
+            element.title = '步数 ' + timing.steps +
+                '\n抖动 最小/平均/p99/最大: ' + [timing.jitter.minMs, timing.jitter.avgMs, timing.jitter.p99Ms, timing.jitter.maxMs].map(v => v.toFixed(2)).join(' / ') + ' ms' +
+                '\n写入 最小/平均/p99/最大: ' + [timing.writeLatency.minMs, timing.writeLatency.avgMs, timing.writeLatency.p99Ms, timing.writeLatency.maxMs].map(v => v.toFixed(2)).join(' / ') + ' ms' +
+                '\n超时 ' + timing.overruns + ' 次，重新对齐 ' + timing.resyncs + ' 次，写入失败 ' + timing.writeErrors + ' 次';
+        }
+
+        function postSpeed(body) {
+            fetch(api('/speed'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+            })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                    }
+                    updateStatus();
+                });
+        }
+
+        function setSpeed(value) {
+            postSpeed(value === 'analog' ? { mode: 'analog' } : { level: parseInt(value, 10) });
+        }
+
+        function setDelay() {
+            const delay = parseInt(document.getElementById('delayInput').value, 10);
+            if (!delay) {
+                alert('请输入延时');
+                return;
+            }
+            postSpeed({ delayMs: delay });
+        }
+
+        function setPattern(name) {
+            fetch(api('/pattern'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ pattern: name })
+            })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                    }
+                    updateStatus();
+                });
+        }
+
+        // refreshPatterns 重新加载花样选择框，自定义序列保存或删除后调用
+        function refreshPatterns() {
+            fetch(api('/pattern'))
+                .then(response => response.json())
+                .then(data => {
+                    const select = document.getElementById('patternSelect');
+                    select.innerHTML = '';
+                    data.patterns.forEach(p => select.add(new Option(p.label, p.name)));
+                    select.value = data.pattern;
+                });
+        }
+
+        let sequences = [];
+
+        function loadSequences(selected) {
+            fetch(api('/sequences'))
+                .then(response => response.json())
+                .then(data => {
+                    sequences = data.sequences || [];
+                    const select = document.getElementById('sequenceSelect');
+                    select.innerHTML = '';
+                    select.add(new Option('新建序列', ''));
+                    sequences.forEach(seq => select.add(new Option(seq.label || seq.name, seq.name)));
+                    select.value = selected || '';
+                });
+        }
+
+        function loadSequence(name) {
+            const seq = sequences.find(s => s.name === name) || { name: '', label: '', lines: [] };
+            document.getElementById('sequenceName').value = seq.name;
+            document.getElementById('sequenceLabel').value = seq.label || '';
+            document.getElementById('sequenceSource').value = seq.lines.join('\n');
+            previewSequence();
+        }
+
+        function sequenceLines() {
+            return document.getElementById('sequenceSource').value.split('\n');
+        }
+
+        function showSequenceErrors(data) {
+            const errors = data.errors && data.errors.length
+                ? data.errors.map(e => (e.line ? '第' + e.line + '行: ' : '') + e.message).join('\n')
+                : (data.error || '');
+            document.getElementById('sequenceErrors').textContent = errors;
+        }
+
+        // 输入停顿后再预览，避免每次按键都请求
+        let previewTimer = null;
+        function schedulePreview() {
+            clearTimeout(previewTimer);
+            previewTimer = setTimeout(previewSequence, 400);
+        }
+
+        let previewAnimation = null;
+        function previewSequence() {
+            const lines = sequenceLines();
+            if (lines.every(line => line.trim() === '')) {
+                clearTimeout(previewAnimation);
+                showSequenceErrors({});
+                document.getElementById('sequencePreview').innerHTML = '';
+                document.getElementById('sequencePreviewInfo').textContent = '';
+                return;
+            }
+            fetch(api('/sequences/preview'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ lines: lines })
+            })
+                .then(response => response.json())
+                .then(data => {
+                    showSequenceErrors(data);
+                    if (data.error) {
+                        return;
+                    }
+                    playPreview(data);
+                })
+                .catch(err => console.error('序列预览失败:', err));
+        }
+
+        // playPreview 按各帧时长循环播放预览，省略时长的帧按当前速度挡位的延时
+        function playPreview(data) {
+            clearTimeout(previewAnimation);
+            const container = document.getElementById('sequencePreview');
+            container.innerHTML = '';
+            const lamps = data.outputs.map(address => {
+                const lamp = document.createElement('div');
+                lamp.className = 'sequence-lamp';
+                lamp.textContent = address;
+                container.appendChild(lamp);
+                return lamp;
+            });
+
+            const info = document.getElementById('sequencePreviewInfo');
+            const total = data.frames.reduce((sum, f) => sum + (f.durationMs || data.delayMs), 0);
+            info.textContent = data.frames.length + ' 帧，' + (data.complete ? '一个周期 ' : '前 ') + (total / 1000).toFixed(1) + ' 秒';
+
+            let index = 0;
+            const step = () => {
+                const frame = data.frames[index];
+                frame.lamps.forEach((on, i) => lamps[i].classList.toggle('on', on));
+                info.textContent = info.textContent.replace(/ · 第\d+行$/, '') + ' · 第' + frame.line + '行';
+                index = (index + 1) % data.frames.length;
+                previewAnimation = setTimeout(step, frame.durationMs || data.delayMs);
+            };
+            step();
+        }
+
+        function saveSequence() {
+            const name = document.getElementById('sequenceName').value.trim();
+            fetch(api('/sequences'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    name: name,
+                    label: document.getElementById('sequenceLabel').value.trim(),
+                    lines: sequenceLines()
+                })
+            })
+                .then(response => response.json())
+                .then(data => {
+                    showSequenceErrors(data);
+                    if (data.error) {
+                        return;
+                    }
+                    alert(data.message);
+                    loadSequences(name);
+                    refreshPatterns();
+                });
+        }
+
+        function deleteSequence() {
+            const name = document.getElementById('sequenceSelect').value;
+            if (!name || !confirm('删除序列 ' + name + '？')) {
+                return;
+            }
+            fetch(api('/sequences/delete'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name: name })
+            })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                        return;
+                    }
+                    loadSequences();
+                    loadSequence('');
+                    refreshPatterns();
+                });
+        }
+
+        // updatePattern 同步花样选择（DI按钮也可切换花样），正在选择时不覆盖
+        function updatePattern(name) {
+            const select = document.getElementById('patternSelect');
+            if (name && document.activeElement !== select) {
+                select.value = name;
+            }
+        }
+
         function toggleOutput(index) {
             const checkbox = event.target;
             const status = checkbox.checked; // true = ON, false = OFF
 
-            fetch('/toggle-output', {
+            fetch(api('/toggle-output'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ index, status })
@@ -815,6 +1787,70 @@ func (ui *WebUI) initTemplate() {
             });
         }
 
+        function fillDeviceTable(tableId, rows) {
+            const table = document.getElementById(tableId);
+            table.innerHTML = '';
+            rows.forEach(([label, value]) => {
+                const row = table.insertRow();
+                row.insertCell().textContent = label;
+                row.insertCell().textContent = value;
+            });
+        }
+
+        function readDeviceInfo() {
+            fetch(api('/device-info'))
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                        return;
+                    }
+                    document.getElementById('deviceVendor').textContent = data.vendorName || '-';
+                    document.getElementById('deviceProductCode').textContent = data.productCode || '-';
+                    document.getElementById('deviceRevision').textContent = data.revision || '-';
+                    const rows = [['一致性等级', '0x' + data.conformityLevel.toString(16).padStart(2, '0').toUpperCase()]];
+                    data.objects.forEach(obj => rows.push([obj.name, obj.value]));
+                    fillDeviceTable('deviceObjects', rows);
+                })
+                .catch(err => alert('读取设备标识失败: ' + err.message));
+        }
+
+        function runDiagnostics() {
+            fetch(api('/diagnostics'))
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                        return;
+                    }
+                    const rows = [['回送测试', data.echo]];
+                    if (data.counters) {
+                        const names = {
+                            busMessages: '总线报文', busCommErrors: '通信错误', busExceptions: '异常响应',
+                            serverMessages: '本站报文', serverNoResponses: '未响应', serverNAKs: 'NAK',
+                            serverBusy: '忙', busCharacterOverrun: '字符溢出'
+                        };
+                        Object.keys(names).forEach(key => rows.push([names[key], data.counters[key]]));
+                    } else {
+                        rows.push(['计数器', data.countersError]);
+                    }
+                    fillDeviceTable('deviceDiagnostics', rows);
+                })
+                .catch(err => alert('诊断测试失败: ' + err.message));
+        }
+
+        function clearDiagnostics() {
+            fetch(api('/diagnostics/clear'), { method: 'POST' })
+                .then(response => response.json())
+                .then(data => {
+                    if (data.error) {
+                        alert(data.error);
+                        return;
+                    }
+                    runDiagnostics();
+                });
+        }
+
         function refreshDQStatus() {
             updateStatus();
         }
@@ -834,13 +1870,29 @@ func (ui *WebUI) startServer() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", ui.handleIndex)
 	mux.HandleFunc("/status", ui.handleStatus)
+	mux.HandleFunc("/stations", ui.handleStations)
 	mux.HandleFunc("/connect", ui.handleConnect)
 	mux.HandleFunc("/disconnect", ui.handleDisconnect)
 	mux.HandleFunc("/start", ui.handleStart)
 	mux.HandleFunc("/stop", ui.handleStop)
 	mux.HandleFunc("/switch-speed", ui.handleSwitchSpeed)
+	mux.HandleFunc("/speed", ui.handleSpeed)
+	mux.HandleFunc("/pattern", ui.handlePattern)
+	mux.HandleFunc("/sequences", ui.handleSequences)
+	mux.HandleFunc("/sequences/preview", ui.handlePreviewSequence)
+	mux.HandleFunc("/sequences/delete", ui.handleDeleteSequence)
 	mux.HandleFunc("/toggle-output", ui.handleToggleOutput)
 	mux.HandleFunc("/save-config", ui.handleSaveConfig)
+	mux.HandleFunc("/device-info", ui.handleDeviceInfo)
+	mux.HandleFunc("/diagnostics", ui.handleDiagnostics)
+	mux.HandleFunc("/diagnostics/clear", ui.handleClearDiagnostics)
+	mux.HandleFunc("/tags", ui.handleTags)
+	mux.HandleFunc("/tags/write", ui.handleWriteTag)
+	mux.HandleFunc("/alarms", ui.handleAlarms)
+	mux.HandleFunc("/alarms/ack", ui.handleAckAlarm)
+	mux.HandleFunc("/alarms/shelve", ui.handleShelveAlarm)
+	mux.HandleFunc("/history", ui.handleHistory)
+	mux.HandleFunc("/export", ui.handleExport)
 
 	ui.server = &http.Server{
 		Addr:    ":8080",
@@ -868,8 +1920,13 @@ func (ui *WebUI) Run() {
 
 // handleIndex 处理主页请求
 func (ui *WebUI) handleIndex(w http.ResponseWriter, r *http.Request) {
-	ui.mu.RLock()
-	defer ui.mu.RUnlock()
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	view.mu.RLock()
+	defer view.mu.RUnlock()
 
 	data := struct {
 		ConnectionStatus string
@@ -877,20 +1934,45 @@ func (ui *WebUI) handleIndex(w http.ResponseWriter, r *http.Request) {
 		SpeedLevel       int
 		DelayValue       int
 		CurrentOutput    string
-		DQStatus         [14]string
-		DIStatus         [14]string
-		Temperature      float64
-		Humidity         float64
+		Pattern          string
+		Patterns         []PatternInfo
+		Speed            SpeedState
+		DQStatus         []string
+		DIStatus         []string
+		DQAddresses      []string
+		DIAddresses      []string
+		Analog           []AnalogReading
+		IP               string
+		Port             int
+		UnitID           int
+		Station          string
+		Stations         []string
 	}{
-		ConnectionStatus: ui.connectionStatus,
-		RunStatus:        ui.runStatus,
-		SpeedLevel:       ui.speedLevel,
-		DelayValue:       ui.delayValue,
-		CurrentOutput:    ui.currentOutput,
-		DQStatus:         ui.dqStatus,
-		DIStatus:         ui.diStatus,
-		Temperature:      ui.temperature,
-		Humidity:         ui.humidity,
+		ConnectionStatus: view.connectionStatus,
+		RunStatus:        view.runStatus,
+		SpeedLevel:       view.speedLevel,
+		DelayValue:       view.delayValue,
+		CurrentOutput:    view.currentOutput,
+		Pattern:          view.pattern,
+		DQStatus:         view.dqStatus,
+		DIStatus:         view.diStatus,
+		DQAddresses:      view.dqAddresses,
+		DIAddresses:      view.diAddresses,
+		Analog:           view.analog,
+		IP:               "192.168.0.10",
+		Port:             502,
+		UnitID:           1,
+		Station:          view.name,
+	}
+	for _, station := range ui.stations {
+		data.Stations = append(data.Stations, station.name)
+	}
+	if view.marqueeController != nil {
+		data.Patterns = view.marqueeController.Patterns()
+		data.Speed = view.marqueeController.SpeedState()
+	}
+	if view.config != nil {
+		data.IP, data.Port, data.UnitID = view.config.Connection()
 	}
 
 	ui.template.Execute(w, data)
@@ -898,43 +1980,74 @@ func (ui *WebUI) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 // handleStatus 处理状态请求
 func (ui *WebUI) handleStatus(w http.ResponseWriter, r *http.Request) {
-	ui.mu.RLock()
-	defer ui.mu.RUnlock()
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	view.mu.RLock()
+	defer view.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 
 	// 构建DQ状态数组
-	dqArray := make([]string, 14)
-	for i, status := range ui.dqStatus {
+	dqArray := make([]string, len(view.dqStatus))
+	for i, status := range view.dqStatus {
 		dqArray[i] = fmt.Sprintf(`"%s"`, status)
 	}
 
 	// 构建DI状态数组
-	diArray := make([]string, 14)
-	for i, status := range ui.diStatus {
+	diArray := make([]string, len(view.diStatus))
+	for i, status := range view.diStatus {
 		diArray[i] = fmt.Sprintf(`"%s"`, status)
 	}
 
+	// 构建通信错误信息
+	modbusError, _ := json.Marshal(newModbusErrorInfo(view.modbusError))
+	connectionError := ""
+	if view.connectionError != nil {
+		connectionError = view.connectionError.Error()
+	}
+	connectionErrorJSON, _ := json.Marshal(connectionError)
+	analog, _ := json.Marshal(view.analog)
+
+	// 步进时序统计，用于证明跑马灯的周期精度
+	var timing TimingStats
+	if view.marqueeController != nil {
+		timing = view.marqueeController.TimingStats()
+	}
+	timingJSON, _ := json.Marshal(timing)
+
 	fmt.Fprintf(w, `{
 		"ConnectionStatus": "%s",
 		"RunStatus": "%s",
 		"SpeedLevel": %d,
+		"SpeedMode": "%s",
 		"DelayValue": %d,
 		"CurrentOutput": "%s",
+		"Pattern": "%s",
 		"DQStatus": [%s],
 		"DIStatus": [%s],
-		"Temperature": %.1f,
-		"Humidity": %.1f
+		"Analog": %s,
+		"ModbusError": %s,
+		"ConnectionError": %s,
+		"ReconnectCount": %d,
+		"Timing": %s
 	}`,
-		ui.connectionStatus,
-		ui.runStatus,
-		ui.speedLevel,
-		ui.delayValue,
-		ui.currentOutput,
+		view.connectionStatus,
+		view.runStatus,
+		view.speedLevel,
+		view.speedMode,
+		view.delayValue,
+		view.currentOutput,
+		view.pattern,
 		strings.Join(dqArray, ","),
 		strings.Join(diArray, ","),
-		ui.temperature,
-		ui.humidity,
+		analog,
+		modbusError,
+		connectionErrorJSON,
+		view.reconnectCount,
+		timingJSON,
 	)
 }
 
@@ -944,6 +2057,10 @@ func (ui *WebUI) handleConnect(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
 
 	var req struct {
 		IP     string `json:"ip"`
@@ -956,39 +2073,61 @@ func (ui *WebUI) handleConnect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 实际调用Modbus客户端连接
-	if ui.modbusClient != nil {
-		if err := ui.modbusClient.Connect(); err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			fmt.Fprintf(w, `{"message": "连接失败: %s"}`, err.Error())
-			return
+	// 先应用界面输入的连接参数，无效的输入保持原值
+	changed := false
+	if view.config != nil {
+		ip, port, unitID := view.config.Connection()
+
+		// 转换端口号
+		if p, err := strconv.Atoi(req.Port); err == nil && p > 0 && p <= 65535 {
+			port = p
 		}
 
-		// 连接成功后保存配置
-		if ui.config != nil {
-			// 转换端口号
-			port, err := strconv.Atoi(req.Port)
-			if err == nil && port > 0 && port <= 65535 {
-				ui.config.Port = port
-			}
+		// 转换Unit ID
+		if id, err := strconv.Atoi(req.UnitID); err == nil && id >= 0 && id <= 255 {
+			unitID = id
+		}
 
-			// 转换Unit ID
-			unitID, err := strconv.Atoi(req.UnitID)
-			if err == nil && unitID >= 0 && unitID <= 255 {
-				ui.config.UnitID = unitID
-			}
+		if req.IP != "" {
+			ip = req.IP
+		}
+		changed = view.config.SetConnection(ip, port, unitID)
+	}
 
-			ui.config.IP = req.IP
-			if err := ui.config.SaveConfig(); err != nil {
-				// 保存配置失败不影响连接，但记录日志
-				log.Printf("保存配置失败: %v", err)
-			}
+	// 通过连接监督器连接，失败后自动按退避策略重试
+	// 连接参数有变化时断开现有连接，按新参数重新连接
+	if view.supervisor != nil {
+		connect := view.supervisor.Connect
+		if changed {
+			connect = view.supervisor.Reconnect
 		}
+		if err := connect(); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				Message string `json:"message"`
+			}{"连接失败，将自动重试: " + err.Error()})
+			return
+		}
+	} else if view.modbusClient != nil {
+		if err := view.modbusClient.Connect(); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				Message string `json:"message"`
+			}{"连接失败: " + err.Error()})
+			return
+		}
+		view.mu.Lock()
+		view.connectionStatus = "已连接"
+		view.mu.Unlock()
 	}
 
-	ui.mu.Lock()
-	ui.connectionStatus = "已连接"
-	ui.mu.Unlock()
+	// 连接成功后保存配置
+	if view.config != nil {
+		if err := view.config.SaveConfig(); err != nil {
+			// 保存配置失败不影响连接，但记录日志
+			log.Printf("保存配置失败: %v", err)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "连接成功"}`)
@@ -1000,15 +2139,20 @@ func (ui *WebUI) handleDisconnect(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	// 实际调用Modbus客户端断开
-	if ui.modbusClient != nil {
-		ui.modbusClient.Close()
+	view := ui.station(w, r)
+	if view == nil {
+		return
 	}
 
-	ui.mu.Lock()
-	ui.connectionStatus = "未连接"
-	ui.mu.Unlock()
+	// 实际调用Modbus客户端断开，同时停止自动重连
+	if view.supervisor != nil {
+		view.supervisor.Disconnect()
+	} else if view.modbusClient != nil {
+		view.modbusClient.Close()
+		view.mu.Lock()
+		view.connectionStatus = "未连接"
+		view.mu.Unlock()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "断开连接成功"}`)
@@ -1020,15 +2164,19 @@ func (ui *WebUI) handleStart(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
 
 	// 实际调用跑马灯控制器启动
-	if ui.marqueeController != nil {
-		ui.marqueeController.Start()
+	if view.marqueeController != nil {
+		view.marqueeController.Start()
 	}
 
-	ui.mu.Lock()
-	ui.runStatus = "运行中"
-	ui.mu.Unlock()
+	view.mu.Lock()
+	view.runStatus = "运行中"
+	view.mu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "启动成功"}`)
@@ -1040,15 +2188,19 @@ func (ui *WebUI) handleStop(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
 
 	// 实际调用跑马灯控制器停止
-	if ui.marqueeController != nil {
-		ui.marqueeController.Stop()
+	if view.marqueeController != nil {
+		view.marqueeController.Stop()
 	}
 
-	ui.mu.Lock()
-	ui.runStatus = "停止"
-	ui.mu.Unlock()
+	view.mu.Lock()
+	view.runStatus = "停止"
+	view.mu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "停止成功"}`)
@@ -1060,29 +2212,268 @@ func (ui *WebUI) handleSwitchSpeed(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
 
 	// 实际调用跑马灯控制器切换速度
-	if ui.marqueeController != nil {
-		ui.marqueeController.SwitchSpeed()
+	if view.marqueeController != nil {
+		view.marqueeController.SwitchSpeed()
+		view.UpdateSpeedLevel(view.marqueeController.GetSpeedLevel())
+		view.UpdateSpeedMode(view.marqueeController.SpeedState().Mode)
 	}
 
-	ui.mu.Lock()
-	ui.speedLevel = (ui.speedLevel % 3) + 1
-	ui.mu.Unlock()
-
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "速度切换成功"}`)
 }
 
+// handleSpeed 处理调速请求：GET返回调速状态，POST按挡位、延时或模拟量调速
+func (ui *WebUI) handleSpeed(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+	if view.marqueeController == nil {
+		http.Error(w, "Marquee not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == "POST" {
+		var req struct {
+			Level   int       `json:"level"`   // 速度挡位
+			DelayMs int       `json:"delayMs"` // 延时（毫秒）
+			Mode    SpeedMode `json:"mode"`    // 为analog时切换为模拟量调速
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		var err error
+		switch {
+		case req.Mode == SpeedByAnalog:
+			err = view.marqueeController.SetAnalogSpeed()
+		case req.DelayMs != 0:
+			err = view.marqueeController.SetDelay(req.DelayMs)
+		default:
+			err = view.marqueeController.SetSpeedLevel(req.Level)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": "调速失败: " + err.Error()})
+			return
+		}
+		state := view.marqueeController.SpeedState()
+		view.UpdateSpeedLevel(state.Level)
+		view.UpdateSpeedMode(state.Mode)
+		json.NewEncoder(w).Encode(map[string]string{"message": "速度已设置"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view.marqueeController.SpeedState())
+}
+
+// handlePattern 处理花样请求：GET返回当前花样与全部花样，POST切换花样并保存配置
+func (ui *WebUI) handlePattern(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+	if view.marqueeController == nil {
+		http.Error(w, "Marquee not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == "POST" {
+		var req struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := view.marqueeController.SetPattern(req.Pattern); err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": "切换花样失败: " + err.Error()})
+			return
+		}
+		view.UpdatePattern(view.marqueeController.Pattern().Name)
+		if view.config != nil {
+			if err := view.config.SaveConfig(); err != nil {
+				log.Printf("保存配置失败: %v", err)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "花样已切换"})
+		return
+	}
+
+	result := struct {
+		Pattern  string        `json:"pattern"`
+		Patterns []PatternInfo `json:"patterns"`
+	}{
+		Pattern:  view.marqueeController.Pattern().Name,
+		Patterns: view.marqueeController.Patterns(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeSequenceError 输出序列错误，编译错误附带逐行的错误列表供编辑器显示
+func writeSequenceError(w http.ResponseWriter, prefix string, err error) {
+	var errs SequenceErrors
+	errors.As(err, &errs)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Error  string          `json:"error"`
+		Errors []SequenceError `json:"errors,omitempty"`
+	}{
+		Error:  prefix + err.Error(),
+		Errors: errs,
+	})
+}
+
+// handleSequences 处理自定义序列请求：GET返回全部序列，POST保存序列（同名时替换）
+func (ui *WebUI) handleSequences(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+	if view.marqueeController == nil || view.config == nil {
+		http.Error(w, "Marquee not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Sequences []SequenceConfig `json:"sequences"`
+		}{view.config.SequenceList()})
+		return
+	}
+
+	var seq SequenceConfig
+	if err := json.NewDecoder(r.Body).Decode(&seq); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := validateSequenceName(seq.Name); err != nil {
+		writeSequenceError(w, "保存序列失败: ", err)
+		return
+	}
+	if _, err := CompileSequence(seq, len(view.marqueeController.lamps)); err != nil {
+		writeSequenceError(w, "序列有误: ", err)
+		return
+	}
+	view.config.SetSequence(seq)
+	if err := view.config.SaveConfig(); err != nil {
+		log.Printf("保存配置失败: %v", err)
+	}
+
+	// 正在播放该序列的站点按新内容重新开始
+	for _, station := range ui.stations {
+		if m := station.marqueeController; m != nil && m.Pattern().Name == seq.Name {
+			if err := m.SetPattern(seq.Name); err != nil {
+				log.Printf("[%s] 重新加载序列失败: %v", station.name, err)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "序列已保存"})
+}
+
+// maxPreviewFrames 序列预览最多展开的帧数
+const maxPreviewFrames = 1000
+
+// handlePreviewSequence 处理序列预览请求，返回展开后的帧，出错时返回逐行的错误列表
+func (ui *WebUI) handlePreviewSequence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+	if view.marqueeController == nil {
+		http.Error(w, "Marquee not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var seq SequenceConfig
+	if err := json.NewDecoder(r.Body).Decode(&seq); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	frames, complete, err := view.marqueeController.PreviewSequence(seq, maxPreviewFrames)
+	if err != nil {
+		writeSequenceError(w, "序列有误: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Outputs  []string        `json:"outputs"`  // 各输出点地址
+		Frames   []SequenceFrame `json:"frames"`   // 展开的帧
+		Complete bool            `json:"complete"` // 是否展开了完整周期
+		DelayMs  int             `json:"delayMs"`  // 未指定时长的帧按此延时预览
+	}{
+		Outputs:  view.marqueeController.OutputAddresses(),
+		Frames:   frames,
+		Complete: complete,
+		DelayMs:  view.marqueeController.GetDelay(),
+	})
+}
+
+// handleDeleteSequence 处理删除自定义序列请求，正在播放该序列的站点切换到默认花样
+func (ui *WebUI) handleDeleteSequence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+	if view.config == nil {
+		http.Error(w, "Config not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !view.config.DeleteSequence(req.Name) {
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("删除序列失败: unknown sequence %q", req.Name)})
+		return
+	}
+	for _, station := range ui.stations {
+		if m := station.marqueeController; m != nil && m.Pattern().Name == req.Name {
+			m.SetPattern(DefaultPattern)
+		}
+	}
+	if err := view.config.SaveConfig(); err != nil {
+		log.Printf("保存配置失败: %v", err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "序列已删除"})
+}
+
 // handleToggleOutput 处理输出状态设置请求
 func (ui *WebUI) handleToggleOutput(w http.ResponseWriter, r *http.Request) {
-	ui.mu.Lock()
-	defer ui.mu.Unlock()
-
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
 
 	var req struct {
 		Index  int  `json:"index"`
@@ -1094,115 +2485,117 @@ func (ui *WebUI) handleToggleOutput(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-		if req.Index < 0 || req.Index >= 14 {
+	if req.Index < 0 || req.Index >= len(view.dqStatus) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, `{"error": "无效的输出索引"}`)
 		return
 	}
 
-	// 实际调用手动控制器设置输出状态
-	if ui.manualController != nil {
-		// 读取当前所有输出点状态
-		resp, err := ui.modbusClient.ReadCoils(0, 14)
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			fmt.Fprintf(w, `{"error": "读取当前状态失败: %s"}`, err.Error())
-			return
-		}
-
-				// 解析当前输出状态
-		currentOutputs := parseCoilsResponse(resp, 14)
-
-						// 设置指定输出点的状态
-		currentOutputs[req.Index] = req.Status
-
-		// 写入所有输出点状态到PLC
-		_, err = ui.modbusClient.WriteMultipleCoils(0, currentOutputs)
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			fmt.Fprintf(w, `{"error": "设置输出状态失败: %s"}`, err.Error())
+	// 通过手动控制器写入，跑马灯运行时拒绝
+	if view.manualController != nil {
+		if err := view.manualController.SetOutput(r.Context(), req.Index, req.Status); err != nil {
+			if errors.Is(err, errManualControlLocked) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"error": "跑马灯运行中，不允许手动控制"}`)
+				return
+			}
+			writeModbusError(w, "设置输出状态失败: ", err)
 			return
 		}
 	}
 
-			// 更新本地状态显示
-	ui.mu.Lock()
+	// 更新本地状态显示
+	view.mu.Lock()
 	if req.Status {
-		ui.dqStatus[req.Index] = "ON"
+		view.dqStatus[req.Index] = "ON"
 	} else {
-		ui.dqStatus[req.Index] = "OFF"
+		view.dqStatus[req.Index] = "OFF"
 	}
-	ui.mu.Unlock()
+	view.mu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "设置成功"}`)
 }
 
 // UpdateConnectionStatus 更新连接状态
-func (ui *WebUI) UpdateConnectionStatus(status string) {
-	ui.mu.Lock()
-	ui.connectionStatus = status
-	ui.mu.Unlock()
+func (view *StationView) UpdateConnectionStatus(status string) {
+	view.mu.Lock()
+	view.connectionStatus = status
+	view.mu.Unlock()
 }
 
 // UpdateRunStatus 更新运行状态
-func (ui *WebUI) UpdateRunStatus(status string) {
-	ui.mu.Lock()
-	ui.runStatus = status
-	ui.mu.Unlock()
+func (view *StationView) UpdateRunStatus(status string) {
+	view.mu.Lock()
+	view.runStatus = status
+	view.mu.Unlock()
 }
 
 // UpdateSpeedLevel 更新速度挡位
-func (ui *WebUI) UpdateSpeedLevel(level int) {
-	ui.mu.Lock()
-	ui.speedLevel = level
-	ui.mu.Unlock()
+func (view *StationView) UpdateSpeedLevel(level int) {
+	view.mu.Lock()
+	view.speedLevel = level
+	view.mu.Unlock()
+}
+
+// UpdateSpeedMode 更新调速方式
+func (view *StationView) UpdateSpeedMode(mode SpeedMode) {
+	view.mu.Lock()
+	view.speedMode = mode
+	view.mu.Unlock()
 }
 
 // UpdateDelayValue 更新延时值
-func (ui *WebUI) UpdateDelayValue(delay int) {
-	ui.mu.Lock()
-	ui.delayValue = delay
-	ui.mu.Unlock()
+func (view *StationView) UpdateDelayValue(delay int) {
+	view.mu.Lock()
+	view.delayValue = delay
+	view.mu.Unlock()
 }
 
 // UpdateCurrentOutput 更新当前输出点
-func (ui *WebUI) UpdateCurrentOutput(address string) {
-	ui.mu.Lock()
-	ui.currentOutput = address
-	ui.mu.Unlock()
+func (view *StationView) UpdateCurrentOutput(address string) {
+	view.mu.Lock()
+	view.currentOutput = address
+	view.mu.Unlock()
+}
+
+// UpdatePattern 更新当前花样
+func (view *StationView) UpdatePattern(name string) {
+	view.mu.Lock()
+	view.pattern = name
+	view.mu.Unlock()
 }
 
 // UpdateDQStatus 更新数字输出状态
-func (ui *WebUI) UpdateDQStatus(index int, status string) {
-	if index >= 0 && index < 14 {
-		ui.mu.Lock()
-		ui.dqStatus[index] = status
-		ui.mu.Unlock()
+func (view *StationView) UpdateDQStatus(index int, status string) {
+	view.mu.Lock()
+	if index >= 0 && index < len(view.dqStatus) {
+		view.dqStatus[index] = status
 	}
+	view.mu.Unlock()
 }
 
 // UpdateDIStatus 更新数字输入状态
-func (ui *WebUI) UpdateDIStatus(index int, status string) {
-	if index >= 0 && index < 14 {
-		ui.mu.Lock()
-		ui.diStatus[index] = status
-		ui.mu.Unlock()
+func (view *StationView) UpdateDIStatus(index int, status string) {
+	view.mu.Lock()
+	if index >= 0 && index < len(view.diStatus) {
+		view.diStatus[index] = status
 	}
+	view.mu.Unlock()
 }
 
-// UpdateTemperature 更新温度
-func (ui *WebUI) UpdateTemperature(temp float64) {
-	ui.mu.Lock()
-	ui.temperature = temp
-	ui.mu.Unlock()
+// UpdateModbusError 更新最近一次通信错误，nil表示通信正常
+func (view *StationView) UpdateModbusError(err error) {
+	view.mu.Lock()
+	view.modbusError = err
+	view.mu.Unlock()
 }
 
-// UpdateHumidity 更新湿度
-func (ui *WebUI) UpdateHumidity(humidity float64) {
-	ui.mu.Lock()
-	ui.humidity = humidity
-	ui.mu.Unlock()
+// UpdateAnalog 更新模拟量通道读数
+func (view *StationView) UpdateAnalog(readings []AnalogReading) {
+	view.mu.Lock()
+	view.analog = readings
+	view.mu.Unlock()
 }
 
 // handleSaveConfig 处理保存配置请求
@@ -1211,6 +2604,10 @@ func (ui *WebUI) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
 
 	var req struct {
 		IP     string `json:"ip"`
@@ -1246,20 +2643,487 @@ func (ui *WebUI) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 更新配置
-	if ui.config != nil {
-		ui.config.IP = req.IP
-		ui.config.Port = port
-		ui.config.UnitID = unitID
+	// 更新配置，新的连接参数在下次连接时生效
+	if view.config != nil {
+		view.config.SetConnection(req.IP, port, unitID)
 
 		// 保存配置到文件
-		if err := ui.config.SaveConfig(); err != nil {
+		if err := view.config.SaveConfig(); err != nil {
 			w.Header().Set("Content-Type", "application/json")
-			fmt.Fprintf(w, `{"error": "保存配置失败: %s"}`, err.Error())
+			json.NewEncoder(w).Encode(struct {
+				Error string `json:"error"`
+			}{"保存配置失败: " + err.Error()})
 			return
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, `{"message": "配置保存成功"}`)
-}
\ No newline at end of file
+}
+
+// deviceObjectInfo 设备标识对象的JSON表示
+type deviceObjectInfo struct {
+	ID    byte   `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// handleDeviceInfo 处理读设备标识请求
+// 默认读取常规对象，设备仅支持基本对象时自动降级；level=extended时读取扩展对象
+func (ui *WebUI) handleDeviceInfo(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	code := DeviceIDRegular
+	switch r.URL.Query().Get("level") {
+	case "basic":
+		code = DeviceIDBasic
+	case "extended":
+		code = DeviceIDExtended
+	}
+
+	ident, err := view.modbusClient.ReadDeviceIdentificationCtx(r.Context(), code)
+	var exception *ModbusException
+	if code != DeviceIDBasic && errors.As(err, &exception) {
+		ident, err = view.modbusClient.ReadDeviceIdentificationCtx(r.Context(), DeviceIDBasic)
+	}
+	if err != nil {
+		writeModbusError(w, "读取设备标识失败: ", err)
+		return
+	}
+
+	objects := make([]deviceObjectInfo, 0, len(ident.Objects))
+	for _, id := range ident.ObjectIDs() {
+		objects = append(objects, deviceObjectInfo{ID: id, Name: DeviceObjectName(id), Value: ident.Objects[id]})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ConformityLevel byte               `json:"conformityLevel"`
+		VendorName      string             `json:"vendorName"`
+		ProductCode     string             `json:"productCode"`
+		Revision        string             `json:"revision"`
+		Objects         []deviceObjectInfo `json:"objects"`
+	}{
+		ConformityLevel: ident.ConformityLevel,
+		VendorName:      ident.VendorName(),
+		ProductCode:     ident.ProductCode(),
+		Revision:        ident.Revision(),
+		Objects:         objects,
+	})
+}
+
+// handleDiagnostics 处理诊断请求：回送测试并读取诊断计数器
+// 计数器子功能仅串行链路设备普遍支持，读取失败时单独返回错误而不影响回送结果
+func (ui *WebUI) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	if err := view.modbusClient.ReturnQueryDataCtx(r.Context(), []byte{0xA5, 0x37}); err != nil {
+		writeModbusError(w, "回送测试失败: ", err)
+		return
+	}
+
+	result := struct {
+		Echo          string              `json:"echo"`
+		Counters      *DiagnosticCounters `json:"counters,omitempty"`
+		CountersError string              `json:"countersError,omitempty"`
+	}{Echo: "正常"}
+
+	counters, err := view.modbusClient.ReadDiagnosticCountersCtx(r.Context())
+	if err != nil {
+		result.CountersError = err.Error()
+	} else {
+		result.Counters = counters
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleClearDiagnostics 处理清除诊断计数器请求
+func (ui *WebUI) handleClearDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	if err := view.modbusClient.ClearDiagnosticCountersCtx(r.Context()); err != nil {
+		writeModbusError(w, "清除计数器失败: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"message": "计数器已清除"}`)
+}
+
+// handleStations 处理站点总览请求，返回全部站点的连接与运行状态
+func (ui *WebUI) handleStations(w http.ResponseWriter, r *http.Request) {
+	type stationSummary struct {
+		Name             string `json:"name"`
+		ConnectionStatus string `json:"connectionStatus"`
+		ConnectionError  string `json:"connectionError,omitempty"`
+		RunStatus        string `json:"runStatus"`
+		SpeedLevel       int    `json:"speedLevel"`
+		CurrentOutput    string `json:"currentOutput"`
+	}
+
+	summaries := make([]stationSummary, 0, len(ui.stations))
+	for _, view := range ui.stations {
+		view.mu.RLock()
+		summary := stationSummary{
+			Name:             view.name,
+			ConnectionStatus: view.connectionStatus,
+			RunStatus:        view.runStatus,
+			SpeedLevel:       view.speedLevel,
+			CurrentOutput:    view.currentOutput,
+		}
+		if view.connectionError != nil {
+			summary.ConnectionError = view.connectionError.Error()
+		}
+		view.mu.RUnlock()
+		summaries = append(summaries, summary)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// handleTags 处理标签列表请求，已连接时附带各标签的当前工程值
+func (ui *WebUI) handleTags(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	type tagInfo struct {
+		*Tag
+		Value *float64 `json:"value,omitempty"`
+		Text  *string  `json:"text,omitempty"` // string标签的值
+		Error string   `json:"error,omitempty"`
+	}
+
+	tags := view.tags.DB().Tags()
+	infos := make([]tagInfo, 0, len(tags))
+	for _, tag := range tags {
+		info := tagInfo{Tag: tag}
+		if view.tags.IsConnected() && tag.DataType == TypeString {
+			if text, err := view.tags.ReadStringCtx(r.Context(), tag.Name); err != nil {
+				info.Error = err.Error()
+			} else {
+				info.Text = &text
+			}
+		} else if view.tags.IsConnected() {
+			if value, err := view.tags.ReadValueCtx(r.Context(), tag.Name); err != nil {
+				info.Error = err.Error()
+			} else {
+				info.Value = &value
+			}
+		}
+		infos = append(infos, info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// handleWriteTag 处理按标签名写入工程值请求
+func (ui *WebUI) handleWriteTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	var req struct {
+		Name  string  `json:"name"`
+		Value float64 `json:"value"`
+		Text  *string `json:"text"` // 指定时按字符串写入string标签
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if req.Text != nil {
+		err = view.tags.WriteStringCtx(r.Context(), req.Name, *req.Text)
+	} else {
+		err = view.tags.WriteValueCtx(r.Context(), req.Name, req.Value)
+	}
+	if err != nil {
+		writeModbusError(w, "写入标签失败: ", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"message": "写入成功"}`)
+}
+
+// handleAlarms 处理报警列表请求，返回非正常状态或搁置中的报警
+func (ui *WebUI) handleAlarms(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	alarms := view.alarms.Alarms()
+	result := struct {
+		Alarms  []AlarmStatus `json:"alarms"`
+		Active  int           `json:"active"`  // 越限中的报警数
+		Unacked int           `json:"unacked"` // 未确认的报警数
+	}{Alarms: alarms}
+	for _, alarm := range alarms {
+		if alarm.State == AlarmActiveUnacked || alarm.State == AlarmActiveAcked {
+			result.Active++
+		}
+		if alarm.State == AlarmActiveUnacked || alarm.State == AlarmClearedUnacked {
+			result.Unacked++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleAckAlarm 处理报警确认请求，id为空时确认全部报警
+func (ui *WebUI) handleAckAlarm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	count, err := view.alarms.Acknowledge(req.ID)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "确认报警失败: " + err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf("已确认 %d 条报警", count)})
+}
+
+// handleShelveAlarm 处理报警搁置请求，minutes不大于0时取消搁置
+func (ui *WebUI) handleShelveAlarm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	var req struct {
+		ID      string `json:"id"`
+		Minutes int    `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	err := view.alarms.Shelve(req.ID, time.Duration(req.Minutes)*time.Minute)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": "搁置报警失败: " + err.Error()})
+		return
+	}
+	message := fmt.Sprintf("已搁置 %d 分钟", req.Minutes)
+	if req.Minutes <= 0 {
+		message = "已取消搁置"
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// parseHistoryTime 解析历史查询时间：RFC3339、Unix毫秒或本地时间的 2006-01-02[ 15:04[:05]]，为空时返回默认值
+func parseHistoryTime(text string, defaultTime time.Time) (time.Time, error) {
+	if text == "" {
+		return defaultTime, nil
+	}
+	if ms, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.RFC3339, text)
+}
+
+// 历史查询按点数自动计算间隔时的默认点数与上限
+const (
+	defaultHistoryPoints = 300
+	maxHistoryPoints     = 5000
+)
+
+// handleHistory 处理历史数据查询请求
+// 参数：tag（必填）、from/to（RFC3339或Unix毫秒，默认最近1小时）、
+// aggregate（raw默认/avg/min/max/last/count）、step（如1m）或points（按点数计算间隔）
+func (ui *WebUI) handleHistory(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeError := func(message string) {
+		json.NewEncoder(w).Encode(map[string]string{"error": message})
+	}
+	if !view.history.Enabled() {
+		writeError("历史记录未启用")
+		return
+	}
+
+	query := r.URL.Query()
+	tag := query.Get("tag")
+	if tag == "" {
+		writeError("缺少参数tag")
+		return
+	}
+	now := time.Now()
+	to, err := parseHistoryTime(query.Get("to"), now)
+	if err != nil {
+		writeError("无效的结束时间: " + err.Error())
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-time.Hour))
+	if err != nil {
+		writeError("无效的开始时间: " + err.Error())
+		return
+	}
+	if !from.Before(to) {
+		writeError("开始时间必须早于结束时间")
+		return
+	}
+
+	aggregate := query.Get("aggregate")
+	var step time.Duration
+	if aggregate != "" && aggregate != AggregateRaw {
+		if text := query.Get("step"); text != "" {
+			if step, err = time.ParseDuration(text); err != nil || step <= 0 {
+				writeError("无效的间隔: " + text)
+				return
+			}
+			if step < time.Millisecond {
+				writeError("间隔不能小于1ms: " + text)
+				return
+			}
+		} else {
+			points := defaultHistoryPoints
+			if text := query.Get("points"); text != "" {
+				if points, err = strconv.Atoi(text); err != nil || points <= 0 || points > maxHistoryPoints {
+					writeError(fmt.Sprintf("点数应为1-%d", maxHistoryPoints))
+					return
+				}
+			}
+			step = max(to.Sub(from)/time.Duration(points), time.Second)
+		}
+	}
+
+	points, truncated, err := view.history.Query(tag, from, to, aggregate, step)
+	if err != nil {
+		writeError("查询历史数据失败: " + err.Error())
+		return
+	}
+
+	result := struct {
+		Tag       string         `json:"tag"`
+		Unit      string         `json:"unit,omitempty"`
+		From      time.Time      `json:"from"`
+		To        time.Time      `json:"to"`
+		Aggregate string         `json:"aggregate"`
+		StepMs    int64          `json:"stepMs,omitempty"`
+		Truncated bool           `json:"truncated,omitempty"`
+		Points    []HistoryPoint `json:"points"`
+	}{Tag: tag, From: from, To: to, Aggregate: aggregate, StepMs: step.Milliseconds(), Truncated: truncated, Points: points}
+	if result.Aggregate == "" {
+		result.Aggregate = AggregateRaw
+	}
+	if t, err := view.tags.DB().Lookup(tag); err == nil {
+		result.Unit = t.Unit
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleExport 处理历史数据导出请求，按时间顺序边读边写，不在内存中缓存全部数据
+// 参数：dataset（samples默认/events/runs）、format（csv默认/parquet）、from/to（同/history，默认最近24小时）、
+// tag（可重复）、delimiter（CSV分隔符）、tz（CSV时区）
+func (ui *WebUI) handleExport(w http.ResponseWriter, r *http.Request) {
+	view := ui.station(w, r)
+	if view == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	writeError := func(message string) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"error": message})
+	}
+	if !view.history.Enabled() {
+		writeError("历史记录未启用")
+		return
+	}
+
+	opts := ExportOptions{
+		Dataset: query.Get("dataset"),
+		Format:  query.Get("format"),
+		Tags:    query["tag"],
+		Units:   make(map[string]string),
+	}
+	var err error
+	if opts.To, err = parseHistoryTime(query.Get("to"), time.Now()); err != nil {
+		writeError("无效的结束时间: " + err.Error())
+		return
+	}
+	if opts.From, err = parseHistoryTime(query.Get("from"), opts.To.Add(-24*time.Hour)); err != nil {
+		writeError("无效的开始时间: " + err.Error())
+		return
+	}
+	if opts.Delimiter, err = ParseDelimiter(query.Get("delimiter")); err != nil {
+		writeError("无效的分隔符: " + err.Error())
+		return
+	}
+	if opts.Location, err = ParseTimezone(query.Get("tz")); err != nil {
+		writeError("无效的时区: " + err.Error())
+		return
+	}
+	for _, tag := range view.tags.DB().Tags() {
+		opts.Units[tag.Name] = tag.Unit
+	}
+	if err := opts.normalize(); err != nil {
+		writeError("导出参数无效: " + err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(opts.FileName(view.name))))
+	rows, err := ExportHistory(w, view.history, opts)
+	if err != nil {
+		// 响应已开始发送，只能记录错误
+		log.Printf("[%s] 导出历史数据失败（已写出%d行）: %v", view.name, rows, err)
+	}
+}